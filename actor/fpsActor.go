@@ -15,6 +15,7 @@ type FPSActor struct {
 	lookPitch, lookAngle    float32
 	forwardMove, strafeMove float32
 	walkDirection           mgl32.Vec3
+	jump                    bool
 }
 
 func NewFPSActor(entity renderer.Entity, character physicsAPI.CharacterController) *FPSActor {
@@ -28,6 +29,13 @@ func NewFPSActor(entity renderer.Entity, character physicsAPI.CharacterControlle
 }
 
 func (actor *FPSActor) Update(dt float64) {
+	if actor.jump {
+		actor.jump = false
+		if actor.Character.CanJump() {
+			actor.Character.Jump()
+		}
+	}
+
 	// orientation
 	vertRot := mgl32.QuatRotate(actor.lookAngle, mgl32.Vec3{0, 1, 0})
 	axis := vertRot.Rotate(mgl32.Vec3{1, 0, 0}).Cross(mgl32.Vec3{0, 1, 0})
@@ -86,10 +94,9 @@ func (actor *FPSActor) StopStrafingRight() {
 	actor.strafeMove = 0
 }
 
+// Jump - jump on the next update
 func (actor *FPSActor) Jump() {
-	if actor.Character.CanJump() {
-		actor.Character.Jump()
-	}
+	actor.jump = true
 }
 
 func (actor *FPSActor) StandUp() {
@@ -111,3 +118,38 @@ func (actor *FPSActor) StartSprinting() {
 func (actor *FPSActor) StopSprinting() {
 
 }
+
+// CurrentInput - the actor's current movement and look controls, including any requested jump
+func (actor *FPSActor) CurrentInput() InputCommand {
+	input := InputCommand{
+		Move: mgl32.Vec3{actor.forwardMove, 0, actor.strafeMove},
+		Look: mgl32.Vec2{actor.lookAngle, actor.lookPitch},
+	}
+	if actor.jump {
+		input.Buttons |= JumpButton
+		actor.jump = false
+	}
+	return input
+}
+
+// ApplyInput - set the movement and look controls from the input and update the character's walk direction
+func (actor *FPSActor) ApplyInput(input InputCommand) {
+	actor.forwardMove, actor.strafeMove = input.Move.X(), input.Move.Z()
+	actor.lookAngle, actor.lookPitch = input.Look.X(), input.Look.Y()
+	actor.jump = input.Buttons&JumpButton != 0
+	actor.Update(input.Dt)
+}
+
+func (actor *FPSActor) PredictionState() PredictionState {
+	return PredictionState{
+		Position: actor.Character.GetPosition(),
+		Velocity: actor.walkDirection,
+	}
+}
+
+func (actor *FPSActor) SetPredictionState(state PredictionState) {
+	actor.Character.Warp(state.Position)
+	actor.walkDirection = state.Velocity
+	actor.Character.SetWalkDirection(actor.walkDirection)
+	actor.Entity.SetTranslation(state.Position)
+}
@@ -0,0 +1,130 @@
+package actor
+
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+const (
+	defaultInterpolationDelay = 0.1
+	defaultMaxExtrapolation   = 0.25
+)
+
+type transformSample struct {
+	timestamp   float64
+	position    mgl32.Vec3
+	orientation mgl32.Quat
+}
+
+// InterpolatedActor - smoothly moves a remote entity between timestamped transforms received from the network.
+// The entity is rendered Delay seconds behind the most recent timestamp, so there is usually a transform either side
+// of the render time to interpolate between. If no newer transform arrives the entity is extrapolated
+// for up to MaxExtrapolation seconds using the last known velocity.
+type InterpolatedActor struct {
+	Entity           renderer.Entity
+	Delay            float64
+	MaxExtrapolation float64
+	BufferSize       int
+
+	// Now - optional clock (eg. a NetworkClock's server time in seconds) that timestamps are measured against.
+	// By default the clock starts at the first timestamp received and is advanced by Update.
+	Now func() float64
+
+	time    float64
+	started bool
+	samples []transformSample
+}
+
+func NewInterpolatedActor(entity renderer.Entity) *InterpolatedActor {
+	return &InterpolatedActor{
+		Entity:           entity,
+		Delay:            defaultInterpolationDelay,
+		MaxExtrapolation: defaultMaxExtrapolation,
+		BufferSize:       32,
+	}
+}
+
+// AddTransform - buffer a transform received for the given timestamp (seconds).
+// Transforms that arrive too late to be rendered are discarded.
+func (actor *InterpolatedActor) AddTransform(timestamp float64, position mgl32.Vec3, orientation mgl32.Quat) {
+	if !actor.started || (actor.Now == nil && timestamp-actor.time > actor.Delay+actor.MaxExtrapolation) {
+		// (re)synchronise the clock if it falls too far behind the sender
+		actor.time = timestamp
+		actor.started = true
+		actor.samples = actor.samples[:0]
+	}
+	if timestamp < actor.time-actor.Delay {
+		return
+	}
+
+	i := sort.Search(len(actor.samples), func(i int) bool { return actor.samples[i].timestamp >= timestamp })
+	if i < len(actor.samples) && actor.samples[i].timestamp == timestamp {
+		return
+	}
+	actor.samples = append(actor.samples, transformSample{})
+	copy(actor.samples[i+1:], actor.samples[i:])
+	actor.samples[i] = transformSample{timestamp: timestamp, position: position, orientation: orientation}
+
+	if len(actor.samples) > actor.BufferSize {
+		actor.samples = actor.samples[len(actor.samples)-actor.BufferSize:]
+	}
+}
+
+func (actor *InterpolatedActor) Update(dt float64) {
+	if !actor.started {
+		return
+	}
+	actor.time += dt
+	if actor.Now != nil {
+		actor.time = actor.Now()
+	}
+
+	position, orientation, ok := actor.Sample(actor.time - actor.Delay)
+	if ok {
+		actor.Entity.SetTranslation(position)
+		actor.Entity.SetOrientation(orientation)
+	}
+}
+
+// Sample - the interpolated transform at the given time
+func (actor *InterpolatedActor) Sample(time float64) (mgl32.Vec3, mgl32.Quat, bool) {
+	if len(actor.samples) == 0 {
+		return mgl32.Vec3{}, mgl32.QuatIdent(), false
+	}
+
+	// discard samples that are no longer needed for interpolation
+	for len(actor.samples) > 2 && actor.samples[1].timestamp <= time {
+		actor.samples = actor.samples[1:]
+	}
+
+	first, last := actor.samples[0], actor.samples[len(actor.samples)-1]
+	if time <= first.timestamp {
+		return first.position, first.orientation, true
+	}
+
+	if time >= last.timestamp {
+		if len(actor.samples) < 2 {
+			return last.position, last.orientation, true
+		}
+		previous := actor.samples[len(actor.samples)-2]
+		extrapolate := time - last.timestamp
+		if extrapolate > actor.MaxExtrapolation {
+			extrapolate = actor.MaxExtrapolation
+		}
+		velocity := last.position.Sub(previous.position).Mul(float32(1 / (last.timestamp - previous.timestamp)))
+		return last.position.Add(velocity.Mul(float32(extrapolate))), last.orientation, true
+	}
+
+	for i := 0; i < len(actor.samples)-1; i++ {
+		from, to := actor.samples[i], actor.samples[i+1]
+		if time >= from.timestamp && time <= to.timestamp {
+			amount := float32((time - from.timestamp) / (to.timestamp - from.timestamp))
+			position := from.position.Add(to.position.Sub(from.position).Mul(amount))
+			orientation := mgl32.QuatSlerp(from.orientation, to.orientation, amount)
+			return position, orientation, true
+		}
+	}
+	return last.position, last.orientation, true
+}
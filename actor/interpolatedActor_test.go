@@ -0,0 +1,77 @@
+package actor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+)
+
+func TestInterpolatedActor(t *testing.T) {
+	node := renderer.NewNode()
+	actor := NewInterpolatedActor(node)
+	actor.Delay = 0.1
+	rotated := mgl32.QuatRotate(math.Pi/2, mgl32.Vec3{0, 1, 0})
+
+	// the clock starts at the first timestamp received
+	actor.AddTransform(1.1, mgl32.Vec3{10, 0, 0}, rotated)
+	actor.AddTransform(1.0, mgl32.Vec3{0, 0, 0}, mgl32.QuatIdent())
+	actor.AddTransform(1.1, mgl32.Vec3{99, 0, 0}, rotated) // duplicates are ignored
+
+	actor.Update(0.05)
+	assert.InDelta(t, 5, node.Translation.X(), 0.001, "position should be interpolated Delay behind the clock")
+	expected := mgl32.QuatRotate(math.Pi/4, mgl32.Vec3{0, 1, 0})
+	assert.InDelta(t, 1, math.Abs(float64(node.Orientation.Dot(expected))), 0.001, "orientation should be slerped")
+
+	actor.Update(0.1)
+	assert.InDelta(t, 15, node.Translation.X(), 0.001, "position should be extrapolated when no newer transform is available")
+	assert.True(t, node.Orientation.ApproxEqual(rotated))
+
+	actor.Update(1)
+	assert.InDelta(t, 10+100*actor.MaxExtrapolation, node.Translation.X(), 0.001, "extrapolation should be limited")
+
+	actor.AddTransform(5.0, mgl32.Vec3{20, 0, 0}, rotated)
+	actor.AddTransform(5.1, mgl32.Vec3{30, 0, 0}, rotated)
+	actor.Update(0.15)
+	assert.InDelta(t, 25, node.Translation.X(), 0.001, "the clock should resynchronise after a long gap")
+}
+
+func TestInterpolatedActorLateTransforms(t *testing.T) {
+	node := renderer.NewNode()
+	actor := NewInterpolatedActor(node)
+	actor.Delay = 0.1
+
+	actor.AddTransform(1.0, mgl32.Vec3{0, 0, 0}, mgl32.QuatIdent())
+	actor.AddTransform(1.1, mgl32.Vec3{10, 0, 0}, mgl32.QuatIdent())
+	actor.AddTransform(1.2, mgl32.Vec3{20, 0, 0}, mgl32.QuatIdent())
+	actor.Update(0.15)
+	assert.InDelta(t, 5, node.Translation.X(), 0.001)
+
+	// a transform from long ago should not pull the clock backwards or clear the buffer
+	actor.AddTransform(0.2, mgl32.Vec3{-50, 0, 0}, mgl32.QuatIdent())
+	actor.Update(0.05)
+	assert.InDelta(t, 10, node.Translation.X(), 0.001, "late transforms should be discarded")
+
+	// a reordered transform that can still be rendered is kept
+	actor.AddTransform(1.15, mgl32.Vec3{11, 0, 0}, mgl32.QuatIdent())
+	actor.Update(0.05)
+	assert.InDelta(t, 11, node.Translation.X(), 0.001)
+}
+
+func TestInterpolatedActorClock(t *testing.T) {
+	node := renderer.NewNode()
+	actor := NewInterpolatedActor(node)
+	now := 5.0
+	actor.Now = func() float64 { return now }
+
+	actor.AddTransform(4.8, mgl32.Vec3{0, 0, 0}, mgl32.QuatIdent())
+	actor.AddTransform(5.0, mgl32.Vec3{0, 4, 0}, mgl32.QuatIdent())
+	actor.Update(0)
+	assert.InDelta(t, 2, node.Translation.Y(), 0.001)
+
+	now = 5.05
+	actor.Update(10)
+	assert.InDelta(t, 3, node.Translation.Y(), 0.001, "the clock function should be used instead of dt")
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type PhysicsActor2D struct {
-	Entity renderer.Entity
-	Object physicsAPI.PhysicsObject2D
-	Mask   mgl32.Vec3
+	Entity    renderer.Entity
+	Object    physicsAPI.PhysicsObject2D
+	Mask      mgl32.Vec3
+	MoveForce mgl32.Vec2 // force applied to the object by input commands when using prediction
 }
 
 func NewPhysicsActor2D(entity renderer.Entity, object physicsAPI.PhysicsObject2D, mask mgl32.Vec3) *PhysicsActor2D {
@@ -37,3 +38,33 @@ func (actor *PhysicsActor2D) Update(dt float64) {
 	actor.Entity.SetTranslation(position)
 	actor.Entity.SetOrientation(orientation)
 }
+
+// CurrentInput - the actor's MoveForce as an input command
+func (actor *PhysicsActor2D) CurrentInput() InputCommand {
+	return InputCommand{
+		Move: actor.MoveForce.Vec3(0),
+	}
+}
+
+// ApplyInput - set the force acting on the object from the input
+func (actor *PhysicsActor2D) ApplyInput(input InputCommand) {
+	actor.Object.SetForce(input.Move.Vec2())
+	actor.Update(input.Dt)
+}
+
+func (actor *PhysicsActor2D) PredictionState() PredictionState {
+	return PredictionState{
+		Position:        actor.Object.GetPosition().Vec3(0),
+		Velocity:        actor.Object.GetVelocity().Vec3(0),
+		Angle:           actor.Object.GetAngle(),
+		AngularVelocity: actor.Object.GetAngularVelocity(),
+	}
+}
+
+func (actor *PhysicsActor2D) SetPredictionState(state PredictionState) {
+	actor.Object.SetPosition(state.Position.Vec2())
+	actor.Object.SetVelocity(state.Velocity.Vec2())
+	actor.Object.SetAngle(state.Angle)
+	actor.Object.SetAngularVelocity(state.AngularVelocity)
+	actor.Update(0)
+}
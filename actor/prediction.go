@@ -0,0 +1,249 @@
+package actor
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	JumpButton uint32 = 1 << iota
+	FireButton
+	UseButton
+)
+
+const (
+	defaultPredictionStepTime = 1.0 / 60.0
+	maxInputsPerMessage       = 32
+	maxPendingInputs          = 1024
+)
+
+// InputCommand - one fixed step of player input, numbered so the server can acknowledge it
+type InputCommand struct {
+	Sequence uint32
+	Dt       float64
+	Move     mgl32.Vec3
+	Look     mgl32.Vec2
+	Buttons  uint32
+}
+
+// PredictionState - the server authoritative state of a predicted actor
+type PredictionState struct {
+	Position, Velocity     mgl32.Vec3
+	Angle, AngularVelocity float32
+}
+
+// Predictable - an actor that can be driven by input commands and rewound to an authoritative state
+type Predictable interface {
+	CurrentInput() InputCommand
+	ApplyInput(input InputCommand)
+	PredictionState() PredictionState
+	SetPredictionState(state PredictionState)
+}
+
+// Predictor - runs the controlled actor locally on the client, ahead of the server.
+// Every StepTime the actor's current input is applied locally and sent to the server along with
+// all other unacknowledged inputs. When the server state arrives, Reconcile rewinds the actor to it
+// and replays the inputs the server has not processed yet.
+type Predictor struct {
+	Actor     Predictable
+	StepTime  float64
+	Simulate  func(dt float64)
+	SendInput func(inputs []InputCommand)
+
+	sequence    uint32
+	accumulator float64
+	pending     []InputCommand
+}
+
+// NewPredictor - simulate should advance the physics simulation (if any) that moves the actor.
+// sendInput is typically a network event, eg.
+// network.TriggerEvent("input", "", EncodeInputCommands(inputs), networking.UnreliableSequenced)
+func NewPredictor(actor Predictable, simulate func(dt float64), sendInput func(inputs []InputCommand)) *Predictor {
+	return &Predictor{
+		Actor:     actor,
+		StepTime:  defaultPredictionStepTime,
+		Simulate:  simulate,
+		SendInput: sendInput,
+	}
+}
+
+func (p *Predictor) Update(dt float64) {
+	p.accumulator += dt
+	for p.accumulator >= p.StepTime {
+		p.accumulator -= p.StepTime
+		p.Step()
+	}
+}
+
+// Step - sample, apply and send a single input command
+func (p *Predictor) Step() InputCommand {
+	p.sequence++
+	input := p.Actor.CurrentInput()
+	input.Sequence = p.sequence
+	input.Dt = p.StepTime
+	if len(p.pending) >= maxPendingInputs {
+		p.pending = append(p.pending[:0], p.pending[len(p.pending)-maxPendingInputs+1:]...)
+	}
+	p.pending = append(p.pending, input)
+	p.apply(input)
+
+	if p.SendInput != nil {
+		inputs := p.pending
+		if len(inputs) > maxInputsPerMessage {
+			inputs = inputs[len(inputs)-maxInputsPerMessage:]
+		}
+		p.SendInput(inputs)
+	}
+	return input
+}
+
+// Reconcile - rewind to the server state, which includes all inputs up to and including sequence,
+// then replay the remaining inputs. Returns the difference between the replayed and previously predicted position.
+func (p *Predictor) Reconcile(sequence uint32, state PredictionState) mgl32.Vec3 {
+	i := 0
+	for i < len(p.pending) && p.pending[i].Sequence <= sequence {
+		i++
+	}
+	p.pending = p.pending[i:]
+
+	predicted := p.Actor.PredictionState().Position
+	p.Actor.SetPredictionState(state)
+	for _, input := range p.pending {
+		p.apply(input)
+	}
+	return p.Actor.PredictionState().Position.Sub(predicted)
+}
+
+// Pending - the number of inputs that have not been acknowledged by the server.
+// Only the most recent inputs are kept if the server stops responding.
+func (p *Predictor) Pending() int {
+	return len(p.pending)
+}
+
+func (p *Predictor) apply(input InputCommand) {
+	p.Actor.ApplyInput(input)
+	if p.Simulate != nil {
+		p.Simulate(input.Dt)
+	}
+}
+
+// InputAuthority - applies the input commands received from a client to the server's copy of the actor.
+// Every input is simulated for StepTime, regardless of the Dt the client sent with it.
+type InputAuthority struct {
+	Actor    Predictable
+	StepTime float64
+	Simulate func(dt float64)
+
+	received      []InputCommand
+	lastProcessed uint32
+}
+
+func NewInputAuthority(actor Predictable, simulate func(dt float64)) *InputAuthority {
+	return &InputAuthority{
+		Actor:    actor,
+		StepTime: defaultPredictionStepTime,
+		Simulate: simulate,
+	}
+}
+
+// Receive - buffer input commands, ignoring any that have already been received or processed
+func (a *InputAuthority) Receive(inputs ...InputCommand) {
+	for _, input := range inputs {
+		if input.Sequence <= a.lastProcessed {
+			continue
+		}
+		i := sort.Search(len(a.received), func(i int) bool { return a.received[i].Sequence >= input.Sequence })
+		if i < len(a.received) && a.received[i].Sequence == input.Sequence {
+			continue
+		}
+		if len(a.received) >= maxPendingInputs {
+			continue
+		}
+		a.received = append(a.received, InputCommand{})
+		copy(a.received[i+1:], a.received[i:])
+		a.received[i] = input
+	}
+}
+
+// Update - apply all buffered input commands in order
+func (a *InputAuthority) Update(dt float64) {
+	for _, input := range a.received {
+		input.Dt = a.StepTime
+		a.Actor.ApplyInput(input)
+		if a.Simulate != nil {
+			a.Simulate(input.Dt)
+		}
+		a.lastProcessed = input.Sequence
+	}
+	a.received = a.received[:0]
+}
+
+// State - the sequence of the last processed input and the resulting actor state
+func (a *InputAuthority) State() (uint32, PredictionState) {
+	return a.lastProcessed, a.Actor.PredictionState()
+}
+
+func EncodeInputCommands(inputs []InputCommand) []byte {
+	buf := new(bytes.Buffer)
+	util.UInt16Bytes(buf, uint16(len(inputs)))
+	for _, input := range inputs {
+		data, _ := util.SerializeArgs(input.Sequence, input.Dt, input.Move, input.Look, input.Buttons)
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func DecodeInputCommands(data []byte) ([]InputCommand, error) {
+	buf := bytes.NewBuffer(data)
+	count, err := util.UInt16frombytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]InputCommand, 0, count)
+	for i := 0; i < int(count); i++ {
+		var input InputCommand
+		if input.Sequence, err = util.UInt32frombytes(buf); err != nil {
+			return nil, err
+		}
+		if input.Dt, err = util.Float64frombytes(buf); err != nil {
+			return nil, err
+		}
+		if input.Move, err = util.Vector3frombytes(buf); err != nil {
+			return nil, err
+		}
+		if input.Look, err = util.Vector2frombytes(buf); err != nil {
+			return nil, err
+		}
+		if input.Buttons, err = util.UInt32frombytes(buf); err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+func EncodePredictionState(sequence uint32, state PredictionState) []byte {
+	data, _ := util.SerializeArgs(sequence, state.Position, state.Velocity, state.Angle, state.AngularVelocity)
+	return data
+}
+
+func DecodePredictionState(data []byte) (sequence uint32, state PredictionState, err error) {
+	buf := bytes.NewBuffer(data)
+	if sequence, err = util.UInt32frombytes(buf); err != nil {
+		return
+	}
+	if state.Position, err = util.Vector3frombytes(buf); err != nil {
+		return
+	}
+	if state.Velocity, err = util.Vector3frombytes(buf); err != nil {
+		return
+	}
+	if state.Angle, err = util.Float32frombytes(buf); err != nil {
+		return
+	}
+	state.AngularVelocity, err = util.Float32frombytes(buf)
+	return
+}
@@ -0,0 +1,197 @@
+package actor
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/engine"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// kinematicCharacter - a CharacterController that moves by its walk direction every simulation step
+type kinematicCharacter struct {
+	position, walkDirection mgl32.Vec3
+	wall                    float32
+	jumps                   int
+}
+
+func (c *kinematicCharacter) step(dt float64) {
+	c.position = c.position.Add(c.walkDirection)
+	if c.wall > 0 && c.position.X() > c.wall {
+		c.position[0] = c.wall
+	}
+}
+
+func (c *kinematicCharacter) Delete()                                                   {}
+func (c *kinematicCharacter) Warp(position mgl32.Vec3)                                  { c.position = position }
+func (c *kinematicCharacter) Jump()                                                     { c.jumps++ }
+func (c *kinematicCharacter) SetWalkDirection(dir mgl32.Vec3)                           { c.walkDirection = dir }
+func (c *kinematicCharacter) SetVelocityForTimeInterval(speed mgl32.Vec3, time float32) {}
+func (c *kinematicCharacter) SetUpAxis(axis int)                                        {}
+func (c *kinematicCharacter) SetFallSpeed(speed float32)                                {}
+func (c *kinematicCharacter) SetJumpSpeed(speed float32)                                {}
+func (c *kinematicCharacter) SetMaxJumpHeight(height float32)                           {}
+func (c *kinematicCharacter) SetGravity(gravity float32)                                {}
+func (c *kinematicCharacter) SetMaxSlope(radian float32)                                {}
+func (c *kinematicCharacter) GetPosition() mgl32.Vec3                                   { return c.position }
+func (c *kinematicCharacter) CanJump() bool                                             { return true }
+func (c *kinematicCharacter) GetGravity() float32                                       { return 0 }
+func (c *kinematicCharacter) GetMaxSlope() float32                                      { return 0 }
+func (c *kinematicCharacter) OnGround() bool                                            { return true }
+
+func newKinematicActor() (*FPSActor, *kinematicCharacter) {
+	character := &kinematicCharacter{}
+	return NewFPSActor(renderer.NewNode(), character), character
+}
+
+func TestPredictorReconcile(t *testing.T) {
+	clientActor, clientCharacter := newKinematicActor()
+	serverActor, serverCharacter := newKinematicActor()
+	var sent [][]InputCommand
+	predictor := NewPredictor(clientActor, clientCharacter.step, func(inputs []InputCommand) {
+		sent = append(sent, append([]InputCommand{}, inputs...))
+	})
+	authority := NewInputAuthority(serverActor, serverCharacter.step)
+
+	clientActor.StartMovingForward()
+	for i := 0; i < 10; i++ {
+		predictor.Step()
+	}
+	assert.InDelta(t, 3.0, clientCharacter.position.X(), 0.0001, "the client should move immediately")
+	assert.EqualValues(t, 10, predictor.Pending())
+
+	// the server only receives the first 4 inputs, but the server position is blocked by a wall
+	serverCharacter.wall = 1
+	authority.Receive(sent[3]...)
+	authority.Receive(sent[1]...)
+	authority.Update(0)
+	sequence, state := authority.State()
+	assert.EqualValues(t, 4, sequence)
+	assert.InDelta(t, 1.0, state.Position.X(), 0.0001)
+
+	data := EncodePredictionState(sequence, state)
+	sequence, state, err := DecodePredictionState(data)
+	assert.NoError(t, err)
+	correction := predictor.Reconcile(sequence, state)
+	assert.EqualValues(t, 6, predictor.Pending())
+	assert.InDelta(t, -0.2, correction.X(), 0.0001)
+	assert.InDelta(t, 2.8, clientCharacter.position.X(), 0.0001, "the client should replay unacknowledged inputs from the server state")
+
+	// duplicate and old inputs are ignored
+	inputs, err := DecodeInputCommands(EncodeInputCommands(sent[9]))
+	assert.NoError(t, err)
+	assert.EqualValues(t, sent[9], inputs)
+	authority.Receive(inputs...)
+	authority.Receive(inputs...)
+	authority.Update(0)
+	sequence, state = authority.State()
+	assert.EqualValues(t, 10, sequence)
+	assert.InDelta(t, 1.0, state.Position.X(), 0.0001)
+
+	predictor.Reconcile(sequence, state)
+	assert.EqualValues(t, 0, predictor.Pending())
+	assert.EqualValues(t, state.Position, clientCharacter.position)
+}
+
+func TestPredictionHeadless(t *testing.T) {
+	clientActor, clientCharacter := newKinematicActor()
+	serverActor, serverCharacter := newKinematicActor()
+	clientEngine, serverEngine := engine.NewHeadlessEngine(), engine.NewHeadlessEngine()
+
+	// messages are delivered one engine update after they are sent
+	var toServer, toClient [][]byte
+	predictor := NewPredictor(clientActor, clientCharacter.step, func(inputs []InputCommand) {
+		toServer = append(toServer, EncodeInputCommands(inputs))
+	})
+	authority := NewInputAuthority(serverActor, serverCharacter.step)
+	clientEngine.AddUpdatable(predictor)
+	serverEngine.AddUpdatable(authority)
+	serverEngine.AddUpdatable(engine.UpdatableFunc(func(dt float64) {
+		toClient = append(toClient, EncodePredictionState(authority.State()))
+	}))
+
+	deliver := func() {
+		for _, data := range toServer {
+			inputs, err := DecodeInputCommands(data)
+			assert.NoError(t, err)
+			authority.Receive(inputs...)
+		}
+		for _, data := range toClient {
+			sequence, state, err := DecodePredictionState(data)
+			assert.NoError(t, err)
+			predictor.Reconcile(sequence, state)
+		}
+		toServer, toClient = nil, nil
+	}
+
+	clientActor.StartMovingForward()
+	clientActor.StartStrafingLeft()
+	for i := 0; i < 20; i++ {
+		clientEngine.Update()
+		deliver()
+		serverEngine.Update()
+		deliver()
+	}
+	clientActor.StopMovingForward()
+	clientActor.StopStrafingLeft()
+	for i := 0; i < 5; i++ {
+		clientEngine.Update()
+		deliver()
+		serverEngine.Update()
+		deliver()
+	}
+
+	assert.True(t, clientCharacter.position.Len() > 0, "the actor should have moved")
+	assert.EqualValues(t, 0, predictor.Pending())
+	assert.EqualValues(t, serverCharacter.position, clientCharacter.position, "the client should agree with the server")
+}
+
+func TestPredictorJump(t *testing.T) {
+	clientActor, clientCharacter := newKinematicActor()
+	serverActor, serverCharacter := newKinematicActor()
+	var sent []InputCommand
+	predictor := NewPredictor(clientActor, clientCharacter.step, func(inputs []InputCommand) {
+		sent = inputs
+	})
+	authority := NewInputAuthority(serverActor, serverCharacter.step)
+
+	clientActor.Jump()
+	input := predictor.Step()
+	assert.EqualValues(t, JumpButton, input.Buttons&JumpButton, "a requested jump should be sent with the input")
+	assert.EqualValues(t, 1, clientCharacter.jumps)
+
+	input = predictor.Step()
+	assert.EqualValues(t, 0, input.Buttons&JumpButton, "the jump should only be sent once")
+	assert.EqualValues(t, 1, clientCharacter.jumps)
+
+	authority.Receive(sent...)
+	authority.Update(0)
+	assert.EqualValues(t, 1, serverCharacter.jumps, "the server should jump when the input says so")
+}
+
+func TestInputAuthorityStepTime(t *testing.T) {
+	serverActor, serverCharacter := newKinematicActor()
+	var simulated []float64
+	authority := NewInputAuthority(serverActor, func(dt float64) {
+		simulated = append(simulated, dt)
+		serverCharacter.step(dt)
+	})
+
+	authority.Receive(InputCommand{Sequence: 1, Dt: 10}, InputCommand{Sequence: 2, Dt: -1})
+	authority.Update(0)
+	assert.EqualValues(t, []float64{authority.StepTime, authority.StepTime}, simulated, "client supplied step times should be ignored")
+}
+
+func TestPredictorPendingLimit(t *testing.T) {
+	clientActor, clientCharacter := newKinematicActor()
+	predictor := NewPredictor(clientActor, clientCharacter.step, nil)
+
+	for i := 0; i < maxPendingInputs+10; i++ {
+		predictor.Step()
+	}
+	assert.EqualValues(t, maxPendingInputs, predictor.Pending(), "old inputs should be dropped when the server stops responding")
+
+	predictor.Reconcile(maxPendingInputs+10, PredictionState{})
+	assert.EqualValues(t, 0, predictor.Pending())
+}
@@ -0,0 +1,122 @@
+package animation
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func rotationY(degrees float32) mgl32.Quat {
+	return mgl32.QuatRotate(mgl32.DegToRad(degrees), mgl32.Vec3{0, 1, 0})
+}
+
+func assertVec3(t *testing.T, expected, actual mgl32.Vec3, msgAndArgs ...interface{}) {
+	assert.InDelta(t, 0, expected.Sub(actual).Len(), 1e-4, msgAndArgs...)
+}
+
+func assertRotation(t *testing.T, expected, actual mgl32.Quat, msgAndArgs ...interface{}) {
+	assert.InDelta(t, 1, mgl32.Abs(expected.Dot(actual)), 1e-4, msgAndArgs...)
+}
+
+// twoBoneSkeleton - a root bone with a child 1 unit along the x axis
+func twoBoneSkeleton() *Skeleton {
+	skeleton := NewSkeleton()
+	root := skeleton.AddBone("root", -1, IdentityTransform())
+	child := IdentityTransform()
+	child.Translation = mgl32.Vec3{1, 0, 0}
+	skeleton.AddBone("child", root, child)
+	return skeleton
+}
+
+func walkClip() *Clip {
+	return NewClip("walk", Channel{
+		Bone:         0,
+		Translations: []Vec3Key{{0, mgl32.Vec3{0, 0, 0}}, {1, mgl32.Vec3{2, 0, 0}}},
+		Rotations:    []QuatKey{{0, rotationY(0)}, {1, rotationY(90)}},
+	})
+}
+
+func TestClipSample(t *testing.T) {
+	skeleton := twoBoneSkeleton()
+	clip := walkClip()
+	assert.EqualValues(t, 1, clip.Duration)
+
+	pose := skeleton.RestPose()
+	clip.Sample(0.5, pose)
+	assertVec3(t, mgl32.Vec3{1, 0, 0}, pose[0].Translation)
+	assertRotation(t, rotationY(45), pose[0].Rotation)
+	assertVec3(t, mgl32.Vec3{1, 1, 1}, pose[0].Scale, "components without keys are unchanged")
+	assertVec3(t, mgl32.Vec3{1, 0, 0}, pose[1].Translation, "bones without channels are unchanged")
+
+	clip.Sample(-1, pose)
+	assertVec3(t, mgl32.Vec3{0, 0, 0}, pose[0].Translation)
+	clip.Sample(5, pose)
+	assertVec3(t, mgl32.Vec3{2, 0, 0}, pose[0].Translation)
+	assertRotation(t, rotationY(90), pose[0].Rotation)
+}
+
+func TestPoseBlend(t *testing.T) {
+	a := Pose{IdentityTransform()}
+	b := Pose{{Translation: mgl32.Vec3{4, 0, 0}, Rotation: rotationY(80), Scale: mgl32.Vec3{3, 3, 3}}}
+	a.Blend(b, 0.25)
+	assertVec3(t, mgl32.Vec3{1, 0, 0}, a[0].Translation)
+	assertRotation(t, rotationY(20), a[0].Rotation)
+	assertVec3(t, mgl32.Vec3{1.5, 1.5, 1.5}, a[0].Scale)
+
+	// rotations blend the short way around
+	c := Pose{{Rotation: rotationY(170), Scale: mgl32.Vec3{1, 1, 1}}}
+	d := Pose{{Rotation: rotationY(-170).Scale(-1), Scale: mgl32.Vec3{1, 1, 1}}}
+	c.Blend(d, 0.5)
+	assertRotation(t, rotationY(180), c[0].Rotation)
+}
+
+func TestSkinMatrices(t *testing.T) {
+	skeleton := twoBoneSkeleton()
+	for _, matrix := range skeleton.SkinMatrices(skeleton.RestPose(), nil) {
+		assert.True(t, matrix.ApproxEqualThreshold(mgl32.Ident4(), 1e-5), "the rest pose doesn't move the verticies")
+	}
+
+	pose := skeleton.RestPose()
+	pose[0].Rotation = rotationY(90)
+	world := skeleton.WorldMatrices(pose, nil)
+	assertVec3(t, mgl32.Vec3{0, 0, -1}, mgl32.TransformCoordinate(mgl32.Vec3{}, world[1]), "the child follows the root")
+
+	skin := skeleton.SkinMatrices(pose, world)
+	assertVec3(t, mgl32.Vec3{0, 0, -2}, mgl32.TransformCoordinate(mgl32.Vec3{2, 0, 0}, skin[1]))
+}
+
+func TestAnimatorCrossfade(t *testing.T) {
+	skeleton := twoBoneSkeleton()
+	walk := walkClip()
+	jump := NewClip("jump", Channel{
+		Bone:         0,
+		Translations: []Vec3Key{{0, mgl32.Vec3{0, 2, 0}}, {2, mgl32.Vec3{0, 2, 0}}},
+	})
+
+	animator := NewAnimator(skeleton)
+	state := animator.Play(walk, 0)
+	state.Loop = false
+	animator.Update(0.5)
+	assertVec3(t, mgl32.Vec3{1, 0, 0}, animator.Pose()[0].Translation)
+
+	animator.Play(jump, 1)
+	animator.Update(0.5)
+	assert.Len(t, animator.Clips(), 2)
+	assert.InDelta(t, 0.5, state.Weight, 1e-5)
+	assertVec3(t, mgl32.Vec3{1, 1, 0}, animator.Pose()[0].Translation, "halfway between the walk and the jump")
+	assert.True(t, state.Finished())
+
+	animator.Update(0.5)
+	assert.Len(t, animator.Clips(), 1, "the walk stops once it has faded out")
+	assertVec3(t, mgl32.Vec3{0, 2, 0}, animator.Pose()[0].Translation)
+	assertVec3(t, mgl32.Vec3{0, 2, 0}, mgl32.TransformCoordinate(mgl32.Vec3{}, animator.SkinMatrices()[0]))
+}
+
+func TestAnimatorLoop(t *testing.T) {
+	animator := NewAnimator(twoBoneSkeleton())
+	state := animator.Play(walkClip(), 0)
+	animator.Update(1.25)
+	assert.InDelta(t, 0.25, state.Time, 1e-5)
+	assertVec3(t, mgl32.Vec3{0.5, 0, 0}, animator.Pose()[0].Translation)
+}
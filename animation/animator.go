@@ -0,0 +1,213 @@
+package animation
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// ClipState - a clip playing on an Animator
+type ClipState struct {
+	Clip   *Clip
+	Time   float32
+	Speed  float32
+	Loop   bool
+	Weight float32
+
+	fadeTarget, fadeRate float32
+}
+
+// FadeTo - change the weight of the clip over the fade time (in seconds), the clip stops when it fades out to 0
+func (state *ClipState) FadeTo(weight, fadeTime float32) {
+	state.fadeTarget = weight
+	if fadeTime <= 0 {
+		state.Weight, state.fadeRate = weight, 0
+		return
+	}
+	state.fadeRate = float32(math.Abs(float64(weight-state.Weight))) / fadeTime
+}
+
+// Finished - true if the clip isn't looping and has played to the end
+func (state *ClipState) Finished() bool {
+	return !state.Loop && state.Time >= state.Clip.Duration
+}
+
+func (state *ClipState) update(dt float32) {
+	state.Time += dt * state.Speed
+	if duration := state.Clip.Duration; duration > 0 {
+		if state.Loop {
+			state.Time = float32(math.Mod(float64(state.Time), float64(duration)))
+			if state.Time < 0 {
+				state.Time += duration
+			}
+		} else {
+			state.Time = mgl32.Clamp(state.Time, 0, duration)
+		}
+	}
+
+	step := state.fadeRate * dt
+	switch {
+	case state.Weight < state.fadeTarget:
+		state.Weight = minF32(state.Weight+step, state.fadeTarget)
+	case state.Weight > state.fadeTarget:
+		state.Weight = maxF32(state.Weight-step, state.fadeTarget)
+	}
+}
+
+// Animator - an Updatable that plays clips on a skeleton, blending the clips by their weights.
+// After each update the skin matrices of the pose are set on the target geometry.
+type Animator struct {
+	Skeleton *Skeleton
+	Speed    float32
+
+	clips        []*ClipState
+	pose         Pose
+	samplePose   Pose
+	skinMatrices []mgl32.Mat4
+	targets      []*renderer.SkinnedGeometry
+}
+
+func NewAnimator(skeleton *Skeleton) *Animator {
+	animator := &Animator{
+		Skeleton: skeleton,
+		Speed:    1,
+	}
+	animator.updatePose()
+	return animator
+}
+
+// AddTarget - add geometry to be deformed by the animator
+func (animator *Animator) AddTarget(skinned *renderer.SkinnedGeometry) {
+	animator.targets = append(animator.targets, skinned)
+	skinned.SetBoneMatrices(animator.skinMatrices)
+}
+
+func (animator *Animator) RemoveTarget(skinned *renderer.SkinnedGeometry) {
+	for i, target := range animator.targets {
+		if target == skinned {
+			animator.targets = append(animator.targets[:i], animator.targets[i+1:]...)
+			break
+		}
+	}
+}
+
+// Play - crossfade to the clip over the fade time (in seconds), fading out every other clip
+func (animator *Animator) Play(clip *Clip, fadeTime float32) *ClipState {
+	for _, state := range animator.clips {
+		if state.Clip != clip {
+			state.FadeTo(0, fadeTime)
+		}
+	}
+	state := animator.Blend(clip, 0)
+	state.FadeTo(1, fadeTime)
+	return state
+}
+
+// Blend - play the clip alongside the other clips with the given weight.
+// If the clip is already playing its state is returned with the weight unchanged.
+func (animator *Animator) Blend(clip *Clip, weight float32) *ClipState {
+	if state := animator.ClipState(clip); state != nil {
+		return state
+	}
+	state := &ClipState{
+		Clip:       clip,
+		Speed:      1,
+		Loop:       true,
+		Weight:     weight,
+		fadeTarget: weight,
+	}
+	animator.clips = append(animator.clips, state)
+	return state
+}
+
+// Stop - fade out the clip over the fade time (in seconds)
+func (animator *Animator) Stop(clip *Clip, fadeTime float32) {
+	if state := animator.ClipState(clip); state != nil {
+		state.FadeTo(0, fadeTime)
+	}
+	animator.removeStopped()
+}
+
+// ClipState - the state of the playing clip, nil if the clip isn't playing
+func (animator *Animator) ClipState(clip *Clip) *ClipState {
+	for _, state := range animator.clips {
+		if state.Clip == clip {
+			return state
+		}
+	}
+	return nil
+}
+
+// Clips - the playing clips
+func (animator *Animator) Clips() []*ClipState {
+	return animator.clips
+}
+
+// Pose - the local bone transforms of the last update
+func (animator *Animator) Pose() Pose {
+	return animator.pose
+}
+
+// SkinMatrices - the skin matrices of the last update
+func (animator *Animator) SkinMatrices() []mgl32.Mat4 {
+	return animator.skinMatrices
+}
+
+func (animator *Animator) Update(dt float64) {
+	for _, state := range animator.clips {
+		state.update(float32(dt) * animator.Speed)
+	}
+	animator.removeStopped()
+	animator.updatePose()
+}
+
+func (animator *Animator) removeStopped() {
+	clips := animator.clips[:0]
+	for _, state := range animator.clips {
+		if state.Weight > 0 || state.fadeTarget > 0 {
+			clips = append(clips, state)
+		}
+	}
+	for i := len(clips); i < len(animator.clips); i++ {
+		animator.clips[i] = nil
+	}
+	animator.clips = clips
+}
+
+// updatePose - sample the clips, blending each into the pose by its share of the total weight
+func (animator *Animator) updatePose() {
+	skeleton := animator.Skeleton
+	if len(animator.pose) != len(skeleton.Bones) {
+		animator.pose = skeleton.RestPose()
+		animator.samplePose = skeleton.RestPose()
+	}
+	for i, bone := range skeleton.Bones {
+		animator.pose[i] = bone.Rest
+	}
+
+	var totalWeight float32
+	for _, state := range animator.clips {
+		if state.Weight <= 0 {
+			continue
+		}
+		for i, bone := range skeleton.Bones {
+			animator.samplePose[i] = bone.Rest
+		}
+		state.Clip.Sample(state.Time, animator.samplePose)
+		totalWeight += state.Weight
+		animator.pose.Blend(animator.samplePose, state.Weight/totalWeight)
+	}
+
+	animator.skinMatrices = skeleton.SkinMatrices(animator.pose, animator.skinMatrices)
+	for _, target := range animator.targets {
+		target.SetBoneMatrices(animator.skinMatrices)
+	}
+}
+
+func minF32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
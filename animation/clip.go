@@ -0,0 +1,112 @@
+package animation
+
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Vec3Key struct {
+	Time  float32
+	Value mgl32.Vec3
+}
+
+type QuatKey struct {
+	Time  float32
+	Value mgl32.Quat
+}
+
+// Channel - the keyframes animating one bone, sorted by time.
+// A component without keys is left unchanged when the channel is sampled.
+type Channel struct {
+	Bone         int
+	Translations []Vec3Key
+	Rotations    []QuatKey
+	Scales       []Vec3Key
+}
+
+// Clip - a keyframed animation of the bones of a skeleton
+type Clip struct {
+	Name     string
+	Duration float32
+	Channels []Channel
+}
+
+// NewClip - create a clip lasting until its last keyframe
+func NewClip(name string, channels ...Channel) *Clip {
+	clip := &Clip{Name: name, Channels: channels}
+	for _, channel := range channels {
+		if n := len(channel.Translations); n > 0 {
+			clip.Duration = maxF32(clip.Duration, channel.Translations[n-1].Time)
+		}
+		if n := len(channel.Rotations); n > 0 {
+			clip.Duration = maxF32(clip.Duration, channel.Rotations[n-1].Time)
+		}
+		if n := len(channel.Scales); n > 0 {
+			clip.Duration = maxF32(clip.Duration, channel.Scales[n-1].Time)
+		}
+	}
+	return clip
+}
+
+// Sample - set the bones animated by the clip to their transforms at the time, times outside the keyframes hold the first or last key
+func (clip *Clip) Sample(time float32, pose Pose) {
+	for _, channel := range clip.Channels {
+		if channel.Bone < 0 || channel.Bone >= len(pose) {
+			continue
+		}
+		transform := &pose[channel.Bone]
+		if len(channel.Translations) > 0 {
+			transform.Translation = sampleVec3(channel.Translations, time)
+		}
+		if len(channel.Rotations) > 0 {
+			transform.Rotation = sampleQuat(channel.Rotations, time)
+		}
+		if len(channel.Scales) > 0 {
+			transform.Scale = sampleVec3(channel.Scales, time)
+		}
+	}
+}
+
+// keyframe - the index of the key after the time and the fraction of the way to it from the previous key
+func keyframe(count int, keyTime func(i int) float32, time float32) (int, float32) {
+	next := sort.Search(count, func(i int) bool { return keyTime(i) > time })
+	if next == 0 || next == count {
+		return next, 0
+	}
+	previous := keyTime(next - 1)
+	span := keyTime(next) - previous
+	if span <= 0 {
+		return next, 1
+	}
+	return next, (time - previous) / span
+}
+
+func sampleVec3(keys []Vec3Key, time float32) mgl32.Vec3 {
+	next, amount := keyframe(len(keys), func(i int) float32 { return keys[i].Time }, time)
+	switch next {
+	case 0:
+		return keys[0].Value
+	case len(keys):
+		return keys[len(keys)-1].Value
+	}
+	return lerpVec3(keys[next-1].Value, keys[next].Value, amount)
+}
+
+func sampleQuat(keys []QuatKey, time float32) mgl32.Quat {
+	next, amount := keyframe(len(keys), func(i int) float32 { return keys[i].Time }, time)
+	switch next {
+	case 0:
+		return keys[0].Value
+	case len(keys):
+		return keys[len(keys)-1].Value
+	}
+	return slerp(keys[next-1].Value, keys[next].Value, amount)
+}
+
+func maxF32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
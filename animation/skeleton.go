@@ -0,0 +1,144 @@
+package animation
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Transform - the translation, rotation and scale of a bone relative to its parent
+type Transform struct {
+	Translation mgl32.Vec3
+	Rotation    mgl32.Quat
+	Scale       mgl32.Vec3
+}
+
+func IdentityTransform() Transform {
+	return Transform{
+		Rotation: mgl32.QuatIdent(),
+		Scale:    mgl32.Vec3{1, 1, 1},
+	}
+}
+
+func (t Transform) Mat4() mgl32.Mat4 {
+	return mgl32.Translate3D(t.Translation.X(), t.Translation.Y(), t.Translation.Z()).
+		Mul4(t.Rotation.Mat4()).
+		Mul4(mgl32.Scale3D(t.Scale.X(), t.Scale.Y(), t.Scale.Z()))
+}
+
+// Lerp - interpolate towards the other transform, rotations take the shortest path
+func (t Transform) Lerp(other Transform, amount float32) Transform {
+	return Transform{
+		Translation: lerpVec3(t.Translation, other.Translation, amount),
+		Rotation:    slerp(t.Rotation, other.Rotation, amount),
+		Scale:       lerpVec3(t.Scale, other.Scale, amount),
+	}
+}
+
+func lerpVec3(a, b mgl32.Vec3, amount float32) mgl32.Vec3 {
+	return a.Add(b.Sub(a).Mul(amount))
+}
+
+func slerp(a, b mgl32.Quat, amount float32) mgl32.Quat {
+	if a.Dot(b) < 0 {
+		b = b.Scale(-1)
+	}
+	return mgl32.QuatSlerp(a, b, amount)
+}
+
+// Bone - a joint of a skeleton.
+// Parent is the index of the parent bone, or -1 for a root bone; parents always come before their children.
+// Rest is the bone's transform in the bind pose, and InverseBind moves model space verticies into the bone's space in the bind pose.
+type Bone struct {
+	Name        string
+	Parent      int
+	Rest        Transform
+	InverseBind mgl32.Mat4
+}
+
+// Skeleton - a hierarchy of bones
+type Skeleton struct {
+	Bones []Bone
+}
+
+func NewSkeleton() *Skeleton {
+	return &Skeleton{}
+}
+
+// AddBone - add a bone with an inverse bind matrix matching the rest transforms, returns the index of the bone
+func (skeleton *Skeleton) AddBone(name string, parent int, rest Transform) int {
+	inverseBind := rest.Mat4()
+	if parent >= 0 {
+		inverseBind = skeleton.Bones[parent].InverseBind.Inv().Mul4(inverseBind)
+	}
+	skeleton.Bones = append(skeleton.Bones, Bone{
+		Name:        name,
+		Parent:      parent,
+		Rest:        rest,
+		InverseBind: inverseBind.Inv(),
+	})
+	return len(skeleton.Bones) - 1
+}
+
+// BoneIndex - the index of the named bone, -1 if there is no bone with that name
+func (skeleton *Skeleton) BoneIndex(name string) int {
+	for i, bone := range skeleton.Bones {
+		if bone.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// RestPose - a new pose with every bone at its rest transform
+func (skeleton *Skeleton) RestPose() Pose {
+	pose := make(Pose, len(skeleton.Bones))
+	for i, bone := range skeleton.Bones {
+		pose[i] = bone.Rest
+	}
+	return pose
+}
+
+// WorldMatrices - the model space transform of every bone in the pose, reusing the matrices slice if it is large enough
+func (skeleton *Skeleton) WorldMatrices(pose Pose, matrices []mgl32.Mat4) []mgl32.Mat4 {
+	matrices = resizeMatrices(matrices, len(skeleton.Bones))
+	for i, bone := range skeleton.Bones {
+		local := pose[i].Mat4()
+		if bone.Parent >= 0 {
+			matrices[i] = matrices[bone.Parent].Mul4(local)
+		} else {
+			matrices[i] = local
+		}
+	}
+	return matrices
+}
+
+// SkinMatrices - the matrices moving bind pose verticies to the pose, as used by renderer.SkinnedGeometry
+func (skeleton *Skeleton) SkinMatrices(pose Pose, matrices []mgl32.Mat4) []mgl32.Mat4 {
+	matrices = skeleton.WorldMatrices(pose, matrices)
+	for i, bone := range skeleton.Bones {
+		matrices[i] = matrices[i].Mul4(bone.InverseBind)
+	}
+	return matrices
+}
+
+func resizeMatrices(matrices []mgl32.Mat4, size int) []mgl32.Mat4 {
+	if cap(matrices) < size {
+		return make([]mgl32.Mat4, size)
+	}
+	return matrices[:size]
+}
+
+// Pose - the local transform of every bone in a skeleton
+type Pose []Transform
+
+// Blend - interpolate each bone towards the other pose
+func (pose Pose) Blend(other Pose, weight float32) {
+	for i := range pose {
+		pose[i] = pose[i].Lerp(other[i], weight)
+	}
+}
+
+func (pose Pose) Copy() Pose {
+	result := make(Pose, len(pose))
+	copy(result, pose)
+	return result
+}
@@ -8,6 +8,7 @@ import (
 )
 
 type AssetCache struct {
+	fsys        FS
 	geometries  map[string]*renderer.Geometry
 	materials   map[string]*renderer.Material
 	images      map[string]image.Image
@@ -22,12 +23,25 @@ func init() {
 }
 
 func (ac *AssetCache) ImportObj(path string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return ac.importObj(path, func(path string) (*renderer.Geometry, *renderer.Material, error) {
+		return ImportObjFS(ac.fsys, path)
+	})
+}
+
+// ImportObjMeshCache - import an obj through the .gemesh cache in cacheDir, the mesh textures are loaded through the image cache
+func (ac *AssetCache) ImportObjMeshCache(path, cacheDir string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return ac.importObj(path, func(path string) (*renderer.Geometry, *renderer.Material, error) {
+		return importObjMeshCache(ac.fsys, path, cacheDir, ac.ImportImage)
+	})
+}
+
+func (ac *AssetCache) importObj(path string, importer func(path string) (*renderer.Geometry, *renderer.Material, error)) (geometry *renderer.Geometry, material *renderer.Material, err error) {
 	ac.lockFilepath(path)
 	var okGeom, okMat bool
 	geometry, okGeom = ac.geometries[path]
 	material, okMat = ac.materials[path]
 	if !okGeom && !okMat {
-		geometry, material, err = ImportObj(path)
+		geometry, material, err = importer(path)
 		ac.mutex.Lock()
 		ac.geometries[path] = geometry
 		ac.materials[path] = material
@@ -42,7 +56,7 @@ func (ac *AssetCache) ImportImage(path string) (img image.Image, err error) {
 	var ok bool
 	img, ok = ac.images[path]
 	if !ok {
-		img, err = ImportImage(path)
+		img, err = ImportImageFS(ac.fsys, path)
 		ac.mutex.Lock()
 		ac.images[path] = img
 		ac.mutex.Unlock()
@@ -76,8 +90,18 @@ func ImportObjCached(path string) (geometry *renderer.Geometry, material *render
 	return globalCache.ImportObj(path)
 }
 
+func ImportObjMeshCacheCached(path, cacheDir string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return globalCache.ImportObjMeshCache(path, cacheDir)
+}
+
 func NewAssetCache() *AssetCache {
+	return NewAssetCacheFS(OSFS)
+}
+
+// NewAssetCacheFS - a cache of the assets imported from the file system
+func NewAssetCacheFS(fsys FS) *AssetCache {
 	return &AssetCache{
+		fsys:        fsys,
 		geometries:  make(map[string]*renderer.Geometry),
 		materials:   make(map[string]*renderer.Material),
 		images:      make(map[string]image.Image),
@@ -0,0 +1,182 @@
+package assets
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FS - a read only file system that assets are loaded from, paths are slash separated
+type FS interface {
+	fs.FS
+}
+
+// OSFS - the operating system file system.
+// Unlike a DirFS, paths are passed to the os as they are, so they can be absolute or relative to the working directory.
+var OSFS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+// DirFS - the files in a directory
+func DirFS(dir string) FS {
+	return os.DirFS(dir)
+}
+
+// Pack - an asset pack, a zip archive of asset files
+type Pack struct {
+	*zip.Reader
+	closer io.Closer
+}
+
+// OpenPack - open an asset pack file
+func OpenPack(path string) (*Pack, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Pack{Reader: &reader.Reader, closer: reader}, nil
+}
+
+// NewPack - read an asset pack from memory or an embedded file
+func NewPack(r io.ReaderAt, size int64) (*Pack, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Pack{Reader: reader}, nil
+}
+
+func (pack *Pack) Close() error {
+	if pack.closer != nil {
+		return pack.closer.Close()
+	}
+	return nil
+}
+
+// WritePack - write every file in fsys to w as an asset pack
+func WritePack(w io.Writer, fsys FS) error {
+	zipWriter := zip.NewWriter(w)
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = path
+		header.Method = zip.Deflate
+		fileWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(fileWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// OverlayFS - layers of file systems, files in earlier layers replace the files in later layers.
+// eg. NewOverlayFS(DirFS("mods"), basePack) loads mod files over the base pack.
+type OverlayFS []FS
+
+func NewOverlayFS(layers ...FS) OverlayFS {
+	return OverlayFS(layers)
+}
+
+// Open - open the file from the first layer that has it, directories list the entries of every layer
+func (overlay OverlayFS) Open(name string) (fs.File, error) {
+	for _, layer := range overlay {
+		file, err := layer.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if info, err := file.Stat(); err != nil || !info.IsDir() {
+			return file, nil
+		}
+		entries, err := overlay.ReadDir(name)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &overlayDir{File: file, entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir - the entries of the directory in every layer, sorted by name
+func (overlay OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+	found := false
+	for i := len(overlay) - 1; i >= 0; i-- {
+		layerEntries, err := fs.ReadDir(overlay[i], name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, entry := range layerEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	result := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
+
+// overlayDir - a directory of the first layer, listing the entries of every layer
+type overlayDir struct {
+	fs.File
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (dir *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := dir.entries[dir.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+	}
+	dir.offset += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPack(t *testing.T) {
+	src := fstest.MapFS{
+		"models/test.obj": {Data: []byte(testObj)},
+		"models/test.mtl": {Data: []byte(testMtl)},
+		"maps/empty.json": {Data: []byte(`{"name": "empty", "root": {"id": "root"}}`)},
+	}
+	var buf bytes.Buffer
+	if !assert.NoError(t, WritePack(&buf, src)) {
+		return
+	}
+	pack, err := NewPack(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pack.Close()
+	assert.NoError(t, fstest.TestFS(pack, "models/test.obj", "models/test.mtl", "maps/empty.json"))
+
+	geometry, material, err := ImportObjFS(pack, "models/test.obj")
+	if assert.NoError(t, err) {
+		assert.Len(t, geometry.Indicies, 12)
+		assert.Len(t, material.Textures, 3, "the mtl is loaded from the pack")
+	}
+	if mapModel := LoadMapFS(pack, "maps/empty.json"); assert.NotNil(t, mapModel) {
+		assert.Equal(t, "empty", mapModel.Name)
+	}
+}
+
+func TestOverlayFS(t *testing.T) {
+	base := fstest.MapFS{
+		"a.txt":     {Data: []byte("base a")},
+		"dir/b.txt": {Data: []byte("base b")},
+	}
+	mod := fstest.MapFS{
+		"a.txt":     {Data: []byte("mod a")},
+		"dir/c.txt": {Data: []byte("mod c")},
+	}
+	overlay := NewOverlayFS(mod, base)
+
+	data, err := fs.ReadFile(overlay, "a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "mod a", string(data), "earlier layers replace later ones")
+	data, err = fs.ReadFile(overlay, "dir/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "base b", string(data))
+	_, err = overlay.Open("missing.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	entries, err := fs.ReadDir(overlay, "dir")
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.Equal(t, "b.txt", entries[0].Name())
+		assert.Equal(t, "c.txt", entries[1].Name())
+	}
+	assert.NoError(t, fstest.TestFS(overlay, "a.txt", "dir/b.txt", "dir/c.txt"))
+}
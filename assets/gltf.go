@@ -0,0 +1,429 @@
+package assets
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+)
+
+// the subset of the glTF 2.0 json schema used by the importer
+type gltfDocument struct {
+	Scene       *int              `json:"scene"`
+	Scenes      []gltfScene       `json:"scenes"`
+	Nodes       []gltfNode        `json:"nodes"`
+	Meshes      []gltfMesh        `json:"meshes"`
+	Materials   []gltfMaterial    `json:"materials"`
+	Textures    []gltfTexture     `json:"textures"`
+	Images      []gltfImage       `json:"images"`
+	Accessors   []gltfAccessor    `json:"accessors"`
+	BufferViews []gltfBufferView  `json:"bufferViews"`
+	Buffers     []gltfBuffer      `json:"buffers"`
+	Cameras     []gltfCamera      `json:"cameras"`
+	Skins       []gltfSkin        `json:"skins"`
+	Animations  []gltfAnimation   `json:"animations"`
+	Extensions  gltfDocExtensions `json:"extensions"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string             `json:"name"`
+	Children    []int              `json:"children"`
+	Mesh        *int               `json:"mesh"`
+	Camera      *int               `json:"camera"`
+	Skin        *int               `json:"skin"`
+	Matrix      []float32          `json:"matrix"`
+	Translation []float32          `json:"translation"`
+	Rotation    []float32          `json:"rotation"`
+	Scale       []float32          `json:"scale"`
+	Extensions  gltfNodeExtensions `json:"extensions"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfTextureInfo struct {
+	Index    int     `json:"index"`
+	TexCoord int     `json:"texCoord"`
+	Scale    float32 `json:"scale"`
+	Strength float32 `json:"strength"`
+}
+
+type gltfMaterial struct {
+	Name                 string `json:"name"`
+	PbrMetallicRoughness struct {
+		BaseColorFactor          []float32        `json:"baseColorFactor"`
+		BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture"`
+		MetallicFactor           *float32         `json:"metallicFactor"`
+		RoughnessFactor          *float32         `json:"roughnessFactor"`
+		MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture    *gltfTextureInfo `json:"normalTexture"`
+	OcclusionTexture *gltfTextureInfo `json:"occlusionTexture"`
+	EmissiveTexture  *gltfTextureInfo `json:"emissiveTexture"`
+	EmissiveFactor   []float32        `json:"emissiveFactor"`
+	AlphaMode        string           `json:"alphaMode"`
+	DoubleSided      bool             `json:"doubleSided"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri"`
+	BufferView *int   `json:"bufferView"`
+	MimeType   string `json:"mimeType"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int      `json:"bufferView"`
+	ByteOffset    int       `json:"byteOffset"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min"`
+	Max           []float32 `json:"max"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfCamera struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Perspective *struct {
+		AspectRatio float32 `json:"aspectRatio"`
+		Yfov        float32 `json:"yfov"`
+		Znear       float32 `json:"znear"`
+		Zfar        float32 `json:"zfar"`
+	} `json:"perspective"`
+	Orthographic *struct {
+		Xmag  float32 `json:"xmag"`
+		Ymag  float32 `json:"ymag"`
+		Znear float32 `json:"znear"`
+		Zfar  float32 `json:"zfar"`
+	} `json:"orthographic"`
+}
+
+type gltfSkin struct {
+	Name                string `json:"name"`
+	InverseBindMatrices *int   `json:"inverseBindMatrices"`
+	Skeleton            *int   `json:"skeleton"`
+	Joints              []int  `json:"joints"`
+}
+
+type gltfAnimation struct {
+	Name     string `json:"name"`
+	Channels []struct {
+		Sampler int `json:"sampler"`
+		Target  struct {
+			Node *int   `json:"node"`
+			Path string `json:"path"`
+		} `json:"target"`
+	} `json:"channels"`
+	Samplers []struct {
+		Input         int    `json:"input"`
+		Output        int    `json:"output"`
+		Interpolation string `json:"interpolation"`
+	} `json:"samplers"`
+}
+
+// KHR_lights_punctual
+type gltfLight struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Color     []float32 `json:"color"`
+	Intensity *float32  `json:"intensity"`
+	Range     float32   `json:"range"`
+	Spot      *struct {
+		InnerConeAngle float32  `json:"innerConeAngle"`
+		OuterConeAngle *float32 `json:"outerConeAngle"`
+	} `json:"spot"`
+}
+
+type gltfDocExtensions struct {
+	LightsPunctual struct {
+		Lights []gltfLight `json:"lights"`
+	} `json:"KHR_lights_punctual"`
+}
+
+type gltfNodeExtensions struct {
+	LightsPunctual *struct {
+		Light int `json:"light"`
+	} `json:"KHR_lights_punctual"`
+}
+
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+// gltfFile - a parsed glTF document with its buffers loaded
+type gltfFile struct {
+	gltfDocument
+	buffers [][]byte
+	images  [][]byte
+}
+
+// parseGLTF - parse a .gltf json document or a .glb binary, reading external buffers and images with readFile
+func parseGLTF(data []byte, readFile func(uri string) ([]byte, error)) (*gltfFile, error) {
+	file := &gltfFile{}
+	var binChunk []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == glbMagic {
+		jsonChunk, bin, err := parseGLB(data)
+		if err != nil {
+			return nil, err
+		}
+		data, binChunk = jsonChunk, bin
+	}
+	if err := json.Unmarshal(data, &file.gltfDocument); err != nil {
+		return nil, fmt.Errorf("Error parsing gltf json: %v", err)
+	}
+
+	for i, buffer := range file.Buffers {
+		var bufferData []byte
+		var err error
+		if buffer.URI == "" {
+			if binChunk == nil {
+				return nil, fmt.Errorf("gltf buffer %v has no uri", i)
+			}
+			bufferData = binChunk
+		} else if bufferData, err = readURI(buffer.URI, readFile); err != nil {
+			return nil, err
+		}
+		if len(bufferData) < buffer.ByteLength {
+			return nil, fmt.Errorf("gltf buffer %v is too short: %v < %v", i, len(bufferData), buffer.ByteLength)
+		}
+		file.buffers = append(file.buffers, bufferData)
+	}
+
+	for i, image := range file.Images {
+		var imageData []byte
+		var err error
+		if image.BufferView != nil {
+			imageData, _, err = file.bufferView(*image.BufferView)
+		} else {
+			imageData, err = readURI(image.URI, readFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error loading gltf image %v: %v", i, err)
+		}
+		file.images = append(file.images, imageData)
+	}
+	return file, nil
+}
+
+func parseGLB(data []byte) (jsonChunk, binChunk []byte, err error) {
+	if version := binary.LittleEndian.Uint32(data[4:]); version != 2 {
+		return nil, nil, fmt.Errorf("Unsupported glb version: %v", version)
+	}
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, fmt.Errorf("glb is too short: %v < %v", len(data), length)
+	}
+	for offset := 12; offset+8 <= length; {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		start, end := offset+8, offset+8+chunkLength
+		if end > length {
+			return nil, nil, fmt.Errorf("glb chunk is too long")
+		}
+		switch chunkType {
+		case glbChunkJSON:
+			jsonChunk = data[start:end]
+		case glbChunkBIN:
+			binChunk = data[start:end]
+		}
+		offset = end
+	}
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("glb has no json chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// readURI - decode a base64 data uri or read a file relative to the gltf file
+func readURI(uri string, readFile func(uri string) ([]byte, error)) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.Index(uri, ",")
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("Unsupported data uri: %.40v", uri)
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	if readFile == nil {
+		return nil, fmt.Errorf("Cannot read external gltf file: %v", uri)
+	}
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+	return readFile(uri)
+}
+
+func (file *gltfFile) bufferView(index int) ([]byte, int, error) {
+	if index < 0 || index >= len(file.BufferViews) {
+		return nil, 0, fmt.Errorf("Invalid gltf buffer view: %v", index)
+	}
+	view := file.BufferViews[index]
+	if view.Buffer < 0 || view.Buffer >= len(file.buffers) {
+		return nil, 0, fmt.Errorf("Invalid gltf buffer: %v", view.Buffer)
+	}
+	buffer := file.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 ||
+		view.ByteOffset > len(buffer) || view.ByteLength > len(buffer)-view.ByteOffset {
+		return nil, 0, fmt.Errorf("gltf buffer view %v is out of range", index)
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+var gltfComponentCounts = map[string]int{
+	"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT2": 4, "MAT3": 9, "MAT4": 16,
+}
+
+var gltfComponentSizes = map[int]int{
+	5120: 1, 5121: 1, 5122: 2, 5123: 2, 5125: 4, 5126: 4,
+}
+
+// maxGLTFAccessorValues - the most components an accessor can have in total, accessors without a buffer view are allocated without reading any data
+const maxGLTFAccessorValues = 1 << 26
+
+// accessorData - the data of an accessor's buffer view and the stride between its elements, checking that every element is in range.
+// The data is nil if the accessor has no buffer view.
+func (file *gltfFile) accessorData(index, components, componentSize int) ([]byte, int, error) {
+	accessor := file.Accessors[index]
+	if accessor.Count < 0 || int64(accessor.Count)*int64(components) > maxGLTFAccessorValues || accessor.ByteOffset < 0 {
+		return nil, 0, fmt.Errorf("Invalid gltf accessor: %v", index)
+	}
+	elementSize := components * componentSize
+	if accessor.BufferView == nil {
+		return nil, 0, nil
+	}
+	data, stride, err := file.bufferView(*accessor.BufferView)
+	if err != nil {
+		return nil, 0, err
+	}
+	if stride == 0 {
+		stride = elementSize
+	}
+	if accessor.Count > 0 && (stride > len(data) ||
+		int64(accessor.ByteOffset)+int64(accessor.Count-1)*int64(stride)+int64(elementSize) > int64(len(data))) {
+		return nil, 0, fmt.Errorf("gltf accessor %v is out of range", index)
+	}
+	return data, stride, nil
+}
+
+// readFloats - the elements of an accessor as floats, with the components of each element consecutive.
+// Normalized integer components are converted to the range 0..1 (or -1..1 if signed).
+func (file *gltfFile) readFloats(index int) ([]float32, int, error) {
+	if index < 0 || index >= len(file.Accessors) {
+		return nil, 0, fmt.Errorf("Invalid gltf accessor: %v", index)
+	}
+	accessor := file.Accessors[index]
+	components, ok := gltfComponentCounts[accessor.Type]
+	if !ok {
+		return nil, 0, fmt.Errorf("Unsupported gltf accessor type: %v", accessor.Type)
+	}
+	size, ok := gltfComponentSizes[accessor.ComponentType]
+	if !ok {
+		return nil, 0, fmt.Errorf("Unsupported gltf component type: %v", accessor.ComponentType)
+	}
+	data, stride, err := file.accessorData(index, components, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make([]float32, accessor.Count*components)
+	if data == nil {
+		return values, components, nil // all zeros, as for a sparse accessor without a buffer view
+	}
+	for i := 0; i < accessor.Count; i++ {
+		element := data[accessor.ByteOffset+i*stride:]
+		for c := 0; c < components; c++ {
+			values[i*components+c] = readComponent(element[c*size:], accessor.ComponentType, accessor.Normalized)
+		}
+	}
+	return values, components, nil
+}
+
+func readComponent(data []byte, componentType int, normalized bool) float32 {
+	var value, max float32
+	switch componentType {
+	case 5120:
+		value, max = float32(int8(data[0])), 127
+	case 5121:
+		value, max = float32(data[0]), 255
+	case 5122:
+		value, max = float32(int16(binary.LittleEndian.Uint16(data))), 32767
+	case 5123:
+		value, max = float32(binary.LittleEndian.Uint16(data)), 65535
+	case 5125:
+		value, max = float32(binary.LittleEndian.Uint32(data)), 4294967295
+	case 5126:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data))
+	}
+	if normalized {
+		value = value / max
+		if value < -1 {
+			value = -1
+		}
+	}
+	return value
+}
+
+// readIndices - the elements of a scalar integer accessor
+func (file *gltfFile) readIndices(index int) ([]uint32, error) {
+	if index < 0 || index >= len(file.Accessors) {
+		return nil, fmt.Errorf("Invalid gltf accessor: %v", index)
+	}
+	accessor := file.Accessors[index]
+	size, ok := gltfComponentSizes[accessor.ComponentType]
+	if !ok || accessor.ComponentType == 5126 || accessor.Type != "SCALAR" {
+		return nil, fmt.Errorf("Invalid gltf index accessor: %v", index)
+	}
+	data, stride, err := file.accessorData(index, 1, size)
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]uint32, accessor.Count)
+	if data == nil {
+		return indices, nil
+	}
+	for i := range indices {
+		element := data[accessor.ByteOffset+i*stride:]
+		switch size {
+		case 1:
+			indices[i] = uint32(element[0])
+		case 2:
+			indices[i] = uint32(binary.LittleEndian.Uint16(element))
+		case 4:
+			indices[i] = binary.LittleEndian.Uint32(element)
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,775 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"log"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/animation"
+	"github.com/walesey/go-engine/renderer"
+	"github.com/walesey/go-engine/util"
+)
+
+// GLTFScene - the contents of a glTF file.
+// Each glTF node becomes a renderer.Node, with a child node holding the Material and Geometry of each mesh primitive.
+type GLTFScene struct {
+	Root    *renderer.Node
+	Nodes   []*renderer.Node // the node imported for each glTF node by index, nil if the node isn't in the scene
+	Cameras []GLTFCamera
+	Lights  []*renderer.Light // KHR_lights_punctual lights, placed at their world positions
+	Skins   []*GLTFSkin
+}
+
+// GLTFCamera - a camera from the scene. The camera looks along -z of its world transform with +y up.
+type GLTFCamera struct {
+	Name             string
+	Node             *renderer.Node
+	Transform        mgl32.Mat4
+	Angle, Near, Far float32 // vertical field of view in degrees
+	Ortho            bool
+}
+
+// Apply - move the camera to the glTF camera's position, using its field of view and clipping planes if it has a perspective projection
+func (c GLTFCamera) Apply(camera *renderer.Camera) {
+	camera.Translation = mgl32.TransformCoordinate(mgl32.Vec3{}, c.Transform)
+	camera.Lookat = camera.Translation.Add(mgl32.TransformNormal(mgl32.Vec3{0, 0, -1}, c.Transform).Normalize())
+	camera.Up = mgl32.TransformNormal(mgl32.Vec3{0, 1, 0}, c.Transform).Normalize()
+	if !c.Ortho {
+		camera.Angle, camera.Near, camera.Far = c.Angle, c.Near, c.Far
+	}
+}
+
+// GLTFSkin - a skeleton and the geometry it deforms.
+// The skinned geometry is added to the parent of the skeleton's root joint, so the skin matrices place it relative to the joints.
+// Clips are imported from the animation channels that target the skin's joints.
+type GLTFSkin struct {
+	Name       string
+	Skeleton   *animation.Skeleton
+	Joints     []int // the glTF node of each bone
+	Geometries []*renderer.SkinnedGeometry
+	Clips      []*animation.Clip
+
+	jointBones []int // the bone of each joint index used by the meshes
+}
+
+// NewAnimator - create an animator for the skeleton targeting the skinned geometry
+func (skin *GLTFSkin) NewAnimator() *animation.Animator {
+	animator := animation.NewAnimator(skin.Skeleton)
+	for _, geometry := range skin.Geometries {
+		animator.AddTarget(geometry)
+	}
+	return animator
+}
+
+// Clip - the named clip, nil if the skin has no clip with that name
+func (skin *GLTFSkin) Clip(name string) *animation.Clip {
+	for _, clip := range skin.Clips {
+		if clip.Name == name {
+			return clip
+		}
+	}
+	return nil
+}
+
+// ImportGLTF - import a .gltf or .glb file, external buffers and images are read relative to the file
+func ImportGLTF(filePath string) (*GLTFScene, error) {
+	return ImportGLTFFS(OSFS, filepath.ToSlash(filePath))
+}
+
+// ImportGLTFFS - import a .gltf or .glb file from a file system, see ImportGLTF
+func ImportGLTFFS(fsys FS, filePath string) (*GLTFScene, error) {
+	data, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		fmt.Printf("Error opening gltf file: %v\n", err)
+		return nil, err
+	}
+	dir := path.Dir(filePath)
+	return DecodeGLTF(data, func(uri string) ([]byte, error) {
+		return fs.ReadFile(fsys, path.Join(dir, uri))
+	})
+}
+
+// DecodeGLTF - import .gltf or .glb data, reading external buffers and images with readFile
+func DecodeGLTF(data []byte, readFile func(uri string) ([]byte, error)) (*GLTFScene, error) {
+	file, err := parseGLTF(data, readFile)
+	if err != nil {
+		return nil, err
+	}
+	importer := &gltfImporter{
+		file:        file,
+		scene:       &GLTFScene{Root: renderer.NewNode(), Nodes: make([]*renderer.Node, len(file.Nodes))},
+		parents:     make(map[int]int),
+		parentNodes: make(map[int]*renderer.Node),
+		images:      make(map[int]image.Image),
+		materials:   make(map[int]*gltfMaterialData),
+		geometries:  make(map[[2]int]*renderer.Geometry),
+	}
+	for _, index := range importer.rootNodes() {
+		if err := importer.importNode(index, -1, importer.scene.Root, mgl32.Ident4()); err != nil {
+			return nil, err
+		}
+	}
+	for i := range file.Skins {
+		if err := importer.importSkin(i); err != nil {
+			return nil, err
+		}
+	}
+	return importer.scene, nil
+}
+
+type gltfImporter struct {
+	file        *gltfFile
+	scene       *GLTFScene
+	parents     map[int]int
+	parentNodes map[int]*renderer.Node
+	images      map[int]image.Image
+	materials   map[int]*gltfMaterialData
+	geometries  map[[2]int]*renderer.Geometry
+}
+
+type gltfMaterialData struct {
+	material  *renderer.Material
+	params    *renderer.RendererParams
+	baseColor mgl32.Vec4
+}
+
+// rootNodes - the nodes of the default scene, or every node without a parent if there are no scenes
+func (importer *gltfImporter) rootNodes() []int {
+	file := importer.file
+	if len(file.Scenes) > 0 {
+		scene := 0
+		if file.Scene != nil && *file.Scene >= 0 && *file.Scene < len(file.Scenes) {
+			scene = *file.Scene
+		}
+		return file.Scenes[scene].Nodes
+	}
+	isChild := make(map[int]bool)
+	for _, node := range file.Nodes {
+		for _, child := range node.Children {
+			isChild[child] = true
+		}
+	}
+	var roots []int
+	for i := range file.Nodes {
+		if !isChild[i] {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+func (importer *gltfImporter) importNode(index, parentIndex int, parent *renderer.Node, parentWorld mgl32.Mat4) error {
+	file := importer.file
+	if index < 0 || index >= len(file.Nodes) {
+		return fmt.Errorf("Invalid gltf node: %v", index)
+	}
+	if importer.scene.Nodes[index] != nil {
+		return fmt.Errorf("gltf node %v has more than one parent", index)
+	}
+	gltfNode := file.Nodes[index]
+
+	node := renderer.NewNode()
+	transform := gltfNodeTransform(gltfNode)
+	node.SetScale(transform.Scale)
+	node.SetTranslation(transform.Translation)
+	node.SetOrientation(transform.Rotation)
+	parent.Add(node)
+	importer.scene.Nodes[index] = node
+	importer.parents[index] = parentIndex
+	importer.parentNodes[index] = parent
+	world := parentWorld.Mul4(node.Transform)
+
+	// skinned meshes are imported with their skin
+	if gltfNode.Mesh != nil && gltfNode.Skin == nil {
+		if err := importer.importMesh(*gltfNode.Mesh, node, nil); err != nil {
+			return err
+		}
+	}
+	if gltfNode.Camera != nil {
+		importer.importCamera(*gltfNode.Camera, node, world)
+	}
+	if gltfNode.Extensions.LightsPunctual != nil {
+		importer.importLight(gltfNode.Extensions.LightsPunctual.Light, world)
+	}
+	for _, child := range gltfNode.Children {
+		if err := importer.importNode(child, index, node, world); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gltfNodeTransform(node gltfNode) animation.Transform {
+	transform := animation.IdentityTransform()
+	if len(node.Matrix) == 16 {
+		var m mgl32.Mat4
+		copy(m[:], node.Matrix)
+		transform.Translation = m.Col(3).Vec3()
+		x, y, z := m.Col(0).Vec3(), m.Col(1).Vec3(), m.Col(2).Vec3()
+		transform.Scale = mgl32.Vec3{x.Len(), y.Len(), z.Len()}
+		if x.Cross(y).Dot(z) < 0 {
+			transform.Scale[0] = -transform.Scale[0]
+		}
+		if transform.Scale[0] != 0 && transform.Scale[1] != 0 && transform.Scale[2] != 0 {
+			rotation := mgl32.Mat3FromCols(x.Mul(1/transform.Scale[0]), y.Mul(1/transform.Scale[1]), z.Mul(1/transform.Scale[2]))
+			transform.Rotation = mgl32.Mat4ToQuat(rotation.Mat4()).Normalize()
+		}
+		return transform
+	}
+	if len(node.Translation) == 3 {
+		transform.Translation = mgl32.Vec3{node.Translation[0], node.Translation[1], node.Translation[2]}
+	}
+	if len(node.Rotation) == 4 {
+		transform.Rotation = mgl32.Quat{W: node.Rotation[3], V: mgl32.Vec3{node.Rotation[0], node.Rotation[1], node.Rotation[2]}}
+	}
+	if len(node.Scale) == 3 {
+		transform.Scale = mgl32.Vec3{node.Scale[0], node.Scale[1], node.Scale[2]}
+	}
+	return transform
+}
+
+// importMesh - add a node for each primitive of the mesh, skinned with the joint indices mapped to bones if a skin is given
+func (importer *gltfImporter) importMesh(meshIndex int, node *renderer.Node, skin *GLTFSkin) error {
+	file := importer.file
+	if meshIndex < 0 || meshIndex >= len(file.Meshes) {
+		return fmt.Errorf("Invalid gltf mesh: %v", meshIndex)
+	}
+	for i, primitive := range file.Meshes[meshIndex].Primitives {
+		if primitive.Mode != nil && *primitive.Mode != 4 {
+			log.Printf("Skipping gltf primitive with unsupported mode: %v\n", *primitive.Mode)
+			continue
+		}
+		material := importer.material(primitive.Material)
+		geometry, err := importer.primitiveGeometry(meshIndex, i, material.baseColor)
+		if err != nil {
+			return err
+		}
+
+		primitiveNode := renderer.NewNode()
+		primitiveNode.Material = material.material
+		primitiveNode.RendererParams = material.params
+		if skin == nil {
+			primitiveNode.Add(geometry)
+		} else {
+			skinData, err := importer.primitiveSkin(primitive, skin)
+			if err != nil {
+				return err
+			}
+			skinned := renderer.NewSkinnedGeometry(geometry, skinData)
+			skinned.SetBoneMatrices(skin.Skeleton.SkinMatrices(skin.Skeleton.RestPose(), nil))
+			skin.Geometries = append(skin.Geometries, skinned)
+			primitiveNode.Add(skinned)
+		}
+		node.Add(primitiveNode)
+	}
+	return nil
+}
+
+// primitiveGeometry - the geometry of a primitive, copied if the mesh has already been imported by another node
+func (importer *gltfImporter) primitiveGeometry(meshIndex, primitiveIndex int, baseColor mgl32.Vec4) (*renderer.Geometry, error) {
+	key := [2]int{meshIndex, primitiveIndex}
+	if geometry, ok := importer.geometries[key]; ok {
+		return geometry.Copy(), nil
+	}
+
+	file := importer.file
+	primitive := file.Meshes[meshIndex].Primitives[primitiveIndex]
+	positionAccessor, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return nil, fmt.Errorf("gltf mesh %v primitive %v has no positions", meshIndex, primitiveIndex)
+	}
+	positions, _, err := file.readFloats(positionAccessor)
+	if err != nil {
+		return nil, err
+	}
+	count := len(positions) / 3
+	attribute := func(name string) ([]float32, int, error) {
+		accessor, ok := primitive.Attributes[name]
+		if !ok {
+			return nil, 0, nil
+		}
+		values, components, err := file.readFloats(accessor)
+		if err == nil && len(values) < count*components {
+			err = fmt.Errorf("gltf attribute %v has too few elements", name)
+		}
+		return values, components, err
+	}
+	normals, _, err := attribute("NORMAL")
+	if err != nil {
+		return nil, err
+	}
+	uvs, _, err := attribute("TEXCOORD_0")
+	if err != nil {
+		return nil, err
+	}
+	colors, colorComponents, err := attribute("COLOR_0")
+	if err != nil {
+		return nil, err
+	}
+
+	var indices []uint32
+	if primitive.Indices != nil {
+		if indices, err = file.readIndices(*primitive.Indices); err != nil {
+			return nil, err
+		}
+		for _, index := range indices {
+			if int(index) >= count {
+				return nil, fmt.Errorf("gltf mesh %v primitive %v has an index out of range: %v", meshIndex, primitiveIndex, index)
+			}
+		}
+	} else {
+		indices = make([]uint32, count)
+		for i := range indices {
+			indices[i] = uint32(i)
+		}
+	}
+	indices = indices[:len(indices)-len(indices)%3]
+
+	verticies := make([]float32, count*renderer.VertexStride)
+	for i := 0; i < count; i++ {
+		v := verticies[i*renderer.VertexStride : (i+1)*renderer.VertexStride]
+		copy(v[0:3], positions[i*3:i*3+3])
+		if normals != nil {
+			copy(v[3:6], normals[i*3:i*3+3])
+		}
+		if uvs != nil {
+			// images are flipped when they are decoded, so v runs bottom to top
+			v[6], v[7] = uvs[i*2], 1-uvs[i*2+1]
+		}
+		color := baseColor
+		if colors != nil {
+			c := colors[i*colorComponents:]
+			color = mgl32.Vec4{color[0] * c[0], color[1] * c[1], color[2] * c[2], color[3]}
+			if colorComponents == 4 {
+				color[3] *= c[3]
+			}
+		}
+		copy(v[8:12], color[:])
+	}
+	if normals == nil {
+		smoothNormals(indices, verticies)
+	}
+
+	geometry := renderer.CreateGeometry(indices, verticies)
+	importer.geometries[key] = geometry
+	return geometry, nil
+}
+
+// smoothNormals - set each vertex normal to the average of the normals of the triangles using it
+func smoothNormals(indices []uint32, verticies []float32) {
+	position := func(index uint32) mgl32.Vec3 {
+		i := index * renderer.VertexStride
+		return mgl32.Vec3{verticies[i], verticies[i+1], verticies[i+2]}
+	}
+	normals := make([]mgl32.Vec3, len(verticies)/renderer.VertexStride)
+	for i := 0; i+2 < len(indices); i += 3 {
+		a, b, c := indices[i], indices[i+1], indices[i+2]
+		normal := position(b).Sub(position(a)).Cross(position(c).Sub(position(a)))
+		normals[a], normals[b], normals[c] = normals[a].Add(normal), normals[b].Add(normal), normals[c].Add(normal)
+	}
+	for i, normal := range normals {
+		if normal.Len() > 0 {
+			normal = normal.Normalize()
+		}
+		copy(verticies[i*renderer.VertexStride+3:], normal[:])
+	}
+}
+
+// primitiveSkin - the skin buffer of a primitive with the joint indices mapped to the skin's bones
+func (importer *gltfImporter) primitiveSkin(primitive gltfPrimitive, skin *GLTFSkin) ([]float32, error) {
+	file := importer.file
+	jointsAccessor, hasJoints := primitive.Attributes["JOINTS_0"]
+	weightsAccessor, hasWeights := primitive.Attributes["WEIGHTS_0"]
+	if !hasJoints || !hasWeights {
+		return nil, nil
+	}
+	joints, jointComponents, err := file.readFloats(jointsAccessor)
+	if err != nil {
+		return nil, err
+	}
+	weights, weightComponents, err := file.readFloats(weightsAccessor)
+	if err != nil {
+		return nil, err
+	}
+	if jointComponents != 4 || weightComponents != 4 || len(joints) != len(weights) {
+		return nil, fmt.Errorf("Invalid gltf skin attributes")
+	}
+	skinData := make([]float32, len(joints)/4*renderer.SkinStride)
+	for i := 0; i < len(joints)/4; i++ {
+		for j := 0; j < 4; j++ {
+			joint := int(joints[i*4+j])
+			if joint < 0 || joint >= len(skin.jointBones) {
+				return nil, fmt.Errorf("gltf joint index out of range: %v", joint)
+			}
+			skinData[i*renderer.SkinStride+j] = float32(skin.jointBones[joint])
+			skinData[i*renderer.SkinStride+4+j] = weights[i*4+j]
+		}
+	}
+	return skinData, nil
+}
+
+// material - the material with its textures mapped to the names used by pbr.glsl, nil uses the default material
+func (importer *gltfImporter) material(index *int) *gltfMaterialData {
+	if index == nil || *index < 0 || *index >= len(importer.file.Materials) {
+		return &gltfMaterialData{baseColor: mgl32.Vec4{1, 1, 1, 1}}
+	}
+	if data, ok := importer.materials[*index]; ok {
+		return data
+	}
+	gltfMaterial := importer.file.Materials[*index]
+	pbr := gltfMaterial.PbrMetallicRoughness
+	data := &gltfMaterialData{baseColor: mgl32.Vec4{1, 1, 1, 1}}
+	if len(pbr.BaseColorFactor) == 4 {
+		copy(data.baseColor[:], pbr.BaseColorFactor)
+	}
+
+	var textures []*renderer.Texture
+	addTexture := func(name string, img image.Image) {
+		textures = append(textures, renderer.NewTexture(name, img, true))
+	}
+	if img := importer.texture(pbr.BaseColorTexture); img != nil {
+		addTexture("diffuseMap", img)
+	} else {
+		addTexture("diffuseMap", util.ImageColor(255, 255, 255, 255))
+	}
+	if img := importer.texture(gltfMaterial.NormalTexture); img != nil {
+		addTexture("normalMap", img)
+	}
+	metallic, roughness := float32(1), float32(1)
+	if pbr.MetallicFactor != nil {
+		metallic = *pbr.MetallicFactor
+	}
+	if pbr.RoughnessFactor != nil {
+		roughness = *pbr.RoughnessFactor
+	}
+	if img := importer.texture(pbr.MetallicRoughnessTexture); img != nil {
+		addTexture("metalnessMap", channelImage(img, 2, metallic))
+		addTexture("roughnessMap", channelImage(img, 1, roughness))
+	} else {
+		addTexture("metalnessMap", grayImage(metallic))
+		addTexture("roughnessMap", grayImage(roughness))
+	}
+	if img := importer.texture(gltfMaterial.OcclusionTexture); img != nil {
+		addTexture("aoMap", channelImage(img, 0, 1))
+	} else {
+		addTexture("aoMap", grayImage(1))
+	}
+	emissive := mgl32.Vec3{}
+	if len(gltfMaterial.EmissiveFactor) == 3 {
+		emissive = mgl32.Vec3{gltfMaterial.EmissiveFactor[0], gltfMaterial.EmissiveFactor[1], gltfMaterial.EmissiveFactor[2]}
+	}
+	if img := importer.texture(gltfMaterial.EmissiveTexture); img != nil {
+		addTexture("glowMap", tintImage(img, emissive))
+	} else if emissive != (mgl32.Vec3{}) {
+		addTexture("glowMap", tintImage(util.ImageColor(255, 255, 255, 255), emissive))
+	}
+	data.material = renderer.NewMaterial(textures...)
+
+	if gltfMaterial.AlphaMode == "BLEND" || gltfMaterial.DoubleSided {
+		data.params = renderer.NewRendererParams()
+		data.params.CullBackface = !gltfMaterial.DoubleSided
+		if gltfMaterial.AlphaMode == "BLEND" {
+			data.params.Transparency = renderer.NON_EMISSIVE
+			data.params.CastShadows = false
+		}
+	}
+	importer.materials[*index] = data
+	return data
+}
+
+// texture - the decoded image of a texture, nil if there is no texture or it can't be decoded
+func (importer *gltfImporter) texture(info *gltfTextureInfo) image.Image {
+	file := importer.file
+	if info == nil || info.Index < 0 || info.Index >= len(file.Textures) {
+		return nil
+	}
+	source := file.Textures[info.Index].Source
+	if source == nil || *source < 0 || *source >= len(file.images) {
+		return nil
+	}
+	if img, ok := importer.images[*source]; ok {
+		return img
+	}
+	img, err := DecodeImage(bytes.NewReader(file.images[*source]))
+	if err != nil {
+		log.Printf("Error decoding gltf image %v: %v\n", *source, err)
+	}
+	importer.images[*source] = img
+	return img
+}
+
+// channelImage - one channel of the image scaled by the factor, as a grayscale image
+func channelImage(img image.Image, channel int, factor float32) image.Image {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			value := [4]uint8{c.R, c.G, c.B, c.A}[channel]
+			gray.SetGray(x, y, color.Gray{Y: scaleByte(value, factor)})
+		}
+	}
+	return gray
+}
+
+// tintImage - the image with its color multiplied by the tint
+func tintImage(img image.Image, tint mgl32.Vec3) image.Image {
+	bounds := img.Bounds()
+	result := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			result.SetNRGBA(x, y, color.NRGBA{scaleByte(c.R, tint[0]), scaleByte(c.G, tint[1]), scaleByte(c.B, tint[2]), c.A})
+		}
+	}
+	return result
+}
+
+func grayImage(value float32) image.Image {
+	gray := scaleByte(255, value)
+	return util.ImageColor(gray, gray, gray, 255)
+}
+
+func scaleByte(value uint8, factor float32) uint8 {
+	return uint8(mgl32.Clamp(float32(value)*factor+0.5, 0, 255))
+}
+
+func (importer *gltfImporter) importCamera(index int, node *renderer.Node, world mgl32.Mat4) {
+	if index < 0 || index >= len(importer.file.Cameras) {
+		log.Printf("Invalid gltf camera: %v\n", index)
+		return
+	}
+	gltfCamera := importer.file.Cameras[index]
+	camera := GLTFCamera{Name: gltfCamera.Name, Node: node, Transform: world}
+	if gltfCamera.Perspective != nil {
+		camera.Angle = mgl32.RadToDeg(gltfCamera.Perspective.Yfov)
+		camera.Near, camera.Far = gltfCamera.Perspective.Znear, gltfCamera.Perspective.Zfar
+		if camera.Far == 0 {
+			camera.Far = renderer.CreateCamera().Far
+		}
+	} else if gltfCamera.Orthographic != nil {
+		camera.Ortho = true
+		camera.Near, camera.Far = gltfCamera.Orthographic.Znear, gltfCamera.Orthographic.Zfar
+	}
+	importer.scene.Cameras = append(importer.scene.Cameras, camera)
+}
+
+// importLight - the light color is multiplied by the intensity
+func (importer *gltfImporter) importLight(index int, world mgl32.Mat4) {
+	lights := importer.file.Extensions.LightsPunctual.Lights
+	if index < 0 || index >= len(lights) {
+		log.Printf("Invalid gltf light: %v\n", index)
+		return
+	}
+	gltfLight := lights[index]
+	var light *renderer.Light
+	switch gltfLight.Type {
+	case "directional":
+		light = renderer.NewLight(renderer.DIRECTIONAL)
+	case "point":
+		light = renderer.NewLight(renderer.POINT)
+	case "spot":
+		light = renderer.NewLight(renderer.SPOT)
+		if spot := gltfLight.Spot; spot != nil {
+			light.InnerAngle = mgl32.RadToDeg(spot.InnerConeAngle)
+			light.OuterAngle = 45
+			if spot.OuterConeAngle != nil {
+				light.OuterAngle = mgl32.RadToDeg(*spot.OuterConeAngle)
+			}
+		}
+	default:
+		log.Printf("Unsupported gltf light type: %v\n", gltfLight.Type)
+		return
+	}
+	intensity := float32(1)
+	if gltfLight.Intensity != nil {
+		intensity = *gltfLight.Intensity
+	}
+	light.Color = [3]float32{intensity, intensity, intensity}
+	if len(gltfLight.Color) == 3 {
+		light.Color = [3]float32{gltfLight.Color[0] * intensity, gltfLight.Color[1] * intensity, gltfLight.Color[2] * intensity}
+	}
+	light.Range = gltfLight.Range
+	light.Position = mgl32.TransformCoordinate(mgl32.Vec3{}, world)
+	light.Direction = mgl32.TransformNormal(mgl32.Vec3{0, 0, -1}, world).Normalize()
+	importer.scene.Lights = append(importer.scene.Lights, light)
+}
+
+// importSkin - build the skeleton of a skin, import the meshes it deforms and the animations of its joints
+func (importer *gltfImporter) importSkin(index int) error {
+	file := importer.file
+	gltfSkin := file.Skins[index]
+	skin := &GLTFSkin{Name: gltfSkin.Name, Skeleton: animation.NewSkeleton()}
+	importer.scene.Skins = append(importer.scene.Skins, skin)
+
+	inverseBinds := make(map[int]mgl32.Mat4)
+	if gltfSkin.InverseBindMatrices != nil {
+		values, components, err := file.readFloats(*gltfSkin.InverseBindMatrices)
+		if err != nil {
+			return err
+		}
+		if components != 16 || len(values) < len(gltfSkin.Joints)*16 {
+			return fmt.Errorf("Invalid gltf inverse bind matrices")
+		}
+		for i, joint := range gltfSkin.Joints {
+			var m mgl32.Mat4
+			copy(m[:], values[i*16:i*16+16])
+			inverseBinds[joint] = m
+		}
+	}
+
+	// order the joints so parents come before their children
+	isJoint := make(map[int]bool)
+	for _, joint := range gltfSkin.Joints {
+		if joint < 0 || joint >= len(file.Nodes) || importer.scene.Nodes[joint] == nil {
+			return fmt.Errorf("Invalid gltf joint: %v", joint)
+		}
+		isJoint[joint] = true
+	}
+	jointParent := func(joint int) int {
+		parent, ok := importer.parents[joint]
+		for ok && parent >= 0 && !isJoint[parent] {
+			parent, ok = importer.parents[parent]
+		}
+		if !ok {
+			return -1
+		}
+		return parent
+	}
+	depth := func(joint int) int {
+		d := 0
+		for parent := jointParent(joint); parent >= 0; parent = jointParent(parent) {
+			d++
+		}
+		return d
+	}
+	skin.Joints = append([]int{}, gltfSkin.Joints...)
+	sort.SliceStable(skin.Joints, func(i, j int) bool { return depth(skin.Joints[i]) < depth(skin.Joints[j]) })
+
+	bones := make(map[int]int)
+	for bone, joint := range skin.Joints {
+		bones[joint] = bone
+		parent := -1
+		if parentJoint := jointParent(joint); parentJoint >= 0 {
+			parent = bones[parentJoint]
+		}
+		inverseBind, ok := inverseBinds[joint]
+		if !ok {
+			inverseBind = mgl32.Ident4()
+		}
+		skin.Skeleton.Bones = append(skin.Skeleton.Bones, animation.Bone{
+			Name:        file.Nodes[joint].Name,
+			Parent:      parent,
+			Rest:        gltfNodeTransform(file.Nodes[joint]),
+			InverseBind: inverseBind,
+		})
+	}
+	for _, joint := range gltfSkin.Joints {
+		skin.jointBones = append(skin.jointBones, bones[joint])
+	}
+
+	if len(skin.Joints) > 0 {
+		attach := importer.parentNodes[skin.Joints[0]]
+		for i, node := range file.Nodes {
+			if node.Skin != nil && *node.Skin == index && node.Mesh != nil && importer.scene.Nodes[i] != nil {
+				if err := importer.importMesh(*node.Mesh, attach, skin); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i := range file.Animations {
+		clip, err := importer.importClip(i, bones)
+		if err != nil {
+			return err
+		}
+		if clip != nil {
+			skin.Clips = append(skin.Clips, clip)
+		}
+	}
+	return nil
+}
+
+// importClip - the channels of an animation that target the bones, nil if none of the channels do.
+// Step interpolation is approximated with pairs of keys, and cubic splines are sampled linearly between their keys.
+func (importer *gltfImporter) importClip(index int, bones map[int]int) (*animation.Clip, error) {
+	file := importer.file
+	gltfAnimation := file.Animations[index]
+	channels := make(map[int]*animation.Channel)
+	var order []int
+	for _, gltfChannel := range gltfAnimation.Channels {
+		target := gltfChannel.Target
+		if target.Node == nil {
+			continue
+		}
+		bone, ok := bones[*target.Node]
+		if !ok || (target.Path != "translation" && target.Path != "rotation" && target.Path != "scale") {
+			continue
+		}
+		if gltfChannel.Sampler < 0 || gltfChannel.Sampler >= len(gltfAnimation.Samplers) {
+			return nil, fmt.Errorf("Invalid gltf animation sampler: %v", gltfChannel.Sampler)
+		}
+		sampler := gltfAnimation.Samplers[gltfChannel.Sampler]
+		times, _, err := file.readFloats(sampler.Input)
+		if err != nil {
+			return nil, err
+		}
+		values, components, err := file.readFloats(sampler.Output)
+		if err != nil {
+			return nil, err
+		}
+		stride, offset := components, 0
+		if sampler.Interpolation == "CUBICSPLINE" {
+			stride, offset = components*3, components
+		}
+		if len(values) < len(times)*stride {
+			return nil, fmt.Errorf("gltf animation %v has too few output values", index)
+		}
+
+		channel, ok := channels[bone]
+		if !ok {
+			channel = &animation.Channel{Bone: bone}
+			channels[bone] = channel
+			order = append(order, bone)
+		}
+		for i, time := range times {
+			value := values[i*stride+offset:]
+			keyTimes := []float32{time}
+			if sampler.Interpolation == "STEP" && i+1 < len(times) {
+				keyTimes = append(keyTimes, float32(math.Nextafter32(times[i+1], time)))
+			}
+			for _, keyTime := range keyTimes {
+				switch target.Path {
+				case "translation":
+					channel.Translations = append(channel.Translations, animation.Vec3Key{Time: keyTime, Value: mgl32.Vec3{value[0], value[1], value[2]}})
+				case "rotation":
+					channel.Rotations = append(channel.Rotations, animation.QuatKey{Time: keyTime, Value: mgl32.Quat{W: value[3], V: mgl32.Vec3{value[0], value[1], value[2]}}.Normalize()})
+				case "scale":
+					channel.Scales = append(channel.Scales, animation.Vec3Key{Time: keyTime, Value: mgl32.Vec3{value[0], value[1], value[2]}})
+				}
+			}
+		}
+	}
+	if len(order) == 0 {
+		return nil, nil
+	}
+
+	name := gltfAnimation.Name
+	if name == "" {
+		name = fmt.Sprintf("animation%v", index)
+	}
+	clipChannels := make([]animation.Channel, len(order))
+	for i, bone := range order {
+		clipChannels[i] = *channels[bone]
+	}
+	return animation.NewClip(name, clipChannels...), nil
+}
@@ -0,0 +1,251 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// gltfBuilder - builds a glTF document with a single buffer for tests
+type gltfBuilder struct {
+	doc    map[string]interface{}
+	buffer bytes.Buffer
+}
+
+func newGLTFBuilder() *gltfBuilder {
+	return &gltfBuilder{doc: map[string]interface{}{"asset": map[string]string{"version": "2.0"}}}
+}
+
+func (b *gltfBuilder) append(key string, value interface{}) int {
+	list, _ := b.doc[key].([]interface{})
+	b.doc[key] = append(list, value)
+	return len(list)
+}
+
+// accessor - add the data to the buffer with an accessor of the given type
+func (b *gltfBuilder) accessor(accessorType string, componentType int, data interface{}) int {
+	for b.buffer.Len()%4 != 0 {
+		b.buffer.WriteByte(0)
+	}
+	offset := b.buffer.Len()
+	binary.Write(&b.buffer, binary.LittleEndian, data)
+	view := b.append("bufferViews", map[string]interface{}{"buffer": 0, "byteOffset": offset, "byteLength": b.buffer.Len() - offset})
+	size := gltfComponentSizes[componentType] * gltfComponentCounts[accessorType]
+	return b.append("accessors", map[string]interface{}{
+		"bufferView": view, "componentType": componentType, "type": accessorType, "count": (b.buffer.Len() - offset) / size,
+	})
+}
+
+func (b *gltfBuilder) gltf() []byte {
+	b.doc["buffers"] = []interface{}{map[string]interface{}{
+		"byteLength": b.buffer.Len(),
+		"uri":        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b.buffer.Bytes()),
+	}}
+	data, _ := json.Marshal(b.doc)
+	return data
+}
+
+func (b *gltfBuilder) glb() []byte {
+	b.doc["buffers"] = []interface{}{map[string]interface{}{"byteLength": b.buffer.Len()}}
+	jsonData, _ := json.Marshal(b.doc)
+	for len(jsonData)%4 != 0 {
+		jsonData = append(jsonData, ' ')
+	}
+	bin := b.buffer.Bytes()
+	var glb bytes.Buffer
+	binary.Write(&glb, binary.LittleEndian, []uint32{glbMagic, 2, uint32(12 + 8 + len(jsonData) + 8 + len(bin))})
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(jsonData)), glbChunkJSON})
+	glb.Write(jsonData)
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(bin)), glbChunkBIN})
+	glb.Write(bin)
+	return glb.Bytes()
+}
+
+// triangleScene - a parent node with a textured triangle child, a camera and a spot light
+func triangleScene() *gltfBuilder {
+	b := newGLTFBuilder()
+	positions := b.accessor("VEC3", 5126, []float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	uvs := b.accessor("VEC2", 5126, []float32{0, 0, 1, 0, 0, 1})
+	indices := b.accessor("SCALAR", 5123, []uint16{0, 1, 2})
+	b.append("materials", map[string]interface{}{
+		"pbrMetallicRoughness": map[string]interface{}{"baseColorFactor": []float32{1, 0.5, 0, 1}, "metallicFactor": 0.5, "roughnessFactor": 0.25},
+		"doubleSided":          true,
+	})
+	b.append("meshes", map[string]interface{}{"primitives": []interface{}{map[string]interface{}{
+		"attributes": map[string]int{"POSITION": positions, "TEXCOORD_0": uvs}, "indices": indices, "material": 0,
+	}}})
+	b.append("cameras", map[string]interface{}{"type": "perspective", "perspective": map[string]float32{"yfov": 1, "znear": 0.5, "zfar": 100}})
+	b.doc["extensions"] = map[string]interface{}{"KHR_lights_punctual": map[string]interface{}{"lights": []interface{}{
+		map[string]interface{}{"type": "spot", "color": []float32{1, 0, 0}, "intensity": 2, "spot": map[string]float32{"innerConeAngle": 0.1, "outerConeAngle": 0.5}},
+	}}}
+	b.append("nodes", map[string]interface{}{"name": "parent", "translation": []float32{0, 0, 5}, "children": []int{1, 2, 3}})
+	b.append("nodes", map[string]interface{}{"name": "triangle", "mesh": 0, "scale": []float32{2, 2, 2}})
+	b.append("nodes", map[string]interface{}{"name": "camera", "camera": 0, "translation": []float32{0, 1, 0}})
+	b.append("nodes", map[string]interface{}{
+		"name":       "light",
+		"rotation":   []float32{0, 0.7071068, 0, 0.7071068},
+		"extensions": map[string]interface{}{"KHR_lights_punctual": map[string]int{"light": 0}},
+	})
+	b.doc["scenes"] = []interface{}{map[string]interface{}{"nodes": []int{0}}}
+	return b
+}
+
+func TestDecodeGLTF(t *testing.T) {
+	scene, err := DecodeGLTF(triangleScene().gltf(), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, scene.Nodes, 4)
+	parent, triangle := scene.Nodes[0], scene.Nodes[1]
+	assert.Equal(t, mgl32.Vec3{0, 0, 5}, parent.Translation)
+	assert.Equal(t, mgl32.Vec3{2, 2, 2}, triangle.Scale)
+	assert.Equal(t, []renderer.Spatial{parent}, scene.Root.Children())
+
+	primitive := triangle.Children()[0].(*renderer.Node)
+	geometry := primitive.Children()[0].(*renderer.Geometry)
+	assert.Equal(t, []uint32{0, 1, 2}, geometry.Indicies)
+	assert.Equal(t, []float32{1, 0, 0, 0, 0, 1, 1, 1, 1, 0.5, 0, 1}, geometry.Verticies[renderer.VertexStride:2*renderer.VertexStride],
+		"generated normals, flipped uvs and the base color")
+
+	textures := make(map[string]*renderer.Texture)
+	for _, texture := range primitive.Material.Textures {
+		textures[texture.TextureName] = texture
+	}
+	assert.Len(t, textures, 4)
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, textures["metalnessMap"].Img.At(0, 0))
+	assert.Equal(t, color.RGBA{64, 64, 64, 255}, textures["roughnessMap"].Img.At(0, 0))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, textures["aoMap"].Img.At(0, 0))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, textures["diffuseMap"].Img.At(0, 0))
+	assert.False(t, primitive.RendererParams.CullBackface)
+
+	if assert.Len(t, scene.Cameras, 1) {
+		camera := renderer.CreateCamera()
+		scene.Cameras[0].Apply(camera)
+		assert.Equal(t, mgl32.Vec3{0, 1, 5}, camera.Translation)
+		assert.Equal(t, mgl32.Vec3{0, 1, 4}, camera.Lookat)
+		assert.InDelta(t, 57.2958, camera.Angle, 1e-3)
+		assert.EqualValues(t, 0.5, camera.Near)
+	}
+
+	if assert.Len(t, scene.Lights, 1) {
+		light := scene.Lights[0]
+		assert.Equal(t, renderer.SPOT, light.LightType)
+		assert.Equal(t, [3]float32{2, 0, 0}, light.Color)
+		assert.Equal(t, mgl32.Vec3{0, 0, 5}, light.Position)
+		assert.InDelta(t, 0, light.Direction.Sub(mgl32.Vec3{-1, 0, 0}).Len(), 1e-5)
+		assert.InDelta(t, 5.7296, light.InnerAngle, 1e-3)
+		assert.InDelta(t, 28.6479, light.OuterAngle, 1e-3)
+	}
+}
+
+func TestDecodeGLB(t *testing.T) {
+	scene, err := DecodeGLTF(triangleScene().glb(), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	geometry := scene.Nodes[1].Children()[0].(*renderer.Node).Children()[0].(*renderer.Geometry)
+	assert.Len(t, geometry.Verticies, 3*renderer.VertexStride)
+
+	_, err = DecodeGLTF([]byte(`{"buffers": [{"uri": "missing.bin", "byteLength": 4}]}`), nil)
+	assert.Error(t, err)
+}
+
+func TestDecodeGLTFMalformed(t *testing.T) {
+	for _, test := range []struct {
+		key, field string
+		index      int
+		value      interface{}
+	}{
+		{"accessors", "count", 0, -1},
+		{"accessors", "count", 0, maxGLTFAccessorValues/3 + 1},
+		{"accessors", "count", 2, 1 << 40},
+		{"accessors", "byteOffset", 0, -4},
+		{"accessors", "byteOffset", 2, 1 << 62},
+		{"bufferViews", "byteOffset", 0, -4},
+		{"bufferViews", "byteLength", 0, -1},
+		{"bufferViews", "byteOffset", 0, 1 << 62},
+		{"bufferViews", "byteLength", 0, 1 << 62},
+		{"bufferViews", "byteStride", 0, -12},
+		{"bufferViews", "byteStride", 0, 1 << 62},
+	} {
+		b := triangleScene()
+		b.doc[test.key].([]interface{})[test.index].(map[string]interface{})[test.field] = test.value
+		_, err := DecodeGLTF(b.gltf(), nil)
+		assert.Error(t, err, "%v[%v].%v = %v should be rejected", test.key, test.index, test.field, test.value)
+	}
+
+	b := triangleScene()
+	delete(b.doc["accessors"].([]interface{})[0].(map[string]interface{}), "bufferView")
+	b.doc["accessors"].([]interface{})[0].(map[string]interface{})["count"] = 1 << 40
+	_, err := DecodeGLTF(b.gltf(), nil)
+	assert.Error(t, err, "accessors without a buffer view should be limited")
+
+	b = triangleScene()
+	accessor := b.doc["accessors"].([]interface{})[0].(map[string]interface{})
+	delete(accessor, "bufferView")
+	accessor["type"] = "MAT4"
+	accessor["count"] = maxGLTFAccessorValues / 4
+	_, err = DecodeGLTF(b.gltf(), nil)
+	assert.Error(t, err, "the total number of components should be limited")
+}
+
+func TestDecodeGLTFSkin(t *testing.T) {
+	b := newGLTFBuilder()
+	positions := b.accessor("VEC3", 5126, []float32{0, 0, 0, 1, 0, 0, 1, 1, 0})
+	joints := b.accessor("VEC4", 5121, []uint8{0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0})
+	weights := b.accessor("VEC4", 5126, []float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0})
+	inverseBinds := b.accessor("MAT4", 5126, []float32{
+		1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, -1, 0, 0, 1,
+		1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1,
+	})
+	times := b.accessor("SCALAR", 5126, []float32{0, 2})
+	rotations := b.accessor("VEC4", 5126, []float32{0, 0, 0, 1, 0, 0, 0.7071068, 0.7071068})
+	b.append("meshes", map[string]interface{}{"primitives": []interface{}{map[string]interface{}{
+		"attributes": map[string]int{"POSITION": positions, "JOINTS_0": joints, "WEIGHTS_0": weights},
+	}}})
+	// the joints are listed child first
+	b.append("skins", map[string]interface{}{"joints": []int{2, 1}, "inverseBindMatrices": inverseBinds})
+	b.append("animations", map[string]interface{}{
+		"name":     "bend",
+		"channels": []interface{}{map[string]interface{}{"sampler": 0, "target": map[string]interface{}{"node": 2, "path": "rotation"}}},
+		"samplers": []interface{}{map[string]interface{}{"input": times, "output": rotations}},
+	})
+	b.append("nodes", map[string]interface{}{"name": "mesh", "mesh": 0, "skin": 0})
+	b.append("nodes", map[string]interface{}{"name": "root", "children": []int{2}})
+	b.append("nodes", map[string]interface{}{"name": "tip", "translation": []float32{1, 0, 0}})
+
+	scene, err := DecodeGLTF(b.gltf(), nil)
+	if !assert.NoError(t, err) || !assert.Len(t, scene.Skins, 1) {
+		return
+	}
+	skin := scene.Skins[0]
+	assert.Equal(t, []int{1, 2}, skin.Joints)
+	bones := skin.Skeleton.Bones
+	assert.Equal(t, "root", bones[0].Name)
+	assert.Equal(t, -1, bones[0].Parent)
+	assert.Equal(t, 0, bones[1].Parent)
+	assert.Equal(t, mgl32.Translate3D(-1, 0, 0), bones[1].InverseBind)
+
+	if assert.Len(t, skin.Geometries, 1) {
+		assert.Equal(t, []float32{1, 1, 1, 1, 1, 0, 0, 0}, skin.Geometries[0].Skin[:renderer.SkinStride], "joint indices are mapped to bones")
+		assert.Len(t, scene.Nodes[0].Children(), 0, "skinned meshes are added with the skeleton")
+	}
+
+	clip := skin.Clip("bend")
+	if assert.NotNil(t, clip) {
+		assert.EqualValues(t, 2, clip.Duration)
+		animator := skin.NewAnimator()
+		animator.Play(clip, 0).Loop = false
+		animator.Update(2)
+		tip := skin.Geometries[0].CPUSkin()
+		v := mgl32.Vec3{tip.Verticies[0], tip.Verticies[1], tip.Verticies[2]}
+		assert.InDelta(t, 0, v.Sub(mgl32.Vec3{1, -1, 0}).Len(), 1e-5, "the vertex rotates around the tip joint: %v", v)
+	}
+}
@@ -6,20 +6,23 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"os"
-
-	"io/ioutil"
+	"io/fs"
 
 	"github.com/disintegration/imaging"
 	"github.com/walesey/go-engine/renderer"
 )
 
 func ImportImage(file string) (image.Image, error) {
-	imgFile, err := os.Open(file)
+	return ImportImageFS(OSFS, file)
+}
+
+func ImportImageFS(fsys FS, file string) (image.Image, error) {
+	imgFile, err := fsys.Open(file)
 	if err != nil {
 		fmt.Printf("Error opening image file: %v\n", err)
 		return nil, err
 	}
+	defer imgFile.Close()
 	return DecodeImage(imgFile)
 }
 
@@ -34,13 +37,17 @@ func DecodeImage(data io.Reader) (image.Image, error) {
 }
 
 func ImportShader(vertexFile, fragmentFile string) (*renderer.Shader, error) {
-	vertsrc, err := ioutil.ReadFile(vertexFile)
+	return ImportShaderFS(OSFS, vertexFile, fragmentFile)
+}
+
+func ImportShaderFS(fsys FS, vertexFile, fragmentFile string) (*renderer.Shader, error) {
+	vertsrc, err := fs.ReadFile(fsys, vertexFile)
 	if err != nil {
 		fmt.Printf("Error vertex file: %v\n", err)
 		return nil, err
 	}
 
-	fragsrc, err := ioutil.ReadFile(fragmentFile)
+	fragsrc, err := fs.ReadFile(fsys, fragmentFile)
 	if err != nil {
 		fmt.Printf("Error fragment file: %v\n", err)
 		return nil, err
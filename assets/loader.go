@@ -21,17 +21,27 @@ type mapImport struct {
 
 // The loader allows asyncronous loading of obj and map files
 type Loader struct {
-	geoms chan geomImport
-	maps  chan mapImport
+	geoms        chan geomImport
+	maps         chan mapImport
+	cache        *AssetCache
+	meshCacheDir string
 }
 
 func NewLoader() *Loader {
 	return &Loader{
 		geoms: make(chan geomImport, 256),
 		maps:  make(chan mapImport, 256),
+		cache: globalCache,
 	}
 }
 
+// NewLoaderFS - a loader that loads obj and map files from the file system
+func NewLoaderFS(fsys FS) *Loader {
+	loader := NewLoader()
+	loader.cache = NewAssetCacheFS(fsys)
+	return loader
+}
+
 func (loader *Loader) Update(dt float64) {
 	for {
 		select {
@@ -47,9 +57,9 @@ func (loader *Loader) Update(dt float64) {
 
 func (loader *Loader) LoadMap(path string, callback func(node *renderer.Node, model *editorModels.NodeModel)) {
 	go func() {
-		srcModel := LoadMap(path)
+		srcModel := LoadMapFS(loader.cache.fsys, path)
 		destNode := renderer.NewNode()
-		loadedModel := LoadMapToNode(srcModel.Root, destNode)
+		loadedModel := loader.cache.LoadMapToNode(srcModel.Root, destNode)
 		loader.maps <- mapImport{
 			node:     destNode,
 			model:    loadedModel,
@@ -58,9 +68,21 @@ func (loader *Loader) LoadMap(path string, callback func(node *renderer.Node, mo
 	}()
 }
 
+// UseMeshCache - load obj files through .gemesh caches in the directory, building them on first load
+func (loader *Loader) UseMeshCache(cacheDir string) {
+	loader.meshCacheDir = cacheDir
+}
+
 func (loader *Loader) LoadObj(path string, callback func(geometry *renderer.Geometry, material *renderer.Material)) {
+	cache, cacheDir := loader.cache, loader.meshCacheDir
 	go func() {
-		loadedGeometry, loadedMaterial, err := ImportObjCached(path)
+		importObj := cache.ImportObj
+		if cacheDir != "" {
+			importObj = func(path string) (*renderer.Geometry, *renderer.Material, error) {
+				return cache.ImportObjMeshCache(path, cacheDir)
+			}
+		}
+		loadedGeometry, loadedMaterial, err := importObj(path)
 		if err != nil {
 			log.Println("Error Loading Obj: ", err)
 		} else {
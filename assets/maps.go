@@ -3,7 +3,7 @@ package assets
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"log"
 
 	"github.com/walesey/go-engine/editor/models"
@@ -11,7 +11,11 @@ import (
 )
 
 func LoadMap(path string) *editorModels.MapModel {
-	data, err := ioutil.ReadFile(path)
+	return LoadMapFS(OSFS, path)
+}
+
+func LoadMapFS(fsys FS, path string) *editorModels.MapModel {
+	data, err := fs.ReadFile(fsys, path)
 	if err != nil {
 		log.Printf("Error Reading map file: %v\n", err)
 		return nil
@@ -28,15 +32,20 @@ func LoadMap(path string) *editorModels.MapModel {
 }
 
 func LoadMapToNode(srcModel *editorModels.NodeModel, destNode *renderer.Node) *editorModels.NodeModel {
+	return globalCache.LoadMapToNode(srcModel, destNode)
+}
+
+// LoadMapToNode - load the map into the node, importing the geometries through the cache
+func (ac *AssetCache) LoadMapToNode(srcModel *editorModels.NodeModel, destNode *renderer.Node) *editorModels.NodeModel {
 	copy := srcModel.Copy(func(name string) string { return name })
-	loadMapRecursive(copy, srcModel, destNode)
+	ac.loadMapRecursive(copy, srcModel, destNode)
 	return copy
 }
 
-func loadMapRecursive(model, srcModel *editorModels.NodeModel, destNode *renderer.Node) {
+func (ac *AssetCache) loadMapRecursive(model, srcModel *editorModels.NodeModel, destNode *renderer.Node) {
 	model.SetNode(destNode)
 	if model.Geometry != nil {
-		geometry, material, err := ImportObjCached(*model.Geometry)
+		geometry, material, err := ac.ImportObj(*model.Geometry)
 		if err == nil {
 			destNode.Add(geometry)
 			destNode.Material = material
@@ -51,7 +60,7 @@ func loadMapRecursive(model, srcModel *editorModels.NodeModel, destNode *rendere
 	for _, childModel := range model.Children {
 		newNode := renderer.NewNode()
 		destNode.Add(newNode)
-		loadMapRecursive(childModel, srcModel, newNode)
+		ac.loadMapRecursive(childModel, srcModel, newNode)
 	}
 }
 
@@ -0,0 +1,463 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/walesey/go-engine/renderer"
+)
+
+// .gemesh format (little endian):
+//
+//	header : magic "GEMH", version uint16, compression uint8, source hash [32]byte
+//	body   : (compressed with the header compression)
+//	         attribute count uint8, {semantic uint8, components uint8} per attribute
+//	         vertex count uint32, vertex data float32 * components * vertex count
+//	         index width uint8 (2 or 4), index count uint32, index data
+//	         texture count uint16, {name, kind uint8, path | width uint32, height uint32, rgba pixels} per texture
+//
+// strings are written as a uint16 length followed by the bytes.
+const (
+	meshMagic   = "GEMH"
+	meshVersion = 1
+	meshExt     = ".gemesh"
+
+	// maxMeshElements - the most floats, indicies or texture pixels read from a mesh file, so corrupt counts can't exhaust memory
+	maxMeshElements = 1 << 26
+)
+
+type MeshCompression uint8
+
+const (
+	MESH_UNCOMPRESSED MeshCompression = iota
+	MESH_GZIP
+)
+
+// vertex attribute semantics of the layout descriptor
+const (
+	meshPosition uint8 = iota
+	meshNormal
+	meshTexCoord
+	meshColor
+)
+
+const (
+	meshTexturePath uint8 = iota
+	meshTextureInline
+)
+
+// meshLayout - the vertex layout of renderer.Geometry, written with every mesh
+var meshLayout = [][2]uint8{{meshPosition, 3}, {meshNormal, 3}, {meshTexCoord, 2}, {meshColor, 4}}
+
+// meshAttributes - the offset and size of each attribute within renderer.VertexStride
+var meshAttributes = map[uint8][2]int{meshPosition: {0, 3}, meshNormal: {3, 3}, meshTexCoord: {6, 2}, meshColor: {8, 4}}
+
+// MeshTexture - a material texture, referenced by path or stored inline when the image has no path
+type MeshTexture struct {
+	Name string
+	Path string
+	Img  image.Image
+}
+
+// Mesh - a geometry and its material textures as stored in a .gemesh file
+type Mesh struct {
+	Geometry   *renderer.Geometry
+	Textures   []MeshTexture
+	SourceHash [32]byte
+}
+
+// Material - create a material from the mesh textures, loading referenced images that aren't loaded yet
+func (mesh *Mesh) Material() *renderer.Material {
+	return mesh.material(ImportImageCached)
+}
+
+// MaterialFS - create a material from the mesh textures, loading referenced images from the file system
+func (mesh *Mesh) MaterialFS(fsys FS) *renderer.Material {
+	return mesh.material(func(path string) (image.Image, error) {
+		return ImportImageFS(fsys, path)
+	})
+}
+
+func (mesh *Mesh) material(importImage func(path string) (image.Image, error)) *renderer.Material {
+	textures := []*renderer.Texture{}
+	for _, texture := range mesh.Textures {
+		img := texture.Img
+		if img == nil && texture.Path != "" {
+			var err error
+			if img, err = importImage(texture.Path); err != nil {
+				log.Printf("Error loading mesh texture %v: %v\n", texture.Name, err)
+				continue
+			}
+		}
+		if img != nil {
+			textures = append(textures, renderer.NewTexture(texture.Name, img, true))
+		}
+	}
+	return renderer.NewMaterial(textures...)
+}
+
+// WriteMesh - write the mesh in the .gemesh format
+func WriteMesh(w io.Writer, mesh *Mesh, compression MeshCompression) error {
+	header := make([]byte, 0, 39)
+	header = append(header, meshMagic...)
+	header = append(header, meshVersion, 0, byte(compression))
+	header = append(header, mesh.SourceHash[:]...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	switch compression {
+	case MESH_UNCOMPRESSED:
+		buf := bufio.NewWriter(w)
+		if err := writeMeshBody(buf, mesh); err != nil {
+			return err
+		}
+		return buf.Flush()
+	case MESH_GZIP:
+		gz := gzip.NewWriter(w)
+		if err := writeMeshBody(gz, mesh); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+	return fmt.Errorf("Unsupported mesh compression: %v", compression)
+}
+
+func writeMeshBody(w io.Writer, mesh *Mesh) error {
+	geometry := mesh.Geometry
+	vertexCount := len(geometry.Verticies) / renderer.VertexStride
+	data := []interface{}{uint8(len(meshLayout)), meshLayout, uint32(vertexCount), geometry.Verticies[:vertexCount*renderer.VertexStride]}
+	if vertexCount <= 1<<16 {
+		indicies := make([]uint16, len(geometry.Indicies))
+		for i, index := range geometry.Indicies {
+			indicies[i] = uint16(index)
+		}
+		data = append(data, uint8(2), uint32(len(indicies)), indicies)
+	} else {
+		data = append(data, uint8(4), uint32(len(geometry.Indicies)), geometry.Indicies)
+	}
+	data = append(data, uint16(len(mesh.Textures)))
+	for _, value := range data {
+		if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+
+	for _, texture := range mesh.Textures {
+		if err := writeMeshString(w, texture.Name); err != nil {
+			return err
+		}
+		if texture.Path != "" || texture.Img == nil {
+			if err := binary.Write(w, binary.LittleEndian, meshTexturePath); err != nil {
+				return err
+			}
+			if err := writeMeshString(w, filepath.ToSlash(texture.Path)); err != nil {
+				return err
+			}
+			continue
+		}
+		bounds := texture.Img.Bounds()
+		rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), texture.Img, bounds.Min, draw.Src)
+		for _, value := range []interface{}{meshTextureInline, uint32(bounds.Dx()), uint32(bounds.Dy()), rgba.Pix} {
+			if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeMeshString(w io.Writer, value string) error {
+	if len(value) > 0xffff {
+		return fmt.Errorf("Mesh string is too long: %v", len(value))
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(value))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, value)
+	return err
+}
+
+// ReadMesh - read a mesh in the .gemesh format, referenced textures are loaded by Mesh.Material
+func ReadMesh(r io.Reader) (*Mesh, error) {
+	header := make([]byte, 39)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != meshMagic {
+		return nil, errors.New("Invalid mesh file")
+	}
+	if version := binary.LittleEndian.Uint16(header[4:6]); version != meshVersion {
+		return nil, fmt.Errorf("Unsupported mesh version: %v", version)
+	}
+	mesh := &Mesh{}
+	copy(mesh.SourceHash[:], header[7:])
+
+	switch compression := MeshCompression(header[6]); compression {
+	case MESH_UNCOMPRESSED:
+		r = bufio.NewReader(r)
+	case MESH_GZIP:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return nil, fmt.Errorf("Unsupported mesh compression: %v", compression)
+	}
+
+	var attributeCount uint8
+	if err := binary.Read(r, binary.LittleEndian, &attributeCount); err != nil {
+		return nil, err
+	}
+	layout := make([][2]uint8, attributeCount)
+	var vertexCount uint32
+	if err := readMeshValues(r, layout, &vertexCount); err != nil {
+		return nil, err
+	}
+	verticies, err := readMeshVerticies(r, layout, int(vertexCount))
+	if err != nil {
+		return nil, err
+	}
+
+	var indexWidth uint8
+	var indexCount uint32
+	if err := readMeshValues(r, &indexWidth, &indexCount); err != nil {
+		return nil, err
+	}
+	if indexCount > maxMeshElements {
+		return nil, fmt.Errorf("Mesh has too many indicies: %v", indexCount)
+	}
+	indicies := make([]uint32, indexCount)
+	switch indexWidth {
+	case 2:
+		data := make([]uint16, indexCount)
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return nil, err
+		}
+		for i, index := range data {
+			indicies[i] = uint32(index)
+		}
+	case 4:
+		if err := binary.Read(r, binary.LittleEndian, indicies); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Invalid mesh index width: %v", indexWidth)
+	}
+	for _, index := range indicies {
+		if index >= vertexCount {
+			return nil, fmt.Errorf("Mesh index out of range: %v", index)
+		}
+	}
+	mesh.Geometry = renderer.CreateGeometry(indicies, verticies)
+
+	var textureCount uint16
+	if err := binary.Read(r, binary.LittleEndian, &textureCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(textureCount); i++ {
+		texture, err := readMeshTexture(r)
+		if err != nil {
+			return nil, err
+		}
+		mesh.Textures = append(mesh.Textures, texture)
+	}
+	return mesh, nil
+}
+
+func readMeshValues(r io.Reader, values ...interface{}) error {
+	for _, value := range values {
+		if err := binary.Read(r, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMeshVerticies - read verticies in the layout, converting them to the renderer vertex layout.
+// Attributes missing from the layout are left at zero, except the color which defaults to white.
+func readMeshVerticies(r io.Reader, layout [][2]uint8, vertexCount int) ([]float32, error) {
+	stride := 0
+	hasColor := false
+	for _, attribute := range layout {
+		stride += int(attribute[1])
+		hasColor = hasColor || attribute[0] == meshColor
+	}
+	if uint64(vertexCount)*uint64(stride) > maxMeshElements || uint64(vertexCount)*renderer.VertexStride > maxMeshElements {
+		return nil, fmt.Errorf("Mesh has too many verticies: %v", vertexCount)
+	}
+	data := make([]float32, vertexCount*stride)
+	if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+		return nil, err
+	}
+
+	verticies := make([]float32, vertexCount*renderer.VertexStride)
+	for i := 0; i < vertexCount; i++ {
+		vertex := verticies[i*renderer.VertexStride : (i+1)*renderer.VertexStride]
+		if !hasColor {
+			copy(vertex[meshAttributes[meshColor][0]:], []float32{1, 1, 1, 1})
+		}
+		offset := i * stride
+		for _, attribute := range layout {
+			components := int(attribute[1])
+			if destination, ok := meshAttributes[attribute[0]]; ok {
+				copy(vertex[destination[0]:destination[0]+destination[1]], data[offset:offset+components])
+			}
+			offset += components
+		}
+	}
+	return verticies, nil
+}
+
+func readMeshTexture(r io.Reader) (MeshTexture, error) {
+	var texture MeshTexture
+	var err error
+	if texture.Name, err = readMeshString(r); err != nil {
+		return texture, err
+	}
+	var kind uint8
+	if err := binary.Read(r, binary.LittleEndian, &kind); err != nil {
+		return texture, err
+	}
+	switch kind {
+	case meshTexturePath:
+		texture.Path, err = readMeshString(r)
+		return texture, err
+	case meshTextureInline:
+		var width, height uint32
+		if err := readMeshValues(r, &width, &height); err != nil {
+			return texture, err
+		}
+		if uint64(width)*uint64(height) > maxMeshElements {
+			return texture, fmt.Errorf("Mesh texture is too large: %vx%v", width, height)
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+		if _, err := io.ReadFull(r, img.Pix); err != nil {
+			return texture, err
+		}
+		texture.Img = img
+		return texture, nil
+	}
+	return texture, fmt.Errorf("Invalid mesh texture kind: %v", kind)
+}
+
+func readMeshString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return string(data), err
+}
+
+// ImportObjMeshCache - import an obj, reusing the .gemesh cache in cacheDir keyed by the hash of the obj file path,
+// and the contents of the obj and its mtl files. The cache is built when it is missing or out of date.
+func ImportObjMeshCache(filePath, cacheDir string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return ImportObjMeshCacheFS(OSFS, filePath, cacheDir)
+}
+
+// ImportObjMeshCacheFS - import an obj from a file system through the .gemesh cache, the cache is written to the os cacheDir
+func ImportObjMeshCacheFS(fsys FS, filePath, cacheDir string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return importObjMeshCache(fsys, filePath, cacheDir, func(path string) (image.Image, error) {
+		return ImportImageFS(fsys, path)
+	})
+}
+
+// importObjMeshCache - importImage loads the textures that a cached mesh references by path
+func importObjMeshCache(fsys FS, filePath, cacheDir string, importImage func(path string) (image.Image, error)) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	source, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		fmt.Printf("Error opening geometry file: %v\n", err)
+		return
+	}
+	hash := objSourceHash(fsys, filePath, source)
+	cachePath := filepath.Join(cacheDir, hex.EncodeToString(hash[:])+meshExt)
+
+	if mesh, err := readMeshFile(cachePath); err == nil && mesh.SourceHash == hash {
+		return mesh.Geometry, mesh.material(importImage), nil
+	}
+
+	mesh, err := importObjMesh(fsys, filePath)
+	if err != nil {
+		return
+	}
+	mesh.SourceHash = hash
+	if err := writeMeshFile(cachePath, mesh); err != nil {
+		log.Printf("Error writing mesh cache %v: %v\n", cachePath, err)
+	}
+	return mesh.Geometry, mesh.material(importImage), nil
+}
+
+// objSourceHash - the hash of the obj file path and contents, and the contents of each mtllib it references.
+// Texture files are not included, they are referenced by path and loaded when the material is created.
+func objSourceHash(fsys FS, filePath string, source []byte) [32]byte {
+	h := sha256.New()
+	writePart := func(data []byte) {
+		binary.Write(h, binary.LittleEndian, uint64(len(data)))
+		h.Write(data)
+	}
+	writePart([]byte(filePath))
+	writePart(source)
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) < 2 || tokens[0] != "mtllib" {
+			continue
+		}
+		// a missing mtl file is hashed as empty, both import without materials
+		mtl, _ := fs.ReadFile(fsys, objDir(filePath)+tokens[1])
+		writePart([]byte(tokens[1]))
+		writePart(mtl)
+	}
+	var hash [32]byte
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
+
+func readMeshFile(path string) (*Mesh, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadMesh(file)
+}
+
+// writeMeshFile - write the mesh to a temporary file and move it into place, so readers never see a partial cache
+func writeMeshFile(path string, mesh *Mesh) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "mesh")
+	if err != nil {
+		return err
+	}
+	err = WriteMesh(file, mesh, MESH_GZIP)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,141 @@
+package assets
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+	"github.com/walesey/go-engine/util"
+)
+
+func TestWriteReadMesh(t *testing.T) {
+	for _, compression := range []MeshCompression{MESH_UNCOMPRESSED, MESH_GZIP} {
+		geometry := renderer.CreateBox(2, 1)
+		mesh := &Mesh{
+			Geometry: geometry,
+			Textures: []MeshTexture{
+				{Name: "diffuseMap", Path: "textures/diffuse.png"},
+				{Name: "specularMap", Img: util.ImageColor(10, 20, 30, 255)},
+			},
+			SourceHash: [32]byte{1, 2, 3},
+		}
+		var buf bytes.Buffer
+		if !assert.NoError(t, WriteMesh(&buf, mesh, compression)) {
+			return
+		}
+		result, err := ReadMesh(&buf)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, geometry.Verticies, result.Geometry.Verticies)
+		assert.Equal(t, geometry.Indicies, result.Geometry.Indicies)
+		assert.Equal(t, mesh.SourceHash, result.SourceHash)
+		if assert.Len(t, result.Textures, 2) {
+			assert.Equal(t, MeshTexture{Name: "diffuseMap", Path: "textures/diffuse.png"}, result.Textures[0])
+			assert.Equal(t, color.NRGBA{10, 20, 30, 255}, result.Textures[1].Img.At(0, 0), "textures without a path are stored inline")
+		}
+	}
+
+	_, err := ReadMesh(bytes.NewReader([]byte("not a mesh file at all, just some text")))
+	assert.Error(t, err)
+
+	// corrupt counts are rejected before they are allocated
+	header := append([]byte(meshMagic), meshVersion, 0, byte(MESH_UNCOMPRESSED))
+	header = append(header, make([]byte, 32)...)
+	for _, body := range [][]byte{
+		{1, meshPosition, 3, 0xff, 0xff, 0xff, 0xff},
+		{1, meshPosition, 255, 0, 0, 0x10, 0},
+		{0, 0, 0, 0, 0, 4, 0xff, 0xff, 0xff, 0xff},
+	} {
+		_, err := ReadMesh(bytes.NewReader(append(append([]byte{}, header...), body...)))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "too many")
+		}
+	}
+}
+
+func TestImportObjMeshCache(t *testing.T) {
+	dir := writeTestObj(t)
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	objPath := filepath.Join(dir, "test.obj")
+
+	geometry, material, err := ImportObjMeshCache(objPath, cacheDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	files, _ := ioutil.ReadDir(cacheDir)
+	if !assert.Len(t, files, 1) || !assert.Equal(t, meshExt, filepath.Ext(files[0].Name())) {
+		return
+	}
+
+	cached, cachedMaterial, err := ImportObjMeshCache(objPath, cacheDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, geometry.Verticies, cached.Verticies)
+		assert.Equal(t, geometry.Indicies, cached.Indicies)
+		assert.Len(t, cachedMaterial.Textures, len(material.Textures))
+	}
+	files, _ = ioutil.ReadDir(cacheDir)
+	assert.Len(t, files, 1, "the cached mesh should be reused")
+
+	// changing the mtl rebuilds the cache
+	ioutil.WriteFile(filepath.Join(dir, "test.mtl"), []byte("newmtl red\nKd 0 1 0\nnewmtl glass\n"), 0644)
+	rebuilt, rebuiltMaterial, err := ImportObjMeshCache(objPath, cacheDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, float32(1), rebuilt.Verticies[9], "the vertex color should come from the new mtl")
+		assert.Len(t, rebuiltMaterial.Textures, 1)
+	}
+	files, _ = ioutil.ReadDir(cacheDir)
+	assert.Len(t, files, 2)
+
+	// the same source at another path has its own cache
+	copyPath := filepath.Join(dir, "copy.obj")
+	source, _ := ioutil.ReadFile(objPath)
+	ioutil.WriteFile(copyPath, source, 0644)
+	_, _, err = ImportObjMeshCache(copyPath, cacheDir)
+	assert.NoError(t, err)
+	files, _ = ioutil.ReadDir(cacheDir)
+	assert.Len(t, files, 3)
+
+	// changing the source rebuilds the cache
+	ioutil.WriteFile(objPath, []byte("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), 0644)
+	rebuilt, _, err = ImportObjMeshCache(objPath, cacheDir)
+	if assert.NoError(t, err) {
+		assert.Len(t, rebuilt.Indicies, 3)
+	}
+	files, _ = ioutil.ReadDir(cacheDir)
+	assert.Len(t, files, 4)
+}
+
+func TestAssetCacheMeshTextures(t *testing.T) {
+	dir := writeTestObj(t)
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	os.Mkdir(filepath.Join(dir, "textures"), 0755)
+	file, _ := os.Create(filepath.Join(dir, "textures", "diffuse.png"))
+	png.Encode(file, util.ImageColor(10, 20, 30, 255))
+	file.Close()
+	ioutil.WriteFile(filepath.Join(dir, "test.mtl"), []byte("newmtl red\nmap_Kd textures/diffuse.png\n"), 0644)
+
+	_, _, err := NewAssetCacheFS(DirFS(dir)).ImportObjMeshCache("test.obj", cacheDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// a cache hit loads the referenced textures through the asset cache's images
+	cache := NewAssetCacheFS(DirFS(dir))
+	img, err := cache.ImportImage("textures/diffuse.png")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, material, err := cache.ImportObjMeshCache("test.obj", cacheDir)
+	if assert.NoError(t, err) && assert.Len(t, material.Textures, 1) {
+		assert.True(t, img == material.Textures[0].Img, "the cached image should be reused")
+	}
+}
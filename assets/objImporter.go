@@ -4,13 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"log"
-	"os"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
 	"image"
 
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/walesey/go-engine/renderer"
+	"github.com/walesey/go-engine/util"
 )
 
 //vericies format : x,y,z,   nx,ny,nz,tx,ty,tz,btx,bty,btz,   u,v,  r,g,b,a
@@ -23,43 +26,131 @@ type objData struct {
 }
 
 type mtlData struct {
-	Name                  string
-	Ns, Ka, Kd, Ks, Ni, D float32
-	Illum                 int
+	Name       string
+	Ka, Kd, Ks mgl32.Vec3
+	Ns, Ni, D  float32
+	Illum      int
+	hasKs      bool
 
-	maps map[string]image.Image
+	maps  map[string]image.Image
+	paths map[string]string
 }
 
-//imports an obj from a filePath and return a Geometry
+//mtl map statements and the texture names they are loaded as
+var mtlMapNames = map[string]string{
+	"map_Kd":        "diffuseMap",
+	"map_Spec":      "specularMap",
+	"map_AO":        "aoMap",
+	"map_Disp":      "normalMap",
+	"map_Roughness": "roughnessMap",
+	"map_Metalness": "metalnessMap",
+	"map_Composite": "compositeMap",
+	"map_Glow":      "glowMap",
+}
+
+//the groups of an obj file, split by object and material
+type objFile struct {
+	groups []*objData
+	mtls   []*mtlData
+}
+
+//imports an obj from a filePath and return a Geometry.
+//The faces of every group are merged into one geometry with the material of the first group.
+//The vertex colors are the Kd and d of each group's material, Kd is ignored for materials with a diffuse map.
 func ImportObj(filePath string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	return ImportObjFS(OSFS, filePath)
+}
+
+//imports an obj from a file system, see ImportObj
+func ImportObjFS(fsys FS, filePath string) (geometry *renderer.Geometry, material *renderer.Material, err error) {
+	mesh, err := importObjMesh(fsys, filePath)
+	if err != nil {
+		return
+	}
+	return mesh.Geometry, mesh.Material(), nil
+}
+
+//imports an obj as a single mesh, texture paths are kept so the mesh can be cached
+func importObjMesh(fsys FS, filePath string) (*Mesh, error) {
+	obj, err := importObjFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	mesh := &Mesh{Geometry: renderer.CreateGeometry(make([]uint32, 0, 0), make([]float32, 0, 0))}
+	for _, group := range obj.groups {
+		renderer.CreateGeometry(group.Indicies, group.Vertices).Optimize(mesh.Geometry, mgl32.Ident4())
+	}
+	if mtl := obj.defaultMtl(); mtl != nil {
+		mesh.Textures = mtl.meshTextures()
+	}
+	return mesh, nil
+}
+
+//imports an obj from a filePath and returns a Node with a child node for each object/material group.
+//The vertex colors are set from the group materials, see ImportObj.
+func ImportObjNode(filePath string) (*renderer.Node, error) {
+	return ImportObjNodeFS(OSFS, filePath)
+}
 
-	obj := &objData{Indicies: make([]uint32, 0, 0), Vertices: make([]float32, 0, 0)}
+//imports an obj from a file system, see ImportObjNode
+func ImportObjNodeFS(fsys FS, filePath string) (*renderer.Node, error) {
+	obj, err := importObjFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	node := renderer.NewNode()
+	materials := make(map[*mtlData]*renderer.Material)
+	for _, group := range obj.groups {
+		groupNode := renderer.NewNode()
+		if group.Mtl != nil {
+			material, ok := materials[group.Mtl]
+			if !ok {
+				material = group.Mtl.material()
+				materials[group.Mtl] = material
+			}
+			groupNode.Material = material
+			if group.Mtl.D < 1 {
+				groupNode.RendererParams = renderer.NewRendererParams()
+				groupNode.RendererParams.Transparency = renderer.NON_EMISSIVE
+				groupNode.RendererParams.CastShadows = false
+			}
+		}
+		groupNode.Add(renderer.CreateGeometry(group.Indicies, group.Vertices))
+		node.Add(groupNode)
+	}
+	return node, nil
+}
+
+func importObjFile(fsys FS, filePath string) (*objFile, error) {
+	obj := &objFile{}
 	vertexList := make([]float32, 0, 0)
 	uvList := make([]float32, 0, 0)
 	normalList := make([]float32, 0, 0)
 
-	//split the file name from the file path
-	filePathTokens := strings.Split(strings.Replace(filePath, "\\", "/", -1), "/")
-	fileName := filePathTokens[len(filePathTokens)-1]
-	path := strings.TrimRight(filePath, fileName)
+	path := objDir(filePath)
 
 	//open the file and read all lines
-	file, err := os.Open(filePath)
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		fmt.Printf("Error opening geometry file: %v\n", err)
-		return
+		return nil, err
 	}
 	defer file.Close()
 
-	var mtlErr error
+	var name string
+	var mtl, groupMtl *mtlData
+	var group *objData
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
 		tokens := strings.Fields(line)
 		if len(tokens) > 0 {
 			dataType := tokens[0]
-			if dataType == "o" { //sub mesh
-				obj.Name = tokens[1]
+			if (dataType == "o" || dataType == "g") && len(tokens) > 1 { //sub mesh
+				name = tokens[1]
+				group = nil
 			} else if dataType == "v" { //xyz vertex
 				vertexList = append(vertexList, stf(tokens[1]), stf(tokens[2]), stf(tokens[3]))
 			} else if dataType == "vt" { //uv coord
@@ -67,31 +158,119 @@ func ImportObj(filePath string) (geometry *renderer.Geometry, material *renderer
 			} else if dataType == "vn" { //xyz vertex normal
 				normalList = append(normalList, stf(tokens[1]), stf(tokens[2]), stf(tokens[3]))
 			} else if dataType == "f" { // v/t/n face
-				obj.processFace(line, vertexList, uvList, normalList)
-			} else if dataType == "mtllib" {
-				obj.Mtl, mtlErr = importMTL(path, tokens[1])
-			} else if dataType == "usemtl" { //mtl material
-				//TODO: multiple mtls
+				if group == nil {
+					group = obj.group(name, groupMtl)
+				}
+				group.processFace(line, vertexList, uvList, normalList)
+			} else if dataType == "mtllib" && len(tokens) > 1 {
+				if mtls, err := importMTL(fsys, path, tokens[1]); err == nil {
+					obj.mtls = append(obj.mtls, mtls...)
+				}
+			} else if dataType == "usemtl" && len(tokens) > 1 { //mtl material
+				if mtl = obj.mtl(tokens[1]); mtl != groupMtl {
+					groupMtl = mtl
+					group = nil
+				}
 			}
 		}
 	}
 
-	geometry = renderer.CreateGeometry(obj.Indicies, obj.Vertices)
-	if mtlErr == nil && obj.Mtl != nil {
-		textures := []*renderer.Texture{}
-		for key, img := range obj.Mtl.maps {
-			textures = append(textures, renderer.NewTexture(key, img, true))
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("Error loading geometry: %v\n", err)
+		return nil, err
+	}
+	return obj, nil
+}
+
+//the directory of an obj file path, including the trailing separator. mtl files are relative to it.
+func objDir(filePath string) string {
+	//split the file name from the file path
+	filePathTokens := strings.Split(strings.Replace(filePath, "\\", "/", -1), "/")
+	fileName := filePathTokens[len(filePathTokens)-1]
+	return strings.TrimSuffix(filePath, fileName)
+}
+
+//returns the group for the object and material, faces of a group can be split across the file
+func (obj *objFile) group(name string, mtl *mtlData) *objData {
+	for _, group := range obj.groups {
+		if group.Name == name && group.Mtl == mtl {
+			return group
+		}
+	}
+	group := &objData{Name: name, Indicies: make([]uint32, 0, 0), Vertices: make([]float32, 0, 0), Mtl: mtl}
+	obj.groups = append(obj.groups, group)
+	return group
+}
+
+func (obj *objFile) mtl(name string) *mtlData {
+	for _, mtl := range obj.mtls {
+		if mtl.Name == name {
+			return mtl
 		}
-		material = renderer.NewMaterial(textures...)
 	}
+	log.Printf("Missing obj material: %v\n", name)
+	return nil
+}
 
-	if err = scanner.Err(); err != nil {
-		fmt.Printf("Error loading geometry: %v\n", err)
+//the material of the first group, otherwise the last material in the mtl files
+func (obj *objFile) defaultMtl() *mtlData {
+	for _, group := range obj.groups {
+		if group.Mtl != nil {
+			return group.Mtl
+		}
+	}
+	if len(obj.mtls) > 0 {
+		return obj.mtls[len(obj.mtls)-1]
+	}
+	return nil
+}
+
+//creates a material from the mtl maps
+func (mtl *mtlData) material() *renderer.Material {
+	mesh := Mesh{Textures: mtl.meshTextures()}
+	return mesh.Material()
+}
+
+//the textures of the mtl sorted by name.
+//Ks and Ns are used as specular and roughness maps when the mtl has them but no map for them.
+func (mtl *mtlData) meshTextures() []MeshTexture {
+	var textures []MeshTexture
+	for key, img := range mtl.maps {
+		if img != nil {
+			textures = append(textures, MeshTexture{Name: key, Path: mtl.paths[key], Img: img})
+		}
+	}
+	if mtl.maps["diffuseMap"] == nil {
+		textures = append(textures, MeshTexture{Name: "diffuseMap", Img: util.ImageColor(255, 255, 255, 255)})
+	}
+	if mtl.maps["specularMap"] == nil && mtl.hasKs {
+		textures = append(textures, MeshTexture{Name: "specularMap", Img: util.ImageColor(colorByte(mtl.Ks[0]), colorByte(mtl.Ks[1]), colorByte(mtl.Ks[2]), 255)})
+	}
+	if mtl.maps["roughnessMap"] == nil && mtl.Ns > 0 {
+		roughness := colorByte(float32(math.Sqrt(2 / float64(mtl.Ns+2))))
+		textures = append(textures, MeshTexture{Name: "roughnessMap", Img: util.ImageColor(roughness, roughness, roughness, 255)})
+	}
+	sort.Slice(textures, func(i, j int) bool { return textures[i].Name < textures[j].Name })
+	return textures
+}
+
+//the vertex color of the material, Kd is only used when there is no diffuse map
+func (mtl *mtlData) color() (r, g, b, a float32) {
+	r, g, b, a = 1.0, 1.0, 1.0, 1.0
+	if mtl == nil {
 		return
 	}
+	if mtl.maps["diffuseMap"] == nil {
+		r, g, b = mtl.Kd[0], mtl.Kd[1], mtl.Kd[2]
+	}
+	a = mtl.D
 	return
 }
 
+func colorByte(value float32) uint8 {
+	return uint8(mgl32.Clamp(value*255+0.5, 0, 255))
+}
+
 //returns corresponding index (0,1,2...)
 func (obj *objData) pushVert(x, y, z, nx, ny, nz, u, v, r, g, b, a float32) uint32 {
 	obj.Vertices = append(obj.Vertices, x, y, z, nx, ny, nz, u, v, r, g, b, a)
@@ -138,7 +317,8 @@ func (obj *objData) processFaceVertex(token string, vertexList, uvList, normalLi
 		nz = normalList[index+2]
 	}
 
-	return obj.pushVert(vx, vy, vz, nx, ny, nz, vtx, vty, 1.0, 1.0, 1.0, 1.0)
+	r, g, b, a := obj.Mtl.color()
+	return obj.pushVert(vx, vy, vz, nx, ny, nz, vtx, vty, r, g, b, a)
 }
 
 //Processes a polygonal face by splitting it into triangles
@@ -168,11 +348,12 @@ func (obj *objData) processFace(line string, vertexList, uvList, normalList []fl
 	}
 }
 
-//Returns mtl object data type
-func importMTL(filePath, fileName string) (*mtlData, error) {
-	mtl := &mtlData{maps: make(map[string]image.Image)}
+//Returns the materials defined in an mtl file
+func importMTL(fsys FS, filePath, fileName string) ([]*mtlData, error) {
+	var mtls []*mtlData
+	var mtl *mtlData
 
-	file, err := os.Open(filePath + fileName)
+	file, err := fsys.Open(filePath + fileName)
 	if err != nil {
 		fmt.Printf("Error opening material file: %v\n", err)
 		return nil, err
@@ -183,31 +364,39 @@ func importMTL(filePath, fileName string) (*mtlData, error) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		tokens := strings.Fields(line)
-		if len(tokens) > 0 {
+		if len(tokens) > 1 {
 			dataType := tokens[0]
+			if dataType == "newmtl" {
+				mtl = &mtlData{Name: tokens[1], Kd: mgl32.Vec3{1, 1, 1}, D: 1, maps: make(map[string]image.Image), paths: make(map[string]string)}
+				mtls = append(mtls, mtl)
+				continue
+			}
+			if mtl == nil {
+				continue
+			}
 			var err error = nil
 			switch dataType {
-			case "newmtl":
-				mtl.Name = tokens[1]
 			case "Ns":
 				mtl.Ns = stf(tokens[1])
-			//TODO: Other mtl variables
-			case "map_Kd":
-				mtl.maps["diffuseMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Spec":
-				mtl.maps["specularMap"], err = ImportImage(filePath + tokens[1])
-			case "map_AO":
-				mtl.maps["aoMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Disp":
-				mtl.maps["normalMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Roughness":
-				mtl.maps["roughnessMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Metalness":
-				mtl.maps["metalnessMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Composite":
-				mtl.maps["compositeMap"], err = ImportImage(filePath + tokens[1])
-			case "map_Glow":
-				mtl.maps["glowMap"], err = ImportImage(filePath + tokens[1])
+			case "Ni":
+				mtl.Ni = stf(tokens[1])
+			case "d":
+				mtl.D = stf(tokens[1])
+			case "Tr":
+				mtl.D = 1 - stf(tokens[1])
+			case "illum":
+				mtl.Illum = int(sti(tokens[1]))
+			case "Ka":
+				mtl.Ka = stv(tokens[1:])
+			case "Kd":
+				mtl.Kd = stv(tokens[1:])
+			case "Ks":
+				mtl.Ks, mtl.hasKs = stv(tokens[1:]), true
+			default:
+				if mapName, ok := mtlMapNames[dataType]; ok {
+					mtl.paths[mapName] = filePath + tokens[1]
+					mtl.maps[mapName], err = ImportImageFS(fsys, mtl.paths[mapName])
+				}
 			}
 			if err != nil {
 				log.Printf("Error parsing mtl data %v: %v\n", dataType, err)
@@ -219,7 +408,7 @@ func importMTL(filePath, fileName string) (*mtlData, error) {
 		return nil, err
 	}
 
-	return mtl, nil
+	return mtls, nil
 }
 
 //string to float32
@@ -231,6 +420,15 @@ func stf(s string) float32 {
 	return (float32)(f)
 }
 
+//string tokens to a color, a single value is used for every channel
+func stv(tokens []string) mgl32.Vec3 {
+	if len(tokens) < 3 {
+		v := stf(tokens[0])
+		return mgl32.Vec3{v, v, v}
+	}
+	return mgl32.Vec3{stf(tokens[0]), stf(tokens[1]), stf(tokens[2])}
+}
+
 //string to int32
 func sti(s string) int32 {
 	i, err := strconv.ParseInt(s, 10, 32)
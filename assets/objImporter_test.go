@@ -0,0 +1,93 @@
+package assets
+
+import (
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+)
+
+const testObj = `mtllib test.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+o first
+usemtl red
+f 1 2 3
+usemtl glass
+f 1 3 4
+o second
+usemtl red
+f 1 2 3 4
+`
+
+const testMtl = `newmtl red
+Kd 1 0 0
+Ks 0.5 0.5 0.5
+Ns 98
+newmtl glass
+d 0.5
+`
+
+func writeTestObj(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "obj")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	ioutil.WriteFile(filepath.Join(dir, "test.obj"), []byte(testObj), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "test.mtl"), []byte(testMtl), 0644)
+	return dir
+}
+
+func TestImportObjNode(t *testing.T) {
+	dir := writeTestObj(t)
+	defer os.RemoveAll(dir)
+
+	node, err := ImportObjNode(filepath.Join(dir, "test.obj"))
+	if !assert.NoError(t, err) || !assert.Len(t, node.Children(), 3) {
+		return
+	}
+	first, glass, second := node.Children()[0].(*renderer.Node), node.Children()[1].(*renderer.Node), node.Children()[2].(*renderer.Node)
+	assert.Equal(t, first.Material, second.Material, "groups with the same material share it")
+	assert.NotEqual(t, first.Material, glass.Material)
+
+	geometry := first.Children()[0].(*renderer.Geometry)
+	assert.Len(t, geometry.Indicies, 3)
+	assert.Equal(t, []float32{1, 0, 0, 1}, geometry.Verticies[8:12], "Kd is used as the vertex color")
+	assert.Len(t, second.Children()[0].(*renderer.Geometry).Indicies, 6)
+
+	textures := make(map[string]*renderer.Texture)
+	for _, texture := range first.Material.Textures {
+		textures[texture.TextureName] = texture
+	}
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, textures["diffuseMap"].Img.At(0, 0))
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, textures["specularMap"].Img.At(0, 0))
+	assert.Equal(t, color.RGBA{36, 36, 36, 255}, textures["roughnessMap"].Img.At(0, 0))
+	assert.Nil(t, first.RendererParams)
+
+	assert.Equal(t, []float32{1, 1, 1, 0.5}, glass.Children()[0].(*renderer.Geometry).Verticies[8:12], "d is used as the vertex alpha")
+	if assert.Len(t, glass.Material.Textures, 1, "Ks and Ns are only used when the mtl has them") {
+		assert.Equal(t, "diffuseMap", glass.Material.Textures[0].TextureName)
+	}
+	if assert.NotNil(t, glass.RendererParams) {
+		assert.Equal(t, renderer.NON_EMISSIVE, glass.RendererParams.Transparency)
+	}
+}
+
+func TestImportObj(t *testing.T) {
+	dir := writeTestObj(t)
+	defer os.RemoveAll(dir)
+
+	geometry, material, err := ImportObj(filepath.Join(dir, "test.obj"))
+	if assert.NoError(t, err) {
+		assert.Len(t, geometry.Indicies, 12, "every group is merged into the geometry")
+		assert.Len(t, geometry.Verticies, 12*renderer.VertexStride)
+		assert.Equal(t, uint32(3), geometry.Indicies[3])
+		assert.Len(t, material.Textures, 3)
+	}
+}
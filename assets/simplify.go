@@ -0,0 +1,340 @@
+package assets
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// GenerateLODs - simplified copies of the geometry, one for each ratio of the original triangle count
+func GenerateLODs(geometry *renderer.Geometry, ratios ...float32) []*renderer.Geometry {
+	lods := make([]*renderer.Geometry, len(ratios))
+	for i, ratio := range ratios {
+		lods[i] = SimplifyGeometry(geometry, int(float32(len(geometry.Indicies)/3)*ratio))
+	}
+	return lods
+}
+
+// LODNodeFromGeometry - a distance based lod node using the geometry up to the first distance,
+// and simplified copies with half as many triangles as the previous level for each further distance.
+func LODNodeFromGeometry(geometry *renderer.Geometry, distances ...float32) *renderer.LODNode {
+	lod := renderer.NewLODNode(renderer.LOD_DISTANCE)
+	level := geometry
+	for i, distance := range distances {
+		if i > 0 {
+			level = SimplifyGeometry(level, len(level.Indicies)/6)
+		}
+		lod.AddLevel(level, distance)
+	}
+	return lod
+}
+
+// SimplifyGeometry - a copy of the geometry reduced to at most targetTriangles triangles by quadric error edge collapse.
+// Normals, uvs and colors are interpolated along collapsed edges.
+// Verticies on open edges or uv/normal seams (verticies that share a position) are never moved,
+// so the simplified mesh has the same outline and no cracks along seams, but may not reach the target.
+func SimplifyGeometry(geometry *renderer.Geometry, targetTriangles int) *renderer.Geometry {
+	s := newSimplifier(geometry)
+	s.simplify(targetTriangles)
+	return s.geometry()
+}
+
+// quadric - the symmetric 4x4 error matrix a b c d / b e f g / c f h i / d g i j
+type quadric [10]float64
+
+func planeQuadric(normal mgl64.Vec3, d float64) quadric {
+	a, b, c := normal[0], normal[1], normal[2]
+	return quadric{a * a, a * b, a * c, a * d, b * b, b * c, b * d, c * c, c * d, d * d}
+}
+
+func (q quadric) add(other quadric) quadric {
+	for i := range q {
+		q[i] += other[i]
+	}
+	return q
+}
+
+func (q quadric) mul(f float64) quadric {
+	for i := range q {
+		q[i] *= f
+	}
+	return q
+}
+
+func (q quadric) error(v mgl64.Vec3) float64 {
+	x, y, z := v[0], v[1], v[2]
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+// optimal - the position with the least error, if the quadric is not singular
+func (q quadric) optimal() (mgl64.Vec3, bool) {
+	m := mgl64.Mat3{q[0], q[1], q[2], q[1], q[4], q[5], q[2], q[5], q[7]}
+	if math.Abs(m.Det()) < 1e-12 {
+		return mgl64.Vec3{}, false
+	}
+	return m.Inv().Mul3x1(mgl64.Vec3{-q[3], -q[6], -q[8]}), true
+}
+
+type collapse struct {
+	cost       float64
+	from, to   int
+	position   mgl64.Vec3
+	fromV, toV int
+}
+
+type collapseHeap []*collapse
+
+func (h collapseHeap) Len() int            { return len(h) }
+func (h collapseHeap) Less(i, j int) bool  { return h[i].cost < h[j].cost }
+func (h collapseHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *collapseHeap) Push(x interface{}) { *h = append(*h, x.(*collapse)) }
+func (h *collapseHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type simplifier struct {
+	verticies [][renderer.VertexStride]float32
+	quadrics  []quadric
+	locked    []bool
+	removed   []bool
+	version   []int
+	vertTris  [][]int
+	triangles [][3]int
+	triAlive  []bool
+	liveTris  int
+	queue     collapseHeap
+}
+
+func newSimplifier(geometry *renderer.Geometry) *simplifier {
+	vertexCount := len(geometry.Verticies) / renderer.VertexStride
+	s := &simplifier{
+		verticies: make([][renderer.VertexStride]float32, vertexCount),
+		quadrics:  make([]quadric, vertexCount),
+		locked:    make([]bool, vertexCount),
+		removed:   make([]bool, vertexCount),
+		version:   make([]int, vertexCount),
+		vertTris:  make([][]int, vertexCount),
+	}
+	positions := make(map[mgl32.Vec3]int)
+	for i := range s.verticies {
+		copy(s.verticies[i][:], geometry.Verticies[i*renderer.VertexStride:])
+		p := s.position32(i)
+		if other, ok := positions[p]; ok {
+			s.locked[i], s.locked[other] = true, true
+		}
+		positions[p] = i
+	}
+
+	edgeCount := make(map[[2]int]int)
+	for i := 0; i+2 < len(geometry.Indicies); i += 3 {
+		tri := [3]int{int(geometry.Indicies[i]), int(geometry.Indicies[i+1]), int(geometry.Indicies[i+2])}
+		if tri[0] >= vertexCount || tri[1] >= vertexCount || tri[2] >= vertexCount ||
+			tri[0] == tri[1] || tri[1] == tri[2] || tri[2] == tri[0] {
+			continue
+		}
+		index := len(s.triangles)
+		s.triangles = append(s.triangles, tri)
+		s.triAlive = append(s.triAlive, true)
+		s.liveTris++
+
+		a, b, c := s.position(tri[0]), s.position(tri[1]), s.position(tri[2])
+		cross := b.Sub(a).Cross(c.Sub(a))
+		area := cross.Len() / 2
+		var q quadric
+		if area > 0 {
+			normal := cross.Normalize()
+			q = planeQuadric(normal, -normal.Dot(a)).mul(area)
+		}
+		for j, v := range tri {
+			s.quadrics[v] = s.quadrics[v].add(q)
+			s.vertTris[v] = append(s.vertTris[v], index)
+			edgeCount[edgeKey(v, tri[(j+1)%3])]++
+		}
+	}
+
+	// verticies on open edges are locked to preserve the outline of the mesh
+	for edge, count := range edgeCount {
+		if count == 1 {
+			s.locked[edge[0]], s.locked[edge[1]] = true, true
+		}
+	}
+	for edge := range edgeCount {
+		s.pushCollapse(edge[0], edge[1])
+	}
+	return s
+}
+
+func edgeKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+func (s *simplifier) position32(v int) mgl32.Vec3 {
+	return mgl32.Vec3{s.verticies[v][0], s.verticies[v][1], s.verticies[v][2]}
+}
+
+func (s *simplifier) position(v int) mgl64.Vec3 {
+	return mgl64.Vec3{float64(s.verticies[v][0]), float64(s.verticies[v][1]), float64(s.verticies[v][2])}
+}
+
+// pushCollapse - queue the cheapest collapse of the edge, moving an unlocked vertex into the other
+func (s *simplifier) pushCollapse(a, b int) {
+	if s.locked[a] && s.locked[b] {
+		return
+	}
+	from, to := a, b
+	if s.locked[from] {
+		from, to = to, from
+	}
+	q := s.quadrics[from].add(s.quadrics[to])
+
+	candidates := []mgl64.Vec3{s.position(to)}
+	if !s.locked[to] {
+		candidates = append(candidates, s.position(from), s.position(from).Add(s.position(to)).Mul(0.5))
+		if optimal, ok := q.optimal(); ok {
+			candidates = append(candidates, optimal)
+		}
+	}
+	best := &collapse{cost: math.Inf(1), from: from, to: to, fromV: s.version[from], toV: s.version[to]}
+	for _, candidate := range candidates {
+		if cost := q.error(candidate); cost < best.cost {
+			best.cost, best.position = cost, candidate
+		}
+	}
+	heap.Push(&s.queue, best)
+}
+
+func (s *simplifier) simplify(targetTriangles int) {
+	for s.liveTris > targetTriangles && s.queue.Len() > 0 {
+		c := heap.Pop(&s.queue).(*collapse)
+		if s.removed[c.from] || s.removed[c.to] || s.version[c.from] != c.fromV || s.version[c.to] != c.toV {
+			continue
+		}
+		if s.flips(c.from, c.to, c.position) || s.flips(c.to, c.from, c.position) {
+			continue
+		}
+		s.collapse(c)
+	}
+}
+
+// flips - true if moving v to position would flip a triangle around v that is not removed by collapsing the edge
+func (s *simplifier) flips(v, other int, position mgl64.Vec3) bool {
+	for _, t := range s.vertTris[v] {
+		if !s.triAlive[t] {
+			continue
+		}
+		tri := s.triangles[t]
+		if tri[0] == other || tri[1] == other || tri[2] == other {
+			continue
+		}
+		var before, after [3]mgl64.Vec3
+		for i, index := range tri {
+			before[i], after[i] = s.position(index), s.position(index)
+			if index == v {
+				after[i] = position
+			}
+		}
+		normalBefore := before[1].Sub(before[0]).Cross(before[2].Sub(before[0]))
+		normalAfter := after[1].Sub(after[0]).Cross(after[2].Sub(after[0]))
+		if normalBefore.Dot(normalAfter) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *simplifier) collapse(c *collapse) {
+	from, to := c.from, c.to
+	if !s.locked[to] {
+		a, b := s.position(from), s.position(to)
+		var t float64
+		if edge := b.Sub(a); edge.Dot(edge) > 0 {
+			t = math.Max(0, math.Min(1, c.position.Sub(a).Dot(edge)/edge.Dot(edge)))
+		}
+		s.verticies[to] = lerpVertex(s.verticies[from], s.verticies[to], float32(t))
+		s.verticies[to][0], s.verticies[to][1], s.verticies[to][2] = float32(c.position[0]), float32(c.position[1]), float32(c.position[2])
+	}
+	s.quadrics[to] = s.quadrics[to].add(s.quadrics[from])
+	s.removed[from] = true
+	s.version[to]++
+
+	for _, t := range s.vertTris[from] {
+		if !s.triAlive[t] {
+			continue
+		}
+		tri := &s.triangles[t]
+		for i := range tri {
+			if tri[i] == from {
+				tri[i] = to
+			}
+		}
+		if tri[0] == tri[1] || tri[1] == tri[2] || tri[2] == tri[0] {
+			s.triAlive[t] = false
+			s.liveTris--
+			continue
+		}
+		s.vertTris[to] = append(s.vertTris[to], t)
+	}
+	s.vertTris[from] = nil
+
+	neighbours := make(map[int]bool)
+	for _, t := range s.vertTris[to] {
+		if !s.triAlive[t] {
+			continue
+		}
+		for _, v := range s.triangles[t] {
+			if v != to {
+				neighbours[v] = true
+			}
+		}
+	}
+	for v := range neighbours {
+		s.pushCollapse(to, v)
+	}
+}
+
+// lerpVertex - interpolate all of the vertex attributes, keeping the normal unit length
+func lerpVertex(a, b [renderer.VertexStride]float32, t float32) [renderer.VertexStride]float32 {
+	var result [renderer.VertexStride]float32
+	for i := range result {
+		result[i] = a[i] + (b[i]-a[i])*t
+	}
+	normal := mgl32.Vec3{result[3], result[4], result[5]}
+	if normal.Len() > 0 {
+		normal = normal.Normalize()
+		result[3], result[4], result[5] = normal[0], normal[1], normal[2]
+	}
+	return result
+}
+
+// geometry - the remaining triangles, with unused verticies removed
+func (s *simplifier) geometry() *renderer.Geometry {
+	remap := make(map[int]uint32)
+	var indicies []uint32
+	var verticies []float32
+	for t, tri := range s.triangles {
+		if !s.triAlive[t] {
+			continue
+		}
+		for _, v := range tri {
+			index, ok := remap[v]
+			if !ok {
+				index = uint32(len(verticies) / renderer.VertexStride)
+				remap[v] = index
+				verticies = append(verticies, s.verticies[v][:]...)
+			}
+			indicies = append(indicies, index)
+		}
+	}
+	return renderer.CreateGeometry(indicies, verticies)
+}
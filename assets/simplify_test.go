@@ -0,0 +1,67 @@
+package assets
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// gridGeometry - a size x size grid of quads in the xy plane, with uvs of x/size, y/size and heights from the height function
+func gridGeometry(size int, height func(x, y float32) float32) *renderer.Geometry {
+	var verticies []float32
+	var indicies []uint32
+	for y := 0; y <= size; y++ {
+		for x := 0; x <= size; x++ {
+			fx, fy := float32(x), float32(y)
+			verticies = append(verticies, fx, fy, height(fx, fy), 0, 0, 1, fx/float32(size), fy/float32(size), 1, 1, 1, 1)
+		}
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			i := uint32(y*(size+1) + x)
+			row := uint32(size + 1)
+			indicies = append(indicies, i, i+1, i+row+1, i+row+1, i+row, i)
+		}
+	}
+	return renderer.CreateGeometry(indicies, verticies)
+}
+
+func TestSimplifyFlatGeometry(t *testing.T) {
+	flat := func(x, y float32) float32 { return 0 }
+	geometry := gridGeometry(16, flat)
+	simplified := SimplifyGeometry(geometry, 100)
+
+	assert.True(t, len(simplified.Indicies)/3 <= 100, "%v triangles", len(simplified.Indicies)/3)
+	assert.Equal(t, geometry.Bounds(), simplified.Bounds())
+	for i := 0; i < len(simplified.Verticies); i += renderer.VertexStride {
+		v := simplified.Verticies[i : i+renderer.VertexStride]
+		assert.EqualValues(t, 0, v[2])
+		assert.True(t, mgl32.Vec3{v[3], v[4], v[5]}.ApproxEqual(mgl32.Vec3{0, 0, 1}))
+		assert.InDelta(t, v[0]/16, v[6], 0.0001, "u")
+		assert.InDelta(t, v[1]/16, v[7], 0.0001, "v")
+	}
+}
+
+func TestSimplifyCurvedGeometry(t *testing.T) {
+	wave := func(x, y float32) float32 { return float32(math.Sin(float64(x)/3)) * 2 }
+	geometry := gridGeometry(16, wave)
+	lods := GenerateLODs(geometry, 0.5, 0.25)
+
+	previous := len(geometry.Indicies)
+	for _, lod := range lods {
+		assert.True(t, len(lod.Indicies) < previous)
+		previous = len(lod.Indicies)
+		// verticies stay close to the surface
+		for i := 0; i < len(lod.Verticies); i += renderer.VertexStride {
+			assert.InDelta(t, wave(lod.Verticies[i], 0), lod.Verticies[i+2], 0.5)
+		}
+	}
+	assert.Equal(t, 128, len(lods[1].Indicies)/3)
+
+	lod := LODNodeFromGeometry(geometry, 10, 20, 40)
+	assert.Equal(t, geometry, lod.Level(0))
+	assert.Equal(t, len(geometry.Indicies)/4, len(lod.Level(2).(*renderer.Geometry).Indicies))
+}
@@ -21,6 +21,8 @@ import (
 
 type Editor struct {
 	assetDir              string
+	fsys                  assets.FS
+	assetCache            *assets.AssetCache
 	renderer              renderer.Renderer
 	gameEngine            engine.Engine
 	currentMap            *editorModels.MapModel
@@ -43,6 +45,8 @@ type Editor struct {
 func New(assetDir string) *Editor {
 	return &Editor{
 		assetDir:    assetDir,
+		fsys:        assets.OSFS,
+		assetCache:  assets.NewAssetCache(),
 		uiAssets:    ui.NewHtmlAssets(),
 		rootMapNode: renderer.NewNode(),
 		currentMap: &editorModels.MapModel{
@@ -53,6 +57,15 @@ func New(assetDir string) *Editor {
 	}
 }
 
+// UseFS - browse and load maps and geometries from the file system, eg. an asset pack or a mod overlay
+func (e *Editor) UseFS(fsys assets.FS) {
+	e.fsys = fsys
+	e.assetCache = assets.NewAssetCacheFS(fsys)
+	if e.fileBrowser != nil {
+		e.fileBrowser.fsys = fsys
+	}
+}
+
 func (e *Editor) Start() {
 
 	glRenderer := opengl.NewOpenglRenderer("GoEngine Editor", 0, 0, false)
@@ -2,11 +2,11 @@ package editor
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
 	"strings"
 
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/assets"
 	"github.com/walesey/go-engine/glfwController"
 	"github.com/walesey/go-engine/ui"
 )
@@ -14,6 +14,7 @@ import (
 const maxFileDisplayed = 37
 
 type FileBrowser struct {
+	fsys            assets.FS
 	window          *ui.Window
 	assets          ui.HtmlAssets
 	callback        func(filePath string)
@@ -74,6 +75,7 @@ func (e *Editor) openFileBrowser(heading string, callback func(filePath string),
 		window.Tabs, _ = ui.LoadHTML(container, strings.NewReader(fileBrowserHtml), strings.NewReader(globalCss), e.uiAssets)
 
 		e.fileBrowser = &FileBrowser{
+			fsys:         e.fsys,
 			window:       window,
 			assets:       e.uiAssets,
 			callback:     callback,
@@ -121,9 +123,11 @@ func (fb *FileBrowser) UpdateFileSystem() {
 	fileCounter := 0
 	inClosedDir := false
 	closedDepth := 0
-	filepath.Walk(fb.root, func(path string, info os.FileInfo, err error) error {
-		usePath := strings.Replace(path, "\\", "/", -1)
-		depth := strings.Count(usePath, "/")
+	fs.WalkDir(fb.fsys, fb.root, func(path string, info fs.DirEntry, err error) error {
+		if info == nil {
+			return nil
+		}
+		depth := strings.Count(path, "/")
 		if inClosedDir {
 			if depth > closedDepth {
 				return nil
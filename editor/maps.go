@@ -30,7 +30,7 @@ func (e *Editor) saveMap(filepath string) {
 }
 
 func (e *Editor) loadMap(path string) {
-	e.currentMap = assets.LoadMap(path)
+	e.currentMap = assets.LoadMapFS(e.fsys, path)
 	e.refreshMap()
 	e.overviewMenu.updateTree(e.currentMap)
 }
@@ -44,7 +44,7 @@ func (e *Editor) refreshMap() {
 	e.setProgressBar(0)
 	e.setProgressTime("Loading Map...")
 
-	mapLoadChan := loadMapToNode(e.currentMap.Root)
+	mapLoadChan := loadMapToNode(e.assetCache, e.currentMap.Root)
 
 	var loader engine.Updatable
 	loader = engine.UpdatableFunc(func(dt float64) {
@@ -65,7 +65,7 @@ func (e *Editor) refreshMap() {
 	e.gameEngine.AddUpdatable(loader)
 }
 
-func loadMapToNode(model *editorModels.NodeModel) chan MapLoadUpdate {
+func loadMapToNode(cache *assets.AssetCache, model *editorModels.NodeModel) chan MapLoadUpdate {
 
 	out := make(chan MapLoadUpdate)
 	geomsLoaded := 0
@@ -74,7 +74,7 @@ func loadMapToNode(model *editorModels.NodeModel) chan MapLoadUpdate {
 	updateNode = func(srcModel *editorModels.NodeModel, destNode *renderer.Node) {
 		srcModel.SetNode(destNode)
 		if srcModel.Geometry != nil {
-			geometry, material, err := assets.ImportObjCached(*srcModel.Geometry)
+			geometry, material, err := cache.ImportObj(*srcModel.Geometry)
 			if err == nil {
 				destNode.Material = material
 				destNode.Add(geometry)
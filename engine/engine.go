@@ -197,6 +197,7 @@ func NewEngine(r renderer.Renderer) Engine {
 	engine.transparentNode.RendererParams = renderer.NewRendererParams()
 	engine.transparentNode.RendererParams.CullBackface = false
 	engine.transparentNode.RendererParams.DepthMask = false
+	engine.transparentNode.RendererParams.CastShadows = false
 	sceneGraph.AddTransparent(engine.transparentNode)
 
 	engine.orthoNode.RendererParams = &renderer.RendererParams{
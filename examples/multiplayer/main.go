@@ -75,12 +75,21 @@ func main() {
 	//Networked Game events
 	network.ClientJoinedEvent(func(clientId string) {
 		fmt.Println("client joined, clientId: ", clientId)
-		network.TriggerOnServerAndClients("spawn", serializer.SerializeArgs(clientId, startingPosition))
+		network.TriggerOnServerAndClients("spawn", serializer.SerializeArgs(clientId, startingPosition), networking.ReliableOrdered)
 		for _, player := range players {
-			network.TriggerEvent("spawn", clientId, serializer.SerializeArgs(player.clientId, player.position))
+			network.TriggerEvent("spawn", clientId, serializer.SerializeArgs(player.clientId, player.position), networking.ReliableOrdered)
 		}
 	})
 
+	network.ClientLeftEvent(func(clientId, reason string) {
+		fmt.Println("client left, clientId: ", clientId, " reason: ", reason)
+		network.TriggerOnServerAndClients("despawn", serializer.SerializeArgs(clientId), networking.ReliableOrdered)
+	})
+
+	network.DisconnectedEvent(func(reason string) {
+		fmt.Println("disconnected from server: ", reason)
+	})
+
 	network.RegisterEvent("spawn", func(clientId string, data []byte) {
 		buf := bytes.NewBuffer(data)
 		playerID := serializer.Stringfrombytes(buf)
@@ -100,6 +109,15 @@ func main() {
 		}
 	})
 
+	network.RegisterEvent("despawn", func(clientId string, data []byte) {
+		playerID := serializer.Stringfrombytes(bytes.NewBuffer(data))
+		if player, ok := players[playerID]; ok {
+			delete(players, playerID)
+			gameEngine.RemoveUpdatable(player)
+			gameEngine.RemoveSpatial(player.node, true)
+		}
+	})
+
 	network.RegisterEvent("move", func(clientId string, data []byte) {
 		buf := bytes.NewBuffer(data)
 		playerID := serializer.Stringfrombytes(buf)
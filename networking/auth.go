@@ -0,0 +1,191 @@
+package networking
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Each datagram starts with a frame type.
+// Handshake frames are sent before a session is established and are not authenticated.
+// Session and fragment frames have a sequence number after the frame type, and end with an HMAC
+// of the direction, frame type, sequence number and payload using the session key.
+const (
+	handshakeFrame byte = iota
+	sessionFrame
+	fragmentFrame
+)
+
+// Handshake:
+// client -> hello
+// server -> challenge (cookie)
+// client -> response (cookie, proof of the pre-shared key, credentials)
+// server -> welcome (session token) or disconnect (reason)
+const (
+	helloCommand     = "__hello"
+	challengeCommand = "__challenge"
+	responseCommand  = "__response"
+	welcomeCommand   = "__welcome"
+)
+
+const (
+	macSize      = 16
+	sequenceSize = 8
+	cookieSize   = 16
+	tokenSize    = 16
+
+	// replayWindow - the number of sequence numbers before the highest received that can still arrive out of order
+	replayWindow = 64
+)
+
+// the direction of a session frame, so frames can't be reflected back to their sender
+const (
+	clientToServer byte = iota
+	serverToClient
+)
+
+// ErrAuthenticationFailed - the client's pre-shared key did not match the server's
+var ErrAuthenticationFailed = errors.New("authentication failed")
+
+// Authenticator - decides whether a client may join, using the credentials sent by the client.
+// Returning an error rejects the client, the error message is sent to the client as the disconnect reason.
+type Authenticator func(addr net.Addr, credentials []byte) error
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func computeMAC(key []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+// generateToken - an unguessable session token
+func generateToken() string {
+	return hex.EncodeToString(randomBytes(tokenSize))
+}
+
+// handshakeCookie - ties a challenge to the client's address without storing any state on the server
+func handshakeCookie(secret []byte, addr net.Addr) []byte {
+	return computeMAC(secret, []byte(addr.String()))[:cookieSize]
+}
+
+// handshakeProof - proves knowledge of the pre-shared key without sending it
+func handshakeProof(preSharedKey, cookie []byte) []byte {
+	return computeMAC(preSharedKey, []byte("proof"), cookie)[:macSize]
+}
+
+// sessionKey - the key used to authenticate session frames.
+// Without a pre-shared key this only protects against senders that cannot observe the handshake.
+func sessionKey(preSharedKey, cookie []byte, token string) []byte {
+	return computeMAC(preSharedKey, []byte("session"), cookie, []byte(token))
+}
+
+// frameAuth - authenticates the session frames sent in one direction and received in the other.
+// Each frame sent has the next sequence number, received frames with a sequence number that has already been received,
+// or that is too far behind the highest received, are rejected as replays.
+type frameAuth struct {
+	key              []byte
+	send, receive    byte
+	mux              *sync.Mutex
+	sequence         uint64
+	highest          uint64
+	receivedSequence uint64 // bit n is set if highest-n has been received
+}
+
+func newFrameAuth(key []byte, client bool) *frameAuth {
+	auth := &frameAuth{key: key, send: serverToClient, receive: clientToServer, mux: &sync.Mutex{}}
+	if client {
+		auth.send, auth.receive = clientToServer, serverToClient
+	}
+	return auth
+}
+
+func (auth *frameAuth) mac(direction, frameType byte, sequence, payload []byte) []byte {
+	return computeMAC(auth.key, []byte{direction, frameType}, sequence, payload)[:macSize]
+}
+
+func (auth *frameAuth) nextSequence() uint64 {
+	auth.mux.Lock()
+	defer auth.mux.Unlock()
+	auth.sequence++
+	return auth.sequence
+}
+
+// accept - record a received sequence number, returns false if it is a replay
+func (auth *frameAuth) accept(sequence uint64) bool {
+	auth.mux.Lock()
+	defer auth.mux.Unlock()
+	if sequence == 0 {
+		return false
+	}
+	if sequence > auth.highest {
+		if shift := sequence - auth.highest; shift < replayWindow {
+			auth.receivedSequence = auth.receivedSequence<<shift | 1
+		} else {
+			auth.receivedSequence = 1
+		}
+		auth.highest = sequence
+		return true
+	}
+	offset := auth.highest - sequence
+	if offset >= replayWindow || auth.receivedSequence&(1<<offset) != 0 {
+		return false
+	}
+	auth.receivedSequence |= 1 << offset
+	return true
+}
+
+// frame - prefix the payload with the frame type, and sign it if the session is authenticated
+func frame(auth *frameAuth, payload []byte) []byte {
+	if auth == nil {
+		return append([]byte{handshakeFrame}, payload...)
+	}
+	return signedFrame(sessionFrame, auth, payload)
+}
+
+func signedFrame(frameType byte, auth *frameAuth, payload []byte) []byte {
+	data := make([]byte, 1+sequenceSize, 1+sequenceSize+len(payload)+macSize)
+	data[0] = frameType
+	binary.BigEndian.PutUint64(data[1:], auth.nextSequence())
+	data = append(data, payload...)
+	return append(data, auth.mac(auth.send, frameType, data[1:1+sequenceSize], payload)...)
+}
+
+// unframe - split a datagram into its frame type and payload, verifying the HMAC and sequence number of session frames
+func unframe(auth *frameAuth, data []byte) (byte, []byte, error) {
+	if len(data) < 1 {
+		return 0, nil, errors.New("empty frame")
+	}
+	switch data[0] {
+	case handshakeFrame:
+		return handshakeFrame, data[1:], nil
+	case sessionFrame, fragmentFrame:
+		if len(data) < 1+sequenceSize+macSize {
+			return 0, nil, errors.New("session frame too short")
+		}
+		if auth == nil {
+			return 0, nil, errors.New("session frame received without a session")
+		}
+		sequence := data[1 : 1+sequenceSize]
+		payload, mac := data[1+sequenceSize:len(data)-macSize], data[len(data)-macSize:]
+		if !hmac.Equal(mac, auth.mac(auth.receive, data[0], sequence, payload)) {
+			return 0, nil, errors.New("invalid frame HMAC")
+		}
+		if !auth.accept(binary.BigEndian.Uint64(sequence)) {
+			return 0, nil, errors.New("replayed frame")
+		}
+		return data[0], payload, nil
+	}
+	return 0, nil, errors.New("unknown frame type")
+}
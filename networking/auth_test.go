@@ -0,0 +1,236 @@
+package networking
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+func TestGenerateToken(t *testing.T) {
+	tokens := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token := generateToken()
+		assert.Len(t, token, tokenSize*2)
+		assert.False(t, tokens[token], "tokens should be unique")
+		tokens[token] = true
+	}
+}
+
+func TestFrame(t *testing.T) {
+	key := []byte("key")
+	payload := []byte("payload")
+	client, server := newFrameAuth(key, true), newFrameAuth(key, false)
+
+	frameType, data, err := unframe(server, frame(client, payload))
+	assert.NoError(t, err)
+	assert.EqualValues(t, sessionFrame, frameType)
+	assert.EqualValues(t, payload, data)
+
+	frameType, data, err = unframe(nil, frame(nil, payload))
+	assert.NoError(t, err)
+	assert.EqualValues(t, handshakeFrame, frameType)
+	assert.EqualValues(t, payload, data)
+
+	_, _, err = unframe(newFrameAuth([]byte("other key"), false), frame(client, payload))
+	assert.Error(t, err, "frames signed with a different key should be rejected")
+	_, _, err = unframe(nil, frame(client, payload))
+	assert.Error(t, err, "session frames should be rejected without a session")
+
+	tampered := frame(client, payload)
+	tampered[len(tampered)-macSize-1] ^= 1
+	_, _, err = unframe(server, tampered)
+	assert.Error(t, err, "tampered frames should be rejected")
+
+	retyped := frame(client, payload)
+	retyped[0] = fragmentFrame
+	_, _, err = unframe(server, retyped)
+	assert.Error(t, err, "the frame type should be authenticated")
+
+	_, _, err = unframe(newFrameAuth(key, true), frame(client, payload))
+	assert.Error(t, err, "frames should not be accepted by their sender")
+}
+
+func TestFrameReplay(t *testing.T) {
+	key := []byte("key")
+	client, server := newFrameAuth(key, true), newFrameAuth(key, false)
+
+	first, second := frame(client, []byte("first")), frame(client, []byte("second"))
+	_, _, err := unframe(server, second)
+	assert.NoError(t, err)
+	_, _, err = unframe(server, first)
+	assert.NoError(t, err, "frames may arrive out of order")
+	_, _, err = unframe(server, second)
+	assert.Error(t, err, "replayed frames should be rejected")
+	_, _, err = unframe(server, first)
+	assert.Error(t, err, "replayed frames should be rejected")
+
+	old := frame(client, []byte("old"))
+	for i := 0; i < replayWindow; i++ {
+		unframe(server, frame(client, []byte("new")))
+	}
+	_, _, err = unframe(server, old)
+	assert.Error(t, err, "frames older than the replay window should be rejected")
+}
+
+func connectClient(t *testing.T, serverAddr net.Addr, psk, credentials []byte) (*Client, chan string, chan string) {
+	connected, disconnected := make(chan string, 1), make(chan string, 1)
+	client := NewClient()
+	client.HeartbeatInterval = 20 * time.Millisecond
+	client.PreSharedKey = psk
+	client.Credentials = credentials
+	client.ConnectedEvent(func(token string) { connected <- token })
+	client.DisconnectedEvent(func(reason string) { disconnected <- reason })
+	client.ConnectConn(newLossyConn(t), serverAddr)
+	return client, connected, disconnected
+}
+
+func TestHandshakeAuthentication(t *testing.T) {
+	serverConn := newLossyConn(t)
+	server := NewServer()
+	server.PreSharedKey = []byte("secret")
+	server.Authenticate = func(addr net.Addr, credentials []byte) error {
+		if string(credentials) != "password" {
+			return errors.New("wrong password")
+		}
+		return nil
+	}
+	server.Serve(serverConn)
+	defer server.Close()
+
+	for _, test := range []struct {
+		psk, credentials string
+		reason           string
+	}{
+		{"secret", "password", ""},
+		{"wrong", "password", ErrAuthenticationFailed.Error()},
+		{"secret", "wrong", "wrong password"},
+	} {
+		client, connected, disconnected := connectClient(t, serverConn.LocalAddr(), []byte(test.psk), []byte(test.credentials))
+		select {
+		case token := <-connected:
+			assert.Empty(t, test.reason, "client should not have connected")
+			_, ok := server.getSession(token)
+			assert.True(t, ok)
+		case reason := <-disconnected:
+			assert.EqualValues(t, test.reason, reason)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for handshake")
+		}
+		client.Close()
+	}
+}
+
+func TestSpoofedPacketsRejected(t *testing.T) {
+	serverConn := newLossyConn(t)
+	received := make(chan Packet, 10)
+	server := NewServer()
+	server.PacketReceived(func(packet Packet) { received <- packet })
+	server.Serve(serverConn)
+	defer server.Close()
+
+	victim, connected, _ := connectClient(t, serverConn.LocalAddr(), nil, nil)
+	defer victim.Close()
+	var victimToken string
+	select {
+	case victimToken = <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for handshake")
+	}
+
+	// a handshake frame using the victim's token
+	attackerConn := newLossyConn(t)
+	data, _ := compress(Encode(Packet{Token: victimToken, Command: "spoofed"}))
+	attackerConn.WriteTo(frame(nil, data), serverConn.LocalAddr())
+	// a session frame signed with a guessed key
+	attackerConn.WriteTo(frame(newFrameAuth([]byte("guess"), true), data), serverConn.LocalAddr())
+
+	// an authenticated client using the victim's token
+	attacker, connected, _ := connectClient(t, serverConn.LocalAddr(), nil, nil)
+	defer attacker.Close()
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for handshake")
+	}
+	attacker.stateMux.Lock()
+	attacker.token = victimToken
+	attacker.stateMux.Unlock()
+	attacker.WriteMessage("spoofed", []byte{})
+
+	victim.WriteMessage("genuine", []byte{}, ReliableOrdered)
+	stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+	defer stopFlush()
+	select {
+	case packet := <-received:
+		assert.EqualValues(t, "genuine", packet.Command, "spoofed packets should be rejected")
+		assert.EqualValues(t, victimToken, packet.Token)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for packet")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, received, "spoofed packets should be rejected")
+}
+
+func TestClientIgnoresOtherAddresses(t *testing.T) {
+	// a server that never answers, so the client is still waiting for the handshake
+	serverConn := newLossyConn(t)
+	defer serverConn.Close()
+	clientConn := newLossyConn(t)
+	client := NewClient()
+	disconnected := make(chan string, 1)
+	client.DisconnectedEvent(func(reason string) { disconnected <- reason })
+	client.ConnectConn(clientConn, serverConn.LocalAddr())
+	defer client.Close()
+
+	attackerConn := newLossyConn(t)
+	defer attackerConn.Close()
+	data, _ := compress(Encode(Packet{Command: disconnectCommand, Data: []byte("spoofed")}))
+	attackerConn.WriteTo(frame(nil, data), clientConn.LocalAddr())
+	select {
+	case reason := <-disconnected:
+		t.Fatalf("client was disconnected by another address: %v", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	serverConn.WriteTo(frame(nil, data), clientConn.LocalAddr())
+	select {
+	case reason := <-disconnected:
+		assert.EqualValues(t, "spoofed", reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the server to disconnect the client")
+	}
+}
+
+func TestClientQueuesMessagesBeforeHandshake(t *testing.T) {
+	serverConn := newLossyConn(t)
+	received := make(chan Packet, 10)
+	server := NewServer()
+	server.PacketReceived(func(packet Packet) { received <- packet })
+	server.Serve(serverConn)
+	defer server.Close()
+
+	client, connected, _ := connectClient(t, serverConn.LocalAddr(), nil, nil)
+	defer client.Close()
+	client.WriteMessage("first", []byte{}, ReliableOrdered)
+	client.WriteMessage("second", []byte{})
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for handshake")
+	}
+
+	stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+	defer stopFlush()
+	for _, command := range []string{"first", "second"} {
+		select {
+		case packet := <-received:
+			assert.EqualValues(t, command, packet.Command, "messages written before the handshake should be sent in order")
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", command)
+		}
+	}
+}
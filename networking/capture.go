@@ -0,0 +1,174 @@
+package networking
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/walesey/go-engine/emitter"
+)
+
+// captureMagic - the header written at the start of every capture file
+const captureMagic = "GECAP1"
+
+// CaptureDirection - whether a captured packet was sent or received
+type CaptureDirection byte
+
+const (
+	CaptureReceived CaptureDirection = iota
+	CaptureSent
+)
+
+// CaptureRecord - a packet captured at a time relative to the start of the capture
+type CaptureRecord struct {
+	Time      time.Duration
+	Direction CaptureDirection
+	Packet    Packet
+}
+
+// Capture - records packets with timestamps to a writer.
+// Each record is the varint elapsed nanoseconds, the direction, the varint length and the encoded packet.
+type Capture struct {
+	mux     *sync.Mutex
+	writer  *bufio.Writer
+	started time.Time
+	now     func() time.Time
+	err     error
+}
+
+// NewCapture - start a capture, writing the capture header to w
+func NewCapture(w io.Writer) (*Capture, error) {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.WriteString(captureMagic); err != nil {
+		return nil, err
+	}
+	return &Capture{
+		mux:     &sync.Mutex{},
+		writer:  writer,
+		started: time.Now(),
+		now:     time.Now,
+	}, nil
+}
+
+// Record - write a packet to the capture. After a write error, further records are discarded.
+func (c *Capture) Record(direction CaptureDirection, packet Packet) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	encoded := Encode(packet)
+	header := make([]byte, 2*binary.MaxVarintLen64+1)
+	n := binary.PutUvarint(header, uint64(c.now().Sub(c.started)))
+	header[n] = byte(direction)
+	n++
+	n += binary.PutUvarint(header[n:], uint64(len(encoded)))
+	if _, c.err = c.writer.Write(header[:n]); c.err == nil {
+		_, c.err = c.writer.Write(encoded)
+	}
+	return c.err
+}
+
+// Flush - write any buffered records to the underlying writer
+func (c *Capture) Flush() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.err = c.writer.Flush()
+	return c.err
+}
+
+// ReadCapture - read all the records from a capture
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != captureMagic {
+		return nil, errors.New("not a packet capture")
+	}
+
+	var records []CaptureRecord
+	for {
+		elapsed, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		direction, err := reader.ReadByte()
+		if err != nil {
+			return records, io.ErrUnexpectedEOF
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return records, io.ErrUnexpectedEOF
+		}
+		if length > maxMessageSize {
+			return records, fmt.Errorf("capture record too large: %v", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return records, io.ErrUnexpectedEOF
+		}
+		packet, err, _ := Decode(data, 0)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, CaptureRecord{
+			Time:      time.Duration(elapsed),
+			Direction: CaptureDirection(direction),
+			Packet:    packet,
+		})
+	}
+}
+
+// Replay - feeds the received packets of a capture back into an event emitter, at the times they were captured.
+// Handlers registered with Network.RegisterEvent are triggered just as they were in the captured session.
+//
+//	records, _ := networking.ReadCapture(file)
+//	replay := networking.NewReplay(records, network)
+//	gameEngine.AddUpdatable(replay)
+type Replay struct {
+	records []CaptureRecord
+	target  emitter.EventEmitter
+	elapsed time.Duration
+	next    int
+}
+
+func NewReplay(records []CaptureRecord, target emitter.EventEmitter) *Replay {
+	return &Replay{records: records, target: target}
+}
+
+// Update - advance the replay by dt seconds, emitting every received packet up to the new time
+func (r *Replay) Update(dt float64) {
+	r.elapsed += time.Duration(dt * float64(time.Second))
+	r.emitUntil(r.elapsed)
+}
+
+// ReplayAll - emit every remaining received packet immediately
+func (r *Replay) ReplayAll() {
+	if len(r.records) > 0 {
+		r.elapsed = r.records[len(r.records)-1].Time
+	}
+	r.emitUntil(r.elapsed)
+}
+
+// Done - true once every record has been replayed
+func (r *Replay) Done() bool {
+	return r.next >= len(r.records)
+}
+
+func (r *Replay) emitUntil(elapsed time.Duration) {
+	for ; r.next < len(r.records) && r.records[r.next].Time <= elapsed; r.next++ {
+		record := r.records[r.next]
+		if record.Direction == CaptureReceived {
+			r.target.Do(record.Packet.Command, record.Packet)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package networking
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	var buf bytes.Buffer
+	capture, err := NewCapture(&buf)
+	assert.NoError(t, err)
+	now := capture.started
+	capture.now = func() time.Time { return now }
+	for i, record := range []CaptureRecord{
+		{Time: 0, Direction: CaptureReceived, Packet: Packet{Token: "a", Command: "move", Data: []byte{1}}},
+		{Time: 10 * time.Millisecond, Direction: CaptureSent, Packet: Packet{Token: "a", Command: "ack", Data: []byte{2}}},
+		{Time: 100 * time.Millisecond, Direction: CaptureReceived, Packet: Packet{Token: "b", Command: "move", Data: []byte{3}}},
+	} {
+		now = capture.started.Add(record.Time)
+		assert.NoError(t, capture.Record(record.Direction, record.Packet), "record %v", i)
+	}
+	assert.NoError(t, capture.Flush())
+
+	records, err := ReadCapture(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(records))
+	assert.EqualValues(t, 10*time.Millisecond, records[1].Time)
+	assert.EqualValues(t, CaptureSent, records[1].Direction)
+	assert.EqualValues(t, "ack", records[1].Packet.Command)
+
+	_, err = ReadCapture(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Error(t, err, "truncated capture")
+	_, err = ReadCapture(bytes.NewReader([]byte("not a capture")))
+	assert.Error(t, err)
+
+	network := NewNetwork()
+	var moves []string
+	network.RegisterEvent("move", func(clientId string, data []byte) { moves = append(moves, clientId) })
+	network.RegisterEvent("ack", func(clientId string, data []byte) { t.Fatal("sent packets should not be replayed") })
+	replay := NewReplay(records, network)
+	replay.Update(0.05)
+	assert.EqualValues(t, []string{"a"}, moves)
+	assert.False(t, replay.Done())
+	replay.Update(0.05)
+	assert.EqualValues(t, []string{"a", "b"}, moves)
+	assert.True(t, replay.Done())
+}
+
+func TestNetworkCapture(t *testing.T) {
+	transport := NewMemoryTransport()
+	serverNetwork, clientNetwork := NewNetwork(), NewNetwork()
+	defer serverNetwork.Close()
+	defer clientNetwork.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, serverNetwork.StartCapture(&buf))
+	received := make(chan struct{}, 1)
+	serverNetwork.RegisterEvent("ping", func(clientId string, data []byte) {
+		serverNetwork.TriggerEvent("pong", clientId, data, ReliableOrdered)
+		received <- struct{}{}
+	})
+	serverNetwork.StartServer(1235, transport)
+	assert.NoError(t, clientNetwork.ConnectClient("localhost:1235", transport))
+	clientNetwork.TriggerEvent("ping", "", []byte("hello"), ReliableOrdered)
+
+	timeout := time.After(5 * time.Second)
+	for waiting := true; waiting; {
+		select {
+		case <-received:
+			waiting = false
+		case <-timeout:
+			t.Fatal("timed out waiting for ping")
+		default:
+			serverNetwork.Update(0)
+			time.Sleep(time.Millisecond)
+		}
+	}
+	assert.NoError(t, serverNetwork.StopCapture())
+
+	records, err := ReadCapture(&buf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(records))
+	assert.EqualValues(t, CaptureReceived, records[0].Direction)
+	assert.EqualValues(t, "ping", records[0].Packet.Command)
+	assert.EqualValues(t, CaptureSent, records[1].Direction)
+	assert.EqualValues(t, "pong", records[1].Packet.Command)
+	assert.EqualValues(t, "hello", string(records[1].Packet.Data))
+}
@@ -1,20 +1,45 @@
 package networking
 
 import (
-	"bytes"
-	"compress/gzip"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"sync"
+	"time"
+
+	"github.com/walesey/go-engine/util"
 )
 
+// clientPacketBufferSize - the most messages queued while the handshake completes
 const clientPacketBufferSize = 100
 
 type Client struct {
+	// HeartbeatInterval - how often heartbeats are sent to the server and the connection is checked for timeouts
+	HeartbeatInterval time.Duration
+	// Timeout - the client is disconnected if no packets are received from the server for this long
+	Timeout time.Duration
+	// PreSharedKey - must match the server's PreSharedKey
+	PreSharedKey []byte
+	// Credentials - sent to the server's Authenticate callback during the handshake
+	Credentials []byte
+
 	token                string
-	conn                 *net.UDPConn
+	auth                 *frameAuth
+	ready                bool
+	pending              []pendingMessage
+	cookie               []byte
+	fragments            *reassembler
+	conn                 net.PacketConn
+	serverAddr           net.Addr
+	channel              *reliableChannel
+	stopResend           func()
+	stopHeartbeat        func()
+	stateMux             *sync.Mutex
+	lastReceived         time.Time
+	closed               bool
 	onPacketReceived     func(packet Packet)
+	onConnected          func(token string)
+	onDisconnected       func(reason string)
 	bytesSent            int64
 	bytesReceived        int64
 	bytesSentByEvent     map[string]int64
@@ -22,8 +47,18 @@ type Client struct {
 	bytesByEventMux      *sync.Mutex
 }
 
+// pendingMessage - a message written before the handshake completed
+type pendingMessage struct {
+	packet Packet
+	mode   DeliveryMode
+}
+
 func NewClient() *Client {
 	return &Client{
+		HeartbeatInterval:    defaultHeartbeatInterval,
+		Timeout:              defaultSessionTimeout,
+		stateMux:             &sync.Mutex{},
+		fragments:            newReassembler(),
 		bytesSentByEvent:     make(map[string]int64),
 		bytesReceivedByEvent: make(map[string]int64),
 		bytesByEventMux:      &sync.Mutex{},
@@ -31,35 +66,65 @@ func NewClient() *Client {
 }
 
 func (c *Client) Connect(addr string) error {
-	serverAddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		fmt.Println("Error resolving server udp address: ", err)
-		return err
-	}
+	return c.ConnectTransport(UDPTransport{}, addr)
+}
 
-	c.conn, err = net.DialUDP("udp", nil, serverAddr)
+// ConnectTransport - connect to the server at addr using the transport (eg. UDPTransport, TCPTransport or a MemoryTransport)
+func (c *Client) ConnectTransport(transport Transport, addr string) error {
+	conn, serverAddr, err := transport.Dial(addr)
 	if err != nil {
-		fmt.Println("Error connecting to udp server address: ", err)
+		fmt.Println("Error connecting to server address: ", err)
 		return err
 	}
 
-	data := make([]byte, 65500)
+	c.ConnectConn(conn, serverAddr)
+	return nil
+}
+
+// ConnectConn - start communicating with the server at serverAddr using the given connection.
+// Connect uses a udp connection, but any net.PacketConn can be used (eg. for testing).
+func (c *Client) ConnectConn(conn net.PacketConn, serverAddr net.Addr) {
+	c.conn = conn
+	c.serverAddr = serverAddr
+	c.channel = newReliableChannel(c.writePacket)
+	c.lastReceived = time.Now()
+	c.stopResend = util.SetInterval(c.channel.resend, resendInterval)
+	c.stopHeartbeat = util.SetInterval(c.heartbeat, c.HeartbeatInterval)
+	c.sendHandshake()
+
+	data := make([]byte, readBufferSize)
 	go func() {
-		for c.conn != nil {
-			n, _, err := c.conn.ReadFromUDP(data)
+		for {
+			n, addr, err := conn.ReadFrom(data)
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				fmt.Println("Error reading udp packet: ", err)
 				continue
 			}
+			if !sameAddr(addr, serverAddr) {
+				continue
+			}
 
-			dataBuf := bytes.NewBuffer(data[0:n])
-			gzipReader, err := gzip.NewReader(dataBuf)
+			frameType, payload, err := unframe(c.getAuth(), data[0:n])
 			if err != nil {
-				fmt.Println("Error creating gzip Reader for udp packet: ", err)
+				fmt.Println("Error reading udp frame: ", err)
 				continue
 			}
 
-			unzipped, err := ioutil.ReadAll(gzipReader)
+			if frameType == fragmentFrame {
+				var complete bool
+				if payload, complete, err = c.fragments.add(payload); err != nil {
+					fmt.Println("Error reassembling udp fragment: ", err)
+				}
+				if !complete {
+					continue
+				}
+				frameType = sessionFrame
+			}
+
+			unzipped, err := decompress(payload)
 			if err != nil {
 				fmt.Println("Error unzipping udp packet: ", err)
 				continue
@@ -75,57 +140,250 @@ func (c *Client) Connect(addr string) error {
 				}
 				c.updateBytesReceived(packet.Command, int64(i-j))
 
-				c.token = packet.Token
+				if frameType == handshakeFrame {
+					if !c.handshake(packet) {
+						return
+					}
+					continue
+				}
 
-				if c.onPacketReceived != nil {
-					c.onPacketReceived(packet)
+				c.touch()
+				switch packet.Command {
+				case heartbeatCommand:
+				case disconnectCommand:
+					c.disconnect(string(packet.Data))
+					return
+				default:
+					for _, received := range c.channel.receive(packet) {
+						if c.onPacketReceived != nil {
+							c.onPacketReceived(received)
+						}
+					}
 				}
 			}
 		}
 	}()
-	return nil
 }
 
 func (c *Client) PacketReceived(callback func(packet Packet)) {
 	c.onPacketReceived = callback
 }
 
-func (c *Client) WriteMessage(command string, data []byte) {
+// ConnectedEvent - called when the handshake completes and the client has joined the server
+func (c *Client) ConnectedEvent(callback func(token string)) {
+	c.onConnected = callback
+}
+
+// DisconnectedEvent - called once when the client is kicked, the server closes or the connection times out
+func (c *Client) DisconnectedEvent(callback func(reason string)) {
+	c.onDisconnected = callback
+}
+
+// heartbeat - let the server know the client is still connected and check for a timeout
+func (c *Client) heartbeat() {
+	c.stateMux.Lock()
+	idle := time.Since(c.lastReceived)
+	c.stateMux.Unlock()
+	if idle > c.Timeout {
+		c.disconnect(ReasonTimeout)
+		return
+	}
+	if len(c.getToken()) > 0 {
+		c.writePacket(Packet{Command: heartbeatCommand})
+	} else {
+		c.sendHandshake()
+	}
+}
+
+// sendHandshake - send the next handshake packet, this is repeated by heartbeat until the handshake completes
+func (c *Client) sendHandshake() {
+	c.stateMux.Lock()
+	cookie := c.cookie
+	c.stateMux.Unlock()
+	if cookie == nil {
+		c.writeFrame(nil, Packet{Command: helloCommand})
+		return
+	}
+	data := append(append([]byte{}, cookie...), handshakeProof(c.PreSharedKey, cookie)...)
+	c.writeFrame(nil, Packet{Command: responseCommand, Data: append(data, c.Credentials...)})
+}
+
+// handshake - handle an unauthenticated packet from the server, returns false if the client was disconnected
+func (c *Client) handshake(packet Packet) bool {
+	c.stateMux.Lock()
+	connected := c.auth != nil
+	c.stateMux.Unlock()
+	if connected {
+		return true
+	}
+
+	switch packet.Command {
+	case challengeCommand:
+		if len(packet.Data) != cookieSize {
+			return true
+		}
+		c.stateMux.Lock()
+		c.cookie = append([]byte{}, packet.Data...)
+		c.stateMux.Unlock()
+		c.touch()
+		c.sendHandshake()
+
+	case welcomeCommand:
+		token := string(packet.Data)
+		c.stateMux.Lock()
+		if c.cookie == nil {
+			c.stateMux.Unlock()
+			return true
+		}
+		c.token = token
+		c.auth = newFrameAuth(sessionKey(c.PreSharedKey, c.cookie, token), true)
+		c.stateMux.Unlock()
+		c.touch()
+		c.sendPending()
+		if c.onConnected != nil {
+			c.onConnected(token)
+		}
+
+	case disconnectCommand:
+		c.disconnect(string(packet.Data))
+		return false
+	}
+	return true
+}
+
+// sendPending - send the messages written before the handshake completed, in the order they were written
+func (c *Client) sendPending() {
+	for {
+		c.stateMux.Lock()
+		pending := c.pending
+		c.pending = nil
+		if len(pending) == 0 {
+			c.ready = true
+			c.stateMux.Unlock()
+			return
+		}
+		c.stateMux.Unlock()
+		for _, message := range pending {
+			c.channel.send(message.packet, message.mode)
+		}
+	}
+}
+
+// sameAddr - true if the addresses are the same, udp addresses are compared by ip and port
+func sameAddr(a, b net.Addr) bool {
+	if udpA, ok := a.(*net.UDPAddr); ok {
+		if udpB, ok := b.(*net.UDPAddr); ok {
+			return udpA.IP.Equal(udpB.IP) && udpA.Port == udpB.Port
+		}
+	}
+	return a != nil && b != nil && a.String() == b.String()
+}
+
+func (c *Client) getToken() string {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	return c.token
+}
+
+func (c *Client) getAuth() *frameAuth {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	return c.auth
+}
+
+func (c *Client) touch() {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	c.lastReceived = time.Now()
+}
+
+// disconnect - stop the client without notifying the server
+func (c *Client) disconnect(reason string) {
+	if !c.shutdown() {
+		return
+	}
+	if c.onDisconnected != nil {
+		c.onDisconnected(reason)
+	}
+}
+
+// shutdown - stop all intervals and close the connection, returns false if the client was already closed
+func (c *Client) shutdown() bool {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	if c.stopResend != nil {
+		c.stopResend()
+	}
+	if c.stopHeartbeat != nil {
+		c.stopHeartbeat()
+	}
+	c.conn.Close()
+	return true
+}
+
+// WriteMessage - send a message to the server. The delivery mode defaults to Unreliable.
+// Messages written before the handshake completes are queued and sent once it has completed.
+func (c *Client) WriteMessage(command string, data []byte, mode ...DeliveryMode) {
 	packet := Packet{
-		Token:   c.token,
 		Command: command,
 		Data:    data,
 	}
-
-	packetData := Encode(packet)
-	var gzipBuf bytes.Buffer
-	gzipWriter := gzip.NewWriter(&gzipBuf)
-	_, err := gzipWriter.Write(packetData)
-	if err != nil {
-		fmt.Println("Error Gzip compressing udp message: ", err)
+	c.stateMux.Lock()
+	if !c.ready {
+		if len(c.pending) < clientPacketBufferSize {
+			c.pending = append(c.pending, pendingMessage{packet: packet, mode: deliveryMode(mode)})
+		} else {
+			fmt.Println("Error writing message before the handshake completed: too many queued messages")
+		}
+		c.stateMux.Unlock()
 		return
 	}
+	c.stateMux.Unlock()
+	c.channel.send(packet, deliveryMode(mode))
+}
 
-	if err := gzipWriter.Flush(); err != nil {
-		fmt.Println("Error Flushing Gzip writer for udp message: ", err)
+// writePacket - send a packet on the session. Packets written before the handshake completes are dropped,
+// messages are queued by WriteMessage until it has completed.
+func (c *Client) writePacket(packet Packet) {
+	auth := c.getAuth()
+	if auth == nil {
 		return
 	}
+	packet.Token = c.getToken()
+	c.writeFrame(auth, packet)
+}
 
-	if err := gzipWriter.Close(); err != nil {
-		fmt.Println("Error Closing Gzip writer for udp message: ", err)
+// writeFrame - send a single packet, authenticated with the session if it is not nil
+func (c *Client) writeFrame(auth *frameAuth, packet Packet) {
+	gzipData, err := compress(Encode(packet))
+	if err != nil {
+		fmt.Println("Error Gzip compressing udp message: ", err)
 		return
 	}
 
-	gzipData := gzipBuf.Bytes()
-	c.updateBytesSent(command, int64(len(gzipData)))
-	_, err = c.conn.Write(gzipData)
+	err = writeFrames(frames(auth, gzipData), func(framed []byte) error {
+		c.updateBytesSent(packet.Command, int64(len(framed)))
+		_, err := c.conn.WriteTo(framed, c.serverAddr)
+		return err
+	})
 	if err != nil {
 		fmt.Println("Error writing udp message: ", err)
 	}
 }
 
+// Close - notify the server and close the connection
 func (c *Client) Close() {
-	c.conn.Close()
+	c.stateMux.Lock()
+	closed, token := c.closed, c.token
+	c.stateMux.Unlock()
+	if !closed && len(token) > 0 {
+		c.writePacket(Packet{Command: disconnectCommand, Data: []byte(ReasonDisconnected)})
+	}
+	c.shutdown()
 }
 
 func (c *Client) updateBytesSent(event string, sent int64) {
@@ -1,3 +1,161 @@
 package networking
 
-//TODO
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	clockPingEvent = "__clockPing"
+	clockPongEvent = "__clockPong"
+)
+
+const (
+	defaultPingInterval = time.Second
+	rttSmoothing        = 0.125
+	offsetSmoothing     = 0.125
+	jitterSmoothing     = 0.25
+)
+
+// NetworkClock - estimates the round trip time and clock offset between a client and the server
+// using an NTP style ping exchange, so that events can be timestamped and scheduled using the server's time.
+// On the server the offset is always zero.
+type NetworkClock struct {
+	PingInterval time.Duration
+
+	network   eventNetwork
+	now       func() time.Time
+	sinceLast time.Duration
+	mux       *sync.Mutex
+	samples   int
+	rtt       float64
+	offset    float64
+	jitter    float64
+}
+
+// NewNetworkClock - create a clock and register the ping/pong events on the network.
+// The clock must be created after the server is started or the client is connected, so that only the server answers pings
+// and only the client accepts pongs.
+// Add the clock to the engine (AddUpdatable) to send pings every PingInterval.
+func NewNetworkClock(network *Network) *NetworkClock {
+	return newNetworkClock(network, time.Now)
+}
+
+func newNetworkClock(network eventNetwork, now func() time.Time) *NetworkClock {
+	clock := &NetworkClock{
+		PingInterval: defaultPingInterval,
+		network:      network,
+		now:          now,
+		sinceLast:    defaultPingInterval,
+		mux:          &sync.Mutex{},
+	}
+	if network.IsServer() {
+		network.RegisterEvent(clockPingEvent, clock.handlePing)
+	}
+	if network.IsClient() {
+		network.RegisterEvent(clockPongEvent, clock.handlePong)
+	}
+	return clock
+}
+
+// Update - send a ping to the server every PingInterval
+func (c *NetworkClock) Update(dt float64) {
+	if !c.network.IsClient() {
+		return
+	}
+	c.sinceLast += time.Duration(dt * float64(time.Second))
+	if c.sinceLast >= c.PingInterval {
+		c.sinceLast = 0
+		c.Ping()
+	}
+}
+
+// Ping - send a ping to the server immediately
+func (c *NetworkClock) Ping() {
+	data, _ := util.SerializeArgs(c.now().UnixNano())
+	c.network.TriggerEvent(clockPingEvent, "", data)
+}
+
+// handlePing - reply with the client's send time, and the time the ping was received and replied to
+func (c *NetworkClock) handlePing(clientId string, data []byte) {
+	received := c.now().UnixNano()
+	sent, err := util.UInt64frombytes(bytes.NewBuffer(data))
+	if err != nil {
+		return
+	}
+	reply, _ := util.SerializeArgs(sent, uint64(received), uint64(c.now().UnixNano()))
+	c.network.TriggerEvent(clockPongEvent, clientId, reply)
+}
+
+func (c *NetworkClock) handlePong(clientId string, data []byte) {
+	t3 := float64(c.now().UnixNano())
+	buf := bytes.NewBuffer(data)
+	var stamps [3]uint64
+	for i := range stamps {
+		stamp, err := util.UInt64frombytes(buf)
+		if err != nil {
+			return
+		}
+		stamps[i] = stamp
+	}
+	t0, t1, t2 := float64(int64(stamps[0])), float64(int64(stamps[1])), float64(int64(stamps[2]))
+	c.addSample((t3-t0)-(t2-t1), ((t1-t0)+(t2-t3))/2)
+}
+
+func (c *NetworkClock) addSample(rtt, offset float64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.samples == 0 {
+		c.rtt, c.offset = rtt, offset
+	} else {
+		deviation := rtt - c.rtt
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		c.jitter += (deviation - c.jitter) * jitterSmoothing
+		c.rtt += (rtt - c.rtt) * rttSmoothing
+		c.offset += (offset - c.offset) * offsetSmoothing
+	}
+	c.samples++
+}
+
+// ServerTime - the current time on the server
+func (c *NetworkClock) ServerTime() time.Time {
+	return c.now().Add(c.Offset())
+}
+
+// LocalTime - convert a server time to the equivalent local time
+func (c *NetworkClock) LocalTime(serverTime time.Time) time.Time {
+	return serverTime.Add(-c.Offset())
+}
+
+// Offset - the estimated difference between the server's clock and the local clock
+func (c *NetworkClock) Offset() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return time.Duration(c.offset)
+}
+
+// RTT - the smoothed round trip time to the server
+func (c *NetworkClock) RTT() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return time.Duration(c.rtt)
+}
+
+// Jitter - the smoothed variation in round trip time
+func (c *NetworkClock) Jitter() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return time.Duration(c.jitter)
+}
+
+// Samples - the number of ping replies received
+func (c *NetworkClock) Samples() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.samples
+}
@@ -0,0 +1,90 @@
+package networking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+func newFakeClocks(serverOffset time.Duration, latency func() time.Duration) (client, server *NetworkClock, clientNet *fakeNetwork) {
+	serverNet := newFakeServer()
+	serverNet.latency = latency
+	clientNet = serverNet.connect("1")
+	base := time.Unix(1000, 0)
+	elapsed := serverNet.elapsed
+	client = newNetworkClock(clientNet, func() time.Time { return base.Add(*elapsed) })
+	server = newNetworkClock(serverNet, func() time.Time { return base.Add(*elapsed + serverOffset) })
+	return
+}
+
+func TestNetworkClockConstantLatency(t *testing.T) {
+	client, server, clientNet := newFakeClocks(5*time.Second, func() time.Duration { return 20 * time.Millisecond })
+	for i := 0; i < 5; i++ {
+		client.Ping()
+		clientNet.flush()
+	}
+
+	assert.EqualValues(t, 5, client.Samples())
+	assert.EqualValues(t, 40*time.Millisecond, client.RTT())
+	assert.EqualValues(t, 5*time.Second, client.Offset())
+	assert.EqualValues(t, 0, client.Jitter())
+	assert.EqualValues(t, server.ServerTime(), client.ServerTime())
+	assert.EqualValues(t, client.now(), client.LocalTime(client.ServerTime()))
+
+	assert.EqualValues(t, 0, server.Samples())
+	assert.EqualValues(t, 0, server.Offset(), "the server clock should not be offset")
+}
+
+func TestNetworkClockJitter(t *testing.T) {
+	// alternate between 20ms and 60ms round trips
+	latencies := []time.Duration{10 * time.Millisecond, 30 * time.Millisecond}
+	calls := 0
+	client, _, clientNet := newFakeClocks(-2*time.Second, func() time.Duration {
+		latency := latencies[(calls/2)%2]
+		calls++
+		return latency
+	})
+	for i := 0; i < 100; i++ {
+		client.Ping()
+		clientNet.flush()
+	}
+
+	assert.InDelta(t, float64(40*time.Millisecond), float64(client.RTT()), float64(5*time.Millisecond))
+	assert.InDelta(t, float64(-2*time.Second), float64(client.Offset()), float64(time.Millisecond))
+	assert.True(t, client.Jitter() > 10*time.Millisecond, "varying round trips should produce jitter")
+}
+
+func TestNetworkClockUpdate(t *testing.T) {
+	client, server, clientNet := newFakeClocks(0, func() time.Duration { return time.Millisecond })
+	client.PingInterval = 500 * time.Millisecond
+
+	client.Update(0.1)
+	assert.EqualValues(t, 1, clientNet.sent, "the first update should ping immediately")
+	client.Update(0.3)
+	assert.EqualValues(t, 1, clientNet.sent)
+	client.Update(0.3)
+	assert.EqualValues(t, 2, clientNet.sent)
+
+	server.Update(10)
+	assert.EqualValues(t, 2, clientNet.sent, "the server should not send pings")
+}
+
+func TestNetworkClockIgnoresForgedEvents(t *testing.T) {
+	client, server, clientNet := newFakeClocks(0, func() time.Duration { return time.Millisecond })
+	serverNet := clientNet.root()
+
+	data, _ := util.SerializeArgs(int64(0), int64(time.Hour), int64(time.Hour))
+	clientNet.TriggerEvent(clockPongEvent, "", data)
+	clientNet.flush()
+	assert.EqualValues(t, 0, server.Samples(), "the server should ignore pongs")
+	assert.EqualValues(t, 0, server.Offset())
+
+	sent := clientNet.sent
+	ping, _ := util.SerializeArgs(int64(0))
+	serverNet.TriggerEvent(clockPingEvent, "1", ping)
+	serverNet.flush()
+	assert.EqualValues(t, sent, clientNet.sent, "clients should not answer pings")
+	assert.EqualValues(t, 0, client.Samples())
+}
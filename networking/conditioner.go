@@ -0,0 +1,136 @@
+package networking
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxQueueDelay - packets that would wait longer than this for bandwidth are dropped, like a full router queue
+const maxQueueDelay = time.Second
+
+// NetworkConditions - simulated conditions applied to every packet sent
+type NetworkConditions struct {
+	// Latency - the delay added to every packet
+	Latency time.Duration
+	// Jitter - a random amount between -Jitter and +Jitter added to the latency. Jitter can reorder packets.
+	Jitter time.Duration
+	// Loss - the probability (0-1) that a packet is dropped
+	Loss float64
+	// Bandwidth - the maximum bytes per second sent, 0 is unlimited
+	Bandwidth int
+}
+
+// Conditioner - a Transport that simulates latency, jitter, packet loss and bandwidth limits
+// on the send path of another transport. The conditions can be changed at any time.
+//
+//	network.StartServer(port, networking.NewConditioner(networking.UDPTransport{}, networking.NetworkConditions{Latency: 100 * time.Millisecond}))
+type Conditioner struct {
+	Transport Transport
+
+	mux        *sync.Mutex
+	conditions NetworkConditions
+	random     *rand.Rand
+}
+
+func NewConditioner(transport Transport, conditions NetworkConditions) *Conditioner {
+	return &Conditioner{
+		Transport:  transport,
+		mux:        &sync.Mutex{},
+		conditions: conditions,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Seed - seed the random number generator used for loss and jitter, so a run can be repeated
+func (c *Conditioner) Seed(seed int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.random = rand.New(rand.NewSource(seed))
+}
+
+func (c *Conditioner) SetConditions(conditions NetworkConditions) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.conditions = conditions
+}
+
+func (c *Conditioner) Conditions() NetworkConditions {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.conditions
+}
+
+func (c *Conditioner) Listen(addr string) (net.PacketConn, error) {
+	conn, err := c.Transport.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	return c.Wrap(conn), nil
+}
+
+func (c *Conditioner) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	conn, serverAddr, err := c.Transport.Dial(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Wrap(conn), serverAddr, nil
+}
+
+// Wrap - apply the conditions to packets written to conn
+func (c *Conditioner) Wrap(conn net.PacketConn) net.PacketConn {
+	return &conditionedConn{PacketConn: conn, conditioner: c, now: time.Now}
+}
+
+// schedule - decide if a packet of the given size is sent, and how long after the given time it arrives
+func (c *Conditioner) schedule(size int, now time.Time, bandwidthFree *time.Time) (time.Duration, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.conditions.Loss > 0 && c.random.Float64() < c.conditions.Loss {
+		return 0, false
+	}
+
+	sendTime := now
+	if c.conditions.Bandwidth > 0 {
+		if bandwidthFree.After(sendTime) {
+			sendTime = *bandwidthFree
+		}
+		if sendTime.Sub(now) > maxQueueDelay {
+			return 0, false
+		}
+		*bandwidthFree = sendTime.Add(time.Duration(size) * time.Second / time.Duration(c.conditions.Bandwidth))
+	}
+
+	delay := c.conditions.Latency
+	if c.conditions.Jitter > 0 {
+		delay += time.Duration(c.random.Int63n(int64(2*c.conditions.Jitter))) - c.conditions.Jitter
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return sendTime.Sub(now) + delay, true
+}
+
+type conditionedConn struct {
+	net.PacketConn
+	conditioner   *Conditioner
+	now           func() time.Time
+	mux           sync.Mutex
+	bandwidthFree time.Time
+}
+
+func (c *conditionedConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mux.Lock()
+	delay, ok := c.conditioner.schedule(len(p), c.now(), &c.bandwidthFree)
+	c.mux.Unlock()
+	if !ok {
+		return len(p), nil
+	}
+	if delay == 0 {
+		return c.PacketConn.WriteTo(p, addr)
+	}
+	data := append([]byte{}, p...)
+	time.AfterFunc(delay, func() { c.PacketConn.WriteTo(data, addr) })
+	return len(p), nil
+}
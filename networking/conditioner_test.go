@@ -0,0 +1,66 @@
+package networking
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func conditionedPair(t *testing.T, conditioner *Conditioner) (net.PacketConn, net.PacketConn, net.Addr) {
+	transport := NewMemoryTransport()
+	conditioner.Transport = transport
+	server, err := transport.Listen(":1234")
+	assert.NoError(t, err)
+	client, serverAddr, err := conditioner.Dial("localhost:1234")
+	assert.NoError(t, err)
+	return server, client, serverAddr
+}
+
+func TestConditionerLatency(t *testing.T) {
+	conditioner := NewConditioner(nil, NetworkConditions{Latency: 50 * time.Millisecond})
+	server, client, serverAddr := conditionedPair(t, conditioner)
+	defer server.Close()
+	defer client.Close()
+
+	start := time.Now()
+	_, err := client.WriteTo([]byte("hello"), serverAddr)
+	assert.NoError(t, err)
+	buf := make([]byte, 16)
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "hello", string(buf[:n]))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "packet should be delayed")
+}
+
+func TestConditionerSchedule(t *testing.T) {
+	conditioner := NewConditioner(nil, NetworkConditions{Loss: 0.25})
+	conditioner.Seed(1)
+	now, bandwidthFree := time.Now(), time.Time{}
+	sent := 0
+	for i := 0; i < 1000; i++ {
+		if _, ok := conditioner.schedule(100, now, &bandwidthFree); ok {
+			sent++
+		}
+	}
+	assert.InDelta(t, 750, sent, 50, "about a quarter of the packets should be lost")
+
+	conditioner.SetConditions(NetworkConditions{Latency: 100 * time.Millisecond, Jitter: 20 * time.Millisecond})
+	for i := 0; i < 100; i++ {
+		delay, ok := conditioner.schedule(100, now, &bandwidthFree)
+		assert.True(t, ok)
+		assert.True(t, delay >= 80*time.Millisecond && delay <= 120*time.Millisecond, "delay should be within the jitter")
+	}
+
+	conditioner.SetConditions(NetworkConditions{Bandwidth: 1000})
+	for i := 0; i < 10; i++ {
+		delay, ok := conditioner.schedule(100, now, &bandwidthFree)
+		assert.True(t, ok)
+		assert.EqualValues(t, time.Duration(i)*100*time.Millisecond, delay, "each packet waits for the previous ones to be sent")
+	}
+	_, ok := conditioner.schedule(100, now, &bandwidthFree)
+	assert.True(t, ok)
+	_, ok = conditioner.schedule(100, now, &bandwidthFree)
+	assert.False(t, ok, "packets are dropped when the queue is full")
+}
@@ -0,0 +1,159 @@
+package networking
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+const (
+	// maxDatagramSize - larger payloads are split into fragments so datagrams are not fragmented by the network
+	maxDatagramSize = 1400
+	// readBufferSize - the largest possible udp datagram
+	readBufferSize = 65535
+	// maxFragments - limits the size of a reassembled message
+	maxFragments = 4096
+	// maxPartialMessages - the number of messages that can be reassembled at the same time, per session.
+	// When the limit is reached the oldest message is discarded.
+	maxPartialMessages = 16
+
+	// fragments are written in bursts with a short pause between, so the receiver's socket buffer is not overrun
+	fragmentBurst = 32
+	fragmentPause = time.Millisecond
+
+	fragmentTimeout   = 5 * time.Second
+	fragmentHeaderMax = 3 * binary.MaxVarintLen32
+	maxFragmentSize   = maxDatagramSize - 1 - sequenceSize - macSize - fragmentHeaderMax
+)
+
+// frames - frame a payload, splitting it into fragment frames if it does not fit in a single datagram.
+// The fragments are identified by a checksum of the payload, so if a message is resent
+// the receiver can use the new fragments to fill in any that were lost.
+func frames(auth *frameAuth, payload []byte) [][]byte {
+	if auth == nil || len(payload)+1+sequenceSize+macSize <= maxDatagramSize {
+		return [][]byte{frame(auth, payload)}
+	}
+	id := crc32.ChecksumIEEE(payload)
+
+	count := (len(payload) + maxFragmentSize - 1) / maxFragmentSize
+	result := make([][]byte, 0, count)
+	header := make([]byte, fragmentHeaderMax)
+	for index := 0; index < count; index++ {
+		end := (index + 1) * maxFragmentSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		n := binary.PutUvarint(header, uint64(id))
+		n += binary.PutUvarint(header[n:], uint64(index))
+		n += binary.PutUvarint(header[n:], uint64(count))
+		fragment := append(append([]byte{}, header[:n]...), payload[index*maxFragmentSize:end]...)
+		result = append(result, signedFrame(fragmentFrame, auth, fragment))
+	}
+	return result
+}
+
+// writeFrames - write framed datagrams, pausing between bursts of fragments
+func writeFrames(framed [][]byte, write func(data []byte) error) error {
+	for i, data := range framed {
+		if i > 0 && i%fragmentBurst == 0 {
+			time.Sleep(fragmentPause)
+		}
+		if err := write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type partialMessage struct {
+	fragments [][]byte
+	received  int
+	started   time.Time
+}
+
+// reassembler - joins fragment frames back together
+type reassembler struct {
+	mux      *sync.Mutex
+	now      func() time.Time
+	messages map[uint32]*partialMessage
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{
+		mux:      &sync.Mutex{},
+		now:      time.Now,
+		messages: make(map[uint32]*partialMessage),
+	}
+}
+
+// add - add a fragment, returning the whole payload once every fragment of the message has been received
+func (r *reassembler) add(fragment []byte) ([]byte, bool, error) {
+	id, n := binary.Uvarint(fragment)
+	if n <= 0 || id > 1<<32-1 {
+		return nil, false, errors.New("invalid fragment id")
+	}
+	fragment = fragment[n:]
+	index, n := binary.Uvarint(fragment)
+	if n <= 0 {
+		return nil, false, errors.New("invalid fragment index")
+	}
+	fragment = fragment[n:]
+	count, n := binary.Uvarint(fragment)
+	if n <= 0 || count < 2 || count > maxFragments || index >= count {
+		return nil, false, errors.New("invalid fragment count")
+	}
+	fragment = fragment[n:]
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.expire()
+
+	message, ok := r.messages[uint32(id)]
+	if !ok {
+		if len(r.messages) >= maxPartialMessages {
+			r.evictOldest()
+		}
+		message = &partialMessage{fragments: make([][]byte, count), started: r.now()}
+		r.messages[uint32(id)] = message
+	}
+	if len(message.fragments) != int(count) {
+		return nil, false, errors.New("fragment count does not match")
+	}
+	if message.fragments[index] != nil {
+		return nil, false, nil
+	}
+	message.fragments[index] = append([]byte{}, fragment...)
+	message.received++
+	if message.received < len(message.fragments) {
+		return nil, false, nil
+	}
+
+	delete(r.messages, uint32(id))
+	var payload []byte
+	for _, part := range message.fragments {
+		payload = append(payload, part...)
+	}
+	return payload, true, nil
+}
+
+func (r *reassembler) evictOldest() {
+	var oldestId uint32
+	var oldest *partialMessage
+	for id, message := range r.messages {
+		if oldest == nil || message.started.Before(oldest.started) {
+			oldestId, oldest = id, message
+		}
+	}
+	delete(r.messages, oldestId)
+}
+
+// expire - discard messages that have not been completed within the fragmentTimeout
+func (r *reassembler) expire() {
+	for id, message := range r.messages {
+		if r.now().Sub(message.started) > fragmentTimeout {
+			delete(r.messages, id)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package networking
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+func randomPayload(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestFragmentation(t *testing.T) {
+	client, server := newFrameAuth([]byte("key"), true), newFrameAuth([]byte("key"), false)
+	payload := randomPayload(10 * maxDatagramSize)
+
+	fragments := frames(client, payload)
+	assert.True(t, len(fragments) > 10)
+	r := newReassembler()
+	for i := len(fragments) - 1; i >= 0; i-- {
+		assert.True(t, len(fragments[i]) <= maxDatagramSize, "fragments should fit in a datagram")
+		frameType, data, err := unframe(server, fragments[i])
+		assert.NoError(t, err)
+		assert.EqualValues(t, fragmentFrame, frameType)
+
+		reassembled, complete, err := r.add(data)
+		assert.NoError(t, err)
+		if i > 0 {
+			assert.False(t, complete)
+			_, complete, err = r.add(data)
+			assert.NoError(t, err, "duplicate fragments should be ignored")
+			assert.False(t, complete)
+		} else {
+			assert.True(t, complete)
+			assert.EqualValues(t, payload, reassembled)
+		}
+	}
+	assert.Empty(t, r.messages)
+
+	small := frames(client, []byte("small"))
+	assert.Len(t, small, 1, "small payloads should not be fragmented")
+	frameType, _, _ := unframe(server, small[0])
+	assert.EqualValues(t, sessionFrame, frameType)
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newReassembler()
+	r.now = func() time.Time { return now }
+
+	_, data, _ := unframe(newFrameAuth([]byte("key"), false), frames(newFrameAuth([]byte("key"), true), randomPayload(3*maxDatagramSize))[0])
+	_, complete, err := r.add(data)
+	assert.NoError(t, err)
+	assert.False(t, complete)
+	assert.Len(t, r.messages, 1)
+
+	now = now.Add(fragmentTimeout + time.Second)
+	r.add([]byte{2, 0, 2})
+	assert.Len(t, r.messages, 1, "incomplete messages should expire")
+
+	for id := 3; id < 3+maxPartialMessages; id++ {
+		now = now.Add(time.Millisecond)
+		r.add([]byte{byte(id), 0, 2})
+	}
+	assert.Len(t, r.messages, maxPartialMessages, "the number of partial messages should be limited")
+	assert.NotContains(t, r.messages, uint32(2), "the oldest partial message should be discarded")
+
+	for _, fragment := range [][]byte{
+		{},
+		{1},
+		{1, 0},
+		{1, 0, 1},
+		{1, 2, 2},
+		{1, 0, 0xff, 0xff, 0x7f},
+		{2 + maxPartialMessages, 1, 3},
+	} {
+		_, _, err := r.add(fragment)
+		assert.Error(t, err, "invalid fragment should be rejected: %v", fragment)
+	}
+}
+
+func FuzzReassembler(f *testing.F) {
+	f.Add([]byte{1, 0, 2, 'a'}, []byte{1, 1, 2, 'b'})
+	f.Add([]byte{1, 0, 2}, []byte{1, 0, 3})
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		r := newReassembler()
+		r.add(a)
+		r.add(b)
+	})
+}
+
+func TestLargeMessageLoopback(t *testing.T) {
+	serverConn, clientConn := newLossyConn(t), newLossyConn(t)
+	serverReceived := make(chan Packet, 10)
+	server := NewServer()
+	server.PacketReceived(func(packet Packet) { serverReceived <- packet })
+	defer server.Close()
+	clientReceived := make(chan Packet, 10)
+	client := NewClient()
+	client.PacketReceived(func(packet Packet) { clientReceived <- packet })
+	token := startLoopback(t, server, client, serverConn, clientConn)
+	defer client.Close()
+	stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+	defer stopFlush()
+
+	payload := randomPayload(150000)
+	client.WriteMessage("toServer", payload, ReliableOrdered)
+	server.WriteMessage(Packet{Token: token, Command: "toClient", Data: payload}, ReliableOrdered)
+
+	for _, received := range []chan Packet{serverReceived, clientReceived} {
+		select {
+		case packet := <-received:
+			assert.EqualValues(t, payload, packet.Data, "large messages should be reassembled")
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for large message")
+		}
+	}
+}
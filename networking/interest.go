@@ -0,0 +1,371 @@
+package networking
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const defaultInterestCellSize = 50
+
+// Positioned - an object with a position in world space, such as a *renderer.Node
+type Positioned interface {
+	WorldPosition() mgl32.Vec3
+}
+
+type gridCell [3]int32
+
+// SpatialGrid - a uniform grid of cells used to find the objects near a point
+type SpatialGrid struct {
+	CellSize float32
+
+	cells     map[gridCell]map[uint32]bool
+	positions map[uint32]mgl32.Vec3
+}
+
+func NewSpatialGrid(cellSize float32) *SpatialGrid {
+	return &SpatialGrid{
+		CellSize:  cellSize,
+		cells:     make(map[gridCell]map[uint32]bool),
+		positions: make(map[uint32]mgl32.Vec3),
+	}
+}
+
+func (g *SpatialGrid) cellOf(position mgl32.Vec3) gridCell {
+	return gridCell{
+		int32(math.Floor(float64(position.X() / g.CellSize))),
+		int32(math.Floor(float64(position.Y() / g.CellSize))),
+		int32(math.Floor(float64(position.Z() / g.CellSize))),
+	}
+}
+
+// Set - insert or move an object
+func (g *SpatialGrid) Set(id uint32, position mgl32.Vec3) {
+	if previous, ok := g.positions[id]; ok {
+		if g.cellOf(previous) == g.cellOf(position) {
+			g.positions[id] = position
+			return
+		}
+		g.Remove(id)
+	}
+	cell := g.cellOf(position)
+	if g.cells[cell] == nil {
+		g.cells[cell] = make(map[uint32]bool)
+	}
+	g.cells[cell][id] = true
+	g.positions[id] = position
+}
+
+func (g *SpatialGrid) Remove(id uint32) {
+	position, ok := g.positions[id]
+	if !ok {
+		return
+	}
+	cell := g.cellOf(position)
+	delete(g.cells[cell], id)
+	if len(g.cells[cell]) == 0 {
+		delete(g.cells, cell)
+	}
+	delete(g.positions, id)
+}
+
+func (g *SpatialGrid) Position(id uint32) (position mgl32.Vec3, ok bool) {
+	position, ok = g.positions[id]
+	return
+}
+
+// Query - the ids of all objects within radius of center, in ascending order.
+// Only occupied cells are visited, so a large radius costs no more than scanning every object.
+func (g *SpatialGrid) Query(center mgl32.Vec3, radius float32) []uint32 {
+	offset := mgl32.Vec3{radius, radius, radius}
+	min, max := g.cellOf(center.Sub(offset)), g.cellOf(center.Add(offset))
+	var result []uint32
+	query := func(ids map[uint32]bool) {
+		for id := range ids {
+			if g.positions[id].Sub(center).Len() <= radius {
+				result = append(result, id)
+			}
+		}
+	}
+
+	cellCount := int64(1)
+	for i := range min {
+		cellCount *= int64(max[i]) - int64(min[i]) + 1
+		if cellCount > int64(len(g.cells)) {
+			break
+		}
+	}
+	if cellCount > int64(len(g.cells)) {
+		for cell, ids := range g.cells {
+			if cell[0] >= min[0] && cell[0] <= max[0] && cell[1] >= min[1] && cell[1] <= max[1] && cell[2] >= min[2] && cell[2] <= max[2] {
+				query(ids)
+			}
+		}
+	} else {
+		for x := min[0]; x <= max[0]; x++ {
+			for y := min[1]; y <= max[1]; y++ {
+				for z := min[2]; z <= max[2]; z++ {
+					query(g.cells[gridCell{x, y, z}])
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+type viewpoint struct {
+	position  mgl32.Vec3
+	radius    float32
+	following Positioned
+	relevant  map[uint32]bool
+}
+
+// InterestManager - tracks which objects are relevant to each client, so the server only sends
+// updates for objects near the client's viewpoint. Objects enter a client's interest when they are within
+// the viewpoint's radius, and leave when they are further than the radius plus LeaveMargin.
+// Use with Replicator.SetInterest, or send events to the clients returned by InterestedClients.
+type InterestManager struct {
+	LeaveMargin float32
+
+	network    eventNetwork
+	mux        *sync.Mutex
+	grid       *SpatialGrid
+	tracked    map[uint32]Positioned
+	global     map[uint32]bool
+	viewpoints map[string]*viewpoint
+
+	onEnterInterest func(clientId string, id uint32)
+	onLeaveInterest func(clientId string, id uint32)
+}
+
+// NewInterestManager - create an interest manager on the server, using a spatial grid with the given cell size.
+// Viewpoints are removed automatically when clients leave.
+func NewInterestManager(network *Network, cellSize float32) *InterestManager {
+	return newInterestManager(network, cellSize)
+}
+
+func newInterestManager(network eventNetwork, cellSize float32) *InterestManager {
+	if cellSize <= 0 {
+		cellSize = defaultInterestCellSize
+	}
+	m := &InterestManager{
+		network:    network,
+		mux:        &sync.Mutex{},
+		grid:       NewSpatialGrid(cellSize),
+		tracked:    make(map[uint32]Positioned),
+		global:     make(map[uint32]bool),
+		viewpoints: make(map[string]*viewpoint),
+	}
+	network.ClientLeftEvent(func(clientId, reason string) { m.RemoveViewpoint(clientId) })
+	return m
+}
+
+// EnterInterestEvent - called when an object becomes relevant to a client
+func (m *InterestManager) EnterInterestEvent(fn func(clientId string, id uint32)) {
+	m.onEnterInterest = fn
+}
+
+// LeaveInterestEvent - called when an object is no longer relevant to a client
+func (m *InterestManager) LeaveInterestEvent(fn func(clientId string, id uint32)) {
+	m.onLeaveInterest = fn
+}
+
+// SetViewpoint - set the position and radius of interest for a client
+func (m *InterestManager) SetViewpoint(clientId string, position mgl32.Vec3, radius float32) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	vp := m.viewpoint(clientId)
+	vp.position, vp.radius, vp.following = position, radius, nil
+}
+
+// FollowViewpoint - use the position of an object (such as the client's player node) as the client's viewpoint
+func (m *InterestManager) FollowViewpoint(clientId string, object Positioned, radius float32) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	vp := m.viewpoint(clientId)
+	vp.radius, vp.following = radius, object
+}
+
+func (m *InterestManager) viewpoint(clientId string) *viewpoint {
+	vp, ok := m.viewpoints[clientId]
+	if !ok {
+		vp = &viewpoint{relevant: make(map[uint32]bool)}
+		m.viewpoints[clientId] = vp
+	}
+	return vp
+}
+
+func (m *InterestManager) RemoveViewpoint(clientId string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.viewpoints, clientId)
+}
+
+// SetPosition - set the position of an object
+func (m *InterestManager) SetPosition(id uint32, position mgl32.Vec3) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.tracked, id)
+	m.grid.Set(id, position)
+}
+
+// Track - update the position of an object from its WorldPosition every Update, such as a *renderer.Node
+func (m *InterestManager) Track(id uint32, object Positioned) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.tracked[id] = object
+	m.grid.Set(id, object.WorldPosition())
+}
+
+// SetGlobal - make an object relevant to every client regardless of position, such as a scoreboard
+func (m *InterestManager) SetGlobal(id uint32, global bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if global {
+		m.global[id] = true
+	} else {
+		delete(m.global, id)
+	}
+}
+
+// RemoveObject - stop tracking an object, it leaves the interest of every client on the next Update
+func (m *InterestManager) RemoveObject(id uint32) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.tracked, id)
+	delete(m.global, id)
+	m.grid.Remove(id)
+}
+
+type interestChange struct {
+	clientId string
+	id       uint32
+	enter    bool
+}
+
+// Update - recalculate the objects relevant to each client, triggering enter and leave interest events
+func (m *InterestManager) Update(dt float64) {
+	m.mux.Lock()
+	for id, object := range m.tracked {
+		m.grid.Set(id, object.WorldPosition())
+	}
+
+	var changes []interestChange
+	for _, clientId := range m.clientIds() {
+		vp := m.viewpoints[clientId]
+		if vp.following != nil {
+			vp.position = vp.following.WorldPosition()
+		}
+
+		relevant := make(map[uint32]bool)
+		for id := range m.global {
+			relevant[id] = true
+		}
+		for _, id := range m.grid.Query(vp.position, vp.radius+m.LeaveMargin) {
+			position, _ := m.grid.Position(id)
+			if vp.relevant[id] || position.Sub(vp.position).Len() <= vp.radius {
+				relevant[id] = true
+			}
+		}
+
+		for _, id := range sortedIds(relevant) {
+			if !vp.relevant[id] {
+				changes = append(changes, interestChange{clientId: clientId, id: id, enter: true})
+			}
+		}
+		for _, id := range sortedIds(vp.relevant) {
+			if !relevant[id] {
+				changes = append(changes, interestChange{clientId: clientId, id: id})
+			}
+		}
+		vp.relevant = relevant
+	}
+	m.mux.Unlock()
+
+	for _, change := range changes {
+		if change.enter && m.onEnterInterest != nil {
+			m.onEnterInterest(change.clientId, change.id)
+		}
+		if !change.enter && m.onLeaveInterest != nil {
+			m.onLeaveInterest(change.clientId, change.id)
+		}
+	}
+}
+
+// IsRelevant - true if the object was relevant to the client at the last Update.
+// Clients without a viewpoint receive every object.
+func (m *InterestManager) IsRelevant(clientId string, id uint32) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	vp, ok := m.viewpoints[clientId]
+	return !ok || vp.relevant[id]
+}
+
+// Relevant - the objects relevant to the client at the last Update, in ascending order
+func (m *InterestManager) Relevant(clientId string) []uint32 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if vp, ok := m.viewpoints[clientId]; ok {
+		return sortedIds(vp.relevant)
+	}
+	return nil
+}
+
+// InterestedClients - the clients with a viewpoint that the object was relevant to at the last Update
+func (m *InterestManager) InterestedClients(id uint32) []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var clients []string
+	for _, clientId := range m.clientIds() {
+		if m.viewpoints[clientId].relevant[id] {
+			clients = append(clients, clientId)
+		}
+	}
+	return clients
+}
+
+// TriggerInterested - trigger an event about an object on every client that the object is relevant to
+func (m *InterestManager) TriggerInterested(id uint32, name string, data []byte, mode ...DeliveryMode) {
+	for _, clientId := range m.InterestedClients(id) {
+		m.network.TriggerEvent(name, clientId, data, mode...)
+	}
+}
+
+// filter - the objects in the snapshot relevant to the client
+func (m *InterestManager) filter(clientId string, snapshot Snapshot) Snapshot {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	vp, ok := m.viewpoints[clientId]
+	if !ok {
+		return snapshot
+	}
+	filtered := Snapshot{Tick: snapshot.Tick, Objects: make(map[uint32][][]byte)}
+	for id, fields := range snapshot.Objects {
+		if vp.relevant[id] {
+			filtered.Objects[id] = fields
+		}
+	}
+	return filtered
+}
+
+// clientIds - the lock must be held
+func (m *InterestManager) clientIds() []string {
+	ids := make([]string, 0, len(m.viewpoints))
+	for clientId := range m.viewpoints {
+		ids = append(ids, clientId)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedIds(set map[uint32]bool) []uint32 {
+	ids := make([]uint32, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
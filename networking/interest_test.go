@@ -0,0 +1,128 @@
+package networking
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/renderer"
+)
+
+func TestSpatialGrid(t *testing.T) {
+	grid := NewSpatialGrid(10)
+	grid.Set(1, mgl32.Vec3{0, 0, 0})
+	grid.Set(2, mgl32.Vec3{15, 0, 0})
+	grid.Set(3, mgl32.Vec3{-25, 5, 0})
+	grid.Set(4, mgl32.Vec3{100, 100, 100})
+	assert.EqualValues(t, []uint32{1, 2}, grid.Query(mgl32.Vec3{5, 0, 0}, 10))
+	assert.EqualValues(t, []uint32{1, 3}, grid.Query(mgl32.Vec3{-10, 0, 0}, 16))
+
+	grid.Set(4, mgl32.Vec3{-20, 0, 0})
+	grid.Remove(1)
+	assert.EqualValues(t, []uint32{3, 4}, grid.Query(mgl32.Vec3{-10, 0, 0}, 16))
+	assert.Len(t, grid.cells, 3, "empty cells should be removed")
+
+	// a radius covering far more cells than are occupied
+	assert.EqualValues(t, []uint32{2, 3, 4}, grid.Query(mgl32.Vec3{}, 1e9))
+	assert.EqualValues(t, []uint32{3, 4}, grid.Query(mgl32.Vec3{-1e6, 0, 0}, 1e6-15))
+}
+
+func TestInterestManager(t *testing.T) {
+	serverNet := newFakeServer()
+	interest := newInterestManager(serverNet, 10)
+	interest.LeaveMargin = 5
+	var events []string
+	interest.EnterInterestEvent(func(clientId string, id uint32) { events = append(events, fmt.Sprintf("%v enter %v", clientId, id)) })
+	interest.LeaveInterestEvent(func(clientId string, id uint32) { events = append(events, fmt.Sprintf("%v leave %v", clientId, id)) })
+
+	player := renderer.NewNode()
+	interest.Track(1, player)
+	interest.SetPosition(2, mgl32.Vec3{30, 0, 0})
+	interest.SetPosition(3, mgl32.Vec3{200, 0, 0})
+	interest.SetGlobal(4, true)
+	serverNet.connect("a")
+	serverNet.connect("b")
+	interest.FollowViewpoint("a", player, 20)
+	interest.SetViewpoint("b", mgl32.Vec3{200, 0, 0}, 20)
+	interest.Update(0)
+	assert.EqualValues(t, []string{"a enter 1", "a enter 4", "b enter 3", "b enter 4"}, events)
+	assert.True(t, interest.IsRelevant("c", 3), "clients without a viewpoint receive everything")
+	assert.False(t, interest.IsRelevant("a", 3))
+
+	events = nil
+	player.SetTranslation(mgl32.Vec3{15, 0, 0})
+	interest.Update(0)
+	assert.EqualValues(t, []string{"a enter 2"}, events)
+	assert.EqualValues(t, []string{"a"}, interest.InterestedClients(1))
+
+	events = nil
+	player.SetTranslation(mgl32.Vec3{8, 0, 0})
+	interest.Update(0)
+	assert.Empty(t, events, "objects within the leave margin should stay relevant")
+	player.SetTranslation(mgl32.Vec3{0, 0, 0})
+	interest.Update(0)
+	assert.EqualValues(t, []string{"a leave 2"}, events)
+
+	// tracked objects are positioned in world space
+	events = nil
+	vehicle := renderer.NewNode()
+	vehicle.Add(player)
+	vehicle.SetTranslation(mgl32.Vec3{15, 0, 0})
+	interest.Update(0)
+	assert.EqualValues(t, []string{"a enter 2"}, events)
+	assert.EqualValues(t, mgl32.Vec3{15, 0, 0}, player.WorldPosition())
+
+	interest.TriggerInterested(4, "score", []byte{1})
+	assert.EqualValues(t, 2, serverNet.sent)
+	serverNet.disconnect("a", ReasonDisconnected)
+	assert.EqualValues(t, []string{"b"}, interest.InterestedClients(4))
+}
+
+func TestReplicatorInterest(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newReplicator(serverNet)
+	interest := newInterestManager(serverNet, 10)
+	server.SetInterest(interest)
+	positions := map[uint32]*mgl32.Vec3{1: {0, 0, 0}, 2: {100, 0, 0}}
+	for id, position := range positions {
+		server.Register(id).Vec3(position)
+		interest.SetPosition(id, *position)
+	}
+
+	client := newReplicator(serverNet.connect("a"))
+	clientPositions := map[uint32]*mgl32.Vec3{}
+	client.ObjectAddedEvent(func(id uint32) {
+		clientPositions[id] = &mgl32.Vec3{}
+		client.Register(id).Vec3(clientPositions[id])
+	})
+	client.ObjectRemovedEvent(func(id uint32) { delete(clientPositions, id) })
+
+	interest.SetViewpoint("a", mgl32.Vec3{0, 0, 0}, 20)
+	interest.Update(0)
+	server.SendSnapshot()
+	serverNet.flush()
+	assert.EqualValues(t, []uint32{1}, sortedPositionIds(clientPositions))
+
+	interest.SetViewpoint("a", mgl32.Vec3{90, 0, 0}, 20)
+	*positions[2] = mgl32.Vec3{95, 0, 0}
+	interest.SetPosition(2, *positions[2])
+	interest.Update(0)
+	server.SendSnapshot()
+	serverNet.flush()
+	assert.EqualValues(t, []uint32{2}, sortedPositionIds(clientPositions))
+	assert.EqualValues(t, mgl32.Vec3{95, 0, 0}, *clientPositions[2])
+
+	*positions[2] = mgl32.Vec3{96, 0, 0}
+	server.SendSnapshot()
+	serverNet.flush()
+	assert.EqualValues(t, mgl32.Vec3{96, 0, 0}, *clientPositions[2], "deltas should be encoded against the client's filtered baseline")
+}
+
+func sortedPositionIds(positions map[uint32]*mgl32.Vec3) []uint32 {
+	set := make(map[uint32]bool)
+	for id := range positions {
+		set[id] = true
+	}
+	return sortedIds(set)
+}
@@ -0,0 +1,493 @@
+package networking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	roomCreateEvent     = "__roomCreate"
+	roomListEvent       = "__roomList"
+	roomJoinEvent       = "__roomJoin"
+	roomLeaveEvent      = "__roomLeave"
+	roomListResultEvent = "__roomListResult"
+	roomJoinedEvent     = "__roomJoined"
+	roomLeftEvent       = "__roomLeft"
+	roomMemberEvent     = "__roomMember"
+	roomErrorEvent      = "__roomError"
+	roomEventPrefix     = "__room:"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomFull     = errors.New("room is full")
+	ErrRoomNameLong = fmt.Errorf("room names must be at most %v bytes", util.MaxStringLength)
+)
+
+// RoomInfo - a summary of a room, as listed by the lobby
+type RoomInfo struct {
+	Id         string
+	Name       string
+	Members    int
+	MaxClients int
+}
+
+type room struct {
+	info       RoomInfo
+	members    map[string]bool
+	persistent bool
+}
+
+// Lobby - groups clients into rooms so a server can run several matches at once.
+// Clients can create, list, join and leave rooms. Room events registered with RegisterRoomEvent
+// are received by the server along with the sender's room id, and can be broadcast to the members of a single room.
+// Each client can be in one room at a time.
+type Lobby struct {
+	network eventNetwork
+	mux     *sync.Mutex
+	rooms   map[string]*room
+	members map[string]string
+	nextId  int
+
+	// client state
+	roomId      string
+	roomMembers []string
+
+	onRoomsListed  func(rooms []RoomInfo)
+	onJoinedRoom   func(roomId string)
+	onLeftRoom     func(roomId string)
+	onMemberJoined func(roomId, clientId string)
+	onMemberLeft   func(roomId, clientId string)
+	onRoomError    func(err string)
+}
+
+// NewLobby - create a lobby and register the room events on the network.
+// The lobby must be created on the server and the clients, after the server is started or the client is connected,
+// so that only the server's room requests, or the client's room notifications, are registered.
+func NewLobby(network *Network) *Lobby {
+	return newLobby(network)
+}
+
+func newLobby(network eventNetwork) *Lobby {
+	l := &Lobby{
+		network: network,
+		mux:     &sync.Mutex{},
+		rooms:   make(map[string]*room),
+		members: make(map[string]string),
+	}
+	if network.IsServer() {
+		network.ClientLeftEvent(func(clientId, reason string) { l.leave(clientId) })
+		network.RegisterEvent(roomCreateEvent, l.handleCreate)
+		network.RegisterEvent(roomListEvent, l.handleList)
+		network.RegisterEvent(roomJoinEvent, l.handleJoin)
+		network.RegisterEvent(roomLeaveEvent, func(clientId string, data []byte) { l.leave(clientId) })
+	}
+	if network.IsClient() {
+		network.RegisterEvent(roomListResultEvent, l.handleListResult)
+		network.RegisterEvent(roomJoinedEvent, l.handleJoined)
+		network.RegisterEvent(roomLeftEvent, l.handleLeft)
+		network.RegisterEvent(roomMemberEvent, l.handleMember)
+		network.RegisterEvent(roomErrorEvent, func(clientId string, data []byte) {
+			if l.onRoomError != nil {
+				l.onRoomError(string(data))
+			}
+		})
+	}
+	return l
+}
+
+// RoomsListedEvent - called on the client with the result of ListRooms
+func (l *Lobby) RoomsListedEvent(fn func(rooms []RoomInfo)) {
+	l.onRoomsListed = fn
+}
+
+// JoinedRoomEvent - called on the client when it joins a room
+func (l *Lobby) JoinedRoomEvent(fn func(roomId string)) {
+	l.onJoinedRoom = fn
+}
+
+// LeftRoomEvent - called on the client when it leaves a room
+func (l *Lobby) LeftRoomEvent(fn func(roomId string)) {
+	l.onLeftRoom = fn
+}
+
+// MemberJoinedEvent - called on the server, and on the clients in the room, when a client joins a room
+func (l *Lobby) MemberJoinedEvent(fn func(roomId, clientId string)) {
+	l.onMemberJoined = fn
+}
+
+// MemberLeftEvent - called on the server, and on the clients in the room, when a client leaves a room
+func (l *Lobby) MemberLeftEvent(fn func(roomId, clientId string)) {
+	l.onMemberLeft = fn
+}
+
+// RoomErrorEvent - called on the client when a room request fails, eg. joining a room that is full
+func (l *Lobby) RoomErrorEvent(fn func(err string)) {
+	l.onRoomError = fn
+}
+
+// CreateRoom - on the client, request a new room and join it.
+// On the server, create a room that remains open when it is empty and return its id.
+// Names longer than util.MaxStringLength are rejected: the client's RoomError event is called and the server returns an empty id.
+func (l *Lobby) CreateRoom(name string, maxClients int) string {
+	if l.network.IsClient() {
+		data, err := util.SerializeArgs(name, uint32(maxClients))
+		if err != nil {
+			if l.onRoomError != nil {
+				l.onRoomError(ErrRoomNameLong.Error())
+			}
+			return ""
+		}
+		l.network.TriggerEvent(roomCreateEvent, "", data, ReliableOrdered)
+		return ""
+	}
+	if len(name) > util.MaxStringLength {
+		return ""
+	}
+	return l.createRoom(name, maxClients, true)
+}
+
+// ListRooms - request the list of rooms from the server, the result is passed to the RoomsListed event
+func (l *Lobby) ListRooms() {
+	l.network.TriggerEvent(roomListEvent, "", []byte{}, ReliableOrdered)
+}
+
+// JoinRoom - join a room, leaving the current room if there is one
+func (l *Lobby) JoinRoom(roomId string) {
+	l.network.TriggerEvent(roomJoinEvent, "", []byte(roomId), ReliableOrdered)
+}
+
+// LeaveRoom - leave the current room
+func (l *Lobby) LeaveRoom() {
+	l.network.TriggerEvent(roomLeaveEvent, "", []byte{}, ReliableOrdered)
+}
+
+// Room - on the client, the id of the room the client is in, or "" if it is not in a room
+func (l *Lobby) Room() string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.roomId
+}
+
+// RoomOf - on the server, the id of the room the client is in, or "" if it is not in a room
+func (l *Lobby) RoomOf(clientId string) string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.members[clientId]
+}
+
+// Members - the clients in a room. On the client the roomId is ignored and the members of the current room are returned.
+func (l *Lobby) Members(roomId string) []string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.network.IsClient() {
+		return append([]string{}, l.roomMembers...)
+	}
+	if r, ok := l.rooms[roomId]; ok {
+		return r.memberList()
+	}
+	return nil
+}
+
+// Rooms - on the server, all open rooms
+func (l *Lobby) Rooms() []RoomInfo {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	rooms := make([]RoomInfo, 0, len(l.rooms))
+	for _, r := range l.rooms {
+		info := r.info
+		info.Members = len(r.members)
+		rooms = append(rooms, info)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Id < rooms[j].Id })
+	return rooms
+}
+
+// RegisterRoomEvent - register an event that is scoped to rooms.
+// On the server the handler receives the room id of the client that triggered the event,
+// events from clients that are not in a room are ignored. On the client the room id is the current room.
+func (l *Lobby) RegisterRoomEvent(name string, fn func(roomId, clientId string, data []byte)) {
+	l.network.RegisterEvent(roomEventPrefix+name, func(clientId string, data []byte) {
+		var roomId string
+		if l.network.IsClient() {
+			roomId = l.Room()
+		} else {
+			roomId = l.RoomOf(clientId)
+		}
+		if len(roomId) > 0 {
+			fn(roomId, clientId, data)
+		}
+	})
+}
+
+// TriggerRoomEvent - on the client, trigger a room event on the server
+func (l *Lobby) TriggerRoomEvent(name string, data []byte, mode ...DeliveryMode) {
+	l.network.TriggerEvent(roomEventPrefix+name, "", data, mode...)
+}
+
+// BroadcastToRoom - on the server, trigger a room event on every client in the room
+func (l *Lobby) BroadcastToRoom(roomId, name string, data []byte, mode ...DeliveryMode) {
+	for _, clientId := range l.Members(roomId) {
+		l.network.TriggerEvent(roomEventPrefix+name, clientId, data, mode...)
+	}
+}
+
+func (l *Lobby) createRoom(name string, maxClients int, persistent bool) string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.nextId++
+	id := fmt.Sprint(l.nextId)
+	l.rooms[id] = &room{
+		info:       RoomInfo{Id: id, Name: name, MaxClients: maxClients},
+		members:    make(map[string]bool),
+		persistent: persistent,
+	}
+	return id
+}
+
+// join - add a client to a room on the server
+func (l *Lobby) join(clientId, roomId string) error {
+	l.mux.Lock()
+	r, ok := l.rooms[roomId]
+	if !ok {
+		l.mux.Unlock()
+		return ErrRoomNotFound
+	}
+	if r.members[clientId] {
+		l.mux.Unlock()
+		return nil
+	}
+	if r.info.MaxClients > 0 && len(r.members) >= r.info.MaxClients {
+		l.mux.Unlock()
+		return ErrRoomFull
+	}
+	previousRoom, previousMembers, left := l.removeMember(clientId)
+	r.members[clientId] = true
+	l.members[clientId] = roomId
+	members := r.memberList()
+	l.mux.Unlock()
+
+	if left {
+		l.notifyLeft(clientId, previousRoom, previousMembers)
+	}
+	buf := new(bytes.Buffer)
+	util.Stringbytes(buf, roomId)
+	util.UInt32Bytes(buf, uint32(len(members)))
+	for _, member := range members {
+		util.Stringbytes(buf, member)
+	}
+	l.network.TriggerEvent(roomJoinedEvent, clientId, buf.Bytes(), ReliableOrdered)
+	l.notifyMembers(roomId, clientId, true, members)
+	if l.onMemberJoined != nil {
+		l.onMemberJoined(roomId, clientId)
+	}
+	return nil
+}
+
+// leave - remove a client from its room on the server
+func (l *Lobby) leave(clientId string) {
+	l.mux.Lock()
+	roomId, members, ok := l.removeMember(clientId)
+	l.mux.Unlock()
+	if ok {
+		l.notifyLeft(clientId, roomId, members)
+	}
+}
+
+// removeMember - remove a client from its room, closing the room if it is empty. The lock must be held.
+func (l *Lobby) removeMember(clientId string) (string, []string, bool) {
+	roomId, ok := l.members[clientId]
+	if !ok {
+		return "", nil, false
+	}
+	delete(l.members, clientId)
+	r := l.rooms[roomId]
+	delete(r.members, clientId)
+	if len(r.members) == 0 && !r.persistent {
+		delete(l.rooms, roomId)
+	}
+	return roomId, r.memberList(), true
+}
+
+func (l *Lobby) notifyLeft(clientId, roomId string, members []string) {
+	l.network.TriggerEvent(roomLeftEvent, clientId, []byte(roomId), ReliableOrdered)
+	l.notifyMembers(roomId, clientId, false, members)
+	if l.onMemberLeft != nil {
+		l.onMemberLeft(roomId, clientId)
+	}
+}
+
+// notifyMembers - tell the other members of a room that a client joined or left
+func (l *Lobby) notifyMembers(roomId, clientId string, joined bool, members []string) {
+	data, _ := util.SerializeArgs(roomId, clientId, joined)
+	for _, member := range members {
+		if member != clientId {
+			l.network.TriggerEvent(roomMemberEvent, member, data, ReliableOrdered)
+		}
+	}
+}
+
+func (l *Lobby) sendError(clientId string, err error) {
+	l.network.TriggerEvent(roomErrorEvent, clientId, []byte(err.Error()), ReliableOrdered)
+}
+
+func (l *Lobby) handleCreate(clientId string, data []byte) {
+	buf := bytes.NewBuffer(data)
+	name, err := util.Stringfrombytes(buf)
+	if err != nil {
+		return
+	}
+	maxClients, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return
+	}
+	roomId := l.createRoom(name, int(maxClients), false)
+	if err := l.join(clientId, roomId); err != nil {
+		l.sendError(clientId, err)
+	}
+}
+
+func (l *Lobby) handleList(clientId string, data []byte) {
+	buf := new(bytes.Buffer)
+	rooms := l.Rooms()
+	util.UInt32Bytes(buf, uint32(len(rooms)))
+	for _, info := range rooms {
+		util.Stringbytes(buf, info.Id)
+		util.Stringbytes(buf, info.Name)
+		util.UInt32Bytes(buf, uint32(info.Members))
+		util.UInt32Bytes(buf, uint32(info.MaxClients))
+	}
+	l.network.TriggerEvent(roomListResultEvent, clientId, buf.Bytes(), ReliableOrdered)
+}
+
+func (l *Lobby) handleJoin(clientId string, data []byte) {
+	if err := l.join(clientId, string(data)); err != nil {
+		l.sendError(clientId, err)
+	}
+}
+
+func (l *Lobby) handleListResult(clientId string, data []byte) {
+	buf := bytes.NewBuffer(data)
+	count, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return
+	}
+	var rooms []RoomInfo
+	for i := 0; i < int(count); i++ {
+		var info RoomInfo
+		var members, maxClients uint32
+		if info.Id, err = util.Stringfrombytes(buf); err != nil {
+			return
+		}
+		if info.Name, err = util.Stringfrombytes(buf); err != nil {
+			return
+		}
+		if members, err = util.UInt32frombytes(buf); err != nil {
+			return
+		}
+		if maxClients, err = util.UInt32frombytes(buf); err != nil {
+			return
+		}
+		info.Members, info.MaxClients = int(members), int(maxClients)
+		rooms = append(rooms, info)
+	}
+	if l.onRoomsListed != nil {
+		l.onRoomsListed(rooms)
+	}
+}
+
+func (l *Lobby) handleJoined(clientId string, data []byte) {
+	buf := bytes.NewBuffer(data)
+	roomId, err := util.Stringfrombytes(buf)
+	if err != nil {
+		return
+	}
+	count, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return
+	}
+	var members []string
+	for i := 0; i < int(count); i++ {
+		member, err := util.Stringfrombytes(buf)
+		if err != nil {
+			return
+		}
+		members = append(members, member)
+	}
+	l.mux.Lock()
+	l.roomId = roomId
+	l.roomMembers = members
+	l.mux.Unlock()
+	if l.onJoinedRoom != nil {
+		l.onJoinedRoom(roomId)
+	}
+}
+
+func (l *Lobby) handleLeft(clientId string, data []byte) {
+	roomId := string(data)
+	l.mux.Lock()
+	if l.roomId != roomId {
+		l.mux.Unlock()
+		return
+	}
+	l.roomId, l.roomMembers = "", nil
+	l.mux.Unlock()
+	if l.onLeftRoom != nil {
+		l.onLeftRoom(roomId)
+	}
+}
+
+func (l *Lobby) handleMember(clientId string, data []byte) {
+	buf := bytes.NewBuffer(data)
+	roomId, err := util.Stringfrombytes(buf)
+	if err != nil {
+		return
+	}
+	memberId, err := util.Stringfrombytes(buf)
+	if err != nil {
+		return
+	}
+	joined, err := util.BoolFromBytes(buf)
+	if err != nil {
+		return
+	}
+
+	l.mux.Lock()
+	if l.roomId != roomId {
+		l.mux.Unlock()
+		return
+	}
+	if joined {
+		l.roomMembers = append(l.roomMembers, memberId)
+		sort.Strings(l.roomMembers)
+	} else {
+		for i, member := range l.roomMembers {
+			if member == memberId {
+				l.roomMembers = append(l.roomMembers[:i], l.roomMembers[i+1:]...)
+				break
+			}
+		}
+	}
+	l.mux.Unlock()
+
+	if joined && l.onMemberJoined != nil {
+		l.onMemberJoined(roomId, memberId)
+	}
+	if !joined && l.onMemberLeft != nil {
+		l.onMemberLeft(roomId, memberId)
+	}
+}
+
+func (r *room) memberList() []string {
+	members := make([]string, 0, len(r.members))
+	for clientId := range r.members {
+		members = append(members, clientId)
+	}
+	sort.Strings(members)
+	return members
+}
@@ -0,0 +1,173 @@
+package networking
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+type lobbyClient struct {
+	*Lobby
+	events []string
+	rooms  []RoomInfo
+	errors []string
+}
+
+func newLobbyClient(server *fakeNetwork, clientId string) *lobbyClient {
+	client := &lobbyClient{Lobby: newLobby(server.connect(clientId))}
+	client.JoinedRoomEvent(func(roomId string) { client.events = append(client.events, "joined "+roomId) })
+	client.LeftRoomEvent(func(roomId string) { client.events = append(client.events, "left "+roomId) })
+	client.MemberJoinedEvent(func(roomId, clientId string) {
+		client.events = append(client.events, clientId+" joined "+roomId)
+	})
+	client.MemberLeftEvent(func(roomId, clientId string) {
+		client.events = append(client.events, clientId+" left "+roomId)
+	})
+	client.RoomsListedEvent(func(rooms []RoomInfo) { client.rooms = rooms })
+	client.RoomErrorEvent(func(err string) { client.errors = append(client.errors, err) })
+	return client
+}
+
+func TestLobbyRooms(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newLobby(serverNet)
+	var serverEvents []string
+	server.MemberJoinedEvent(func(roomId, clientId string) { serverEvents = append(serverEvents, clientId+" joined "+roomId) })
+	server.MemberLeftEvent(func(roomId, clientId string) { serverEvents = append(serverEvents, clientId+" left "+roomId) })
+	lobbyId := server.CreateRoom("lobby", 0)
+
+	a, b, c := newLobbyClient(serverNet, "a"), newLobbyClient(serverNet, "b"), newLobbyClient(serverNet, "c")
+	a.CreateRoom("match", 2)
+	serverNet.flush()
+	matchId := a.Room()
+	assert.NotEmpty(t, matchId)
+	assert.EqualValues(t, matchId, server.RoomOf("a"))
+
+	b.ListRooms()
+	serverNet.flush()
+	assert.EqualValues(t, []RoomInfo{
+		{Id: lobbyId, Name: "lobby", Members: 0, MaxClients: 0},
+		{Id: matchId, Name: "match", Members: 1, MaxClients: 2},
+	}, b.rooms)
+
+	b.JoinRoom(matchId)
+	c.JoinRoom(matchId)
+	serverNet.flush()
+	assert.EqualValues(t, []string{"a", "b"}, server.Members(matchId))
+	assert.EqualValues(t, []string{"a", "b"}, a.Members(""))
+	assert.EqualValues(t, []string{"a", "b"}, b.Members(""))
+	assert.EqualValues(t, []string{ErrRoomFull.Error()}, c.errors)
+	assert.Empty(t, c.Room())
+
+	c.JoinRoom("missing")
+	c.JoinRoom(lobbyId)
+	serverNet.flush()
+	assert.EqualValues(t, []string{ErrRoomFull.Error(), ErrRoomNotFound.Error()}, c.errors)
+	assert.EqualValues(t, lobbyId, c.Room())
+
+	b.JoinRoom(lobbyId)
+	serverNet.flush()
+	assert.EqualValues(t, []string{"a"}, a.Members(""))
+	assert.EqualValues(t, []string{"b", "c"}, c.Members(""))
+
+	a.LeaveRoom()
+	serverNet.flush()
+	assert.Empty(t, a.Room())
+	assert.Len(t, server.Rooms(), 1, "empty rooms created by clients should be closed")
+
+	serverNet.disconnect("b", ReasonDisconnected)
+	assert.EqualValues(t, []string{"c"}, server.Members(lobbyId))
+	serverNet.flush()
+	assert.EqualValues(t, []string{"c"}, c.Members(""))
+
+	assert.EqualValues(t, []string{"joined " + matchId, "b joined " + matchId, "b left " + matchId, "left " + matchId}, a.events)
+	assert.EqualValues(t, []string{"joined " + lobbyId, "b joined " + lobbyId, "b left " + lobbyId}, c.events)
+	assert.EqualValues(t, []string{
+		"a joined " + matchId, "b joined " + matchId, "c joined " + lobbyId,
+		"b left " + matchId, "b joined " + lobbyId, "a left " + matchId, "b left " + lobbyId,
+	}, serverEvents)
+}
+
+func TestLobbyRoomEvents(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newLobby(serverNet)
+	red, blue := server.CreateRoom("red", 0), server.CreateRoom("blue", 0)
+
+	a, b, c := newLobbyClient(serverNet, "a"), newLobbyClient(serverNet, "b"), newLobbyClient(serverNet, "c")
+	lonely := newLobbyClient(serverNet, "lonely")
+	a.JoinRoom(red)
+	b.JoinRoom(red)
+	c.JoinRoom(blue)
+	serverNet.flush()
+
+	server.RegisterRoomEvent("chat", func(roomId, clientId string, data []byte) {
+		server.BroadcastToRoom(roomId, "chat", append([]byte(clientId+": "), data...))
+	})
+	received := make(map[string][]string)
+	for name, client := range map[string]*lobbyClient{"a": a, "b": b, "c": c, "lonely": lonely} {
+		name := name
+		client.RegisterRoomEvent("chat", func(roomId, clientId string, data []byte) {
+			received[name] = append(received[name], roomId+" "+string(data))
+		})
+	}
+
+	a.TriggerRoomEvent("chat", []byte("hello red"))
+	c.TriggerRoomEvent("chat", []byte("hello blue"))
+	lonely.TriggerRoomEvent("chat", []byte("hello?"))
+	serverNet.flush()
+
+	assert.EqualValues(t, map[string][]string{
+		"a": {red + " a: hello red"},
+		"b": {red + " a: hello red"},
+		"c": {blue + " c: hello blue"},
+	}, received, "room events should only be broadcast to the sender's room")
+}
+
+func TestLobbyLongRoomNames(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newLobby(serverNet)
+	a := newLobbyClient(serverNet, "a")
+
+	long := strings.Repeat("x", util.MaxStringLength+1)
+	assert.EqualValues(t, "", server.CreateRoom(long, 0))
+	a.CreateRoom(long, 0)
+	serverNet.flush()
+	assert.EqualValues(t, []string{ErrRoomNameLong.Error()}, a.errors)
+	assert.Empty(t, server.Rooms())
+
+	name := strings.Repeat("x", util.MaxStringLength)
+	a.CreateRoom(name, 0)
+	serverNet.flush()
+	a.ListRooms()
+	serverNet.flush()
+	if assert.Len(t, a.rooms, 1) {
+		assert.EqualValues(t, name, a.rooms[0].Name)
+	}
+}
+
+func TestLobbyIgnoresClientNotifications(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newLobby(serverNet)
+	var serverEvents []string
+	server.MemberJoinedEvent(func(roomId, clientId string) { serverEvents = append(serverEvents, clientId+" joined "+roomId) })
+	server.MemberLeftEvent(func(roomId, clientId string) { serverEvents = append(serverEvents, clientId+" left "+roomId) })
+
+	attacker := serverNet.connect("attacker")
+	for _, joined := range []bool{true, false} {
+		data, _ := util.SerializeArgs("", "victim", joined)
+		attacker.TriggerEvent(roomMemberEvent, "", data, ReliableOrdered)
+	}
+	joined := new(bytes.Buffer)
+	util.Stringbytes(joined, "spoofed")
+	util.UInt32Bytes(joined, 1)
+	util.Stringbytes(joined, "victim")
+	attacker.TriggerEvent(roomJoinedEvent, "", joined.Bytes(), ReliableOrdered)
+	serverNet.flush()
+
+	assert.Empty(t, serverEvents, "room notifications from clients should be ignored by the server")
+	assert.Empty(t, server.Room())
+	assert.Empty(t, server.Members(""))
+}
@@ -1,6 +1,11 @@
 package networking
 
 import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/walesey/go-engine/emitter"
@@ -13,11 +18,32 @@ type Network struct {
 	server       *Server
 	stopInterval func()
 	writeBuffer  chan message
+	writerDone   chan struct{}
+	preSharedKey []byte
+	authenticate Authenticator
+	credentials  []byte
+	captureMux   sync.Mutex
+	capture      *Capture
+}
+
+// eventNetwork - the event api of Network, used by the higher level networking components
+type eventNetwork interface {
+	RegisterEvent(name string, fn func(clientId string, data []byte))
+	TriggerEvent(name, clientId string, data []byte, mode ...DeliveryMode)
+	ClientJoinedEvent(fn func(clientId string))
+	ClientLeftEvent(fn func(clientId, reason string))
+	IsClient() bool
+	IsServer() bool
+}
+
+type clientLeft struct {
+	clientId, reason string
 }
 
 type message struct {
 	Packet
 	broadcast bool
+	mode      DeliveryMode
 }
 
 func NewNetwork() *Network {
@@ -27,32 +53,99 @@ func NewNetwork() *Network {
 	}
 }
 
-func (n *Network) StartServer(port int) {
+// StartServer - start a server on the given port.
+// An optional Transport can be given, the default is UDPTransport.
+func (n *Network) StartServer(port int, transport ...Transport) {
 	n.server = NewServer()
+	n.server.PreSharedKey = n.preSharedKey
+	n.server.Authenticate = n.authenticate
 	n.server.ClientJoinedEvent(func(clientId string) {
 		n.Emit("newClient", clientId)
 	})
+	n.server.ClientLeftEvent(func(clientId, reason string) {
+		n.Emit("clientLeft", clientLeft{clientId: clientId, reason: reason})
+	})
 	n.server.PacketReceived(func(packet Packet) {
+		n.record(CaptureReceived, packet)
 		n.Emit(packet.Command, packet)
 	})
-	n.server.Listen(port)
+	if err := n.server.ListenTransport(transportOf(transport), fmt.Sprintf(":%v", port)); err != nil {
+		log.Println("Error starting server: ", err)
+	}
 	n.startWriteInterval(50 * time.Millisecond)
 	n.startMessageWriter()
 }
 
-func (n *Network) ConnectClient(addr string) error {
+// ConnectClient - connect to the server at addr.
+// An optional Transport can be given, the default is UDPTransport.
+func (n *Network) ConnectClient(addr string, transport ...Transport) error {
 	n.client = NewClient()
+	n.client.PreSharedKey = n.preSharedKey
+	n.client.Credentials = n.credentials
 	n.client.PacketReceived(func(packet Packet) {
+		n.record(CaptureReceived, packet)
 		n.Emit(packet.Command, packet)
 	})
-	if err := n.client.Connect(addr); err != nil {
+	n.client.DisconnectedEvent(func(reason string) {
+		n.Emit("disconnected", reason)
+	})
+	if err := n.client.ConnectTransport(transportOf(transport), addr); err != nil {
 		return err
 	}
 	n.startMessageWriter()
-	n.writeMessage("", "", []byte{}, false)
 	return nil
 }
 
+// SetPreSharedKey - set a key that the server and clients must share, call before StartServer or ConnectClient
+func (n *Network) SetPreSharedKey(key []byte) {
+	n.preSharedKey = key
+}
+
+// SetAuthenticator - set a callback used by the server to accept or reject clients, call before StartServer
+func (n *Network) SetAuthenticator(authenticate Authenticator) {
+	n.authenticate = authenticate
+}
+
+// SetCredentials - set the credentials the client sends to the server's authenticator, call before ConnectClient
+func (n *Network) SetCredentials(credentials []byte) {
+	n.credentials = credentials
+}
+
+// StartCapture - record every packet sent and received to w, see ReadCapture and Replay
+func (n *Network) StartCapture(w io.Writer) error {
+	capture, err := NewCapture(w)
+	if err != nil {
+		return err
+	}
+	n.captureMux.Lock()
+	defer n.captureMux.Unlock()
+	n.capture = capture
+	return nil
+}
+
+// StopCapture - stop recording packets and flush the capture
+func (n *Network) StopCapture() error {
+	n.captureMux.Lock()
+	capture := n.capture
+	n.capture = nil
+	n.captureMux.Unlock()
+	if capture == nil {
+		return nil
+	}
+	return capture.Flush()
+}
+
+func (n *Network) record(direction CaptureDirection, packet Packet) {
+	n.captureMux.Lock()
+	capture := n.capture
+	n.captureMux.Unlock()
+	if capture != nil {
+		if err := capture.Record(direction, packet); err != nil {
+			log.Println("Error writing packet capture: ", err)
+		}
+	}
+}
+
 // Update is used when using network in a fully syncronous manner.
 // Update should not be called when using asyncronous (channel) based event handling
 func (n *Network) Update(dt float64) {
@@ -68,6 +161,38 @@ func (n *Network) ClientJoinedEvent(fn func(clientId string)) {
 	})
 }
 
+// ClientLeftEvent - register an event to trigger when a client disconnects, times out or is kicked from the server
+func (n *Network) ClientLeftEvent(fn func(clientId, reason string)) {
+	n.On("clientLeft", func(event emitter.Event) {
+		if left, ok := event.(clientLeft); ok {
+			fn(left.clientId, left.reason)
+		}
+	})
+}
+
+// DisconnectedEvent - register an event to trigger when the client is disconnected from the server
+func (n *Network) DisconnectedEvent(fn func(reason string)) {
+	n.On("disconnected", func(event emitter.Event) {
+		if reason, ok := event.(string); ok {
+			fn(reason)
+		}
+	})
+}
+
+// Kick - disconnect a client from the server
+func (n *Network) Kick(clientId, reason string) {
+	if n.IsServer() {
+		n.server.Kick(clientId, reason)
+	}
+}
+
+// Ban - disconnect a client and reject future connections from the same host
+func (n *Network) Ban(clientId, reason string) {
+	if n.IsServer() {
+		n.server.Ban(clientId, reason)
+	}
+}
+
 // RegisterEvent - register an event that will be triggered on clients and server.
 // RegisterEvent should be used to register syncronous events
 func (n *Network) RegisterEvent(name string, fn func(clientId string, data []byte)) {
@@ -80,25 +205,28 @@ func (n *Network) RegisterEvent(name string, fn func(clientId string, data []byt
 
 // TriggerEvent - Trigger an event to run on a particular client.
 // If called on the client, this will trigger the event on the server.
-func (n *Network) TriggerEvent(name, clientId string, data []byte) {
-	n.writeMessage(name, clientId, data, false)
+// An optional DeliveryMode can be given, the default is Unreliable.
+func (n *Network) TriggerEvent(name, clientId string, data []byte, mode ...DeliveryMode) {
+	n.writeMessage(name, clientId, data, false, deliveryMode(mode))
 }
 
 // BroadcastEvent - trigger an event on all clients.
 // If called on the client, this will trigger the event on the server.
-func (n *Network) BroadcastEvent(name string, data []byte) {
-	n.writeMessage(name, "", data, true)
+// An optional DeliveryMode can be given, the default is Unreliable.
+func (n *Network) BroadcastEvent(name string, data []byte, mode ...DeliveryMode) {
+	n.writeMessage(name, "", data, true, deliveryMode(mode))
 }
 
 // CallOnServerAndClient - trigger an event on the server and on all client.
 // If called on the client, this will trigger the event on the client and on the server.
-func (n *Network) TriggerOnServerAndClients(name string, data []byte) {
+// An optional DeliveryMode can be given, the default is Unreliable.
+func (n *Network) TriggerOnServerAndClients(name string, data []byte, mode ...DeliveryMode) {
 	n.Emit(name, Packet{Data: data})
-	n.writeMessage(name, "", data, true)
+	n.writeMessage(name, "", data, true, deliveryMode(mode))
 }
 
 func (n *Network) ClientToken() string {
-	return n.client.token
+	return n.client.getToken()
 }
 
 func (n *Network) FlushAllWriteBuffers() {
@@ -118,7 +246,7 @@ func (n *Network) BytesSent() int64 {
 		return n.client.bytesSent
 	}
 	if n.IsServer() {
-		return n.server.bytesSent
+		return atomic.LoadInt64(&n.server.bytesSent)
 	}
 	return 0
 }
@@ -155,10 +283,14 @@ func (n *Network) IsServer() bool {
 	return n.server != nil
 }
 
+// Close - stop the message writer, once the buffered messages are written, then close the client and server
 func (n *Network) Close() {
+	close(n.writeBuffer)
+	if n.writerDone != nil {
+		<-n.writerDone
+	}
 	n.killClient()
 	n.killServer()
-	close(n.writeBuffer)
 }
 
 func (n *Network) killClient() {
@@ -173,6 +305,7 @@ func (n *Network) killServer() {
 	if n.server != nil {
 		n.server.Close()
 		n.Off("newClient")
+		n.Off("clientLeft")
 	}
 	n.killInterval()
 	n.server = nil
@@ -188,38 +321,49 @@ func (n *Network) startWriteInterval(bufferedWriteDuration time.Duration) {
 func (n *Network) killInterval() {
 	if n.stopInterval != nil {
 		n.stopInterval()
+		n.stopInterval = nil
 	}
 }
 
 // async message writing
 func (n *Network) startMessageWriter() {
 	close(n.writeBuffer)
+	if n.writerDone != nil {
+		<-n.writerDone
+	}
 	n.writeBuffer = make(chan message, 64)
-	go n.writeMessages()
+	n.writerDone = make(chan struct{})
+	go writeMessages(n.writeBuffer, n.writerDone, n.client, n.server)
 }
 
-func (n *Network) writeMessages() {
-	for msg := range n.writeBuffer {
-		if n.IsClient() {
-			n.client.WriteMessage(msg.Command, msg.Data)
+// writeMessages - write the buffered messages until the buffer is closed.
+// The client and server are passed in so the writer never reads the Network's fields while they are cleared by Close.
+func writeMessages(buffer chan message, done chan struct{}, client *Client, server *Server) {
+	defer close(done)
+	for msg := range buffer {
+		if client != nil {
+			client.WriteMessage(msg.Command, msg.Data, msg.mode)
 		}
-		if n.IsServer() {
+		if server != nil {
 			if msg.broadcast {
-				n.server.BroadcastMessage(msg.Command, msg.Data)
+				server.BroadcastMessage(msg.Command, msg.Data, msg.mode)
 			} else {
-				n.server.WriteMessage(msg.Packet)
+				server.WriteMessage(msg.Packet, msg.mode)
 			}
 		}
 	}
 }
 
-func (n *Network) writeMessage(name, clientId string, data []byte, broadcast bool) {
+func (n *Network) writeMessage(name, clientId string, data []byte, broadcast bool, mode DeliveryMode) {
+	packet := Packet{
+		Token:   clientId,
+		Command: name,
+		Data:    data,
+	}
+	n.record(CaptureSent, packet)
 	n.writeBuffer <- message{
 		broadcast: broadcast,
-		Packet: Packet{
-			Token:   clientId,
-			Command: name,
-			Data:    data,
-		},
+		mode:      mode,
+		Packet:    packet,
 	}
 }
@@ -0,0 +1,119 @@
+package networking
+
+import "time"
+
+type fakeEvent struct {
+	to       *fakeNetwork
+	name     string
+	clientId string
+	data     []byte
+}
+
+// fakeNetwork - an in memory eventNetwork, events are queued until flush is called
+type fakeNetwork struct {
+	clientId string
+	server   *fakeNetwork
+	clients  map[string]*fakeNetwork
+	handlers map[string][]func(clientId string, data []byte)
+	joined   []func(clientId string)
+	left     []func(clientId, reason string)
+	sent     int
+
+	// shared by the server and all clients
+	queue   *[]fakeEvent
+	elapsed *time.Duration
+	latency func() time.Duration
+	drop    func(event fakeEvent) bool
+}
+
+func newFakeServer() *fakeNetwork {
+	var elapsed time.Duration
+	return &fakeNetwork{
+		clients:  make(map[string]*fakeNetwork),
+		handlers: make(map[string][]func(string, []byte)),
+		queue:    &[]fakeEvent{},
+		elapsed:  &elapsed,
+		latency:  func() time.Duration { return 0 },
+		drop:     func(event fakeEvent) bool { return false },
+	}
+}
+
+// connect - create a client connected to this server
+func (f *fakeNetwork) connect(clientId string) *fakeNetwork {
+	client := &fakeNetwork{
+		clientId: clientId,
+		server:   f,
+		handlers: make(map[string][]func(string, []byte)),
+		queue:    f.queue,
+		elapsed:  f.elapsed,
+	}
+	f.clients[clientId] = client
+	for _, fn := range f.joined {
+		fn(clientId)
+	}
+	return client
+}
+
+// disconnect - remove a client from this server
+func (f *fakeNetwork) disconnect(clientId, reason string) {
+	delete(f.clients, clientId)
+	for _, fn := range f.left {
+		fn(clientId, reason)
+	}
+}
+
+func (f *fakeNetwork) root() *fakeNetwork {
+	if f.server != nil {
+		return f.server
+	}
+	return f
+}
+
+// flush - deliver queued events until there are none left
+func (f *fakeNetwork) flush() {
+	root := f.root()
+	for len(*root.queue) > 0 {
+		event := (*root.queue)[0]
+		*root.queue = (*root.queue)[1:]
+		*root.elapsed += root.latency()
+		if root.drop(event) {
+			continue
+		}
+		for _, fn := range event.to.handlers[event.name] {
+			fn(event.clientId, event.data)
+		}
+	}
+}
+
+func (f *fakeNetwork) RegisterEvent(name string, fn func(clientId string, data []byte)) {
+	f.handlers[name] = append(f.handlers[name], fn)
+}
+
+func (f *fakeNetwork) TriggerEvent(name, clientId string, data []byte, mode ...DeliveryMode) {
+	f.sent++
+	event := fakeEvent{name: name, clientId: f.clientId, data: data}
+	if f.IsClient() {
+		event.to = f.server
+	} else if client, ok := f.clients[clientId]; ok {
+		event.to, event.clientId = client, clientId
+	} else {
+		return
+	}
+	*f.queue = append(*f.queue, event)
+}
+
+func (f *fakeNetwork) ClientJoinedEvent(fn func(clientId string)) {
+	f.joined = append(f.joined, fn)
+}
+
+func (f *fakeNetwork) ClientLeftEvent(fn func(clientId, reason string)) {
+	f.left = append(f.left, fn)
+}
+
+func (f *fakeNetwork) IsClient() bool {
+	return f.server != nil
+}
+
+func (f *fakeNetwork) IsServer() bool {
+	return f.server == nil
+}
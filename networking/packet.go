@@ -2,8 +2,11 @@ package networking
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 )
 
 type Packet struct {
@@ -12,37 +15,92 @@ type Packet struct {
 	Data    []byte
 }
 
+// packetVersion - the first byte of every encoded packet, so the framing can be changed in future
+const packetVersion byte = 1
+
+// maxMessageSize - the largest decompressed datagram or reassembled message that will be accepted
+const maxMessageSize = 16 << 20
+
+// Encode - encode a packet as the version byte, the varint lengths of the token, command and data,
+// followed by the token, command and data
 func Encode(packet Packet) []byte {
-	tokenLen := len(packet.Token)
-	commandLen := len(packet.Command)
-	dataLen := len(packet.Data)
-	data := new(bytes.Buffer)
-	data.WriteByte(byte(tokenLen))
-	data.WriteByte(byte(commandLen))
-	binary.Write(data, binary.LittleEndian, uint16(dataLen))
-	data.WriteString(packet.Token)
-	data.WriteString(packet.Command)
-	data.Write(packet.Data)
-	return data.Bytes()
+	data := make([]byte, 0, 1+3*binary.MaxVarintLen64+len(packet.Token)+len(packet.Command)+len(packet.Data))
+	var lengths [binary.MaxVarintLen64]byte
+	data = append(data, packetVersion)
+	for _, length := range []int{len(packet.Token), len(packet.Command), len(packet.Data)} {
+		n := binary.PutUvarint(lengths[:], uint64(length))
+		data = append(data, lengths[:n]...)
+	}
+	data = append(data, packet.Token...)
+	data = append(data, packet.Command...)
+	return append(data, packet.Data...)
 }
 
+// Decode - decode the packet starting at index i, returning the index of the next packet.
+// If the packet is truncated or malformed an error is returned along with len(data).
 func Decode(data []byte, i int) (Packet, error, int) {
-	if len(data)-i < 4 {
+	if i < 0 || i >= len(data) {
 		return Packet{}, fmt.Errorf("No data provided to Decode: len=%v", len(data)), len(data)
 	}
-	tokenLen := int(data[i])
-	commandLen := int(data[i+1])
-	dataLen := int(binary.LittleEndian.Uint16(data[i+2 : i+4]))
-	i += 4
-	token := string(data[i : i+tokenLen])
-	i += tokenLen
-	command := string(data[i : i+commandLen])
-	i += commandLen
-	packetData := data[i : i+dataLen]
-	i += dataLen
+	if data[i] != packetVersion {
+		return Packet{}, fmt.Errorf("Unsupported packet version: %v", data[i]), len(data)
+	}
+	i++
+
+	var lengths [3]int
+	for j := range lengths {
+		length, n := binary.Uvarint(data[i:])
+		if n <= 0 {
+			return Packet{}, fmt.Errorf("Invalid packet length at index %v", i), len(data)
+		}
+		if length > uint64(len(data)-i-n) {
+			return Packet{}, fmt.Errorf("Packet length %v exceeds the remaining data", length), len(data)
+		}
+		lengths[j] = int(length)
+		i += n
+	}
+	if lengths[0]+lengths[1]+lengths[2] > len(data)-i {
+		return Packet{}, fmt.Errorf("Truncated packet: len=%v", len(data)), len(data)
+	}
+
+	token := string(data[i : i+lengths[0]])
+	i += lengths[0]
+	command := string(data[i : i+lengths[1]])
+	i += lengths[1]
+	packetData := data[i : i+lengths[2]]
+	i += lengths[2]
 	return Packet{
 		Token:   token,
 		Command: command,
 		Data:    packetData,
 	}, nil, i
 }
+
+// compress - gzip data to be written to a udp connection
+func compress(data []byte) ([]byte, error) {
+	var gzipBuf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipBuf)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return gzipBuf.Bytes(), nil
+}
+
+// decompress - unzip data read from a udp connection
+func decompress(data []byte) ([]byte, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	unzipped, err := ioutil.ReadAll(io.LimitReader(gzipReader, maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(unzipped) > maxMessageSize {
+		return nil, fmt.Errorf("Message exceeds the maximum size: %v", maxMessageSize)
+	}
+	return unzipped, nil
+}
@@ -1,7 +1,8 @@
 package networking
 
 import (
-	"encoding/binary"
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,9 +14,7 @@ func TestEncode(t *testing.T) {
 		Command: "testCommand",
 		Data:    []byte("test Data"),
 	}
-	var bytes [2]byte
-	binary.LittleEndian.PutUint16(bytes[:], uint16(len(packet.Data)))
-	expectedData := []byte{byte(len(packet.Token)), byte(len(packet.Command)), bytes[0], bytes[1]}
+	expectedData := []byte{packetVersion, byte(len(packet.Token)), byte(len(packet.Command)), byte(len(packet.Data))}
 	expectedData = append(expectedData, []byte(packet.Token)...)
 	expectedData = append(expectedData, []byte(packet.Command)...)
 	expectedData = append(expectedData, packet.Data...)
@@ -60,3 +59,67 @@ func TestDecodeMultiple(t *testing.T) {
 	assert.EqualValues(t, len(data), i, "Decode should return the correct read index")
 	assert.EqualValues(t, testPacket2, packet, "Decode second packet didn't work")
 }
+
+func TestEncodeLargePacket(t *testing.T) {
+	expectedPacket := Packet{
+		Token:   strings.Repeat("t", 300),
+		Command: strings.Repeat("c", 1000),
+		Data:    bytes.Repeat([]byte("data"), 100000),
+	}
+	data := Encode(expectedPacket)
+
+	packet, err, i := Decode(data, 0)
+	assert.Nil(t, err, "decode should not return an error")
+	assert.EqualValues(t, len(data), i, "Decode should return the correct read index")
+	assert.EqualValues(t, expectedPacket, packet, "tokens, commands and data longer than 255/65535 bytes should not be truncated")
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	data := Encode(Packet{Token: "123", Command: "testCommand", Data: []byte("test Data")})
+	for i := 0; i < len(data); i++ {
+		_, err, next := Decode(data[:i], 0)
+		assert.NotNil(t, err, "decoding a truncated packet should fail: len=%v", i)
+		assert.EqualValues(t, i, next, "Decode should return len(data) on error")
+	}
+
+	_, err, _ := Decode(append([]byte{packetVersion + 1}, data[1:]...), 0)
+	assert.NotNil(t, err, "decoding an unknown version should fail")
+
+	_, err, _ = Decode([]byte{packetVersion, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0, 0}, 0)
+	assert.NotNil(t, err, "decoding an overflowing length should fail")
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(Encode(Packet{Token: "123", Command: "testCommand", Data: []byte("test Data")}))
+	f.Add(append(Encode(Packet{Command: "a"}), Encode(Packet{Token: "b", Data: []byte{1, 2}})...))
+	f.Add([]byte{packetVersion, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80})
+	f.Add([]byte{packetVersion, 5, 0, 0, 'a'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for i := 0; i < len(data); {
+			packet, err, next := Decode(data, i)
+			if next <= i {
+				t.Fatalf("Decode did not advance: %v -> %v", i, next)
+			}
+			if err == nil {
+				assert.True(t, next-i >= 4+len(packet.Token)+len(packet.Command)+len(packet.Data), "Decode should not read past the packet")
+			}
+			i = next
+		}
+	})
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	f.Add("123", "testCommand", []byte("test Data"))
+	f.Add("", "", []byte{})
+	f.Fuzz(func(t *testing.T, token, command string, data []byte) {
+		packet := Packet{Token: token, Command: command, Data: data}
+		encoded := Encode(packet)
+		decoded, err, i := Decode(encoded, 0)
+		assert.Nil(t, err)
+		assert.EqualValues(t, len(encoded), i)
+		assert.EqualValues(t, packet.Token, decoded.Token)
+		assert.EqualValues(t, packet.Command, decoded.Command)
+		assert.EqualValues(t, len(packet.Data), len(decoded.Data))
+		assert.True(t, bytes.Equal(packet.Data, decoded.Data))
+	})
+}
@@ -0,0 +1,246 @@
+package networking
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/walesey/go-engine/util"
+)
+
+// DeliveryMode - controls how a message is delivered to the remote peer
+type DeliveryMode byte
+
+const (
+	// Unreliable - the message may be dropped, duplicated or arrive out of order
+	Unreliable DeliveryMode = iota
+	// UnreliableSequenced - the message may be dropped, but older messages are discarded if they arrive after newer ones
+	UnreliableSequenced
+	// ReliableOrdered - the message is resent until acknowledged and is delivered exactly once, in order
+	ReliableOrdered
+)
+
+const (
+	sequencedCommand = "__seq"
+	reliableCommand  = "__rel"
+	ackCommand       = "__ack"
+)
+
+const (
+	defaultResendTimeout = 100 * time.Millisecond
+	resendInterval       = 20 * time.Millisecond
+
+	// reliableWindow - the most reliable packets buffered ahead of the next expected packet,
+	// packets beyond the window are not acknowledged and are resent later
+	reliableWindow = 1024
+)
+
+type pendingPacket struct {
+	packet Packet
+	sent   time.Time
+}
+
+// reliableChannel - sequencing, acknowledgement and retransmission state for a single remote peer
+type reliableChannel struct {
+	write         func(packet Packet)
+	now           func() time.Time
+	resendTimeout time.Duration
+
+	mux              *sync.Mutex
+	sequencedOut     uint32
+	sequencedIn      uint32
+	reliableOut      uint32
+	reliableExpected uint32
+	pending          map[uint32]*pendingPacket
+	reliableReceived map[uint32]Packet
+}
+
+func newReliableChannel(write func(packet Packet)) *reliableChannel {
+	return &reliableChannel{
+		write:            write,
+		now:              time.Now,
+		resendTimeout:    defaultResendTimeout,
+		mux:              &sync.Mutex{},
+		reliableExpected: 1,
+		pending:          make(map[uint32]*pendingPacket),
+		reliableReceived: make(map[uint32]Packet),
+	}
+}
+
+func deliveryMode(modes []DeliveryMode) DeliveryMode {
+	if len(modes) > 0 {
+		return modes[0]
+	}
+	return Unreliable
+}
+
+// send - write a packet using the given delivery mode
+func (c *reliableChannel) send(packet Packet, mode DeliveryMode) {
+	switch mode {
+	case UnreliableSequenced:
+		c.mux.Lock()
+		c.sequencedOut++
+		seq := c.sequencedOut
+		c.mux.Unlock()
+		c.write(wrapPacket(packet, sequencedCommand, seq))
+	case ReliableOrdered:
+		c.mux.Lock()
+		c.reliableOut++
+		seq := c.reliableOut
+		wrapped := wrapPacket(packet, reliableCommand, seq)
+		c.pending[seq] = &pendingPacket{packet: wrapped, sent: c.now()}
+		c.mux.Unlock()
+		c.write(wrapped)
+	default:
+		c.write(packet)
+	}
+}
+
+// receive - process an incoming packet and return any packets that are ready to be delivered
+func (c *reliableChannel) receive(packet Packet) []Packet {
+	switch packet.Command {
+	case ackCommand:
+		c.receiveAcks(packet.Data)
+		return nil
+	case sequencedCommand:
+		seq, inner, err := unwrapPacket(packet)
+		if err != nil {
+			return nil
+		}
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		if seq <= c.sequencedIn {
+			return nil
+		}
+		c.sequencedIn = seq
+		return []Packet{inner}
+	case reliableCommand:
+		seq, inner, err := unwrapPacket(packet)
+		if err != nil {
+			return nil
+		}
+		ready, ok := c.receiveReliable(seq, inner)
+		if ok {
+			c.sendAck(packet.Token, seq)
+		}
+		return ready
+	}
+	return []Packet{packet}
+}
+
+// receiveReliable - buffer a reliable packet and return the packets that are ready in order.
+// Returns false if the packet is beyond the receive window and should not be acknowledged.
+func (c *reliableChannel) receiveReliable(seq uint32, packet Packet) ([]Packet, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if seq < c.reliableExpected {
+		return nil, true // duplicate
+	}
+	if seq-c.reliableExpected >= reliableWindow {
+		return nil, false
+	}
+	if _, ok := c.reliableReceived[seq]; ok {
+		return nil, true // duplicate
+	}
+	c.reliableReceived[seq] = packet
+
+	var ready []Packet
+	for {
+		next, ok := c.reliableReceived[c.reliableExpected]
+		if !ok {
+			break
+		}
+		ready = append(ready, next)
+		delete(c.reliableReceived, c.reliableExpected)
+		c.reliableExpected++
+	}
+	return ready, true
+}
+
+func (c *reliableChannel) sendAck(token string, seq uint32) {
+	buf := new(bytes.Buffer)
+	util.UInt32Bytes(buf, seq)
+	c.write(Packet{
+		Token:   token,
+		Command: ackCommand,
+		Data:    buf.Bytes(),
+	})
+}
+
+func (c *reliableChannel) receiveAcks(data []byte) {
+	buf := bytes.NewBuffer(data)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for buf.Len() >= 4 {
+		seq, err := util.UInt32frombytes(buf)
+		if err != nil {
+			return
+		}
+		delete(c.pending, seq)
+	}
+}
+
+// resend - write all reliable packets that have not been acknowledged within the resend timeout
+func (c *reliableChannel) resend() {
+	c.mux.Lock()
+	now := c.now()
+	var expired []uint32
+	for seq, p := range c.pending {
+		if now.Sub(p.sent) >= c.resendTimeout {
+			expired = append(expired, seq)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	packets := make([]Packet, len(expired))
+	for i, seq := range expired {
+		p := c.pending[seq]
+		p.sent = now
+		packets[i] = p.packet
+	}
+	c.mux.Unlock()
+
+	for _, packet := range packets {
+		c.write(packet)
+	}
+}
+
+// pendingCount - the number of reliable packets waiting to be acknowledged
+func (c *reliableChannel) pendingCount() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.pending)
+}
+
+func wrapPacket(packet Packet, command string, seq uint32) Packet {
+	buf := new(bytes.Buffer)
+	util.UInt32Bytes(buf, seq)
+	util.Stringbytes(buf, packet.Command)
+	buf.Write(packet.Data)
+	return Packet{
+		Token:   packet.Token,
+		Command: command,
+		Data:    buf.Bytes(),
+	}
+}
+
+func unwrapPacket(packet Packet) (uint32, Packet, error) {
+	if len(packet.Data) < 5 {
+		return 0, Packet{}, fmt.Errorf("Sequenced packet is too short: len=%v", len(packet.Data))
+	}
+	buf := bytes.NewBuffer(packet.Data)
+	seq, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return 0, Packet{}, err
+	}
+	command, err := util.Stringfrombytes(buf)
+	if err != nil {
+		return 0, Packet{}, err
+	}
+	return seq, Packet{
+		Token:   packet.Token,
+		Command: command,
+		Data:    buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,213 @@
+package networking
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+// lossyConn - a net.PacketConn that drops every nth write and optionally swaps the order of consecutive writes
+type lossyConn struct {
+	net.PacketConn
+	mux       sync.Mutex
+	writes    int
+	dropEvery int
+	reorder   bool
+	held      []byte
+	heldAddr  net.Addr
+}
+
+func newLossyConn(t *testing.T) *lossyConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &lossyConn{PacketConn: conn}
+}
+
+func (l *lossyConn) setConditions(dropEvery int, reorder bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.dropEvery, l.reorder = dropEvery, reorder
+}
+
+func (l *lossyConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.writes++
+	if l.dropEvery > 0 && l.writes%l.dropEvery == 0 {
+		return len(p), nil
+	}
+	if l.reorder {
+		if l.held == nil {
+			l.held, l.heldAddr = append([]byte{}, p...), addr
+			return len(p), nil
+		}
+		n, err := l.PacketConn.WriteTo(p, addr)
+		l.PacketConn.WriteTo(l.held, l.heldAddr)
+		l.held = nil
+		return n, err
+	}
+	return l.PacketConn.WriteTo(p, addr)
+}
+
+// channelPair - two reliableChannels connected by in memory queues
+type channelPair struct {
+	a, b       *reliableChannel
+	aOut, bOut []Packet
+	now        time.Time
+}
+
+func newChannelPair() *channelPair {
+	pair := &channelPair{now: time.Unix(0, 0)}
+	pair.a = newReliableChannel(func(packet Packet) { pair.aOut = append(pair.aOut, packet) })
+	pair.b = newReliableChannel(func(packet Packet) { pair.bOut = append(pair.bOut, packet) })
+	pair.a.now = func() time.Time { return pair.now }
+	pair.b.now = func() time.Time { return pair.now }
+	return pair
+}
+
+// deliver - deliver queued packets in reverse order, dropping any where drop(i) is true
+func deliver(queue []Packet, to *reliableChannel, drop func(i int) bool) (received []Packet) {
+	for i := len(queue) - 1; i >= 0; i-- {
+		if !drop(i) {
+			received = append(received, to.receive(queue[i])...)
+		}
+	}
+	return
+}
+
+func TestReliableOrderedDelivery(t *testing.T) {
+	pair := newChannelPair()
+	for i := 0; i < 10; i++ {
+		pair.a.send(Packet{Command: "test", Data: []byte{byte(i)}}, ReliableOrdered)
+	}
+
+	var received []Packet
+	for round := 0; round < 10 && len(received) < 10; round++ {
+		queue := pair.aOut
+		pair.aOut = nil
+		received = append(received, deliver(queue, pair.b, func(i int) bool { return i%3 == round%3 })...)
+
+		acks := pair.bOut
+		pair.bOut = nil
+		deliver(acks, pair.a, func(i int) bool { return i%2 == 0 })
+
+		pair.now = pair.now.Add(defaultResendTimeout)
+		pair.a.resend()
+	}
+
+	assert.Len(t, received, 10, "all reliable packets should be delivered")
+	for i, packet := range received {
+		assert.EqualValues(t, "test", packet.Command)
+		assert.EqualValues(t, []byte{byte(i)}, packet.Data, "reliable packets should be delivered in order")
+	}
+
+	queue := pair.aOut
+	pair.aOut = nil
+	assert.Empty(t, deliver(queue, pair.b, func(i int) bool { return false }), "duplicates should be suppressed")
+	deliver(pair.bOut, pair.a, func(i int) bool { return false })
+	assert.EqualValues(t, 0, pair.a.pendingCount(), "all packets should be acknowledged")
+}
+
+func TestReliableReceiveWindow(t *testing.T) {
+	pair := newChannelPair()
+	for i := 0; i <= reliableWindow; i++ {
+		pair.a.send(Packet{Command: "test"}, ReliableOrdered)
+	}
+	queue := pair.aOut
+	pair.aOut = nil
+
+	// packets beyond the window are neither buffered nor acknowledged
+	assert.Empty(t, deliver(queue[1:], pair.b, func(i int) bool { return false }))
+	assert.Len(t, pair.b.reliableReceived, reliableWindow-1)
+	assert.Len(t, pair.bOut, reliableWindow-1)
+	deliver(pair.bOut, pair.a, func(i int) bool { return false })
+	pair.bOut = nil
+	assert.EqualValues(t, 2, pair.a.pendingCount())
+
+	received := deliver(queue[:1], pair.b, func(i int) bool { return false })
+	assert.Len(t, received, reliableWindow)
+	pair.now = pair.now.Add(defaultResendTimeout)
+	pair.a.resend()
+	received = deliver(pair.aOut, pair.b, func(i int) bool { return false })
+	assert.Len(t, received, 1, "packets beyond the window should be delivered when they are resent")
+}
+
+func TestUnreliableSequencedDelivery(t *testing.T) {
+	pair := newChannelPair()
+	for i := 0; i < 5; i++ {
+		pair.a.send(Packet{Command: "test", Data: []byte{byte(i)}}, UnreliableSequenced)
+	}
+
+	received := deliver(pair.aOut, pair.b, func(i int) bool { return false })
+	assert.Len(t, received, 1, "older sequenced packets should be discarded")
+	assert.EqualValues(t, []byte{4}, received[0].Data)
+	assert.Empty(t, pair.bOut, "sequenced packets should not be acknowledged")
+	assert.EqualValues(t, 0, pair.a.pendingCount(), "sequenced packets should not be resent")
+}
+
+func TestUnreliableDelivery(t *testing.T) {
+	pair := newChannelPair()
+	pair.a.send(Packet{Command: "test", Data: []byte{1}}, Unreliable)
+	pair.a.send(Packet{Command: "test", Data: []byte{1}}, Unreliable)
+
+	received := deliver(pair.aOut, pair.b, func(i int) bool { return false })
+	assert.Len(t, received, 2)
+	assert.EqualValues(t, Packet{Command: "test", Data: []byte{1}}, received[0])
+}
+
+func TestReliableOrderedLoopback(t *testing.T) {
+	serverConn, clientConn := newLossyConn(t), newLossyConn(t)
+
+	serverReceived := make(chan Packet, 100)
+	server := NewServer()
+	server.PacketReceived(func(packet Packet) { serverReceived <- packet })
+	server.Serve(serverConn)
+	defer server.Close()
+	stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+	defer stopFlush()
+
+	clientReceived := make(chan Packet, 100)
+	connected := make(chan string, 1)
+	client := NewClient()
+	client.PacketReceived(func(packet Packet) { clientReceived <- packet })
+	client.ConnectedEvent(func(token string) { connected <- token })
+	client.ConnectConn(clientConn, serverConn.LocalAddr())
+	defer client.Close()
+
+	var token string
+	select {
+	case token = <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for session token")
+	}
+
+	serverConn.setConditions(3, true)
+	clientConn.setConditions(4, true)
+
+	for i := 0; i < 30; i++ {
+		client.WriteMessage("toServer", []byte(fmt.Sprint(i)), ReliableOrdered)
+		server.WriteMessage(Packet{Token: token, Command: "toClient", Data: []byte(fmt.Sprint(i))}, ReliableOrdered)
+	}
+
+	for _, expect := range []struct {
+		received chan Packet
+		command  string
+	}{{serverReceived, "toServer"}, {clientReceived, "toClient"}} {
+		for i := 0; i < 30; i++ {
+			select {
+			case packet := <-expect.received:
+				assert.EqualValues(t, expect.command, packet.Command)
+				assert.EqualValues(t, fmt.Sprint(i), string(packet.Data), "packets should arrive in order")
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %v packet %v", expect.command, i)
+			}
+		}
+	}
+}
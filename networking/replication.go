@@ -0,0 +1,629 @@
+package networking
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	snapshotEvent    = "__snapshot"
+	snapshotAckEvent = "__snapshotAck"
+)
+
+const (
+	snapshotHistorySize     = 64
+	defaultSnapshotInterval = 50 * time.Millisecond
+)
+
+const (
+	objectRemoved byte = iota
+	objectFull
+	objectDelta
+)
+
+// Snapshot - the replicated state of all objects at a server tick.
+// Objects maps each object id to its encoded field values.
+type Snapshot struct {
+	Tick    uint32
+	Objects map[uint32][][]byte
+}
+
+type replicatedField struct {
+	encode func(w io.Writer) error
+	decode func(r io.Reader) error
+}
+
+// ReplicatedObject - a set of fields that are replicated from the server to all clients.
+// Fields must be registered in the same order on the server and the clients.
+type ReplicatedObject struct {
+	Id     uint32
+	fields []replicatedField
+}
+
+// Field - register a field with custom encode/decode functions
+func (o *ReplicatedObject) Field(encode func(w io.Writer) error, decode func(r io.Reader) error) *ReplicatedObject {
+	o.fields = append(o.fields, replicatedField{encode: encode, decode: decode})
+	return o
+}
+
+func (o *ReplicatedObject) Vec2(v *mgl32.Vec2) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Vector2bytes(w, *v) },
+		func(r io.Reader) error {
+			value, err := util.Vector2frombytes(r)
+			if err == nil {
+				*v = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Vec3(v *mgl32.Vec3) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Vector3bytes(w, *v) },
+		func(r io.Reader) error {
+			value, err := util.Vector3frombytes(r)
+			if err == nil {
+				*v = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Quat(q *mgl32.Quat) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Quatbytes(w, *q) },
+		func(r io.Reader) error {
+			value, err := util.Quatfrombytes(r)
+			if err == nil {
+				*q = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Float32(f *float32) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Float32bytes(w, *f) },
+		func(r io.Reader) error {
+			value, err := util.Float32frombytes(r)
+			if err == nil {
+				*f = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Float64(f *float64) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Float64bytes(w, *f) },
+		func(r io.Reader) error {
+			value, err := util.Float64frombytes(r)
+			if err == nil {
+				*f = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Int(i *int) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.UInt32Bytes(w, uint32(*i)) },
+		func(r io.Reader) error {
+			value, err := util.UInt32frombytes(r)
+			if err == nil {
+				*i = int(int32(value))
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Int32(i *int32) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.UInt32Bytes(w, uint32(*i)) },
+		func(r io.Reader) error {
+			value, err := util.UInt32frombytes(r)
+			if err == nil {
+				*i = int32(value)
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) Bool(b *bool) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.BoolBytes(w, *b) },
+		func(r io.Reader) error {
+			value, err := util.BoolFromBytes(r)
+			if err == nil {
+				*b = value
+			}
+			return err
+		},
+	)
+}
+
+// String - replicate a string, strings longer than util.MaxStringLength can not be encoded
+func (o *ReplicatedObject) String(s *string) *ReplicatedObject {
+	return o.Field(
+		func(w io.Writer) error { return util.Stringbytes(w, *s) },
+		func(r io.Reader) error {
+			value, err := util.Stringfrombytes(r)
+			if err == nil {
+				*s = value
+			}
+			return err
+		},
+	)
+}
+
+func (o *ReplicatedObject) encodeFields() [][]byte {
+	values := make([][]byte, len(o.fields))
+	for i, field := range o.fields {
+		buf := new(bytes.Buffer)
+		if err := field.encode(buf); err != nil {
+			fmt.Println("Error encoding replicated field: ", err)
+		}
+		values[i] = buf.Bytes()
+	}
+	return values
+}
+
+func (o *ReplicatedObject) decodeFields(values [][]byte) error {
+	for i, field := range o.fields {
+		if i >= len(values) {
+			break
+		}
+		if err := field.decode(bytes.NewBuffer(values[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replicator - sends snapshots of all registered objects from the server to every client.
+// Each client acknowledges the snapshots it receives, and the server encodes the next snapshot
+// as a delta against the last acknowledged one, falling back to a full snapshot when no baseline is available.
+type Replicator struct {
+	SendInterval time.Duration
+
+	network         eventNetwork
+	mux             *sync.Mutex
+	objects         map[uint32]*ReplicatedObject
+	history         map[uint32]Snapshot
+	baselines       map[string]uint32
+	interest        *InterestManager
+	clientHistory   map[string]map[uint32]Snapshot
+	tick            uint32
+	sinceLast       time.Duration
+	onObjectAdded   func(id uint32)
+	onObjectRemoved func(id uint32)
+}
+
+// NewReplicator - create a replicator and register the snapshot events on the network.
+// The replicator must be created after the server is started or the client is connected, so that only clients
+// accept snapshots and only the server accepts acks.
+// Add the replicator to the engine (AddUpdatable) on the server to send snapshots every SendInterval.
+func NewReplicator(network *Network) *Replicator {
+	return newReplicator(network)
+}
+
+func newReplicator(network eventNetwork) *Replicator {
+	r := &Replicator{
+		SendInterval:  defaultSnapshotInterval,
+		network:       network,
+		mux:           &sync.Mutex{},
+		objects:       make(map[uint32]*ReplicatedObject),
+		history:       make(map[uint32]Snapshot),
+		baselines:     make(map[string]uint32),
+		clientHistory: make(map[string]map[uint32]Snapshot),
+	}
+	if network.IsServer() {
+		network.ClientJoinedEvent(r.AddClient)
+		network.ClientLeftEvent(func(clientId, reason string) { r.RemoveClient(clientId) })
+		network.RegisterEvent(snapshotAckEvent, r.handleAck)
+	}
+	if network.IsClient() {
+		network.RegisterEvent(snapshotEvent, r.handleSnapshot)
+	}
+	return r
+}
+
+// Register - register a new replicated object, or return the existing object with the given id
+func (r *Replicator) Register(id uint32) *ReplicatedObject {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if obj, ok := r.objects[id]; ok {
+		return obj
+	}
+	obj := &ReplicatedObject{Id: id}
+	r.objects[id] = obj
+	return obj
+}
+
+func (r *Replicator) Unregister(id uint32) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.objects, id)
+}
+
+func (r *Replicator) Object(id uint32) (obj *ReplicatedObject, ok bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	obj, ok = r.objects[id]
+	return
+}
+
+// ObjectAddedEvent - called on the client when a snapshot contains an object that is not registered.
+// The callback should Register the object with the same fields as the server.
+func (r *Replicator) ObjectAddedEvent(fn func(id uint32)) {
+	r.onObjectAdded = fn
+}
+
+// ObjectRemovedEvent - called on the client when an object is no longer in the snapshot.
+// The object is unregistered after the callback.
+func (r *Replicator) ObjectRemovedEvent(fn func(id uint32)) {
+	r.onObjectRemoved = fn
+}
+
+// SetInterest - only send each client the objects that are relevant to it.
+// Objects entering or leaving a client's interest are added or removed on that client.
+// The interest manager must be updated before the replicator.
+func (r *Replicator) SetInterest(interest *InterestManager) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.interest = interest
+}
+
+// AddClient - start sending snapshots to a client. This is called automatically when a client joins.
+func (r *Replicator) AddClient(clientId string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.baselines[clientId] = 0
+	r.clientHistory[clientId] = make(map[uint32]Snapshot)
+}
+
+// RemoveClient - stop sending snapshots to a client. This is called automatically when a client leaves.
+func (r *Replicator) RemoveClient(clientId string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.baselines, clientId)
+	delete(r.clientHistory, clientId)
+}
+
+// Update - send a snapshot to all clients every SendInterval
+func (r *Replicator) Update(dt float64) {
+	if !r.network.IsServer() {
+		return
+	}
+	r.sinceLast += time.Duration(dt * float64(time.Second))
+	if r.sinceLast >= r.SendInterval {
+		r.sinceLast = 0
+		r.SendSnapshot()
+	}
+}
+
+// SendSnapshot - take a snapshot and send it to all clients immediately
+func (r *Replicator) SendSnapshot() {
+	snapshot := r.TakeSnapshot()
+	r.mux.Lock()
+	deltas := make(map[string][]byte)
+	for clientId, tick := range r.baselines {
+		if r.interest == nil {
+			deltas[clientId] = EncodeSnapshotDelta(r.history[tick], snapshot)
+			continue
+		}
+		filtered := r.interest.filter(clientId, snapshot)
+		r.clientHistory[clientId][snapshot.Tick] = filtered
+		deltas[clientId] = EncodeSnapshotDelta(r.clientHistory[clientId][tick], filtered)
+	}
+	r.mux.Unlock()
+
+	for clientId, data := range deltas {
+		r.network.TriggerEvent(snapshotEvent, clientId, data)
+	}
+}
+
+// TakeSnapshot - capture the current state of all registered objects
+func (r *Replicator) TakeSnapshot() Snapshot {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.tick++
+	snapshot := Snapshot{
+		Tick:    r.tick,
+		Objects: make(map[uint32][][]byte),
+	}
+	for id, obj := range r.objects {
+		snapshot.Objects[id] = obj.encodeFields()
+	}
+	r.storeSnapshot(snapshot)
+	return snapshot
+}
+
+func (r *Replicator) storeSnapshot(snapshot Snapshot) {
+	r.history[snapshot.Tick] = snapshot
+	for tick := range r.history {
+		if tick+snapshotHistorySize <= r.tick {
+			delete(r.history, tick)
+		}
+	}
+	for clientId, tick := range r.baselines {
+		if _, ok := r.history[tick]; !ok {
+			r.baselines[clientId] = 0
+		}
+	}
+	for _, history := range r.clientHistory {
+		for tick := range history {
+			if _, ok := r.history[tick]; !ok {
+				delete(history, tick)
+			}
+		}
+	}
+}
+
+func (r *Replicator) handleAck(clientId string, data []byte) {
+	tick, err := util.UInt32frombytes(bytes.NewBuffer(data))
+	if err != nil {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if baseline, ok := r.baselines[clientId]; ok && tick > baseline {
+		if _, ok := r.history[tick]; ok {
+			r.baselines[clientId] = tick
+		}
+	}
+}
+
+func (r *Replicator) handleSnapshot(clientId string, data []byte) {
+	baseTick, err := snapshotDeltaBase(data)
+	if err != nil {
+		fmt.Println("Error decoding snapshot: ", err)
+		return
+	}
+
+	r.mux.Lock()
+	base, ok := r.history[baseTick]
+	r.mux.Unlock()
+	if !ok && baseTick != 0 {
+		return // the baseline is unknown, wait for the server to send a full snapshot
+	}
+
+	snapshot, err := DecodeSnapshotDelta(base, data)
+	if err != nil {
+		fmt.Println("Error decoding snapshot: ", err)
+		return
+	}
+
+	r.mux.Lock()
+	latest := r.tick
+	if snapshot.Tick > latest {
+		r.tick = snapshot.Tick
+	}
+	r.storeSnapshot(snapshot)
+	r.mux.Unlock()
+
+	ack, _ := util.SerializeArgs(snapshot.Tick)
+	r.network.TriggerEvent(snapshotAckEvent, "", ack)
+	if snapshot.Tick > latest {
+		r.ApplySnapshot(snapshot)
+	}
+}
+
+// ApplySnapshot - decode the snapshot into the registered objects.
+// ObjectAdded and ObjectRemoved events are triggered for objects that are not registered or no longer exist.
+func (r *Replicator) ApplySnapshot(snapshot Snapshot) {
+	for _, id := range sortedObjectIds(snapshot.Objects) {
+		obj, ok := r.Object(id)
+		if !ok && r.onObjectAdded != nil {
+			r.onObjectAdded(id)
+			obj, ok = r.Object(id)
+		}
+		if ok {
+			if err := obj.decodeFields(snapshot.Objects[id]); err != nil {
+				fmt.Println("Error decoding replicated object: ", err)
+			}
+		}
+	}
+
+	r.mux.Lock()
+	var removed []uint32
+	for id := range r.objects {
+		if _, ok := snapshot.Objects[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	r.mux.Unlock()
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, id := range removed {
+		if r.onObjectRemoved != nil {
+			r.onObjectRemoved(id)
+		}
+		r.Unregister(id)
+	}
+}
+
+// EncodeSnapshotDelta - encode snapshot as the changes since base.
+// Unchanged objects are omitted and changed objects only include the fields that differ.
+// An empty base (Tick 0) produces a full snapshot.
+func EncodeSnapshotDelta(base, snapshot Snapshot) []byte {
+	body := new(bytes.Buffer)
+	var count uint32
+	for _, id := range sortedObjectIds(snapshot.Objects) {
+		fields := snapshot.Objects[id]
+		baseFields, ok := base.Objects[id]
+		if !ok || len(baseFields) != len(fields) {
+			count++
+			util.UInt32Bytes(body, id)
+			util.UInt8Bytes(body, objectFull)
+			util.UInt16Bytes(body, uint16(len(fields)))
+			for _, field := range fields {
+				writeField(body, field)
+			}
+			continue
+		}
+
+		mask := make([]byte, (len(fields)+7)/8)
+		changed := false
+		for i, field := range fields {
+			if !bytes.Equal(field, baseFields[i]) {
+				mask[i/8] |= 1 << uint(i%8)
+				changed = true
+			}
+		}
+		if changed {
+			count++
+			util.UInt32Bytes(body, id)
+			util.UInt8Bytes(body, objectDelta)
+			util.UInt16Bytes(body, uint16(len(fields)))
+			body.Write(mask)
+			for i, field := range fields {
+				if mask[i/8]&(1<<uint(i%8)) != 0 {
+					writeField(body, field)
+				}
+			}
+		}
+	}
+
+	for _, id := range sortedObjectIds(base.Objects) {
+		if _, ok := snapshot.Objects[id]; !ok {
+			count++
+			util.UInt32Bytes(body, id)
+			util.UInt8Bytes(body, objectRemoved)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	util.UInt32Bytes(buf, snapshot.Tick)
+	util.UInt32Bytes(buf, base.Tick)
+	util.UInt32Bytes(buf, count)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// DecodeSnapshotDelta - apply an encoded delta to base, returning the resulting snapshot.
+// base must be the snapshot that the delta was encoded against.
+func DecodeSnapshotDelta(base Snapshot, data []byte) (Snapshot, error) {
+	buf := bytes.NewBuffer(data)
+	tick, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	baseTick, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if baseTick != base.Tick {
+		return Snapshot{}, fmt.Errorf("Snapshot delta base mismatch: expected=%v got=%v", baseTick, base.Tick)
+	}
+	count, err := util.UInt32frombytes(buf)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	objects := make(map[uint32][][]byte, len(base.Objects))
+	for id, fields := range base.Objects {
+		objects[id] = fields
+	}
+
+	for i := uint32(0); i < count; i++ {
+		id, err := util.UInt32frombytes(buf)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		kind, err := util.UInt8frombytes(buf)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if kind == objectRemoved {
+			delete(objects, id)
+			continue
+		}
+
+		fieldCount, err := util.UInt16frombytes(buf)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		fields := make([][]byte, fieldCount)
+		switch kind {
+		case objectFull:
+			for j := range fields {
+				if fields[j], err = readField(buf); err != nil {
+					return Snapshot{}, err
+				}
+			}
+		case objectDelta:
+			baseFields, ok := base.Objects[id]
+			if !ok || len(baseFields) != len(fields) {
+				return Snapshot{}, fmt.Errorf("Snapshot delta for object %v does not match the base snapshot", id)
+			}
+			copy(fields, baseFields)
+			mask := make([]byte, (len(fields)+7)/8)
+			if _, err := io.ReadFull(buf, mask); err != nil {
+				return Snapshot{}, err
+			}
+			for j := range fields {
+				if mask[j/8]&(1<<uint(j%8)) != 0 {
+					if fields[j], err = readField(buf); err != nil {
+						return Snapshot{}, err
+					}
+				}
+			}
+		default:
+			return Snapshot{}, fmt.Errorf("Unknown snapshot object type: %v", kind)
+		}
+		objects[id] = fields
+	}
+
+	return Snapshot{
+		Tick:    tick,
+		Objects: objects,
+	}, nil
+}
+
+func snapshotDeltaBase(data []byte) (uint32, error) {
+	buf := bytes.NewBuffer(data)
+	if _, err := util.UInt32frombytes(buf); err != nil {
+		return 0, err
+	}
+	return util.UInt32frombytes(buf)
+}
+
+func writeField(w io.Writer, field []byte) {
+	util.UInt16Bytes(w, uint16(len(field)))
+	w.Write(field)
+}
+
+func readField(r io.Reader) ([]byte, error) {
+	length, err := util.UInt16frombytes(r)
+	if err != nil {
+		return nil, err
+	}
+	field := make([]byte, length)
+	_, err = io.ReadFull(r, field)
+	return field, err
+}
+
+func sortedObjectIds(objects map[uint32][][]byte) []uint32 {
+	ids := make([]uint32, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
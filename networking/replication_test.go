@@ -0,0 +1,197 @@
+package networking
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+type replicatedPlayer struct {
+	position    mgl32.Vec3
+	orientation mgl32.Quat
+	health      float32
+	score       int
+	name        string
+}
+
+func (p *replicatedPlayer) register(obj *ReplicatedObject) {
+	obj.Vec3(&p.position).Quat(&p.orientation).Float32(&p.health).Int(&p.score).String(&p.name)
+}
+
+func testSnapshots() (base, snapshot Snapshot) {
+	base = Snapshot{
+		Tick: 3,
+		Objects: map[uint32][][]byte{
+			1: {{1, 2, 3}, {4, 5}},
+			2: {{6}, {7, 8, 9}},
+			3: {{10}},
+		},
+	}
+	snapshot = Snapshot{
+		Tick: 7,
+		Objects: map[uint32][][]byte{
+			1: {{1, 2, 3}, {4, 5, 6}},
+			2: {{6}, {7, 8, 9}},
+			4: {{11, 12}, {}, {13}},
+		},
+	}
+	return
+}
+
+func TestSnapshotDelta(t *testing.T) {
+	base, snapshot := testSnapshots()
+	delta := EncodeSnapshotDelta(base, snapshot)
+	full := EncodeSnapshotDelta(Snapshot{}, snapshot)
+	assert.True(t, len(delta) < len(full), "delta should be smaller than a full snapshot")
+
+	decoded, err := DecodeSnapshotDelta(base, delta)
+	assert.NoError(t, err)
+	assert.EqualValues(t, snapshot, decoded, "decoded delta should match the snapshot")
+	assert.Len(t, base.Objects, 3, "decoding should not modify the base snapshot")
+	assert.EqualValues(t, []byte{4, 5}, base.Objects[1][1], "decoding should not modify the base snapshot")
+
+	decoded, err = DecodeSnapshotDelta(Snapshot{}, full)
+	assert.NoError(t, err)
+	assert.EqualValues(t, snapshot, decoded, "decoded full snapshot should match the snapshot")
+}
+
+func TestSnapshotDeltaErrors(t *testing.T) {
+	base, snapshot := testSnapshots()
+	delta := EncodeSnapshotDelta(base, snapshot)
+
+	_, err := DecodeSnapshotDelta(Snapshot{Tick: 2}, delta)
+	assert.Error(t, err, "decoding against the wrong base should fail")
+
+	for i := 0; i < len(delta); i++ {
+		_, err := DecodeSnapshotDelta(base, delta[:i])
+		assert.Error(t, err, "decoding truncated data should fail: len=%v", i)
+	}
+}
+
+func TestReplicator(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newReplicator(serverNet)
+	serverPlayers := map[uint32]*replicatedPlayer{
+		1: {position: mgl32.Vec3{1, 2, 3}, orientation: mgl32.QuatIdent(), health: 100, name: "one"},
+		2: {position: mgl32.Vec3{4, 5, 6}, orientation: mgl32.QuatIdent(), health: 50, name: "two"},
+	}
+	for id, player := range serverPlayers {
+		player.register(server.Register(id))
+	}
+
+	clientNet := serverNet.connect("1")
+	client := newReplicator(clientNet)
+	clientPlayers := make(map[uint32]*replicatedPlayer)
+	client.ObjectAddedEvent(func(id uint32) {
+		player := &replicatedPlayer{}
+		clientPlayers[id] = player
+		player.register(client.Register(id))
+	})
+	client.ObjectRemovedEvent(func(id uint32) {
+		delete(clientPlayers, id)
+	})
+
+	var snapshotSizes []int
+	serverNet.drop = func(event fakeEvent) bool {
+		if event.name == snapshotEvent {
+			snapshotSizes = append(snapshotSizes, len(event.data))
+		}
+		return false
+	}
+
+	assertSynced := func() {
+		serverNet.flush()
+		assert.Len(t, clientPlayers, len(serverPlayers))
+		for id, player := range serverPlayers {
+			assert.EqualValues(t, player, clientPlayers[id])
+		}
+	}
+
+	server.Update(server.SendInterval.Seconds())
+	assertSynced()
+
+	serverPlayers[1].position = mgl32.Vec3{7, 8, 9}
+	serverPlayers[2].orientation = mgl32.QuatRotate(1, mgl32.Vec3{0, 1, 0})
+	serverPlayers[2].score = -3
+	server.SendSnapshot()
+	assertSynced()
+
+	server.SendSnapshot()
+	assertSynced()
+	assert.True(t, snapshotSizes[2] < snapshotSizes[1], "an unchanged snapshot should be smaller than a changed one")
+	assert.True(t, snapshotSizes[1] < snapshotSizes[0], "a delta should be smaller than the first full snapshot")
+
+	server.Unregister(1)
+	delete(serverPlayers, 1)
+	server.SendSnapshot()
+	assertSynced()
+
+	serverNet.disconnect("1", ReasonDisconnected)
+	sent := serverNet.sent
+	server.SendSnapshot()
+	assert.EqualValues(t, sent, serverNet.sent, "snapshots should not be sent to clients that have left")
+}
+
+func TestReplicatorFullSnapshotFallback(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newReplicator(serverNet)
+	serverPlayer := &replicatedPlayer{name: "player"}
+	serverPlayer.register(server.Register(1))
+
+	clientNet := serverNet.connect("1")
+	client := newReplicator(clientNet)
+	clientPlayer := &replicatedPlayer{}
+	clientPlayer.register(client.Register(1))
+
+	// drop every ack so the server never has a baseline
+	serverNet.drop = func(event fakeEvent) bool {
+		return event.name == snapshotAckEvent
+	}
+	for i := 0; i < snapshotHistorySize+5; i++ {
+		serverPlayer.score = i
+		server.SendSnapshot()
+		serverNet.flush()
+		assert.EqualValues(t, serverPlayer, clientPlayer)
+	}
+
+	// drop snapshots so the acknowledged baseline falls out of the server's history
+	serverNet.drop = func(event fakeEvent) bool { return false }
+	server.SendSnapshot()
+	serverNet.flush()
+	serverNet.drop = func(event fakeEvent) bool {
+		return event.name == snapshotEvent
+	}
+	for i := 0; i < snapshotHistorySize+5; i++ {
+		server.SendSnapshot()
+		serverNet.flush()
+	}
+	serverNet.drop = func(event fakeEvent) bool { return false }
+	serverPlayer.position = mgl32.Vec3{1, 1, 1}
+	server.SendSnapshot()
+	serverNet.flush()
+	assert.EqualValues(t, serverPlayer, clientPlayer)
+}
+
+func TestReplicatorIgnoresClientSnapshots(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newReplicator(serverNet)
+	serverPlayer := &replicatedPlayer{name: "player", health: 100}
+	serverPlayer.register(server.Register(1))
+	server.SendSnapshot()
+
+	attacker := serverNet.connect("attacker")
+	forged := &replicatedPlayer{name: "forged"}
+	forgedReplicator := newReplicator(attacker)
+	forged.register(forgedReplicator.Register(1))
+	forgedReplicator.Register(2)
+	snapshot := forgedReplicator.TakeSnapshot()
+	snapshot.Tick = 100
+	attacker.TriggerEvent(snapshotEvent, "", EncodeSnapshotDelta(Snapshot{}, snapshot))
+	serverNet.flush()
+
+	assert.EqualValues(t, "player", serverPlayer.name, "snapshots from clients should be ignored by the server")
+	_, ok := server.Object(2)
+	assert.False(t, ok)
+	assert.EqualValues(t, 2, server.TakeSnapshot().Tick, "the server tick should not change")
+}
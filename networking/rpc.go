@@ -0,0 +1,244 @@
+package networking
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	rpcCallEvent     = "__rpcCall"
+	rpcResponseEvent = "__rpcResponse"
+)
+
+const defaultRPCTimeout = 10 * time.Second
+
+var (
+	ErrRPCNotFound   = errors.New("rpc not found")
+	ErrRPCClientLeft = errors.New("client left before responding")
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RPCError - an error returned by a remote procedure
+type RPCError struct {
+	Name    string
+	Message string
+}
+
+func (e RPCError) Error() string {
+	return fmt.Sprintf("rpc %v: %v", e.Name, e.Message)
+}
+
+// Response - the serialized results of a remote procedure call
+type Response []byte
+
+// Decode - read the results into the given pointers, in the order they were returned
+func (r Response) Decode(results ...interface{}) error {
+	return util.DeserializeArgs(bytes.NewReader(r), results...)
+}
+
+type rpcResult struct {
+	response Response
+	err      error
+}
+
+type pendingCall struct {
+	clientId string
+	name     string
+	result   chan rpcResult
+}
+
+// RPC - typed remote procedure calls between the server and clients.
+// Procedures are registered as go functions, with the caller's client id as the first parameter:
+//
+//	rpc.Register("damage", func(clientId string, target string, amount float32) (float32, error) { ... })
+//	response, err := rpc.Call(ctx, "", "damage", "enemy1", float32(10))
+//	var health float32
+//	err = response.Decode(&health)
+//
+// Arguments and results are serialized using util.SerializeArgs.
+// Procedures run when the network's events are flushed.
+type RPC struct {
+	Timeout time.Duration
+
+	network  eventNetwork
+	mux      *sync.Mutex
+	handlers map[string]reflect.Value
+	pending  map[uint32]pendingCall
+	nextId   uint32
+}
+
+// NewRPC - create an rpc layer and register the call/response events on the network.
+// The RPC must be created on the server and the clients.
+func NewRPC(network *Network) *RPC {
+	return newRPC(network)
+}
+
+func newRPC(network eventNetwork) *RPC {
+	r := &RPC{
+		Timeout:  defaultRPCTimeout,
+		network:  network,
+		mux:      &sync.Mutex{},
+		handlers: make(map[string]reflect.Value),
+		pending:  make(map[uint32]pendingCall),
+	}
+	network.RegisterEvent(rpcCallEvent, r.handleCall)
+	network.RegisterEvent(rpcResponseEvent, r.handleResponse)
+	network.ClientLeftEvent(r.clientLeft)
+	return r
+}
+
+// Register - register a function that can be called remotely.
+// The first parameter must be a string, the id of the calling client (empty on clients).
+// The function can return any number of results, if the last result is an error it is returned to the caller.
+func (r *RPC) Register(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("rpc %v must be a function: %T", name, fn)
+	}
+	t := v.Type()
+	if t.NumIn() < 1 || t.In(0).Kind() != reflect.String || t.IsVariadic() {
+		return fmt.Errorf("rpc %v must be a function with a client id as the first parameter: %v", name, t)
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.handlers[name] = v
+	return nil
+}
+
+// Call - call a remote procedure and wait for the response.
+// On the server the procedure is called on the given client, on clients it is called on the server.
+// If the context has no deadline, Timeout is used.
+// Call blocks until the response is received, so it must not be called from the goroutine that flushes the network's events.
+func (r *RPC) Call(ctx context.Context, clientId, name string, args ...interface{}) (Response, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	encodedArgs, err := util.SerializeArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mux.Lock()
+	r.nextId++
+	id := r.nextId
+	call := pendingCall{clientId: clientId, name: name, result: make(chan rpcResult, 1)}
+	r.pending[id] = call
+	r.mux.Unlock()
+	defer func() {
+		r.mux.Lock()
+		delete(r.pending, id)
+		r.mux.Unlock()
+	}()
+
+	data, err := util.SerializeArgs(id, name, encodedArgs)
+	if err != nil {
+		return nil, err
+	}
+	r.network.TriggerEvent(rpcCallEvent, clientId, data, ReliableOrdered)
+
+	select {
+	case result := <-call.result:
+		return result.response, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *RPC) handleCall(clientId string, data []byte) {
+	var id uint32
+	var name string
+	var args []byte
+	if err := util.DeserializeArgs(bytes.NewReader(data), &id, &name, &args); err != nil {
+		return
+	}
+	results, err := r.invoke(clientId, name, args)
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	response, _ := util.SerializeArgs(id, err == nil, []byte(message), results)
+	r.network.TriggerEvent(rpcResponseEvent, clientId, response, ReliableOrdered)
+}
+
+func (r *RPC) invoke(clientId, name string, data []byte) ([]byte, error) {
+	r.mux.Lock()
+	fn, ok := r.handlers[name]
+	r.mux.Unlock()
+	if !ok {
+		return nil, ErrRPCNotFound
+	}
+
+	t := fn.Type()
+	in := []reflect.Value{reflect.ValueOf(clientId).Convert(t.In(0))}
+	buf := bytes.NewReader(data)
+	for i := 1; i < t.NumIn(); i++ {
+		arg := reflect.New(t.In(i))
+		if err := util.DeserializeArgs(buf, arg.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+		in = append(in, arg.Elem())
+	}
+	if buf.Len() > 0 {
+		return nil, errors.New("invalid arguments: too many arguments")
+	}
+
+	out := fn.Call(in)
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+	results := make([]interface{}, len(out))
+	for i, result := range out {
+		results[i] = result.Interface()
+	}
+	return util.SerializeArgs(results...)
+}
+
+func (r *RPC) handleResponse(clientId string, data []byte) {
+	var id uint32
+	var ok bool
+	var message, results []byte
+	if err := util.DeserializeArgs(bytes.NewReader(data), &id, &ok, &message, &results); err != nil {
+		return
+	}
+	r.mux.Lock()
+	call, found := r.pending[id]
+	// on the server, only the client that was called can respond
+	found = found && (r.network.IsClient() || call.clientId == clientId)
+	if found {
+		delete(r.pending, id)
+	}
+	r.mux.Unlock()
+	if !found {
+		return
+	}
+	if ok {
+		call.result <- rpcResult{response: results}
+	} else {
+		call.result <- rpcResult{err: RPCError{Name: call.name, Message: string(message)}}
+	}
+}
+
+// clientLeft - fail the calls waiting on a client that has left the server
+func (r *RPC) clientLeft(clientId, reason string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for id, call := range r.pending {
+		if call.clientId == clientId {
+			delete(r.pending, id)
+			call.result <- rpcResult{err: ErrRPCClientLeft}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package networking
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+type rpcTestItem struct {
+	Name     string
+	Position mgl32.Vec3
+	Count    int
+}
+
+func TestRPCInvoke(t *testing.T) {
+	rpc := newRPC(newFakeServer())
+	assert.Error(t, rpc.Register("bad", "not a function"))
+	assert.Error(t, rpc.Register("bad", func(count int) {}), "the first parameter must be the client id")
+	assert.NoError(t, rpc.Register("items", func(clientId string, names []string, position mgl32.Vec3) ([]rpcTestItem, error) {
+		if len(names) == 0 {
+			return nil, errors.New("no items")
+		}
+		items := []rpcTestItem{}
+		for i, name := range names {
+			items = append(items, rpcTestItem{Name: clientId + name, Position: position, Count: i})
+		}
+		return items, nil
+	}))
+
+	args, _ := util.SerializeArgs([]string{"a", "b"}, mgl32.Vec3{1, 2, 3})
+	results, err := rpc.invoke("client", "items", args)
+	assert.NoError(t, err)
+	var items []rpcTestItem
+	assert.NoError(t, Response(results).Decode(&items))
+	assert.EqualValues(t, []rpcTestItem{{"clienta", mgl32.Vec3{1, 2, 3}, 0}, {"clientb", mgl32.Vec3{1, 2, 3}, 1}}, items)
+
+	args, _ = util.SerializeArgs([]string{}, mgl32.Vec3{})
+	_, err = rpc.invoke("client", "items", args)
+	assert.EqualError(t, err, "no items")
+	_, err = rpc.invoke("client", "items", args[:2])
+	assert.Error(t, err, "missing arguments")
+	_, err = rpc.invoke("client", "items", append(args, 1))
+	assert.Error(t, err, "too many arguments")
+	_, err = rpc.invoke("client", "missing", args)
+	assert.Equal(t, ErrRPCNotFound, err)
+
+	// strings that are too long to encode are an error rather than being truncated
+	assert.NoError(t, rpc.Register("repeat", func(clientId string, count int) string { return strings.Repeat("a", count) }))
+	args, _ = util.SerializeArgs(util.MaxStringLength + 1)
+	_, err = rpc.invoke("client", "repeat", args)
+	assert.Error(t, err)
+	_, err = rpc.Call(context.Background(), "client", "echo", strings.Repeat("a", util.MaxStringLength+1))
+	assert.Error(t, err)
+}
+
+func TestRPCResponses(t *testing.T) {
+	serverNet := newFakeServer()
+	server := newRPC(serverNet)
+	newRPC(serverNet.connect("a"))
+	b := newRPC(serverNet.connect("b"))
+
+	result := make(chan rpcResult, 1)
+	server.pending[1] = pendingCall{clientId: "a", name: "ping", result: result}
+	response, _ := util.SerializeArgs(uint32(1), true, []byte{}, []byte("pong"))
+	b.network.TriggerEvent(rpcResponseEvent, "", response)
+	serverNet.flush()
+	assert.Empty(t, result, "only the called client can respond")
+
+	serverNet.disconnect("a", ReasonDisconnected)
+	assert.Equal(t, ErrRPCClientLeft, (<-result).err)
+	assert.Empty(t, server.pending)
+}
+
+func TestRPCCall(t *testing.T) {
+	transport := NewMemoryTransport()
+	serverNetwork, clientNetwork := NewNetwork(), NewNetwork()
+	defer serverNetwork.Close()
+	defer clientNetwork.Close()
+	serverRPC, clientRPC := NewRPC(serverNetwork), NewRPC(clientNetwork)
+	serverRPC.Register("add", func(clientId string, a, b int) int { return a + b })
+	serverRPC.Register("fail", func(clientId string) error { return errors.New("failed") })
+
+	serverNetwork.StartServer(1236, transport)
+	assert.NoError(t, clientNetwork.ConnectClient("localhost:1236", transport))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		response, err := clientRPC.Call(context.Background(), "", "add", 2, 3)
+		assert.NoError(t, err)
+		var sum int
+		assert.NoError(t, response.Decode(&sum))
+		assert.EqualValues(t, 5, sum)
+
+		_, err = clientRPC.Call(context.Background(), "", "fail")
+		assert.EqualError(t, err, "rpc fail: failed")
+		_, err = clientRPC.Call(context.Background(), "", "missing")
+		assert.EqualError(t, err, "rpc missing: "+ErrRPCNotFound.Error())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		serverRPC.Register("slow", func(clientId string) { time.Sleep(50 * time.Millisecond) })
+		_, err = clientRPC.Call(ctx, "", "slow")
+		assert.Equal(t, context.DeadlineExceeded, err)
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for rpc calls")
+		default:
+			serverNetwork.Update(0)
+			clientNetwork.Update(0)
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
@@ -1,71 +1,132 @@
 package networking
 
 import (
-	"bytes"
-	"compress/gzip"
+	"crypto/hmac"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/walesey/go-engine/util"
+)
+
+const (
+	heartbeatCommand  = "__heartbeat"
+	disconnectCommand = "__disconnect"
 )
 
-const sessionTimeout = 10 * time.Minute
+const (
+	defaultSessionTimeout    = 10 * time.Second
+	defaultHeartbeatInterval = time.Second
+)
+
+// Reasons given to ClientLeft and Disconnected events
+const (
+	ReasonDisconnected = "disconnected"
+	ReasonTimeout      = "timeout"
+	ReasonServerClosed = "server closed"
+	ReasonBanned       = "banned"
+)
 
 type Server struct {
-	conn             *net.UDPConn
+	// SessionTimeout - sessions are closed if no packets are received from the client for this long
+	SessionTimeout time.Duration
+	// HeartbeatInterval - how often heartbeats are sent to clients and sessions are checked for timeouts
+	HeartbeatInterval time.Duration
+	// PreSharedKey - optional key that clients must also have to join, it is also used to derive the session keys
+	PreSharedKey []byte
+	// Authenticate - optional callback to accept or reject clients using the credentials they send
+	Authenticate Authenticator
+
+	secret           []byte
+	conn             net.PacketConn
+	stopResend       func()
+	stopHeartbeat    func()
 	sessions         map[string]*Session
+	addrs            map[string]*Session
+	bans             map[string]string
 	mux              *sync.Mutex
 	onClientJoined   func(clientId string)
+	onClientLeft     func(clientId, reason string)
 	onPacketReceived func(packet Packet)
 	bytesSent        int64
 	bytesReceived    int64
 }
 
 func NewServer() *Server {
-	var server *Server
-	server = &Server{
-		sessions: make(map[string]*Session),
-		mux:      &sync.Mutex{},
-		onClientJoined: func(clientId string) {
-			server.WriteMessage(Packet{Token: clientId, Data: []byte{}})
-		},
+	return &Server{
+		SessionTimeout:    defaultSessionTimeout,
+		HeartbeatInterval: defaultHeartbeatInterval,
+		secret:            randomBytes(32),
+		sessions:          make(map[string]*Session),
+		addrs:             make(map[string]*Session),
+		bans:              make(map[string]string),
+		mux:               &sync.Mutex{},
 	}
-	return server
 }
 
 func (s *Server) Listen(port int) {
-	serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%v", port))
-	if err != nil {
-		log.Println("Error Resolving udp address: ", err)
-		return
+	if err := s.ListenTransport(UDPTransport{}, fmt.Sprintf(":%v", port)); err != nil {
+		log.Println("Error listening on udp address: ", err)
 	}
+}
 
-	s.conn, err = net.ListenUDP("udp", serverAddr)
+// ListenTransport - listen on the given address using the transport (eg. UDPTransport, TCPTransport or a MemoryTransport)
+func (s *Server) ListenTransport(transport Transport, addr string) error {
+	conn, err := transport.Listen(addr)
 	if err != nil {
-		log.Println("Error listening on udp address: ", err)
-		return
+		return err
 	}
+	s.Serve(conn)
+	return nil
+}
 
-	data := make([]byte, 65500)
+// Serve - start handling packets on the given connection.
+// Listen uses a udp connection, but any net.PacketConn can be used (eg. for testing).
+func (s *Server) Serve(conn net.PacketConn) {
+	s.conn = conn
+	s.stopResend = util.SetInterval(s.resendAll, resendInterval)
+	s.stopHeartbeat = util.SetInterval(s.heartbeat, s.HeartbeatInterval)
+
+	data := make([]byte, readBufferSize)
 	go func() {
-		for s.conn != nil {
-			n, addr, err := s.conn.ReadFromUDP(data)
+		for {
+			n, addr, err := conn.ReadFrom(data)
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				log.Println("Error reading udp packet: ", err)
 				continue
 			}
 
-			s.bytesReceived += int64(n)
-			dataBuf := bytes.NewBuffer(data[0:n])
-			gzipReader, err := gzip.NewReader(dataBuf)
+			atomic.AddInt64(&s.bytesReceived, int64(n))
+			var auth *frameAuth
+			session, hasSession := s.getSessionByAddr(addr)
+			if hasSession {
+				auth = session.auth
+			}
+			frameType, payload, err := unframe(auth, data[0:n])
 			if err != nil {
-				log.Println("Error creating gzip Reader for udp packet: ", err)
+				log.Println("Error reading udp frame: ", err)
 				continue
 			}
 
-			unzipped, err := ioutil.ReadAll(gzipReader)
+			if frameType == fragmentFrame {
+				var complete bool
+				if payload, complete, err = session.fragments.add(payload); err != nil {
+					log.Println("Error reassembling udp fragment: ", err)
+				}
+				if !complete {
+					continue
+				}
+				frameType = sessionFrame
+			}
+
+			unzipped, err := decompress(payload)
 			if err != nil {
 				log.Println("Error unzipping udp packet: ", err)
 				continue
@@ -79,111 +140,228 @@ func (s *Server) Listen(port int) {
 					continue
 				}
 
-				if len(packet.Token) == 0 {
-					newSession := NewSession(addr)
-					s.setSession(newSession.token, newSession)
-					s.onClientJoined(newSession.token)
+				if frameType == handshakeFrame {
+					s.handshake(addr, packet)
+				} else if packet.Token != session.token {
+					log.Println("Rejected packet with mismatched session token from: ", addr)
+				} else {
+					s.receive(session, packet)
 				}
+			}
+		}
+	}()
+}
 
-				if session, ok := s.getSession(packet.Token); ok && s.onPacketReceived != nil {
-					s.onPacketReceived(packet)
-					session.idleTimer = time.Now()
-				}
+// handshake - respond to a client that is trying to join
+func (s *Server) handshake(addr net.Addr, packet Packet) {
+	switch packet.Command {
+	case helloCommand:
+		if s.isBanned(addr) {
+			s.writeDisconnect(addr, ReasonBanned)
+			return
+		}
+		s.writeHandshake(addr, Packet{Command: challengeCommand, Data: handshakeCookie(s.secret, addr)})
 
-				s.cleanupSessions()
+	case responseCommand:
+		if len(packet.Data) < cookieSize+macSize {
+			return
+		}
+		cookie := packet.Data[:cookieSize]
+		proof := packet.Data[cookieSize : cookieSize+macSize]
+		credentials := packet.Data[cookieSize+macSize:]
+		if !hmac.Equal(cookie, handshakeCookie(s.secret, addr)) {
+			return
+		}
+		if s.isBanned(addr) {
+			s.writeDisconnect(addr, ReasonBanned)
+			return
+		}
+		if !hmac.Equal(proof, handshakeProof(s.PreSharedKey, cookie)) {
+			s.writeDisconnect(addr, ErrAuthenticationFailed.Error())
+			return
+		}
+		if session, ok := s.getSessionByAddr(addr); ok {
+			// the welcome was lost, send it again
+			s.writeHandshake(addr, Packet{Command: welcomeCommand, Data: []byte(session.token)})
+			return
+		}
+		if s.Authenticate != nil {
+			if err := s.Authenticate(addr, credentials); err != nil {
+				s.writeDisconnect(addr, err.Error())
+				return
 			}
 		}
-	}()
+
+		session := NewSession(addr)
+		session.auth = newFrameAuth(sessionKey(s.PreSharedKey, cookie, session.token), false)
+		s.setSession(session.token, session)
+		s.writeHandshake(addr, Packet{Command: welcomeCommand, Data: []byte(session.token)})
+		if s.onClientJoined != nil {
+			s.onClientJoined(session.token)
+		}
+	}
 }
 
-func (s *Server) WriteMessage(packet Packet) {
-	if session, ok := s.getSession(packet.Token); ok {
-		if _, err := session.packetBuffer.Write(Encode(packet)); err != nil {
-			log.Println("Error Writing udp message to session buffer: ", err)
+// receive - handle a packet from an established session
+func (s *Server) receive(session *Session, packet Packet) {
+	session.touch()
+	switch packet.Command {
+	case heartbeatCommand:
+	case disconnectCommand:
+		s.removeSession(packet.Token, string(packet.Data))
+	default:
+		for _, received := range session.channel.receive(packet) {
+			if s.onPacketReceived != nil {
+				s.onPacketReceived(received)
+			}
 		}
 	}
 }
 
+// WriteMessage - buffer a message to be sent to the session matching packet.Token.
+// The delivery mode defaults to Unreliable.
+func (s *Server) WriteMessage(packet Packet, mode ...DeliveryMode) {
+	if session, ok := s.getSession(packet.Token); ok {
+		session.channel.send(packet, deliveryMode(mode))
+	}
+}
+
+// heartbeat - send a heartbeat to all sessions and check for session timeouts
+func (s *Server) heartbeat() {
+	for _, session := range s.sessionList() {
+		s.WriteMessage(Packet{Token: session.token, Command: heartbeatCommand})
+		s.FlushWriteBuffer(session.token)
+	}
+	s.cleanupSessions()
+}
+
 // check for session timeouts
 func (s *Server) cleanupSessions() {
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	for token, session := range s.sessions {
-		if time.Since(session.idleTimer) > sessionTimeout {
-			log.Println("session timed out: ", token)
-			delete(s.sessions, token)
+	for _, session := range s.sessionList() {
+		if session.idle() > s.SessionTimeout {
+			log.Println("session timed out: ", session.token)
+			s.removeSession(session.token, ReasonTimeout)
 		}
 	}
 }
 
-func (s *Server) BroadcastMessage(command string, data []byte) {
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	for token := range s.sessions {
-		s.mux.Unlock()
+func (s *Server) BroadcastMessage(command string, data []byte, mode ...DeliveryMode) {
+	for _, session := range s.sessionList() {
 		s.WriteMessage(Packet{
 			Command: command,
-			Token:   token,
+			Token:   session.token,
 			Data:    data,
-		})
+		}, mode...)
+	}
+}
+
+// Kick - disconnect a client, the reason is passed to the client's Disconnected event
+func (s *Server) Kick(clientId, reason string) {
+	if _, ok := s.getSession(clientId); ok {
+		s.WriteMessage(Packet{Token: clientId, Command: disconnectCommand, Data: []byte(reason)})
+		s.FlushWriteBuffer(clientId)
+		s.removeSession(clientId, reason)
+	}
+}
+
+// Ban - kick a client and reject any future connections from the same host
+func (s *Server) Ban(clientId, reason string) {
+	if session, ok := s.getSession(clientId); ok {
 		s.mux.Lock()
+		s.bans[hostOf(session.addr)] = reason
+		s.mux.Unlock()
+		s.Kick(clientId, reason)
 	}
 }
 
+// Unban - allow connections from a host that was banned
+func (s *Server) Unban(host string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.bans, host)
+}
+
+func (s *Server) isBanned(addr net.Addr) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, banned := s.bans[hostOf(addr)]
+	return banned
+}
+
+// writeDisconnect - send a disconnect packet to an address that has no session
+func (s *Server) writeDisconnect(addr net.Addr, reason string) {
+	s.writeHandshake(addr, Packet{Command: disconnectCommand, Data: []byte(reason)})
+}
+
+// writeHandshake - send an unauthenticated packet
+func (s *Server) writeHandshake(addr net.Addr, packet Packet) {
+	data, err := compress(Encode(packet))
+	if err != nil {
+		log.Println("Error Gzip compressing udp message: ", err)
+		return
+	}
+	s.conn.WriteTo(frame(nil, data), addr)
+}
+
 func (s *Server) ClientJoinedEvent(callback func(clientId string)) {
 	s.onClientJoined = callback
 }
 
+// ClientLeftEvent - called when a client disconnects, times out or is kicked
+func (s *Server) ClientLeftEvent(callback func(clientId, reason string)) {
+	s.onClientLeft = callback
+}
+
 func (s *Server) PacketReceived(callback func(packet Packet)) {
 	s.onPacketReceived = callback
 }
 
 // FlushAllWriteBuffers - send all buffered messages immediately for all sessions
 func (s *Server) FlushAllWriteBuffers() {
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	for token := range s.sessions {
-		s.mux.Unlock()
-		s.FlushWriteBuffer(token)
-		s.mux.Lock()
+	for _, session := range s.sessionList() {
+		s.FlushWriteBuffer(session.token)
 	}
 }
 
 // FlushWriteBuffer - send all buffered messages immediately
 func (s *Server) FlushWriteBuffer(token string) {
 	if session, ok := s.getSession(token); ok {
-		data := session.packetBuffer.Bytes()
-		if len(data) > 0 {
-			var gzipBuf bytes.Buffer
-			gzipWriter := gzip.NewWriter(&gzipBuf)
-			_, err := gzipWriter.Write(data)
+		for _, data := range session.flush() {
+			gzipData, err := compress(data)
 			if err != nil {
 				log.Println("Error Gzip compressing udp message: ", err)
 				return
 			}
 
-			if err := gzipWriter.Flush(); err != nil {
-				log.Println("Error Flushing Gzip writer for udp message: ", err)
-				return
-			}
-
-			if err := gzipWriter.Close(); err != nil {
-				log.Println("Error Closing Gzip writer for udp message: ", err)
-				return
-			}
-
-			gzipData := gzipBuf.Bytes()
-			s.bytesSent += int64(len(gzipData))
-			s.conn.WriteToUDP(gzipData, session.addr)
-			session.packetBuffer.Reset()
+			writeFrames(frames(session.auth, gzipData), func(framed []byte) error {
+				atomic.AddInt64(&s.bytesSent, int64(len(framed)))
+				_, err := s.conn.WriteTo(framed, session.addr)
+				return err
+			})
 		}
 	}
 }
 
+// resendAll - resend unacknowledged reliable messages for all sessions
+func (s *Server) resendAll() {
+	for _, session := range s.sessionList() {
+		session.channel.resend()
+	}
+}
+
+// Close - notify all clients and stop the server
 func (s *Server) Close() {
+	if s.stopResend != nil {
+		s.stopResend()
+	}
+	if s.stopHeartbeat != nil {
+		s.stopHeartbeat()
+	}
+	for _, session := range s.sessionList() {
+		s.WriteMessage(Packet{Token: session.token, Command: disconnectCommand, Data: []byte(ReasonServerClosed)})
+	}
 	s.FlushAllWriteBuffers()
 	s.conn.Close()
-	s.conn = nil
 }
 
 func (s *Server) getSession(token string) (session *Session, ok bool) {
@@ -193,14 +371,48 @@ func (s *Server) getSession(token string) (session *Session, ok bool) {
 	return
 }
 
+func (s *Server) getSessionByAddr(addr net.Addr) (session *Session, ok bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	session, ok = s.addrs[addr.String()]
+	return
+}
+
 func (s *Server) setSession(token string, session *Session) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 	s.sessions[token] = session
+	s.addrs[session.addr.String()] = session
 }
 
-func (s *Server) deleteSession(token string) {
+// removeSession - delete the session and trigger the ClientLeft event
+func (s *Server) removeSession(token, reason string) {
+	s.mux.Lock()
+	session, ok := s.sessions[token]
+	if ok {
+		delete(s.sessions, token)
+		delete(s.addrs, session.addr.String())
+	}
+	s.mux.Unlock()
+	if ok && s.onClientLeft != nil {
+		s.onClientLeft(token, reason)
+	}
+}
+
+func (s *Server) sessionList() []*Session {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	delete(s.sessions, token)
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
 }
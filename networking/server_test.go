@@ -0,0 +1,149 @@
+package networking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+type leftEvent struct {
+	clientId, reason string
+}
+
+// startLoopback - serve and connect over local udp, returning the client's session token
+func startLoopback(t *testing.T, server *Server, client *Client, serverConn, clientConn *lossyConn) string {
+	server.Serve(serverConn)
+	joined := make(chan string, 1)
+	client.ConnectedEvent(func(token string) { joined <- token })
+	client.ConnectConn(clientConn, serverConn.LocalAddr())
+	select {
+	case token := <-joined:
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for session token")
+	}
+	return ""
+}
+
+func expectLeft(t *testing.T, left chan leftEvent, expected leftEvent) {
+	select {
+	case event := <-left:
+		assert.EqualValues(t, expected, event)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for client to leave: %v", expected.reason)
+	}
+}
+
+func expectDisconnected(t *testing.T, disconnected chan string, reason string) {
+	select {
+	case received := <-disconnected:
+		assert.EqualValues(t, reason, received)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for client to disconnect: %v", reason)
+	}
+}
+
+func newLifecycleTest() (*Server, *Client, chan leftEvent, chan string) {
+	left := make(chan leftEvent, 10)
+	server := NewServer()
+	server.HeartbeatInterval = 20 * time.Millisecond
+	server.SessionTimeout = 200 * time.Millisecond
+	server.ClientLeftEvent(func(clientId, reason string) { left <- leftEvent{clientId, reason} })
+
+	disconnected := make(chan string, 10)
+	client := NewClient()
+	client.HeartbeatInterval = 20 * time.Millisecond
+	client.Timeout = 200 * time.Millisecond
+	client.DisconnectedEvent(func(reason string) { disconnected <- reason })
+	return server, client, left, disconnected
+}
+
+func TestHeartbeatKeepsSessionAlive(t *testing.T) {
+	server, client, left, _ := newLifecycleTest()
+	defer server.Close()
+	token := startLoopback(t, server, client, newLossyConn(t), newLossyConn(t))
+	defer client.Close()
+
+	time.Sleep(3 * server.SessionTimeout)
+	_, ok := server.getSession(token)
+	assert.True(t, ok, "heartbeats should keep an idle session alive")
+	assert.Empty(t, left)
+}
+
+func TestGracefulDisconnect(t *testing.T) {
+	server, client, left, disconnected := newLifecycleTest()
+	defer server.Close()
+	token := startLoopback(t, server, client, newLossyConn(t), newLossyConn(t))
+
+	client.Close()
+	expectLeft(t, left, leftEvent{token, ReasonDisconnected})
+	assert.Empty(t, disconnected, "closing the client should not trigger the Disconnected event")
+}
+
+func TestSessionTimeout(t *testing.T) {
+	server, client, left, disconnected := newLifecycleTest()
+	defer server.Close()
+	serverConn, clientConn := newLossyConn(t), newLossyConn(t)
+	token := startLoopback(t, server, client, serverConn, clientConn)
+	defer client.Close()
+
+	serverConn.setConditions(1, false)
+	clientConn.setConditions(1, false)
+	expectLeft(t, left, leftEvent{token, ReasonTimeout})
+	expectDisconnected(t, disconnected, ReasonTimeout)
+}
+
+func TestServerClose(t *testing.T) {
+	server, client, _, disconnected := newLifecycleTest()
+	startLoopback(t, server, client, newLossyConn(t), newLossyConn(t))
+	defer client.Close()
+
+	server.Close()
+	expectDisconnected(t, disconnected, ReasonServerClosed)
+}
+
+func TestKick(t *testing.T) {
+	server, client, left, disconnected := newLifecycleTest()
+	defer server.Close()
+	token := startLoopback(t, server, client, newLossyConn(t), newLossyConn(t))
+	defer client.Close()
+
+	server.Kick(token, "cheating")
+	expectLeft(t, left, leftEvent{token, "cheating"})
+	expectDisconnected(t, disconnected, "cheating")
+}
+
+func TestBan(t *testing.T) {
+	server, client, left, disconnected := newLifecycleTest()
+	defer server.Close()
+	serverConn := newLossyConn(t)
+	token := startLoopback(t, server, client, serverConn, newLossyConn(t))
+	defer client.Close()
+
+	server.Ban(token, "cheating")
+	expectLeft(t, left, leftEvent{token, "cheating"})
+	expectDisconnected(t, disconnected, "cheating")
+
+	rejoined := make(chan struct{}, 1)
+	server.ClientJoinedEvent(func(clientId string) { rejoined <- struct{}{} })
+	banned := NewClient()
+	banned.DisconnectedEvent(func(reason string) { disconnected <- reason })
+	banned.ConnectConn(newLossyConn(t), serverConn.LocalAddr())
+	defer banned.Close()
+	expectDisconnected(t, disconnected, ReasonBanned)
+	assert.Empty(t, rejoined, "banned hosts should not be able to join")
+
+	server.Unban("127.0.0.1")
+	stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+	defer stopFlush()
+	unbanned := NewClient()
+	unbanned.ConnectConn(newLossyConn(t), serverConn.LocalAddr())
+	defer unbanned.Close()
+	select {
+	case <-rejoined:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for unbanned client to join")
+	}
+}
@@ -2,30 +2,79 @@ package networking
 
 import (
 	"bytes"
-	"fmt"
 	"net"
+	"sync"
 	"time"
 )
 
-var tokens int64
-
 type Session struct {
 	token        string
-	addr         *net.UDPAddr
+	addr         net.Addr
+	auth         *frameAuth
+	largePackets [][]byte
+	fragments    *reassembler
 	idleTimer    time.Time
+	idleMux      *sync.Mutex
 	packetBuffer *bytes.Buffer
+	bufferMux    *sync.Mutex
+	channel      *reliableChannel
 }
 
-func NewSession(addr *net.UDPAddr) *Session {
-	return &Session{
+func NewSession(addr net.Addr) *Session {
+	session := &Session{
 		token:        generateToken(),
 		addr:         addr,
 		idleTimer:    time.Now(),
+		idleMux:      &sync.Mutex{},
 		packetBuffer: new(bytes.Buffer),
+		bufferMux:    &sync.Mutex{},
+		fragments:    newReassembler(),
 	}
+	session.channel = newReliableChannel(func(packet Packet) {
+		session.write(Encode(packet))
+	})
+	return session
+}
+
+// write - buffer an encoded packet. Packets that need to be fragmented are sent on their own,
+// so that a resent packet has the same fragments as the original.
+func (s *Session) write(data []byte) error {
+	s.bufferMux.Lock()
+	defer s.bufferMux.Unlock()
+	if len(data) > maxDatagramSize {
+		s.largePackets = append(s.largePackets, data)
+		return nil
+	}
+	_, err := s.packetBuffer.Write(data)
+	return err
+}
+
+// flush - returns the buffered data, one entry per datagram (or fragmented message), and resets the buffer
+func (s *Session) flush() [][]byte {
+	s.bufferMux.Lock()
+	defer s.bufferMux.Unlock()
+	var payloads [][]byte
+	if s.packetBuffer.Len() > 0 {
+		data := make([]byte, s.packetBuffer.Len())
+		copy(data, s.packetBuffer.Bytes())
+		payloads = append(payloads, data)
+	}
+	payloads = append(payloads, s.largePackets...)
+	s.packetBuffer.Reset()
+	s.largePackets = nil
+	return payloads
+}
+
+// touch - reset the idle timer when a packet is received from the client
+func (s *Session) touch() {
+	s.idleMux.Lock()
+	defer s.idleMux.Unlock()
+	s.idleTimer = time.Now()
 }
 
-func generateToken() string {
-	tokens++
-	return fmt.Sprintf("%v", tokens)
+// idle - the time since a packet was last received from the client
+func (s *Session) idle() time.Duration {
+	s.idleMux.Lock()
+	defer s.idleMux.Unlock()
+	return time.Since(s.idleTimer)
 }
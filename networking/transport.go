@@ -0,0 +1,309 @@
+package networking
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport - creates the packet connections used by the Server and Client.
+// Each packet written to a connection must be delivered whole, or not at all.
+type Transport interface {
+	// Listen - create the server's connection on the given address
+	Listen(addr string) (net.PacketConn, error)
+	// Dial - create a client connection, and return it along with the address of the server
+	Dial(addr string) (net.PacketConn, net.Addr, error)
+}
+
+// UDPTransport - the default transport
+type UDPTransport struct{}
+
+func (UDPTransport) Listen(addr string) (net.PacketConn, error) {
+	return net.ListenPacket("udp", addr)
+}
+
+func (UDPTransport) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, serverAddr, nil
+}
+
+func transportOf(transport []Transport) Transport {
+	if len(transport) > 0 && transport[0] != nil {
+		return transport[0]
+	}
+	return UDPTransport{}
+}
+
+// TCPTransport - sends packets over tcp streams, for networks where udp is blocked.
+// Each packet is prefixed with its length.
+type TCPTransport struct{}
+
+func (TCPTransport) Listen(addr string) (net.PacketConn, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := newStreamPacketConn(listener.Addr())
+	conn.listener = listener
+	go conn.accept()
+	return conn, nil
+}
+
+func (TCPTransport) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	stream, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := newStreamPacketConn(stream.LocalAddr())
+	conn.add(stream)
+	return conn, stream.RemoteAddr(), nil
+}
+
+type streamPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+type stream struct {
+	net.Conn
+	writeMux sync.Mutex
+}
+
+// streamPacketConn - a net.PacketConn over a set of streams, packets are written to the stream matching the address
+type streamPacketConn struct {
+	listener  net.Listener
+	localAddr net.Addr
+	mux       sync.Mutex
+	streams   map[string]*stream
+	packets   chan streamPacket
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamPacketConn(localAddr net.Addr) *streamPacketConn {
+	return &streamPacketConn{
+		localAddr: localAddr,
+		streams:   make(map[string]*stream),
+		packets:   make(chan streamPacket, 256),
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *streamPacketConn) accept() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		c.add(conn)
+	}
+}
+
+func (c *streamPacketConn) add(conn net.Conn) {
+	c.mux.Lock()
+	c.streams[conn.RemoteAddr().String()] = &stream{Conn: conn}
+	c.mux.Unlock()
+	go c.read(conn)
+}
+
+func (c *streamPacketConn) read(conn net.Conn) {
+	defer func() {
+		c.mux.Lock()
+		delete(c.streams, conn.RemoteAddr().String())
+		c.mux.Unlock()
+		conn.Close()
+	}()
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size > readBufferSize {
+			return
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		select {
+		case c.packets <- streamPacket{data: data, addr: conn.RemoteAddr()}:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *streamPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case packet := <-c.packets:
+		return copy(p, packet.data), packet.addr, nil
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *streamPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mux.Lock()
+	s, ok := c.streams[addr.String()]
+	c.mux.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("no connection to %v", addr)
+	}
+	data := make([]byte, 4+len(p))
+	binary.LittleEndian.PutUint32(data, uint32(len(p)))
+	copy(data[4:], p)
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	if _, err := s.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *streamPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.listener != nil {
+			c.listener.Close()
+		}
+		c.mux.Lock()
+		for _, s := range c.streams {
+			s.Close()
+		}
+		c.mux.Unlock()
+	})
+	return nil
+}
+
+func (c *streamPacketConn) LocalAddr() net.Addr                { return c.localAddr }
+func (c *streamPacketConn) SetDeadline(t time.Time) error      { return nil }
+func (c *streamPacketConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamPacketConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// MemoryTransport - delivers packets in memory, for testing game logic without real sockets.
+// Addresses are matched by port, so a server listening on ":1234" can be dialed using "localhost:1234".
+type MemoryTransport struct {
+	mux     sync.Mutex
+	conns   map[string]*memoryConn
+	clients int
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{conns: make(map[string]*memoryConn)}
+}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return "memory" }
+func (a memoryAddr) String() string  { return string(a) }
+
+func memoryAddress(addr string) memoryAddr {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return memoryAddr(port)
+	}
+	return memoryAddr(addr)
+}
+
+func (t *MemoryTransport) Listen(addr string) (net.PacketConn, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	address := memoryAddress(addr)
+	if _, ok := t.conns[address.String()]; ok {
+		return nil, fmt.Errorf("memory address already in use: %v", address)
+	}
+	return t.newConn(address), nil
+}
+
+func (t *MemoryTransport) Dial(addr string) (net.PacketConn, net.Addr, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	serverAddr := memoryAddress(addr)
+	if _, ok := t.conns[serverAddr.String()]; !ok {
+		return nil, nil, fmt.Errorf("no memory server listening on: %v", serverAddr)
+	}
+	t.clients++
+	return t.newConn(memoryAddr(fmt.Sprintf("client-%v", t.clients))), serverAddr, nil
+}
+
+// newConn - the lock must be held
+func (t *MemoryTransport) newConn(addr memoryAddr) *memoryConn {
+	conn := &memoryConn{
+		transport: t,
+		addr:      addr,
+		packets:   make(chan streamPacket, 1024),
+		done:      make(chan struct{}),
+	}
+	t.conns[addr.String()] = conn
+	return conn
+}
+
+func (t *MemoryTransport) lookup(addr net.Addr) (*memoryConn, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	conn, ok := t.conns[addr.String()]
+	return conn, ok
+}
+
+func (t *MemoryTransport) remove(addr memoryAddr) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.conns, addr.String())
+}
+
+type memoryConn struct {
+	transport *MemoryTransport
+	addr      memoryAddr
+	packets   chan streamPacket
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *memoryConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case packet := <-c.packets:
+		return copy(p, packet.data), packet.addr, nil
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo - like udp, packets sent to an address that is not listening, or that is not reading fast enough, are dropped
+func (c *memoryConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	if to, ok := c.transport.lookup(addr); ok {
+		select {
+		case to.packets <- streamPacket{data: append([]byte{}, p...), addr: c.addr}:
+		case <-to.done:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+func (c *memoryConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.transport.remove(c.addr)
+	})
+	return nil
+}
+
+func (c *memoryConn) LocalAddr() net.Addr                { return c.addr }
+func (c *memoryConn) SetDeadline(t time.Time) error      { return nil }
+func (c *memoryConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *memoryConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,93 @@
+package networking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/util"
+)
+
+func TestTransports(t *testing.T) {
+	for name, transport := range map[string]Transport{
+		"udp":    UDPTransport{},
+		"tcp":    TCPTransport{},
+		"memory": NewMemoryTransport(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverReceived, left := make(chan Packet, 10), make(chan leftEvent, 1)
+			server := NewServer()
+			server.PacketReceived(func(packet Packet) { serverReceived <- packet })
+			server.ClientLeftEvent(func(clientId, reason string) { left <- leftEvent{clientId, reason} })
+			assert.NoError(t, server.ListenTransport(transport, "127.0.0.1:0"))
+			defer server.Close()
+			stopFlush := util.SetInterval(server.FlushAllWriteBuffers, 10*time.Millisecond)
+			defer stopFlush()
+
+			clientReceived, connected := make(chan Packet, 10), make(chan string, 1)
+			client := NewClient()
+			client.PacketReceived(func(packet Packet) { clientReceived <- packet })
+			client.ConnectedEvent(func(token string) { connected <- token })
+			assert.NoError(t, client.ConnectTransport(transport, server.conn.LocalAddr().String()))
+
+			var token string
+			select {
+			case token = <-connected:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for handshake")
+			}
+
+			large := randomPayload(20000)
+			client.WriteMessage("toServer", large, ReliableOrdered)
+			server.WriteMessage(Packet{Token: token, Command: "toClient", Data: []byte("hello")}, ReliableOrdered)
+			for _, expect := range []struct {
+				received chan Packet
+				command  string
+				data     []byte
+			}{{serverReceived, "toServer", large}, {clientReceived, "toClient", []byte("hello")}} {
+				select {
+				case packet := <-expect.received:
+					assert.EqualValues(t, expect.command, packet.Command)
+					assert.EqualValues(t, expect.data, packet.Data)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for %v", expect.command)
+				}
+			}
+
+			client.Close()
+			expectLeft(t, left, leftEvent{token, ReasonDisconnected})
+		})
+	}
+}
+
+func TestNetworkMemoryTransport(t *testing.T) {
+	transport := NewMemoryTransport()
+	serverNetwork, clientNetwork := NewNetwork(), NewNetwork()
+	defer serverNetwork.Close()
+	defer clientNetwork.Close()
+
+	serverNetwork.RegisterEvent("ping", func(clientId string, data []byte) {
+		serverNetwork.TriggerEvent("pong", clientId, data, ReliableOrdered)
+	})
+	pong := make(chan string, 1)
+	clientNetwork.RegisterEvent("pong", func(clientId string, data []byte) { pong <- string(data) })
+
+	serverNetwork.StartServer(1234, transport)
+	assert.Error(t, NewNetwork().ConnectClient("localhost:4321", transport), "there is no server on port 4321")
+	assert.NoError(t, clientNetwork.ConnectClient("localhost:1234", transport))
+	clientNetwork.TriggerEvent("ping", "", []byte("hello"), ReliableOrdered)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		serverNetwork.Update(0)
+		clientNetwork.Update(0)
+		select {
+		case data := <-pong:
+			assert.EqualValues(t, "hello", data)
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for pong")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -15,7 +15,9 @@ import (
 	"github.com/walesey/go-engine/util"
 )
 
-const MAX_POINT_LIGHTS = 4
+// the most lights of each type used by a single draw call, chosen from all of the lights in the scene by the light manager
+const MAX_POINT_LIGHTS = 8
+const MAX_SPOT_LIGHTS = 4
 const MAX_DIRECTIONAL_LIGHTS = 4
 
 func init() {
@@ -46,14 +48,33 @@ type OpenglRenderer struct {
 
 	depthTest, depthMast, cullFace, unlit, useTextures bool
 
-	lights                  []*renderer.Light
-	ambientLightValue       mgl32.Vec3
-	nbPointLights           int32
+	boneMatrices []mgl32.Mat4
+
+	lightManager            *renderer.LightManager
 	pointLightValues        []float32
 	pointLightPositions     []float32
-	nbDirectionalLights     int32
+	pointLightAttenuations  []float32
+	spotLightValues         []float32
+	spotLightPositions      []float32
+	spotLightDirections     []float32
+	spotLightAttenuations   []float32
+	spotLightCones          []float32
 	directionalLightValues  []float32
 	directionalLightVectors []float32
+
+	ShadowMapSize, PointShadowMapSize          int32
+	ShadowDistance, PointShadowFar, ShadowBias float32
+	shadowShader                               *renderer.Shader
+	shadowFbo                                  uint32
+	directionalShadowMap, pointShadowMap       uint32
+	directionalShadowLayers, pointShadowLayers int
+	shadowPass, pointShadowPass                bool
+	shadowViewProjection                       mgl32.Mat4
+	shadowLightPosition                        mgl32.Vec3
+	directionalLightShadows, pointLightShadows []int32
+	directionalShadowLights, pointShadowLights []*renderer.Light
+	directionalShadowMatrices                  []mgl32.Mat4
+	shadowCascadeSplits                        mgl32.Vec4
 }
 
 // NewOpenglRenderer - create new renderer
@@ -63,10 +84,26 @@ func NewOpenglRenderer(WindowTitle string, WindowWidth, WindowHeight int, FullSc
 		WindowWidth:             WindowWidth,
 		WindowHeight:            WindowHeight,
 		FullScreen:              FullScreen,
+		lightManager:            renderer.NewLightManager(MAX_POINT_LIGHTS, MAX_SPOT_LIGHTS, MAX_DIRECTIONAL_LIGHTS),
 		pointLightValues:        make([]float32, MAX_POINT_LIGHTS*4),
 		pointLightPositions:     make([]float32, MAX_POINT_LIGHTS*4),
+		pointLightAttenuations:  make([]float32, MAX_POINT_LIGHTS*4),
+		spotLightValues:         make([]float32, MAX_SPOT_LIGHTS*4),
+		spotLightPositions:      make([]float32, MAX_SPOT_LIGHTS*4),
+		spotLightDirections:     make([]float32, MAX_SPOT_LIGHTS*4),
+		spotLightAttenuations:   make([]float32, MAX_SPOT_LIGHTS*4),
+		spotLightCones:          make([]float32, MAX_SPOT_LIGHTS*4),
 		directionalLightValues:  make([]float32, MAX_DIRECTIONAL_LIGHTS*4),
 		directionalLightVectors: make([]float32, MAX_DIRECTIONAL_LIGHTS*4),
+
+		ShadowMapSize:             2048,
+		PointShadowMapSize:        512,
+		ShadowDistance:            50,
+		PointShadowFar:            25,
+		ShadowBias:                0.05,
+		directionalLightShadows:   make([]int32, MAX_DIRECTIONAL_LIGHTS*4),
+		pointLightShadows:         make([]int32, MAX_POINT_LIGHTS*4),
+		directionalShadowMatrices: make([]mgl32.Mat4, MAX_DIRECTIONAL_SHADOWS*MAX_SHADOW_CASCADES),
 	}
 }
 
@@ -145,6 +182,7 @@ func (glRenderer *OpenglRenderer) Start() {
 	gl.CullFace(gl.BACK)
 
 	glRenderer.initPostEffects()
+	glRenderer.initShadows()
 
 	glRenderer.onInit()
 
@@ -171,6 +209,7 @@ func (glRenderer *OpenglRenderer) Start() {
 func (glRenderer *OpenglRenderer) mainLoop() {
 	glRenderer.onUpdate()
 	glRenderer.updateLights()
+	glRenderer.renderShadows()
 
 	//set defaults
 	glRenderer.UseRendererParams(renderer.DefaultRendererParams())
@@ -331,6 +370,13 @@ func (glRenderer *OpenglRenderer) DestroyGeometry(geometry *renderer.Geometry) {
 	gl.DeleteBuffers(1, &geometry.IboId)
 }
 
+func (glRenderer *OpenglRenderer) DestroyInstanced(instanced *renderer.InstancedGeometry) {
+	if instanced.InstanceLoaded {
+		gl.DeleteBuffers(1, &instanced.InstanceVboId)
+		instanced.InstanceLoaded = false
+	}
+}
+
 // CreateMaterial load material
 func (glRenderer *OpenglRenderer) createMaterial(material *renderer.Material) {
 	for _, tex := range material.Textures {
@@ -523,6 +569,67 @@ func (glRenderer *OpenglRenderer) UseCubeMap(cubeMap *renderer.CubeMap) {
 }
 
 func (glRenderer *OpenglRenderer) DrawGeometry(geometry *renderer.Geometry, transform mgl32.Mat4) {
+	if _, ok := glRenderer.setup(geometry, transform, geometry.Bounds().Transform(transform), false); !ok {
+		return
+	}
+	gl.DrawElements(gl.TRIANGLES, (int32)(len(geometry.Indicies)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+}
+
+// DrawInstanced - draw every visible instance with one call, reading the per instance transforms and colors from the instance buffer
+func (glRenderer *OpenglRenderer) DrawInstanced(instanced *renderer.InstancedGeometry, transform mgl32.Mat4) {
+	geometry := instanced.Geometry
+	program, ok := glRenderer.setup(geometry, transform, instanced.Bounds().Transform(transform), true)
+	if !ok {
+		return
+	}
+
+	// upload instance buffer
+	if !instanced.InstanceLoaded {
+		gl.GenBuffers(1, &instanced.InstanceVboId)
+		instanced.InstanceLoaded = true
+	}
+	buffer := instanced.InstanceBuffer()
+	gl.BindBuffer(gl.ARRAY_BUFFER, instanced.InstanceVboId)
+	gl.BufferData(gl.ARRAY_BUFFER, len(buffer)*4, gl.Ptr(buffer), gl.STREAM_DRAW)
+
+	// set instance attributes, a mat4 attribute uses 4 consecutive locations
+	var locations []uint32
+	if modelAttrib := gl.GetAttribLocation(program, gl.Str("instanceModel\x00")); modelAttrib >= 0 {
+		for i := 0; i < 4; i++ {
+			location := uint32(modelAttrib) + uint32(i)
+			gl.EnableVertexAttribArray(location)
+			gl.VertexAttribPointer(location, 4, gl.FLOAT, false, renderer.InstanceStride*4, gl.PtrOffset(i*4*4))
+			locations = append(locations, location)
+		}
+	}
+	if colorAttrib := gl.GetAttribLocation(program, gl.Str("instanceColor\x00")); colorAttrib >= 0 {
+		location := uint32(colorAttrib)
+		gl.EnableVertexAttribArray(location)
+		gl.VertexAttribPointer(location, 4, gl.FLOAT, false, renderer.InstanceStride*4, gl.PtrOffset(16*4))
+		locations = append(locations, location)
+	}
+	for _, location := range locations {
+		gl.VertexAttribDivisor(location, 1)
+	}
+
+	gl.DrawElementsInstanced(gl.TRIANGLES, (int32)(len(geometry.Indicies)), gl.UNSIGNED_INT, gl.PtrOffset(0), (int32)(len(instanced.VisibleInstances())))
+
+	for _, location := range locations {
+		gl.VertexAttribDivisor(location, 0)
+		gl.DisableVertexAttribArray(location)
+	}
+}
+
+// setup - prepare a draw call for the current render pass, false if the geometry shouldn't be drawn
+func (glRenderer *OpenglRenderer) setup(geometry *renderer.Geometry, transform mgl32.Mat4, bounds renderer.AABB, instanced bool) (uint32, bool) {
+	if glRenderer.shadowPass {
+		return glRenderer.setupShadowGeometry(geometry, transform, instanced)
+	}
+	return glRenderer.setupGeometry(geometry, transform, bounds, instanced), true
+}
+
+// setupGeometry - bind the geometry buffers and set the shader uniforms and vertex attributes for a draw call
+func (glRenderer *OpenglRenderer) setupGeometry(geometry *renderer.Geometry, transform mgl32.Mat4, bounds renderer.AABB, instanced bool) uint32 {
 	glRenderer.enableShader()
 
 	if glRenderer.activeShader == nil {
@@ -541,16 +648,7 @@ func (glRenderer *OpenglRenderer) DrawGeometry(geometry *renderer.Geometry, tran
 	glRenderer.enableUnlit(params.Unlit)
 	glRenderer.setTransparency(params.Transparency)
 
-	// set buffers
-	gl.BindBuffer(gl.ARRAY_BUFFER, geometry.VboId)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, geometry.IboId)
-
-	// update buffers
-	if geometry.VboDirty && len(geometry.Verticies) > 0 && len(geometry.Indicies) > 0 {
-		gl.BufferData(gl.ARRAY_BUFFER, len(geometry.Verticies)*4, gl.Ptr(geometry.Verticies), gl.DYNAMIC_DRAW)
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(geometry.Indicies)*4, gl.Ptr(geometry.Indicies), gl.DYNAMIC_DRAW)
-		geometry.VboDirty = false
-	}
+	glRenderer.bindGeometry(geometry)
 
 	// set uniforms
 	modelNormal := transform.Inv().Transpose()
@@ -570,17 +668,12 @@ func (glRenderer *OpenglRenderer) DrawGeometry(geometry *renderer.Geometry, tran
 	}
 
 	shader.Uniforms["unlit"] = glRenderer.unlit
+	shader.Uniforms["instanced"] = instanced
 	shader.Uniforms["useTextures"] = glRenderer.useTextures
+	glRenderer.setSkinUniforms(shader)
 
-	shader.Uniforms["ambientLightValue"] = glRenderer.ambientLightValue
-
-	shader.Uniforms["nbPointLights"] = glRenderer.nbPointLights
-	shader.Uniforms["pointLightValues"] = glRenderer.pointLightValues
-	shader.Uniforms["pointLightPositions"] = glRenderer.pointLightPositions
-
-	shader.Uniforms["nbDirectionalLights"] = glRenderer.nbDirectionalLights
-	shader.Uniforms["directionalLightValues"] = glRenderer.directionalLightValues
-	shader.Uniforms["directionalLightVectors"] = glRenderer.directionalLightVectors
+	glRenderer.setLightUniforms(shader, bounds)
+	glRenderer.setShadowUniforms(shader)
 
 	// set custom uniforms
 	setupUniforms(shader)
@@ -602,48 +695,93 @@ func (glRenderer *OpenglRenderer) DrawGeometry(geometry *renderer.Geometry, tran
 	gl.EnableVertexAttribArray(colorAttrib)
 	gl.VertexAttribPointer(colorAttrib, 4, gl.FLOAT, false, renderer.VertexStride*4, gl.PtrOffset(8*4))
 
-	gl.DrawElements(gl.TRIANGLES, (int32)(len(geometry.Indicies)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+	return program
+}
+
+// bindGeometry - bind the geometry buffers, uploading them if they have changed
+func (glRenderer *OpenglRenderer) bindGeometry(geometry *renderer.Geometry) {
+	// set buffers
+	gl.BindBuffer(gl.ARRAY_BUFFER, geometry.VboId)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, geometry.IboId)
+
+	// update buffers
+	if geometry.VboDirty && len(geometry.Verticies) > 0 && len(geometry.Indicies) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(geometry.Verticies)*4, gl.Ptr(geometry.Verticies), gl.DYNAMIC_DRAW)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(geometry.Indicies)*4, gl.Ptr(geometry.Indicies), gl.DYNAMIC_DRAW)
+		geometry.VboDirty = false
+	}
 }
 
 func (glRenderer *OpenglRenderer) LockCursor(lock bool) {
 	glRenderer.Window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
 }
 
+// updateLights - find the shadow casting lights for the frame, the brightest within ShadowDistance of the camera
 func (glRenderer *OpenglRenderer) updateLights() {
-	glRenderer.nbPointLights = 0
-	glRenderer.nbDirectionalLights = 0
-	for _, light := range glRenderer.lights {
-		c := light.Color
-		p := light.Position
-		d := light.Direction
-		switch light.LightType {
-		case renderer.AMBIENT:
-			glRenderer.ambientLightValue = mgl32.Vec3{c[0], c[1], c[2]}
-		case renderer.POINT:
-			i := glRenderer.nbPointLights
-			glRenderer.pointLightValues[i*4], glRenderer.pointLightValues[i*4+1], glRenderer.pointLightValues[i*4+2] = c[0], c[1], c[2]
-			glRenderer.pointLightPositions[i*4], glRenderer.pointLightPositions[i*4+1], glRenderer.pointLightPositions[i*4+2] = p[0], p[1], p[2]
-			glRenderer.nbPointLights++
-		case renderer.DIRECTIONAL:
-			i := glRenderer.nbDirectionalLights
-			glRenderer.directionalLightValues[i*4], glRenderer.directionalLightValues[i*4+1], glRenderer.directionalLightValues[i*4+2] = c[0], c[1], c[2]
-			glRenderer.directionalLightVectors[i*4], glRenderer.directionalLightVectors[i*4+1], glRenderer.directionalLightVectors[i*4+2] = d[0], d[1], d[2]
-			glRenderer.nbDirectionalLights++
+	area := renderer.SphereAABB(glRenderer.camera.Translation, glRenderer.ShadowDistance)
+	glRenderer.pointShadowLights = glRenderer.lightManager.ShadowCasters(glRenderer.pointShadowLights[:0], renderer.POINT, area, MAX_POINT_SHADOWS)
+	glRenderer.directionalShadowLights = glRenderer.lightManager.ShadowCasters(glRenderer.directionalShadowLights[:0], renderer.DIRECTIONAL, area, MAX_DIRECTIONAL_SHADOWS)
+}
+
+// setLightUniforms - the lights chosen by the light manager for geometry with the given world space bounds
+func (glRenderer *OpenglRenderer) setLightUniforms(shader *renderer.Shader, bounds renderer.AABB) {
+	selection := glRenderer.lightManager.SelectLights(bounds)
+	for i, light := range selection.Point {
+		c, p, a := light.Color, light.Position, light.Attenuation
+		glRenderer.pointLightValues[i*4], glRenderer.pointLightValues[i*4+1], glRenderer.pointLightValues[i*4+2] = c[0], c[1], c[2]
+		glRenderer.pointLightPositions[i*4], glRenderer.pointLightPositions[i*4+1], glRenderer.pointLightPositions[i*4+2] = p[0], p[1], p[2]
+		glRenderer.pointLightAttenuations[i*4], glRenderer.pointLightAttenuations[i*4+1], glRenderer.pointLightAttenuations[i*4+2], glRenderer.pointLightAttenuations[i*4+3] = a[0], a[1], a[2], light.Range
+		glRenderer.pointLightShadows[i*4] = shadowSlot(glRenderer.pointShadowLights, light)
+	}
+	for i, light := range selection.Spot {
+		c, p, d, a := light.Color, light.Position, light.Direction, light.Attenuation
+		inner, outer := light.ConeCosines()
+		glRenderer.spotLightValues[i*4], glRenderer.spotLightValues[i*4+1], glRenderer.spotLightValues[i*4+2] = c[0], c[1], c[2]
+		glRenderer.spotLightPositions[i*4], glRenderer.spotLightPositions[i*4+1], glRenderer.spotLightPositions[i*4+2] = p[0], p[1], p[2]
+		glRenderer.spotLightDirections[i*4], glRenderer.spotLightDirections[i*4+1], glRenderer.spotLightDirections[i*4+2] = d[0], d[1], d[2]
+		glRenderer.spotLightAttenuations[i*4], glRenderer.spotLightAttenuations[i*4+1], glRenderer.spotLightAttenuations[i*4+2], glRenderer.spotLightAttenuations[i*4+3] = a[0], a[1], a[2], light.Range
+		glRenderer.spotLightCones[i*4], glRenderer.spotLightCones[i*4+1] = inner, outer
+	}
+	for i, light := range selection.Directional {
+		c, d := light.Color, light.Direction
+		glRenderer.directionalLightValues[i*4], glRenderer.directionalLightValues[i*4+1], glRenderer.directionalLightValues[i*4+2] = c[0], c[1], c[2]
+		glRenderer.directionalLightVectors[i*4], glRenderer.directionalLightVectors[i*4+1], glRenderer.directionalLightVectors[i*4+2] = d[0], d[1], d[2]
+		glRenderer.directionalLightShadows[i*4] = shadowSlot(glRenderer.directionalShadowLights, light)
+	}
+
+	shader.Uniforms["ambientLightValue"] = selection.Ambient
+
+	shader.Uniforms["nbPointLights"] = int32(len(selection.Point))
+	shader.Uniforms["pointLightValues"] = glRenderer.pointLightValues
+	shader.Uniforms["pointLightPositions"] = glRenderer.pointLightPositions
+	shader.Uniforms["pointLightAttenuations"] = glRenderer.pointLightAttenuations
+
+	shader.Uniforms["nbSpotLights"] = int32(len(selection.Spot))
+	shader.Uniforms["spotLightValues"] = glRenderer.spotLightValues
+	shader.Uniforms["spotLightPositions"] = glRenderer.spotLightPositions
+	shader.Uniforms["spotLightDirections"] = glRenderer.spotLightDirections
+	shader.Uniforms["spotLightAttenuations"] = glRenderer.spotLightAttenuations
+	shader.Uniforms["spotLightCones"] = glRenderer.spotLightCones
+
+	shader.Uniforms["nbDirectionalLights"] = int32(len(selection.Directional))
+	shader.Uniforms["directionalLightValues"] = glRenderer.directionalLightValues
+	shader.Uniforms["directionalLightVectors"] = glRenderer.directionalLightVectors
+}
+
+// shadowSlot - the index of the light's shadow map, -1 if it doesn't cast shadows
+func shadowSlot(shadowLights []*renderer.Light, light *renderer.Light) int32 {
+	for i, l := range shadowLights {
+		if l == light {
+			return int32(i)
 		}
 	}
+	return -1
 }
 
 func (glRenderer *OpenglRenderer) AddLight(light *renderer.Light) {
-	glRenderer.lights = append(glRenderer.lights, light)
+	glRenderer.lightManager.AddLight(light)
 }
 
 func (glRenderer *OpenglRenderer) RemoveLight(light *renderer.Light) {
-	for i, l := range glRenderer.lights {
-		if l == light {
-			glRenderer.lights[i] = glRenderer.lights[len(glRenderer.lights)-1]
-			glRenderer.lights[len(glRenderer.lights)-1] = nil
-			glRenderer.lights = glRenderer.lights[:len(glRenderer.lights)-1]
-			break
-		}
-	}
+	glRenderer.lightManager.RemoveLight(light)
 }
@@ -0,0 +1,185 @@
+package opengl
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+	"github.com/walesey/go-engine/shaders"
+)
+
+const MAX_SHADOW_CASCADES = 3
+
+// the most lights of each type that cast shadows in a frame, point lights render 6 shadow maps each
+const MAX_DIRECTIONAL_SHADOWS = 4
+const MAX_POINT_SHADOWS = 4
+
+const directionalShadowTextureUnit = 21
+const pointShadowTextureUnit = 22
+
+func newShadowShader() *renderer.Shader {
+	shader := renderer.NewShader()
+	shader.FragDataLocations = nil
+	shader.VertSrc = shaders.ShadowVert
+	shader.FragSrc = shaders.ShadowFrag
+	return shader
+}
+
+// initShadows - create the shadow frame buffer and empty shadow maps, so the shadow samplers are always bound to a valid texture
+func (glRenderer *OpenglRenderer) initShadows() {
+	glRenderer.shadowShader = newShadowShader()
+	gl.GenFramebuffers(1, &glRenderer.shadowFbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, glRenderer.shadowFbo)
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	gl.GenTextures(1, &glRenderer.directionalShadowMap)
+	gl.GenTextures(1, &glRenderer.pointShadowMap)
+	glRenderer.allocateShadowMaps(0, 0)
+}
+
+// allocateShadowMaps - resize the shadow map texture arrays for the number of shadow casting lights
+func (glRenderer *OpenglRenderer) allocateShadowMaps(directionalLights, pointLights int) {
+	layers := directionalLights * MAX_SHADOW_CASCADES
+	if layers == 0 {
+		layers = 1
+	}
+	if layers != glRenderer.directionalShadowLayers {
+		glRenderer.directionalShadowLayers = layers
+		size := glRenderer.ShadowMapSize
+		gl.ActiveTexture(gl.TEXTURE0 + directionalShadowTextureUnit)
+		gl.BindTexture(gl.TEXTURE_2D_ARRAY, glRenderer.directionalShadowMap)
+		gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.DEPTH_COMPONENT32F, size, size, int32(layers), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+		setShadowTextureParameters(gl.TEXTURE_2D_ARRAY)
+	}
+
+	if pointLights == 0 {
+		pointLights = 1
+	}
+	if pointLights != glRenderer.pointShadowLayers {
+		glRenderer.pointShadowLayers = pointLights
+		size := glRenderer.PointShadowMapSize
+		gl.ActiveTexture(gl.TEXTURE0 + pointShadowTextureUnit)
+		gl.BindTexture(gl.TEXTURE_CUBE_MAP_ARRAY, glRenderer.pointShadowMap)
+		gl.TexImage3D(gl.TEXTURE_CUBE_MAP_ARRAY, 0, gl.DEPTH_COMPONENT32F, size, size, int32(pointLights*6), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+		setShadowTextureParameters(gl.TEXTURE_CUBE_MAP_ARRAY)
+	}
+}
+
+func setShadowTextureParameters(target uint32) {
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+}
+
+// renderShadows - render the scene into the shadow maps of the shadow casting lights found by updateLights.
+// Directional lights get MAX_SHADOW_CASCADES layers splitting the view up to ShadowDistance,
+// point lights get a cube map out to PointShadowFar.
+// Geometry is only drawn if it passes the main camera's frustrum culling, so nodes with shadows that can be seen
+// while the caster is off screen need FrustrumCulling disabled.
+func (glRenderer *OpenglRenderer) renderShadows() {
+	directionalLights, pointLights := glRenderer.directionalShadowLights, glRenderer.pointShadowLights
+	glRenderer.allocateShadowMaps(len(directionalLights), len(pointLights))
+	if len(directionalLights) == 0 && len(pointLights) == 0 {
+		return
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, glRenderer.shadowFbo)
+	gl.Enable(gl.POLYGON_OFFSET_FILL)
+	gl.PolygonOffset(2, 4)
+	glRenderer.shadowPass = true
+
+	splits := renderer.ShadowCascadeSplits(glRenderer.camera.Near, glRenderer.ShadowDistance, MAX_SHADOW_CASCADES)
+	copy(glRenderer.shadowCascadeSplits[:], splits)
+	gl.Viewport(0, 0, glRenderer.ShadowMapSize, glRenderer.ShadowMapSize)
+	glRenderer.pointShadowPass = false
+	for i, light := range directionalLights {
+		near := glRenderer.camera.Near
+		for cascade, far := range splits {
+			layer := i*MAX_SHADOW_CASCADES + cascade
+			matrix := glRenderer.camera.ShadowCascade(glRenderer.WindowDimensions(), light.Direction, near, far, glRenderer.ShadowDistance, int(glRenderer.ShadowMapSize))
+			glRenderer.directionalShadowMatrices[layer] = matrix
+			gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, glRenderer.directionalShadowMap, 0, int32(layer))
+			glRenderer.renderShadowMap(matrix)
+			near = far
+		}
+	}
+
+	gl.Viewport(0, 0, glRenderer.PointShadowMapSize, glRenderer.PointShadowMapSize)
+	glRenderer.pointShadowPass = true
+	for i, light := range pointLights {
+		glRenderer.shadowLightPosition = light.Position
+		for face, matrix := range renderer.PointShadowMatrices(light.Position, 0.05, glRenderer.PointShadowFar) {
+			gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, glRenderer.pointShadowMap, 0, int32(i*6+face))
+			glRenderer.renderShadowMap(matrix)
+		}
+	}
+
+	glRenderer.shadowPass = false
+	gl.Disable(gl.POLYGON_OFFSET_FILL)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	width, height := glRenderer.Window.GetFramebufferSize()
+	gl.Viewport(0, 0, int32(width), int32(height))
+}
+
+func (glRenderer *OpenglRenderer) renderShadowMap(viewProjection mgl32.Mat4) {
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+	glRenderer.shadowViewProjection = viewProjection
+	glRenderer.UseRendererParams(renderer.DefaultRendererParams())
+	glRenderer.UseMaterial(nil)
+	glRenderer.onRender()
+}
+
+// setupShadowGeometry - prepare a depth only draw call into the current shadow map,
+// false if the geometry doesn't cast shadows
+func (glRenderer *OpenglRenderer) setupShadowGeometry(geometry *renderer.Geometry, transform mgl32.Mat4, instanced bool) (uint32, bool) {
+	if glRenderer.camera.Ortho || !glRenderer.rendererParams.CastShadows {
+		return 0, false
+	}
+	glRenderer.createGeometry(geometry)
+
+	shader := glRenderer.shadowShader
+	if glRenderer.activeShader != shader {
+		glRenderer.createShader(shader)
+		glRenderer.activeShader = shader
+		gl.UseProgram(shader.Program)
+	}
+	program := shader.Program
+
+	glRenderer.enableDepthTest(true)
+	glRenderer.enableDepthMask(true)
+	glRenderer.enableCullFace(false)
+
+	glRenderer.bindGeometry(geometry)
+
+	shader.Uniforms["model"] = transform
+	shader.Uniforms["instanced"] = instanced
+	glRenderer.setSkinUniforms(shader)
+	shader.Uniforms["lightViewProjection"] = glRenderer.shadowViewProjection
+	shader.Uniforms["pointShadow"] = glRenderer.pointShadowPass
+	shader.Uniforms["lightPosition"] = glRenderer.shadowLightPosition
+	shader.Uniforms["shadowFar"] = glRenderer.PointShadowFar
+	setupUniforms(shader)
+
+	vertAttrib := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
+	gl.EnableVertexAttribArray(vertAttrib)
+	gl.VertexAttribPointer(vertAttrib, 3, gl.FLOAT, false, renderer.VertexStride*4, gl.PtrOffset(0))
+	return program, true
+}
+
+// setShadowUniforms - the uniforms used by shaders/lib/shadows.glsl
+func (glRenderer *OpenglRenderer) setShadowUniforms(shader *renderer.Shader) {
+	shader.Uniforms["receiveShadows"] = glRenderer.rendererParams.ReceiveShadows
+	shader.Uniforms["shadowBias"] = glRenderer.ShadowBias
+	shader.Uniforms["directionalShadowMap"] = int32(directionalShadowTextureUnit)
+	shader.Uniforms["directionalLightShadows"] = glRenderer.directionalLightShadows
+	shader.Uniforms["directionalShadowMatrices"] = glRenderer.directionalShadowMatrices
+	shader.Uniforms["shadowCascadeSplits"] = glRenderer.shadowCascadeSplits
+	shader.Uniforms["pointShadowMap"] = int32(pointShadowTextureUnit)
+	shader.Uniforms["pointLightShadows"] = glRenderer.pointLightShadows
+	shader.Uniforms["pointShadowFar"] = glRenderer.PointShadowFar
+}
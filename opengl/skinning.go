@@ -0,0 +1,81 @@
+package opengl
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// DrawSkinned - draw the geometry skinned on the gpu with the bone matrices,
+// geometry with more than renderer.MaxSkinBones bones is skinned on the cpu instead
+func (glRenderer *OpenglRenderer) DrawSkinned(skinned *renderer.SkinnedGeometry, transform mgl32.Mat4) {
+	if len(skinned.BoneMatrices) == 0 || len(skinned.Skin) == 0 {
+		glRenderer.DrawGeometry(skinned.Geometry, transform)
+		return
+	}
+	if len(skinned.BoneMatrices) > renderer.MaxSkinBones {
+		glRenderer.DrawGeometry(skinned.CPUSkin(), transform)
+		return
+	}
+
+	geometry := skinned.Geometry
+	glRenderer.boneMatrices = skinned.BoneMatrices
+	program, ok := glRenderer.setup(geometry, transform, skinned.Bounds().Transform(transform), false)
+	glRenderer.boneMatrices = nil
+	if !ok {
+		return
+	}
+
+	// upload skin buffer
+	if !skinned.SkinLoaded {
+		gl.GenBuffers(1, &skinned.SkinVboId)
+		skinned.SkinLoaded = true
+		skinned.SkinDirty = true
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, skinned.SkinVboId)
+	if skinned.SkinDirty {
+		gl.BufferData(gl.ARRAY_BUFFER, len(skinned.Skin)*4, gl.Ptr(skinned.Skin), gl.STATIC_DRAW)
+		skinned.SkinDirty = false
+	}
+
+	// set skin attributes
+	var locations []uint32
+	if indexAttrib := gl.GetAttribLocation(program, gl.Str("boneIndices\x00")); indexAttrib >= 0 {
+		location := uint32(indexAttrib)
+		gl.EnableVertexAttribArray(location)
+		gl.VertexAttribPointer(location, 4, gl.FLOAT, false, renderer.SkinStride*4, gl.PtrOffset(0))
+		locations = append(locations, location)
+	}
+	if weightAttrib := gl.GetAttribLocation(program, gl.Str("boneWeights\x00")); weightAttrib >= 0 {
+		location := uint32(weightAttrib)
+		gl.EnableVertexAttribArray(location)
+		gl.VertexAttribPointer(location, 4, gl.FLOAT, false, renderer.SkinStride*4, gl.PtrOffset(4*4))
+		locations = append(locations, location)
+	}
+
+	gl.DrawElements(gl.TRIANGLES, (int32)(len(geometry.Indicies)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+
+	for _, location := range locations {
+		gl.DisableVertexAttribArray(location)
+	}
+}
+
+func (glRenderer *OpenglRenderer) DestroySkinned(skinned *renderer.SkinnedGeometry) {
+	if skinned.SkinLoaded {
+		gl.DeleteBuffers(1, &skinned.SkinVboId)
+		skinned.SkinLoaded = false
+	}
+}
+
+// setSkinUniforms - the uniforms used by shaders/lib/skinning.glsl
+func (glRenderer *OpenglRenderer) setSkinUniforms(shader *renderer.Shader) {
+	skinned := len(glRenderer.boneMatrices) > 0
+	shader.Uniforms["skinned"] = skinned
+	if skinned {
+		shader.Uniforms["boneMatrices"] = glRenderer.boneMatrices
+		shader.Uniforms["boneCount"] = len(glRenderer.boneMatrices)
+	} else {
+		delete(shader.Uniforms, "boneMatrices")
+		delete(shader.Uniforms, "boneCount")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/walesey/go-engine/assets"
+)
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: packBuilder path/to/assets out.pack")
+		os.Exit(1)
+	}
+	srcDir, packFile := os.Args[1], os.Args[2]
+	if insideDir(packFile, srcDir) {
+		fmt.Println("The pack file must be outside of the assets directory")
+		os.Exit(1)
+	}
+
+	out, err := os.Create(packFile)
+	if err != nil {
+		fmt.Printf("Error creating pack file: %v\n", err)
+		os.Exit(1)
+	}
+	err = assets.WritePack(out, assets.DirFS(srcDir))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing pack file: %v\n", err)
+		os.Remove(packFile)
+		os.Exit(1)
+	}
+}
+
+// insideDir - true if path is dir or is within it, following symlinks where the paths exist
+func insideDir(path, dir string) bool {
+	path, dir = resolvePath(path), resolvePath(dir)
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func resolvePath(path string) string {
+	path, _ = filepath.Abs(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return path
+}
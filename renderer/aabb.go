@@ -0,0 +1,150 @@
+package renderer
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// AABB - an axis aligned bounding box
+type AABB struct {
+	Min, Max mgl32.Vec3
+}
+
+// EmptyAABB - a box containing nothing, that can be grown with Extend and Union
+func EmptyAABB() AABB {
+	inf := float32(math.Inf(1))
+	return AABB{
+		Min: mgl32.Vec3{inf, inf, inf},
+		Max: mgl32.Vec3{-inf, -inf, -inf},
+	}
+}
+
+// SphereAABB - the box containing the sphere given by center and radius
+func SphereAABB(center mgl32.Vec3, radius float32) AABB {
+	r := mgl32.Vec3{radius, radius, radius}
+	return AABB{Min: center.Sub(r), Max: center.Add(r)}
+}
+
+func (box AABB) IsEmpty() bool {
+	return box.Min[0] > box.Max[0] || box.Min[1] > box.Max[1] || box.Min[2] > box.Max[2]
+}
+
+// Extend - the box grown to contain the point
+func (box AABB) Extend(point mgl32.Vec3) AABB {
+	for i := 0; i < 3; i++ {
+		box.Min[i] = minF32(box.Min[i], point[i])
+		box.Max[i] = maxF32(box.Max[i], point[i])
+	}
+	return box
+}
+
+// Union - the box containing both boxes
+func (box AABB) Union(other AABB) AABB {
+	if other.IsEmpty() {
+		return box
+	}
+	return box.Extend(other.Min).Extend(other.Max)
+}
+
+func (box AABB) Center() mgl32.Vec3 {
+	if box.IsEmpty() {
+		return mgl32.Vec3{}
+	}
+	return box.Min.Add(box.Max).Mul(0.5)
+}
+
+func (box AABB) Size() mgl32.Vec3 {
+	if box.IsEmpty() {
+		return mgl32.Vec3{}
+	}
+	return box.Max.Sub(box.Min)
+}
+
+// Radius - the radius of the sphere around Center containing the box
+func (box AABB) Radius() float32 {
+	return box.Size().Len() * 0.5
+}
+
+func (box AABB) Contains(point mgl32.Vec3) bool {
+	return point[0] >= box.Min[0] && point[0] <= box.Max[0] &&
+		point[1] >= box.Min[1] && point[1] <= box.Max[1] &&
+		point[2] >= box.Min[2] && point[2] <= box.Max[2]
+}
+
+// ClosestPoint - the point in the box nearest to the given point
+func (box AABB) ClosestPoint(point mgl32.Vec3) mgl32.Vec3 {
+	for i := 0; i < 3; i++ {
+		point[i] = mgl32.Clamp(point[i], box.Min[i], box.Max[i])
+	}
+	return point
+}
+
+func (box AABB) Intersects(other AABB) bool {
+	return box.Min[0] <= other.Max[0] && box.Max[0] >= other.Min[0] &&
+		box.Min[1] <= other.Max[1] && box.Max[1] >= other.Min[1] &&
+		box.Min[2] <= other.Max[2] && box.Max[2] >= other.Min[2]
+}
+
+// Transform - the box containing this box after it has been transformed
+func (box AABB) Transform(transform mgl32.Mat4) AABB {
+	if box.IsEmpty() {
+		return box
+	}
+	translation := transform.Col(3).Vec3()
+	result := AABB{Min: translation, Max: translation}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			a, b := transform.At(i, j)*box.Min[j], transform.At(i, j)*box.Max[j]
+			result.Min[i] += minF32(a, b)
+			result.Max[i] += maxF32(a, b)
+		}
+	}
+	return result
+}
+
+// RayIntersect - the distance along the ray, in multiples of direction, to where it enters the box.
+// The distance is zero if the ray starts inside the box.
+func (box AABB) RayIntersect(start, direction mgl32.Vec3) (distance float32, ok bool) {
+	if box.IsEmpty() {
+		return 0, false
+	}
+	tMin, tMax := float32(0), float32(math.Inf(1))
+	for i := 0; i < 3; i++ {
+		if direction[i] == 0 {
+			if start[i] < box.Min[i] || start[i] > box.Max[i] {
+				return 0, false
+			}
+			continue
+		}
+		t1, t2 := (box.Min[i]-start[i])/direction[i], (box.Max[i]-start[i])/direction[i]
+		tMin, tMax = maxF32(tMin, minF32(t1, t2)), minF32(tMax, maxF32(t1, t2))
+		if tMin > tMax {
+			return 0, false
+		}
+	}
+	return tMin, true
+}
+
+// spatialBounds - the bounds of a spatial in its parent's space.
+// Spatials without bounds use the sphere given by Center and BoundingRadius.
+func spatialBounds(spatial Spatial) AABB {
+	if bounded, ok := spatial.(Bounded); ok {
+		return bounded.Bounds()
+	}
+	return SphereAABB(spatial.Center(), spatial.BoundingRadius())
+}
+
+func minF32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
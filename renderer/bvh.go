@@ -0,0 +1,289 @@
+package renderer
+
+import (
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/util"
+)
+
+const bvhLeafSize = 4
+
+// BVH - a bounding volume hierarchy over the spatials in a scene graph, in world space.
+// The hierarchy is built from the children of the nodes, and needs to be rebuilt when spatials are added or removed (see Stale).
+// When the spatials move, the bounds are refit to the same hierarchy (see Refit).
+type BVH struct {
+	root       *Node
+	structure  uint64
+	revision   uint64
+	nodes      []*Node
+	parents    []int
+	transforms []mgl32.Mat4
+	cullable   []bool
+	leaves     []bvhLeaf
+	order      []int
+	tree       []bvhNode
+	dynamic    []int
+
+	// Visited - the number of bvh nodes and leaves tested by the last query
+	Visited int
+}
+
+// bvhLeaf - a spatial that is not a Node, in the order it is drawn by Node.Draw
+type bvhLeaf struct {
+	spatial Spatial
+	node    int
+	bounds  AABB
+}
+
+// bvhNode - either a branch with two children, or a range of leaves in order
+type bvhNode struct {
+	bounds       AABB
+	left, right  int
+	start, count int
+	cullable     bool
+}
+
+// NewBVH - build a bvh of all the spatials under the node.
+// Spatials that are not Bounded are not added to the hierarchy, and are tested individually by each query.
+func NewBVH(root *Node) *BVH {
+	bvh := &BVH{root: root, structure: root.structure, revision: root.revision}
+	bvh.flatten(root, -1)
+	bvh.transforms = make([]mgl32.Mat4, len(bvh.nodes))
+	bvh.cullable = make([]bool, len(bvh.nodes))
+	bvh.updateTransforms()
+	for i, leaf := range bvh.leaves {
+		if _, ok := leaf.spatial.(Bounded); ok {
+			bvh.order = append(bvh.order, i)
+		} else {
+			bvh.dynamic = append(bvh.dynamic, i)
+		}
+	}
+	bvh.updateLeafBounds()
+	if len(bvh.order) > 0 {
+		bvh.build(0, len(bvh.order))
+	}
+	return bvh
+}
+
+// flatten - collect the nodes, and the leaves in the same order as Node.Draw
+func (bvh *BVH) flatten(node *Node, parent int) {
+	index := len(bvh.nodes)
+	bvh.nodes = append(bvh.nodes, node)
+	bvh.parents = append(bvh.parents, parent)
+	sort.Sort(byOrthoOrder(node.children))
+	for _, child := range node.children {
+		if childNode, ok := child.(*Node); ok {
+			bvh.flatten(childNode, index)
+			continue
+		}
+		bvh.leaves = append(bvh.leaves, bvhLeaf{spatial: child, node: index})
+	}
+}
+
+// updateTransforms - recalculate the world transform of each node from the current node transforms.
+// Parents are always before their children in the list of nodes.
+func (bvh *BVH) updateTransforms() {
+	for i, node := range bvh.nodes {
+		transform, cullable := mgl32.Ident4(), false
+		if parent := bvh.parents[i]; parent >= 0 {
+			transform, cullable = bvh.transforms[parent], bvh.cullable[parent]
+		}
+		bvh.transforms[i] = transform.Mul4(node.Transform)
+		bvh.cullable[i] = cullable || node.FrustrumCulling
+	}
+}
+
+// updateLeafBounds - recalculate the world bounds of the leaves in the hierarchy
+func (bvh *BVH) updateLeafBounds() {
+	for _, i := range bvh.order {
+		leaf := &bvh.leaves[i]
+		leaf.bounds = spatialBounds(leaf.spatial).Transform(bvh.transforms[leaf.node])
+	}
+}
+
+// build - build the tree for the leaves order[start:end], splitting at the median of the longest axis
+func (bvh *BVH) build(start, end int) int {
+	index := len(bvh.tree)
+	bvh.tree = append(bvh.tree, bvhNode{bounds: EmptyAABB(), cullable: true})
+	centers := EmptyAABB()
+	for _, i := range bvh.order[start:end] {
+		leaf := bvh.leaves[i]
+		bvh.tree[index].bounds = bvh.tree[index].bounds.Union(leaf.bounds)
+		bvh.tree[index].cullable = bvh.tree[index].cullable && bvh.cullable[leaf.node]
+		centers = centers.Extend(leaf.bounds.Center())
+	}
+
+	size := centers.Size()
+	if end-start <= bvhLeafSize || size.Len() == 0 {
+		bvh.tree[index].start, bvh.tree[index].count = start, end-start
+		return index
+	}
+
+	axis := 0
+	if size[1] > size[axis] {
+		axis = 1
+	}
+	if size[2] > size[axis] {
+		axis = 2
+	}
+	order := bvh.order[start:end]
+	sort.Slice(order, func(i, j int) bool {
+		return bvh.leaves[order[i]].bounds.Center()[axis] < bvh.leaves[order[j]].bounds.Center()[axis]
+	})
+	middle := (start + end) / 2
+	left := bvh.build(start, middle)
+	right := bvh.build(middle, end)
+	bvh.tree[index].left, bvh.tree[index].right = left, right
+	return index
+}
+
+// Stale - true if spatials have been added to or removed from the node since the bvh was built
+func (bvh *BVH) Stale() bool {
+	return bvh.root.structure != bvh.structure
+}
+
+// Refit - update the bounds of the hierarchy after the spatials have moved or changed size, without rebuilding it.
+// The hierarchy becomes less efficient to query as the spatials move further from where they were when it was built.
+func (bvh *BVH) Refit() {
+	bvh.revision = bvh.root.revision
+	bvh.updateTransforms()
+	bvh.updateLeafBounds()
+	// children are always after their parents in the tree
+	for index := len(bvh.tree) - 1; index >= 0; index-- {
+		node := &bvh.tree[index]
+		if node.count == 0 {
+			left, right := bvh.tree[node.left], bvh.tree[node.right]
+			node.bounds = left.bounds.Union(right.bounds)
+			node.cullable = left.cullable && right.cullable
+			continue
+		}
+		node.bounds, node.cullable = EmptyAABB(), true
+		for _, i := range bvh.order[node.start : node.start+node.count] {
+			leaf := bvh.leaves[i]
+			node.bounds = node.bounds.Union(leaf.bounds)
+			node.cullable = node.cullable && bvh.cullable[leaf.node]
+		}
+	}
+}
+
+// Len - the number of spatials in the bvh
+func (bvh *BVH) Len() int {
+	return len(bvh.leaves)
+}
+
+// Frustrum - the spatials that may be visible to the camera, in the order Node.Draw would draw them.
+// Spatials are only culled if they are under a node with FrustrumCulling enabled.
+func (bvh *BVH) Frustrum(camera *Camera, windowSize mgl32.Vec2) []Spatial {
+	visible := bvh.frustrum(camera, windowSize)
+	spatials := make([]Spatial, len(visible))
+	for i, leaf := range visible {
+		spatials[i] = bvh.leaves[leaf].spatial
+	}
+	return spatials
+}
+
+func (bvh *BVH) frustrum(camera *Camera, windowSize mgl32.Vec2) []int {
+	bvh.Visited = 0
+	contains := func(box AABB) bool {
+		return camera.CameraContainsAABB(windowSize, box)
+	}
+
+	var visible []int
+	if len(bvh.tree) > 0 {
+		visible = bvh.query(0, contains, visible)
+	}
+	for _, i := range bvh.dynamic {
+		bvh.Visited++
+		leaf := bvh.leaves[i]
+		if !bvh.cullable[leaf.node] || contains(spatialBounds(leaf.spatial).Transform(bvh.transforms[leaf.node])) {
+			visible = append(visible, i)
+		}
+	}
+	sort.Ints(visible)
+	return visible
+}
+
+func (bvh *BVH) query(index int, contains func(AABB) bool, visible []int) []int {
+	bvh.Visited++
+	node := bvh.tree[index]
+	if node.cullable && !contains(node.bounds) {
+		return visible
+	}
+	if node.count == 0 {
+		visible = bvh.query(node.left, contains, visible)
+		return bvh.query(node.right, contains, visible)
+	}
+	for _, i := range bvh.order[node.start : node.start+node.count] {
+		bvh.Visited++
+		leaf := bvh.leaves[i]
+		if !bvh.cullable[leaf.node] || contains(leaf.bounds) {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+// RayIntersect - the closest point where the ray intersects a geometry or node in the bvh
+func (bvh *BVH) RayIntersect(start, direction mgl32.Vec3) (point mgl32.Vec3, spatial Spatial, ok bool) {
+	bvh.Visited = 0
+	var distSq float32
+	if len(bvh.tree) == 0 {
+		return
+	}
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := bvh.tree[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		bvh.Visited++
+		distance, hit := node.bounds.RayIntersect(start, direction)
+		if !hit || (ok && util.Vec3LenSq(direction.Mul(distance)) > distSq) {
+			continue
+		}
+		if node.count == 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		for _, i := range bvh.order[node.start : node.start+node.count] {
+			bvh.Visited++
+			leaf := bvh.leaves[i]
+			intersect, intersectOk := leaf.rayIntersect(start, direction, bvh.transforms[leaf.node])
+			if !intersectOk {
+				continue
+			}
+			if newDist := util.Vec3LenSq(intersect.Sub(start)); !ok || newDist < distSq {
+				point, spatial, distSq, ok = intersect, leaf.spatial, newDist, true
+			}
+		}
+	}
+	return
+}
+
+func (leaf bvhLeaf) rayIntersect(start, direction mgl32.Vec3, transform mgl32.Mat4) (point mgl32.Vec3, ok bool) {
+	if _, hit := leaf.bounds.RayIntersect(start, direction); !hit {
+		return
+	}
+	geometry, isGeometry := leaf.spatial.(*Geometry)
+	if !isGeometry {
+		return
+	}
+	inverseTx := transform.Inv()
+	point, ok = geometry.RayIntersect(mgl32.TransformCoordinate(start, inverseTx), mgl32.TransformNormal(direction, inverseTx))
+	if ok {
+		point = mgl32.TransformCoordinate(point, transform)
+	}
+	return
+}
+
+// Draw - draw the spatials that may be visible to the camera, with the current transforms and render states of their parent nodes
+func (bvh *BVH) Draw(renderer Renderer) {
+	bvh.updateTransforms()
+	for _, i := range bvh.frustrum(renderer.Camera(), renderer.WindowDimensions()) {
+		leaf := bvh.leaves[i]
+		bvh.nodes[leaf.node].setRenderStates(renderer)
+		leaf.spatial.Draw(renderer, bvh.transforms[leaf.node])
+	}
+	for _, node := range bvh.nodes {
+		node.cleanupDeleted(renderer)
+	}
+}
@@ -0,0 +1,176 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+// gridScene - size^3 cubes spaced 4 units apart, centred on the origin
+func gridScene(size int) *Node {
+	root := NewNode()
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for z := 0; z < size; z++ {
+				offset := float32(size-1) * 2
+				root.Add(nodeAt(mgl32.Vec3{float32(x*4) - offset, float32(y*4) - offset, float32(z*4) - offset}, CreateCube()))
+			}
+		}
+	}
+	root.SetFrustrumCullingRecursive(true)
+	return root
+}
+
+func TestAABB(t *testing.T) {
+	box := EmptyAABB()
+	assert.True(t, box.IsEmpty())
+	box = box.Extend(mgl32.Vec3{1, 2, 3}).Union(AABB{Min: mgl32.Vec3{-1, 0, 0}, Max: mgl32.Vec3{0, 0, 0}})
+	assert.Equal(t, AABB{Min: mgl32.Vec3{-1, 0, 0}, Max: mgl32.Vec3{1, 2, 3}}, box)
+	assert.Equal(t, mgl32.Vec3{0, 1, 1.5}, box.Center())
+
+	rotated := box.Transform(mgl32.Translate3D(10, 0, 0).Mul4(mgl32.HomogRotate3DZ(mgl32.DegToRad(90))))
+	assert.True(t, rotated.Min.ApproxEqualThreshold(mgl32.Vec3{8, -1, 0}, 1e-4), "%v", rotated.Min)
+	assert.True(t, rotated.Max.ApproxEqualThreshold(mgl32.Vec3{10, 1, 3}, 1e-4), "%v", rotated.Max)
+
+	distance, ok := box.RayIntersect(mgl32.Vec3{-5, 1, 1}, mgl32.Vec3{2, 0, 0})
+	assert.True(t, ok)
+	assert.InDelta(t, 2, distance, 0.0001)
+	_, ok = box.RayIntersect(mgl32.Vec3{-5, 1, 1}, mgl32.Vec3{-1, 0, 0})
+	assert.False(t, ok)
+	_, ok = box.RayIntersect(mgl32.Vec3{-5, 5, 1}, mgl32.Vec3{1, 0, 0})
+	assert.False(t, ok)
+}
+
+func TestGeometryBounds(t *testing.T) {
+	geometry := CreateBoxWithOffset(2, 2, 1, 1)
+	assert.Equal(t, mgl32.Vec3{2, 2, 0}, geometry.Center())
+	assert.InDelta(t, 1.4142, geometry.BoundingRadius(), 0.001)
+
+	node := NewNode()
+	node.Add(geometry)
+	node.SetScale(mgl32.Vec3{2, 2, 2})
+	parent := nodeAt(mgl32.Vec3{10, 0, 0}, node)
+	assert.Equal(t, AABB{Min: mgl32.Vec3{12, 2, 0}, Max: mgl32.Vec3{16, 6, 0}}, parent.Bounds())
+
+	revision := parent.revision
+	geometry.Transform(mgl32.Translate3D(0, 0, 1))
+	assert.NotEqual(t, revision, parent.revision)
+	assert.Equal(t, AABB{Min: mgl32.Vec3{12, 2, 2}, Max: mgl32.Vec3{16, 6, 2}}, parent.Bounds())
+
+	node.Remove(geometry, false)
+	assert.True(t, parent.Bounds().IsEmpty())
+}
+
+func TestNodeRayIntersect(t *testing.T) {
+	root := gridScene(4)
+	point, ok := root.RayIntersect(mgl32.Vec3{2.1, 2.2, -20}, mgl32.Vec3{0, 0, 1})
+	assert.True(t, ok)
+	assert.True(t, point.ApproxEqual(mgl32.Vec3{2.1, 2.2, -6.5}), "%v", point)
+}
+
+func TestBVHFrustrum(t *testing.T) {
+	root := gridScene(16)
+	bvh := NewBVH(root)
+	assert.Equal(t, 4096, bvh.Len())
+
+	camera := CreateCamera()
+	camera.Translation = mgl32.Vec3{-40, 0, 0}
+	windowSize := mgl32.Vec2{800, 600}
+	var expected []Spatial
+	for _, leaf := range bvh.leaves {
+		if camera.CameraContainsAABB(windowSize, leaf.bounds) {
+			expected = append(expected, leaf.spatial)
+		}
+	}
+	assert.True(t, len(expected) > 0 && len(expected) < bvh.Len())
+	assert.Equal(t, expected, bvh.Frustrum(camera, windowSize))
+	assert.True(t, bvh.Visited < bvh.Len(), "visited %v of %v", bvh.Visited, bvh.Len())
+
+	// a camera looking away from the scene only tests the top of the tree
+	camera.Lookat = mgl32.Vec3{-50, 0, 0}
+	assert.Empty(t, bvh.Frustrum(camera, windowSize))
+	assert.True(t, bvh.Visited < 20, "visited %v of %v", bvh.Visited, bvh.Len())
+}
+
+func TestBVHRayIntersect(t *testing.T) {
+	root := gridScene(16)
+	bvh := NewBVH(root)
+
+	start, direction := mgl32.Vec3{2.1, 2.2, -40}, mgl32.Vec3{0, 0, 1}
+	expected, ok := root.RayIntersect(start, direction)
+	assert.True(t, ok)
+	point, spatial, ok := bvh.RayIntersect(start, direction)
+	assert.True(t, ok)
+	assert.True(t, point.ApproxEqual(expected), "%v", point)
+	assert.IsType(t, &Geometry{}, spatial)
+	assert.True(t, bvh.Visited < bvh.Len()/10, "visited %v of %v", bvh.Visited, bvh.Len())
+
+	_, _, ok = bvh.RayIntersect(start, direction.Mul(-1))
+	assert.False(t, ok)
+}
+
+func TestSceneGraphBVH(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	root := gridScene(4)
+	sceneGraph := CreateSceneGraph()
+	sceneGraph.Add(root)
+
+	// without culling the scene graph draws the same as Node.Draw
+	root.SetFrustrumCullingRecursive(false)
+	r.SetRender(func() { root.Draw(r, mgl32.Ident4()) })
+	expected := r.RenderFrame().Draws()
+	r.SetRender(func() { sceneGraph.RenderScene(r, r.Camera().Translation) })
+	assert.Empty(t, DiffFrames(expected, r.RenderFrame().Draws()))
+
+	root.SetFrustrumCullingRecursive(true)
+	r.Camera().Translation = mgl32.Vec3{-20, 0, 0}
+	r.Camera().Lookat = mgl32.Vec3{-30, 0, 0}
+	assert.Empty(t, r.RenderFrame().Draws())
+
+	cube := CreateCube()
+	moving := nodeAt(mgl32.Vec3{}, cube)
+	root.Add(moving)
+	moving.SetTranslation(mgl32.Vec3{-30, 0, 0})
+	assert.Equal(t, []*Geometry{cube}, r.RenderFrame().Geometries())
+
+	point, spatial, ok := sceneGraph.RayIntersect(mgl32.Vec3{-30, 0.1, -20}, mgl32.Vec3{0, 0, 1})
+	assert.True(t, ok)
+	assert.Equal(t, cube, spatial)
+	assert.True(t, point.ApproxEqual(mgl32.Vec3{-30, 0.1, -0.5}), "%v", point)
+}
+
+func TestSceneGraphMovingChild(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	cube := CreateCube()
+	child := nodeAt(mgl32.Vec3{-30, 0, 0}, cube)
+	parent := nodeAt(mgl32.Vec3{0, 1, 0}, child)
+	parent.SetFrustrumCullingRecursive(true)
+	sceneGraph := CreateSceneGraph()
+	sceneGraph.Add(parent)
+	r.Camera().Translation = mgl32.Vec3{-20, 0, 0}
+	r.Camera().Lookat = mgl32.Vec3{-30, 0, 0}
+	r.SetRender(func() { sceneGraph.RenderScene(r, r.Camera().Translation) })
+
+	draws := r.RenderFrame().Draws()
+	assert.Len(t, draws, 1)
+	assert.Equal(t, mgl32.Translate3D(-30, 1, 0), draws[0].Transform)
+	bvh := sceneGraph.opaqueBVH
+
+	child.SetTranslation(mgl32.Vec3{-31, 0, 0})
+	draws = r.RenderFrame().Draws()
+	assert.Len(t, draws, 1)
+	assert.Equal(t, mgl32.Translate3D(-31, 1, 0), draws[0].Transform, "the drawn transform should follow the child")
+	assert.Equal(t, bvh, sceneGraph.opaqueBVH, "moving a child should refit the bvh, not rebuild it")
+
+	child.Transform = mgl32.Translate3D(-32, 0, 0)
+	draws = r.RenderFrame().Draws()
+	assert.Len(t, draws, 1)
+	assert.Equal(t, mgl32.Translate3D(-32, 1, 0), draws[0].Transform, "transforms written directly should be drawn")
+
+	child.SetTranslation(mgl32.Vec3{30, 0, 0})
+	assert.Empty(t, r.RenderFrame().Draws(), "the refit bounds should be culled")
+	child.SetTranslation(mgl32.Vec3{-30, 0, 0})
+	assert.Len(t, r.RenderFrame().Draws(), 1)
+	assert.Equal(t, bvh, sceneGraph.opaqueBVH)
+}
@@ -86,6 +86,43 @@ func (c *Camera) FrustrumContainsSphere(windowSize, start, end mgl32.Vec2, radiu
 		util.Vec3LenSq(delta) < (c.Far+r)*(c.Far+r)
 }
 
+// CameraContainsAABB - determines if a box intersects the frustrum given by the camera.
+func (c *Camera) CameraContainsAABB(windowSize mgl32.Vec2, box AABB) bool {
+	return c.FrustrumContainsAABB(windowSize, mgl32.Vec2{}, windowSize, box)
+}
+
+// FrustrumContainsAABB - determines if a box intersects the frustrum given by start/end vectors on the screen.
+func (c *Camera) FrustrumContainsAABB(windowSize, start, end mgl32.Vec2, box AABB) bool {
+	if box.IsEmpty() {
+		return false
+	}
+	tlv := c.GetMouseVector(windowSize, start)
+	trv := c.GetMouseVector(windowSize, mgl32.Vec2{end.X(), start.Y()})
+	blv := c.GetMouseVector(windowSize, mgl32.Vec2{start.X(), end.Y()})
+	brv := c.GetMouseVector(windowSize, end)
+
+	// the corner of the box furthest along each plane normal must be inside the plane
+	for _, normal := range []mgl32.Vec3{tlv.Cross(trv), trv.Cross(brv), brv.Cross(blv), blv.Cross(tlv)} {
+		corner := box.Min
+		for i := 0; i < 3; i++ {
+			if normal[i] > 0 {
+				corner[i] = box.Max[i]
+			}
+		}
+		if corner.Sub(c.Translation).Dot(normal) <= 0 {
+			return false
+		}
+	}
+
+	var nearestSq float32
+	for i := 0; i < 3; i++ {
+		if d := maxF32(box.Min[i]-c.Translation[i], c.Translation[i]-box.Max[i]); d > 0 {
+			nearestSq += d * d
+		}
+	}
+	return nearestSq < c.Far*c.Far
+}
+
 func (c *Camera) SetScale(scale mgl32.Vec3) {} //na
 
 func (c *Camera) SetTranslation(translation mgl32.Vec3) {
@@ -11,12 +11,15 @@ const VertexStride = 12
 
 //Geometry
 type Geometry struct {
-	VboId, IboId   uint32
-	Loaded         bool
-	VboDirty       bool
-	boundingRadius float32
-	Indicies       []uint32
-	Verticies      []float32
+	VboId, IboId uint32
+	Loaded       bool
+	VboDirty     bool
+	Indicies     []uint32
+	Verticies    []float32
+
+	bounds      AABB
+	boundsValid bool
+	parent      *Node
 }
 
 //vericies format : x,y,z,   nx,ny,nz,   u,v,  r,g,b,a
@@ -38,11 +41,10 @@ func (geometry *Geometry) Copy() *Geometry {
 
 func (geometry *Geometry) Draw(renderer Renderer, transform mgl32.Mat4) {
 	if len(geometry.Verticies) == 0 && len(geometry.Indicies) == 0 {
-		geometry.boundingRadius = 0
 		return
 	}
 	if !geometry.Loaded || geometry.VboDirty {
-		geometry.boundingRadius = geometry.MaximalPointFromGeometry().Len()
+		geometry.updateBounds()
 	}
 	renderer.DrawGeometry(geometry, transform)
 }
@@ -52,15 +54,49 @@ func (geometry *Geometry) Destroy(renderer Renderer) {
 	geometry.Loaded = false
 }
 
+// Center - the center of the bounding box of the geometry
 func (geometry *Geometry) Center() mgl32.Vec3 {
-	return mgl32.Vec3{0, 0, 0}
+	return geometry.Bounds().Center()
+}
+
+func (geometry *Geometry) SetParent(parent *Node) {
+	geometry.parent = parent
+}
+
+// Bounds - the bounding box of the verticies of the geometry
+func (geometry *Geometry) Bounds() AABB {
+	if !geometry.boundsValid {
+		geometry.bounds = geometry.computeBounds()
+		geometry.boundsValid = true
+	}
+	return geometry.bounds
+}
+
+func (geometry *Geometry) computeBounds() AABB {
+	bounds := EmptyAABB()
+	verts := geometry.Verticies
+	for i := 0; i+2 < len(verts); i += VertexStride {
+		bounds = bounds.Extend(mgl32.Vec3{verts[i], verts[i+1], verts[i+2]})
+	}
+	return bounds
 }
 
-func (geometry *Geometry) SetParent(parent *Node) {}
+// updateBounds - recalculate the bounds and mark the parent's bounds dirty if they have changed
+func (geometry *Geometry) updateBounds() {
+	bounds := geometry.computeBounds()
+	if geometry.boundsValid && bounds == geometry.bounds {
+		return
+	}
+	geometry.bounds, geometry.boundsValid = bounds, true
+	if geometry.parent != nil {
+		geometry.parent.MarkBoundsDirty()
+	}
+}
 
 func (geometry *Geometry) ClearBuffers() {
 	geometry.Indicies = geometry.Indicies[:0]
 	geometry.Verticies = geometry.Verticies[:0]
+	geometry.boundsValid = false
 }
 
 func (geometry *Geometry) SetColor(color color.Color) {
@@ -113,8 +149,9 @@ func (geometry *Geometry) Optimize(destination *Geometry, transform mgl32.Mat4)
 	geometry.updateGeometry()
 }
 
+// BoundingRadius - the radius of the sphere around Center containing the geometry
 func (geometry *Geometry) BoundingRadius() float32 {
-	return geometry.boundingRadius
+	return geometry.Bounds().Radius()
 }
 
 func (geometry *Geometry) OrthoOrder() int {
@@ -130,7 +167,7 @@ func (geometry *Geometry) SetUVs(uvs ...float32) {
 }
 
 func (geometry *Geometry) updateGeometry() {
-	geometry.boundingRadius = geometry.MaximalPointFromGeometry().Len()
+	geometry.updateBounds()
 	geometry.VboDirty = true
 }
 
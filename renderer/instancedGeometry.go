@@ -0,0 +1,151 @@
+package renderer
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// InstanceStride - the number of floats per instance in the instance buffer: a 4x4 transform followed by r,g,b,a
+const InstanceStride = 20
+
+// Instance - a copy of the geometry of an InstancedGeometry, with its own transform and color.
+// The color is multiplied with the vertex colors of the geometry.
+type Instance struct {
+	Transform mgl32.Mat4
+	Color     mgl32.Vec4
+}
+
+// InstancedGeometry - a Spatial that draws many copies of one geometry with a single draw call.
+// Each frame the instances outside the camera frustrum are culled, and the rest are packed into the instance buffer
+// passed to Renderer.DrawInstanced.
+type InstancedGeometry struct {
+	Geometry        *Geometry
+	FrustrumCulling bool
+
+	InstanceVboId  uint32
+	InstanceLoaded bool
+
+	instances      []Instance
+	visible        []Instance
+	buffer         []float32
+	bounds         AABB
+	geometryBounds AABB
+	boundsDirty    bool
+	parent         *Node
+}
+
+func NewInstancedGeometry(geometry *Geometry) *InstancedGeometry {
+	return &InstancedGeometry{
+		Geometry:        geometry,
+		FrustrumCulling: true,
+		boundsDirty:     true,
+	}
+}
+
+// AddInstance - add an instance and return its index
+func (instanced *InstancedGeometry) AddInstance(transform mgl32.Mat4, color mgl32.Vec4) int {
+	instanced.instances = append(instanced.instances, Instance{Transform: transform, Color: color})
+	instanced.markBoundsDirty()
+	return len(instanced.instances) - 1
+}
+
+func (instanced *InstancedGeometry) SetInstance(index int, transform mgl32.Mat4, color mgl32.Vec4) {
+	instanced.instances[index] = Instance{Transform: transform, Color: color}
+	instanced.markBoundsDirty()
+}
+
+// RemoveInstance - remove an instance, replacing it with the last instance
+func (instanced *InstancedGeometry) RemoveInstance(index int) {
+	last := len(instanced.instances) - 1
+	instanced.instances[index] = instanced.instances[last]
+	instanced.instances = instanced.instances[:last]
+	instanced.markBoundsDirty()
+}
+
+func (instanced *InstancedGeometry) ClearInstances() {
+	instanced.instances = instanced.instances[:0]
+	instanced.markBoundsDirty()
+}
+
+func (instanced *InstancedGeometry) Instances() []Instance {
+	return instanced.instances
+}
+
+// VisibleInstances - the instances that passed frustrum culling in the last draw
+func (instanced *InstancedGeometry) VisibleInstances() []Instance {
+	return instanced.visible
+}
+
+// InstanceBuffer - the visible instances packed for upload, InstanceStride floats per instance
+func (instanced *InstancedGeometry) InstanceBuffer() []float32 {
+	return instanced.buffer
+}
+
+func (instanced *InstancedGeometry) markBoundsDirty() {
+	instanced.boundsDirty = true
+	if instanced.parent != nil {
+		instanced.parent.MarkBoundsDirty()
+	}
+}
+
+func (instanced *InstancedGeometry) Draw(renderer Renderer, transform mgl32.Mat4) {
+	camera := renderer.Camera()
+	cull := instanced.FrustrumCulling && !camera.Ortho
+	windowSize := renderer.WindowDimensions()
+	geometryBounds := instanced.Geometry.Bounds()
+
+	instanced.visible = instanced.visible[:0]
+	instanced.buffer = instanced.buffer[:0]
+	for _, instance := range instanced.instances {
+		if cull && !camera.CameraContainsAABB(windowSize, geometryBounds.Transform(transform.Mul4(instance.Transform))) {
+			continue
+		}
+		instanced.visible = append(instanced.visible, instance)
+		instanced.buffer = append(instanced.buffer, instance.Transform[:]...)
+		instanced.buffer = append(instanced.buffer, instance.Color[:]...)
+	}
+	if len(instanced.visible) > 0 {
+		renderer.DrawInstanced(instanced, transform)
+	}
+}
+
+// Optimize - load every instance into the geometry (instance colors are not applied)
+func (instanced *InstancedGeometry) Optimize(geometry *Geometry, transform mgl32.Mat4) {
+	for _, instance := range instanced.instances {
+		instanced.Geometry.Optimize(geometry, transform.Mul4(instance.Transform))
+	}
+}
+
+func (instanced *InstancedGeometry) Destroy(renderer Renderer) {
+	renderer.DestroyInstanced(instanced)
+	instanced.Geometry.Destroy(renderer)
+}
+
+// Bounds - the bounds containing all of the instances
+func (instanced *InstancedGeometry) Bounds() AABB {
+	geometryBounds := instanced.Geometry.Bounds()
+	if instanced.boundsDirty || geometryBounds != instanced.geometryBounds {
+		instanced.geometryBounds = geometryBounds
+		instanced.bounds = EmptyAABB()
+		for _, instance := range instanced.instances {
+			instanced.bounds = instanced.bounds.Union(geometryBounds.Transform(instance.Transform))
+		}
+		instanced.boundsDirty = false
+	}
+	return instanced.bounds
+}
+
+func (instanced *InstancedGeometry) Center() mgl32.Vec3 {
+	return instanced.Bounds().Center()
+}
+
+func (instanced *InstancedGeometry) BoundingRadius() float32 {
+	return instanced.Bounds().Radius()
+}
+
+func (instanced *InstancedGeometry) OrthoOrder() int {
+	return 0
+}
+
+func (instanced *InstancedGeometry) SetParent(parent *Node) {
+	instanced.parent = parent
+}
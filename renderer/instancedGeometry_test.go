@@ -0,0 +1,53 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstancedGeometryCulling(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	instanced := NewInstancedGeometry(CreateCube())
+	instanced.AddInstance(mgl32.Translate3D(10, 0, 0), mgl32.Vec4{1, 0, 0, 1})
+	instanced.AddInstance(mgl32.Translate3D(-10, 0, 0), mgl32.Vec4{0, 1, 0, 1})
+	instanced.AddInstance(mgl32.Translate3D(20, 2, 0), mgl32.Vec4{0, 0, 1, 0.5})
+
+	node := NewNode()
+	node.Add(instanced)
+	r.SetRender(func() { node.Draw(r, mgl32.Ident4()) })
+
+	frame := r.RenderFrame()
+	assert.Len(t, frame.Draws(), 1)
+	assert.Equal(t, "DrawInstanced", frame.Draws()[0].Call)
+	assert.Equal(t, 2, frame.Draws()[0].Instances, "the instance behind the camera is culled")
+	assert.Equal(t, []Instance{instanced.Instances()[0], instanced.Instances()[2]}, instanced.VisibleInstances())
+
+	buffer := instanced.InstanceBuffer()
+	assert.Len(t, buffer, 2*InstanceStride)
+	assert.EqualValues(t, []float32{20, 2, 0, 1}, buffer[InstanceStride+12:InstanceStride+16])
+	assert.EqualValues(t, []float32{0, 0, 1, 0.5}, buffer[InstanceStride+16:2*InstanceStride])
+
+	r.Camera().Lookat = mgl32.Vec3{0, 0, 100}
+	assert.Empty(t, r.RenderFrame().Draws(), "nothing is drawn when every instance is culled")
+
+	instanced.FrustrumCulling = false
+	assert.Equal(t, 3, r.RenderFrame().Draws()[0].Instances)
+}
+
+func TestInstancedGeometryBounds(t *testing.T) {
+	instanced := NewInstancedGeometry(CreateCube())
+	node := nodeAt(mgl32.Vec3{0, 5, 0}, instanced)
+	assert.True(t, node.Bounds().IsEmpty())
+
+	instanced.AddInstance(mgl32.Translate3D(10, 0, 0), mgl32.Vec4{1, 1, 1, 1})
+	index := instanced.AddInstance(mgl32.Translate3D(-10, 0, 0), mgl32.Vec4{1, 1, 1, 1})
+	assert.Equal(t, AABB{Min: mgl32.Vec3{-10.5, 4.5, -0.5}, Max: mgl32.Vec3{10.5, 5.5, 0.5}}, node.Bounds())
+
+	instanced.SetInstance(index, mgl32.Translate3D(0, 0, 3), mgl32.Vec4{1, 1, 1, 1})
+	assert.Equal(t, AABB{Min: mgl32.Vec3{-0.5, 4.5, -0.5}, Max: mgl32.Vec3{10.5, 5.5, 3.5}}, node.Bounds())
+
+	instanced.RemoveInstance(0)
+	assert.Equal(t, AABB{Min: mgl32.Vec3{-0.5, 4.5, 2.5}, Max: mgl32.Vec3{0.5, 5.5, 3.5}}, node.Bounds())
+}
@@ -1,6 +1,10 @@
 package renderer
 
-import "github.com/go-gl/mathgl/mgl32"
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
 
 type LightType int
 
@@ -9,33 +13,83 @@ const (
 	POINT
 	DIRECTIONAL
 	AMBIENT
+	SPOT
 )
 
 type Light struct {
 	LightType
-	Color     [3]float32 //RGB
-	Position  mgl32.Vec3
-	Direction mgl32.Vec3
+	Color       [3]float32 //RGB
+	Position    mgl32.Vec3
+	Direction   mgl32.Vec3
+	CastShadows bool
+
+	// Attenuation - the constant, linear and quadratic falloff of point and spot lights, 1 / (c + l*d + q*d*d)
+	Attenuation [3]float32
+	// Range - the distance where point and spot lights fade out completely, 0 for no limit
+	Range float32
+	// InnerAngle, OuterAngle - spot light cone half angles in degrees, full brightness inside the inner cone fading out at the outer cone
+	InnerAngle, OuterAngle float32
 }
 
 func NewLight(lightType LightType) *Light {
 	return &Light{
-		LightType: lightType,
-		Color:     [3]float32{1, 1, 1},
-		Direction: mgl32.Vec3{1, 0, 0},
+		LightType:   lightType,
+		Color:       [3]float32{1, 1, 1},
+		Direction:   mgl32.Vec3{1, 0, 0},
+		Attenuation: [3]float32{0, 0, 1},
+		InnerAngle:  20,
+		OuterAngle:  30,
 	}
 }
 
 func (l *Light) SetScale(scale mgl32.Vec3) {} //na
 
 func (l *Light) SetTranslation(translation mgl32.Vec3) {
-	if l.LightType == POINT {
+	if l.LightType == POINT || l.LightType == SPOT {
 		l.Position = translation
 	}
 }
 
 func (l *Light) SetOrientation(orientation mgl32.Quat) {
-	if l.LightType == DIRECTIONAL {
+	if l.LightType == DIRECTIONAL || l.LightType == SPOT {
 		l.Direction = orientation.Rotate(mgl32.Vec3{1, 0, 0})
 	}
 }
+
+// Intensity - the brightest component of the light color
+func (l *Light) Intensity() float32 {
+	return maxF32(l.Color[0], maxF32(l.Color[1], l.Color[2]))
+}
+
+// Falloff - the fraction of a point or spot light reaching the distance, matching shaders/lib/attenuation.glsl
+func (l *Light) Falloff(distance float32) float32 {
+	a := l.Attenuation
+	falloff := 1 / maxF32(a[0]+a[1]*distance+a[2]*distance*distance, 0.0001)
+	if l.Range > 0 {
+		window := mgl32.Clamp(1-float32(math.Pow(float64(distance/l.Range), 4)), 0, 1)
+		falloff *= window * window
+	}
+	return falloff
+}
+
+// SpotFactor - the fraction of a spot light shining in the direction, 1 inside the inner cone fading to 0 at the outer cone
+func (l *Light) SpotFactor(direction mgl32.Vec3) float32 {
+	cosInner, cosOuter := l.ConeCosines()
+	return smoothstep(cosOuter, cosInner, direction.Normalize().Dot(l.Direction.Normalize()))
+}
+
+// ConeCosines - the cosines of the spot light's inner and outer cone angles
+func (l *Light) ConeCosines() (inner, outer float32) {
+	return float32(math.Cos(float64(mgl32.DegToRad(l.InnerAngle)))), float32(math.Cos(float64(mgl32.DegToRad(l.OuterAngle))))
+}
+
+func smoothstep(edge0, edge1, x float32) float32 {
+	if edge0 == edge1 {
+		if x < edge0 {
+			return 0
+		}
+		return 1
+	}
+	t := mgl32.Clamp((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
@@ -0,0 +1,144 @@
+package renderer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LightManager - holds any number of lights and chooses the ones that matter most to each draw call,
+// so a renderer only has to upload a few lights at a time.
+// Point and spot lights are scored by their brightness at the closest point of the drawn geometry's bounds,
+// and directional lights by their intensity. Ambient lights are added together.
+type LightManager struct {
+	MaxPointLights, MaxSpotLights, MaxDirectionalLights int
+
+	lights     []*Light
+	selection  LightSelection
+	candidates []lightCandidate
+}
+
+// LightSelection - the lights chosen for a draw call, brightest first
+type LightSelection struct {
+	Ambient                  mgl32.Vec3
+	Point, Spot, Directional []*Light
+}
+
+type lightCandidate struct {
+	light *Light
+	score float32
+}
+
+func NewLightManager(maxPointLights, maxSpotLights, maxDirectionalLights int) *LightManager {
+	return &LightManager{
+		MaxPointLights:       maxPointLights,
+		MaxSpotLights:        maxSpotLights,
+		MaxDirectionalLights: maxDirectionalLights,
+	}
+}
+
+func (lm *LightManager) AddLight(light *Light) {
+	lm.lights = append(lm.lights, light)
+}
+
+func (lm *LightManager) RemoveLight(light *Light) {
+	for i, l := range lm.lights {
+		if l == light {
+			lm.lights = append(lm.lights[:i], lm.lights[i+1:]...)
+			break
+		}
+	}
+}
+
+func (lm *LightManager) Lights() []*Light {
+	return lm.lights
+}
+
+// SelectLights - the lights affecting geometry with the given world space bounds.
+// The selection is reused, so it is only valid until the next call.
+func (lm *LightManager) SelectLights(bounds AABB) *LightSelection {
+	selection := &lm.selection
+	selection.Ambient = mgl32.Vec3{}
+	for _, light := range lm.lights {
+		if light.LightType == AMBIENT {
+			selection.Ambient = selection.Ambient.Add(mgl32.Vec3{light.Color[0], light.Color[1], light.Color[2]})
+		}
+	}
+	selection.Point = lm.choose(selection.Point[:0], POINT, bounds, lm.MaxPointLights)
+	selection.Spot = lm.choose(selection.Spot[:0], SPOT, bounds, lm.MaxSpotLights)
+	selection.Directional = lm.choose(selection.Directional[:0], DIRECTIONAL, bounds, lm.MaxDirectionalLights)
+	return selection
+}
+
+// choose - append the max highest scoring lights of the type to selected
+func (lm *LightManager) choose(selected []*Light, lightType LightType, bounds AABB, max int) []*Light {
+	lm.candidates = lm.candidates[:0]
+	for _, light := range lm.lights {
+		if light.LightType != lightType {
+			continue
+		}
+		if score := lightScore(light, bounds); score > 0 {
+			lm.candidates = append(lm.candidates, lightCandidate{light: light, score: score})
+		}
+	}
+	sort.SliceStable(lm.candidates, func(i, j int) bool {
+		return lm.candidates[i].score > lm.candidates[j].score
+	})
+	for i := 0; i < len(lm.candidates) && i < max; i++ {
+		selected = append(selected, lm.candidates[i].light)
+	}
+	return selected
+}
+
+// ShadowCasters - append the max shadow casting lights of the type that matter most near the bounds, such as the area around the camera.
+// Lights are scored as for SelectLights, lights that are equally bright at the bounds are ordered by their distance to its center.
+func (lm *LightManager) ShadowCasters(selected []*Light, lightType LightType, bounds AABB, max int) []*Light {
+	lm.candidates = lm.candidates[:0]
+	for _, light := range lm.lights {
+		if light.LightType != lightType || !light.CastShadows {
+			continue
+		}
+		if score := lightScore(light, bounds); score > 0 {
+			lm.candidates = append(lm.candidates, lightCandidate{light: light, score: score})
+		}
+	}
+	center := bounds.Center()
+	sort.SliceStable(lm.candidates, func(i, j int) bool {
+		a, b := lm.candidates[i], lm.candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.light.Position.Sub(center).Len() < b.light.Position.Sub(center).Len()
+	})
+	for i := 0; i < len(lm.candidates) && i < max; i++ {
+		selected = append(selected, lm.candidates[i].light)
+	}
+	return selected
+}
+
+// lightScore - how bright the light is at the closest point of the bounds, 0 if it can't reach them
+func lightScore(light *Light, bounds AABB) float32 {
+	if light.LightType == DIRECTIONAL {
+		return light.Intensity()
+	}
+	if bounds.IsEmpty() {
+		return 0
+	}
+	if light.LightType == SPOT && !spotReaches(light, bounds) {
+		return 0
+	}
+	distance := bounds.ClosestPoint(light.Position).Sub(light.Position).Len()
+	return light.Intensity() * light.Falloff(distance)
+}
+
+// spotReaches - true if the bounding sphere of the box is at least partly inside the spot light's outer cone
+func spotReaches(light *Light, bounds AABB) bool {
+	toCenter := bounds.Center().Sub(light.Position)
+	distance, radius := toCenter.Len(), bounds.Radius()
+	if distance <= radius {
+		return true
+	}
+	angle := math.Acos(float64(mgl32.Clamp(toCenter.Normalize().Dot(light.Direction.Normalize()), -1, 1)))
+	return angle-math.Asin(float64(radius/distance)) <= float64(mgl32.DegToRad(light.OuterAngle))
+}
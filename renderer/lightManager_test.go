@@ -0,0 +1,110 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLightFalloff(t *testing.T) {
+	light := NewLight(POINT)
+	assert.InDelta(t, 0.25, light.Falloff(2), 0.0001, "inverse square by default")
+
+	light.Range = 4
+	assert.InDelta(t, 0.25*(1-1.0/16)*(1-1.0/16), light.Falloff(2), 0.0001)
+	assert.EqualValues(t, 0, light.Falloff(4))
+	assert.EqualValues(t, 0, light.Falloff(10))
+
+	light.Attenuation = [3]float32{1, 0, 0}
+	light.Range = 0
+	assert.EqualValues(t, 1, light.Falloff(100))
+}
+
+func TestLightSpotFactor(t *testing.T) {
+	spot := NewLight(SPOT)
+	spot.SetOrientation(mgl32.QuatRotate(mgl32.DegToRad(90), mgl32.Vec3{0, 0, 1}))
+	assert.InDelta(t, 0, spot.Direction.Sub(mgl32.Vec3{0, 1, 0}).Len(), 1e-5, "%v", spot.Direction)
+
+	assert.EqualValues(t, 1, spot.SpotFactor(mgl32.Vec3{0, 1, 0}))
+	assert.EqualValues(t, 1, spot.SpotFactor(mgl32.Vec3{0.3, 1, 0}), "inside the inner cone")
+	assert.EqualValues(t, 0, spot.SpotFactor(mgl32.Vec3{1, 1, 0}), "outside the outer cone")
+	partial := spot.SpotFactor(mgl32.Vec3{0.5, 1, 0})
+	assert.True(t, partial > 0 && partial < 1, "%v", partial)
+}
+
+func TestLightManager(t *testing.T) {
+	lm := NewLightManager(4, 2, 1)
+	var points []*Light
+	for i := 0; i < 200; i++ {
+		light := NewLight(POINT)
+		light.Position = mgl32.Vec3{float32(i), 2, 0}
+		light.Range = 10
+		lm.AddLight(light)
+		points = append(points, light)
+	}
+	for _, c := range []float32{0.1, 0.2} {
+		ambient := NewLight(AMBIENT)
+		ambient.Color = [3]float32{c, c, c}
+		lm.AddLight(ambient)
+	}
+	dim, bright := NewLight(DIRECTIONAL), NewLight(DIRECTIONAL)
+	dim.Color = [3]float32{0.2, 0.2, 0.2}
+	lm.AddLight(dim)
+	lm.AddLight(bright)
+
+	box := AABB{Min: mgl32.Vec3{99.5, -0.5, -0.5}, Max: mgl32.Vec3{100.5, 0.5, 0.5}}
+	selection := lm.SelectLights(box)
+	assert.True(t, selection.Ambient.ApproxEqual(mgl32.Vec3{0.3, 0.3, 0.3}))
+	assert.Equal(t, []*Light{bright}, selection.Directional)
+	assert.Len(t, selection.Point, 4)
+	assert.Contains(t, selection.Point, points[100])
+	assert.Contains(t, selection.Point, points[99])
+	assert.Contains(t, selection.Point, points[101])
+	assert.Empty(t, selection.Spot)
+
+	// lights out of range are never chosen
+	lm = NewLightManager(4, 2, 1)
+	lm.AddLight(points[0])
+	assert.Empty(t, lm.SelectLights(box).Point)
+	lm.AddLight(points[105])
+	assert.Equal(t, []*Light{points[105]}, lm.SelectLights(box).Point)
+	lm.RemoveLight(points[105])
+	assert.Equal(t, []*Light{points[0]}, lm.Lights())
+}
+
+func TestLightManagerSpotLights(t *testing.T) {
+	lm := NewLightManager(4, 2, 1)
+	towards, away, near := NewLight(SPOT), NewLight(SPOT), NewLight(SPOT)
+	towards.Position, towards.Direction = mgl32.Vec3{-10, 0, 0}, mgl32.Vec3{1, 0, 0}
+	away.Position, away.Direction = mgl32.Vec3{-5, 0, 0}, mgl32.Vec3{-1, 0, 0}
+	near.Position, near.Direction = mgl32.Vec3{-3, 0, 0}, mgl32.Vec3{1, 0.2, 0}
+	lm.AddLight(towards)
+	lm.AddLight(away)
+	lm.AddLight(near)
+
+	selection := lm.SelectLights(SphereAABB(mgl32.Vec3{}, 1))
+	assert.Equal(t, []*Light{near, towards}, selection.Spot, "brightest first, ignoring lights pointing away")
+}
+
+func TestLightManagerShadowCasters(t *testing.T) {
+	lm := NewLightManager(4, 2, 1)
+	var points []*Light
+	for i := 0; i < 20; i++ {
+		light := NewLight(POINT)
+		light.Position = mgl32.Vec3{float32(i * 5), 0, 0}
+		light.Range = 10
+		light.CastShadows = i != 1
+		lm.AddLight(light)
+		points = append(points, light)
+	}
+	sun := NewLight(DIRECTIONAL)
+	lm.AddLight(sun)
+
+	area := SphereAABB(mgl32.Vec3{}, 8)
+	assert.Equal(t, []*Light{points[0], points[2], points[3]}, lm.ShadowCasters(nil, POINT, area, 4), "only shadow casting lights that reach the area")
+	assert.Equal(t, []*Light{points[0], points[2]}, lm.ShadowCasters(nil, POINT, area, 2), "closest first")
+	assert.Empty(t, lm.ShadowCasters(nil, DIRECTIONAL, area, 4))
+	sun.CastShadows = true
+	assert.Equal(t, []*Light{sun}, lm.ShadowCasters(nil, DIRECTIONAL, area, 4))
+}
@@ -0,0 +1,156 @@
+package renderer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type LODMode int
+
+const (
+	// LOD_DISTANCE - level thresholds are the maximum distance from the camera
+	LOD_DISTANCE LODMode = iota
+	// LOD_SCREEN_SIZE - level thresholds are the minimum height of the bounding sphere on the screen, in pixels
+	LOD_SCREEN_SIZE
+)
+
+type lodLevel struct {
+	spatial   Spatial
+	threshold float32
+}
+
+// LODNode - a Spatial that draws one of its levels of detail, chosen by camera distance or screen size.
+// Levels are added from the most to the least detailed; the last level is used past all of the thresholds.
+// Hysteresis is the fraction past a threshold needed to change level, to stop the level flickering at the boundary.
+//
+//	lod := renderer.NewLODNode(renderer.LOD_DISTANCE)
+//	lod.AddLevel(highDetail, 50)
+//	lod.AddLevel(lowDetail, 200)
+type LODNode struct {
+	Mode       LODMode
+	Hysteresis float32
+
+	levels  []lodLevel
+	current int
+	parent  *Node
+}
+
+func NewLODNode(mode LODMode) *LODNode {
+	return &LODNode{
+		Mode:       mode,
+		Hysteresis: 0.1,
+	}
+}
+
+// AddLevel - add a level of detail, used up to the threshold distance, or down to the threshold screen size
+func (lod *LODNode) AddLevel(spatial Spatial, threshold float32) {
+	spatial.SetParent(lod.parent)
+	lod.levels = append(lod.levels, lodLevel{spatial: spatial, threshold: threshold})
+	sort.SliceStable(lod.levels, func(i, j int) bool {
+		if lod.Mode == LOD_SCREEN_SIZE {
+			return lod.levels[i].threshold > lod.levels[j].threshold
+		}
+		return lod.levels[i].threshold < lod.levels[j].threshold
+	})
+	if lod.parent != nil {
+		lod.parent.MarkBoundsDirty()
+	}
+}
+
+// Level - the spatial for a level of detail
+func (lod *LODNode) Level(index int) Spatial {
+	return lod.levels[index].spatial
+}
+
+// CurrentLevel - the index of the level drawn last
+func (lod *LODNode) CurrentLevel() int {
+	return lod.current
+}
+
+func (lod *LODNode) Draw(renderer Renderer, transform mgl32.Mat4) {
+	if len(lod.levels) == 0 {
+		return
+	}
+	lod.current = lod.selectLevel(lod.metric(renderer, transform))
+	lod.levels[lod.current].spatial.Draw(renderer, transform)
+}
+
+// metric - a value that increases as less detail is needed: camera distance, or the inverse of the screen size
+func (lod *LODNode) metric(renderer Renderer, transform mgl32.Mat4) float32 {
+	bounds := lod.Bounds().Transform(transform)
+	camera := renderer.Camera()
+	distance := bounds.Center().Sub(camera.Translation).Len()
+	if lod.Mode == LOD_DISTANCE {
+		return distance
+	}
+
+	size := 2 * bounds.Radius()
+	if !camera.Ortho {
+		fovHeight := 2 * distance * float32(math.Tan(float64(mgl32.DegToRad(camera.Angle))/2))
+		size *= renderer.WindowDimensions().Y() / fovHeight
+	}
+	return 1 / size
+}
+
+// selectLevel - the first level with a threshold past the metric, moving the thresholds away from the current level by the hysteresis
+func (lod *LODNode) selectLevel(metric float32) int {
+	for i, level := range lod.levels {
+		threshold := level.threshold
+		if lod.Mode == LOD_SCREEN_SIZE {
+			threshold = 1 / threshold
+		}
+		if i >= lod.current {
+			threshold *= 1 + lod.Hysteresis
+		} else {
+			threshold *= 1 - lod.Hysteresis
+		}
+		if metric < threshold {
+			return i
+		}
+	}
+	return len(lod.levels) - 1
+}
+
+// Optimize - optimize the most detailed level
+func (lod *LODNode) Optimize(geometry *Geometry, transform mgl32.Mat4) {
+	if len(lod.levels) > 0 {
+		lod.levels[0].spatial.Optimize(geometry, transform)
+	}
+}
+
+func (lod *LODNode) Destroy(renderer Renderer) {
+	for _, level := range lod.levels {
+		level.spatial.Destroy(renderer)
+	}
+}
+
+// Bounds - the bounds containing all of the levels
+func (lod *LODNode) Bounds() AABB {
+	bounds := EmptyAABB()
+	for _, level := range lod.levels {
+		bounds = bounds.Union(spatialBounds(level.spatial))
+	}
+	return bounds
+}
+
+func (lod *LODNode) Center() mgl32.Vec3 {
+	return lod.Bounds().Center()
+}
+
+func (lod *LODNode) BoundingRadius() float32 {
+	return lod.Bounds().Radius()
+}
+
+func (lod *LODNode) OrthoOrder() int {
+	return 0
+}
+
+// SetParent - the levels share the parent of the lod node, so changes to them update the parent's bounds
+func (lod *LODNode) SetParent(parent *Node) {
+	lod.parent = parent
+	for _, level := range lod.levels {
+		level.spatial.SetParent(parent)
+	}
+}
@@ -0,0 +1,52 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLODNodeDistance(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	high, medium, low := CreateCube(), CreateCube(), CreateCube()
+	lod := NewLODNode(LOD_DISTANCE)
+	lod.AddLevel(low, 100)
+	lod.AddLevel(high, 10)
+	lod.AddLevel(medium, 50)
+
+	root := NewNode()
+	root.Add(lod)
+	r.SetRender(func() { root.Draw(r, mgl32.Ident4()) })
+	drawnAt := func(distance float32) *Geometry {
+		r.Camera().Translation = mgl32.Vec3{-distance, 0, 0}
+		return r.RenderFrame().Geometries()[0]
+	}
+
+	assert.Equal(t, high, drawnAt(5))
+	assert.Equal(t, high, drawnAt(10.5), "hysteresis keeps the current level")
+	assert.Equal(t, medium, drawnAt(12))
+	assert.Equal(t, medium, drawnAt(9.5))
+	assert.Equal(t, high, drawnAt(8.5))
+	assert.Equal(t, low, drawnAt(80))
+	assert.Equal(t, low, drawnAt(1000), "the last level is used past all the thresholds")
+	assert.Equal(t, 2, lod.CurrentLevel())
+}
+
+func TestLODNodeScreenSize(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	high, low := CreateCube(), CreateCube()
+	lod := NewLODNode(LOD_SCREEN_SIZE)
+	lod.Hysteresis = 0
+	lod.AddLevel(high, 100)
+	lod.AddLevel(low, 0)
+
+	node := nodeAt(mgl32.Vec3{10, 0, 0}, lod)
+	r.SetRender(func() { node.Draw(r, mgl32.Ident4()) })
+	// the cube's bounding sphere is sqrt(3) across; 100 pixels high at about 12.5 units away
+	assert.Equal(t, []*Geometry{high}, r.RenderFrame().Geometries())
+	node.SetScale(mgl32.Vec3{0.5, 0.5, 0.5})
+	assert.Equal(t, []*Geometry{low}, r.RenderFrame().Geometries())
+
+	assert.Equal(t, AABB{Min: mgl32.Vec3{9.75, -0.25, -0.25}, Max: mgl32.Vec3{10.25, 0.25, 0.25}}, node.Bounds())
+}
@@ -17,10 +17,12 @@ const (
 )
 
 type RendererParams struct {
-	CullBackface bool
-	DepthTest    bool
-	DepthMask    bool
-	Unlit        bool
+	CullBackface   bool
+	DepthTest      bool
+	DepthMask      bool
+	Unlit          bool
+	CastShadows    bool
+	ReceiveShadows bool
 	Transparency
 }
 
@@ -41,6 +43,11 @@ type Node struct {
 	parent   *Node
 	children []Spatial
 	deleted  []Spatial
+
+	localBounds AABB
+	boundsDirty bool
+	revision    uint64
+	structure   uint64
 }
 
 type byOrthoOrder []Spatial
@@ -76,11 +83,13 @@ func NewRendererParams() *RendererParams {
 
 func DefaultRendererParams() RendererParams {
 	return RendererParams{
-		DepthTest:    true,
-		DepthMask:    true,
-		CullBackface: true,
-		Unlit:        false,
-		Transparency: NON_EMISSIVE,
+		DepthTest:      true,
+		DepthMask:      true,
+		CullBackface:   true,
+		Unlit:          false,
+		CastShadows:    true,
+		ReceiveShadows: true,
+		Transparency:   NON_EMISSIVE,
 	}
 }
 
@@ -94,11 +103,7 @@ func (node *Node) Draw(renderer Renderer, transform mgl32.Mat4) {
 }
 
 func (node *Node) DrawChild(renderer Renderer, transform mgl32.Mat4, child Spatial) {
-	if node.FrustrumCulling && !renderer.Camera().CameraContainsSphere(
-		renderer.WindowDimensions(),
-		child.BoundingRadius()*util.MaxF32(node.Scale[0], node.Scale[1], node.Scale[2]),
-		mgl32.TransformCoordinate(child.Center(), transform),
-	) {
+	if node.FrustrumCulling && !renderer.Camera().CameraContainsAABB(renderer.WindowDimensions(), spatialBounds(child).Transform(transform)) {
 		return
 	}
 
@@ -157,6 +162,20 @@ func (node *Node) Center() mgl32.Vec3 {
 	return node.Translation
 }
 
+// WorldTransform - the node's transform combined with the transforms of its parents
+func (node *Node) WorldTransform() mgl32.Mat4 {
+	transform := node.Transform
+	for parent := node.parent; parent != nil; parent = parent.parent {
+		transform = parent.Transform.Mul4(transform)
+	}
+	return transform
+}
+
+// WorldPosition - the position of the node's origin in world space
+func (node *Node) WorldPosition() mgl32.Vec3 {
+	return node.WorldTransform().Col(3).Vec3()
+}
+
 func (node *Node) SetParent(parent *Node) {
 	// nodes can only be added to one parent at a time
 	if node.parent != nil {
@@ -165,9 +184,50 @@ func (node *Node) SetParent(parent *Node) {
 	node.parent = parent
 }
 
+// Bounds - the bounding box of the children of the node in the parent's space.
+// The bounds are cached until the node or one of its descendants changes.
+func (node *Node) Bounds() AABB {
+	if node.boundsDirty {
+		node.localBounds = EmptyAABB()
+		cacheable := true
+		for _, child := range node.children {
+			node.localBounds = node.localBounds.Union(spatialBounds(child))
+			if _, ok := child.(Bounded); !ok {
+				cacheable = false
+			}
+		}
+		// spatials without bounds can change without notifying the node
+		node.boundsDirty = !cacheable
+	}
+	return node.localBounds.Transform(node.Transform)
+}
+
+// MarkBoundsDirty - recalculate the bounds of the node and its parents when they are next needed.
+// This is done automatically, unless the Transform or the verticies of a child geometry are modified directly.
+func (node *Node) MarkBoundsDirty() {
+	for n := node; n != nil; n = n.parent {
+		n.boundsDirty = true
+		n.revision++
+	}
+}
+
+// markStructureDirty - spatials have been added to or removed from the node or one of its descendants
+func (node *Node) markStructureDirty() {
+	for n := node; n != nil; n = n.parent {
+		n.structure++
+	}
+	node.MarkBoundsDirty()
+}
+
+// Children - the spatials added to the node
+func (node *Node) Children() []Spatial {
+	return node.children
+}
+
 func (node *Node) Add(spatial Spatial) {
 	spatial.SetParent(node)
 	node.children = append(node.children, spatial)
+	node.markStructureDirty()
 }
 
 func (node *Node) Remove(spatial Spatial, destroy bool) {
@@ -180,6 +240,7 @@ func (node *Node) Remove(spatial Spatial, destroy bool) {
 			if destroy {
 				node.deleted = append(node.deleted, child)
 			}
+			node.markStructureDirty()
 			break
 		}
 	}
@@ -193,26 +254,31 @@ func (node *Node) RemoveAll(destroy bool) {
 		node.deleted = append(node.deleted, node.children...)
 	}
 	node.children = node.children[:0]
+	node.markStructureDirty()
 }
 
 func (node *Node) SetScale(scale mgl32.Vec3) {
 	node.Scale = scale
 	node.Transform = util.Mat4From(node.Scale, node.Translation, node.Orientation)
+	node.MarkBoundsDirty()
 }
 
 func (node *Node) SetTranslation(translation mgl32.Vec3) {
 	node.Translation = translation
 	node.Transform = util.Mat4From(node.Scale, node.Translation, node.Orientation)
+	node.MarkBoundsDirty()
 }
 
 func (node *Node) SetOrientation(orientation mgl32.Quat) {
 	node.Orientation = orientation
 	node.Transform = util.Mat4From(node.Scale, node.Translation, node.Orientation)
+	node.MarkBoundsDirty()
 }
 
 func (node *Node) SetRotation(angle float32, axis mgl32.Vec3) {
 	node.Orientation = mgl32.QuatRotate(angle, axis)
 	node.Transform = util.Mat4From(node.Scale, node.Translation, node.Orientation)
+	node.MarkBoundsDirty()
 }
 
 func (node *Node) OptimizeNode() *Geometry {
@@ -265,10 +331,15 @@ func (node *Node) OrthoOrder() int {
 }
 
 func (node *Node) SetFrustrumCullingRecursive(enable bool) {
+	node.setFrustrumCulling(enable)
+	node.MarkBoundsDirty()
+}
+
+func (node *Node) setFrustrumCulling(enable bool) {
 	node.FrustrumCulling = enable
 	for _, child := range node.children {
 		if childNode, ok := child.(*Node); ok {
-			childNode.SetFrustrumCullingRecursive(enable)
+			childNode.setFrustrumCulling(enable)
 		}
 	}
 }
@@ -280,6 +351,9 @@ func (node *Node) RayIntersect(start, direction mgl32.Vec3) (point mgl32.Vec3, o
 	var distSq float32
 	var closest *mgl32.Vec3
 	for _, child := range node.children {
+		if _, hit := spatialBounds(child).RayIntersect(s, d); !hit {
+			continue
+		}
 		var intersect mgl32.Vec3
 		var intersectOk bool
 		if childNode, okNode := child.(*Node); okNode {
@@ -0,0 +1,336 @@
+package renderer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// RenderCommand - a call made to a RecordingRenderer.
+// DrawGeometry commands also record the shader, material, cubemap and renderer params in use at the time of the draw.
+type RenderCommand struct {
+	Call      string
+	Geometry  *Geometry
+	Transform mgl32.Mat4
+	Shader    *Shader
+	Material  *Material
+	CubeMap   *CubeMap
+	Params    RendererParams
+	Light     *Light
+	Instanced *InstancedGeometry
+	Instances int
+	Skinned   *SkinnedGeometry
+}
+
+func (c RenderCommand) String() string {
+	switch c.Call {
+	case "DrawGeometry":
+		return fmt.Sprintf("DrawGeometry geometry=%p position=%v shader=%p material=%p cubeMap=%p params=%+v",
+			c.Geometry, c.Transform.Col(3).Vec3(), c.Shader, c.Material, c.CubeMap, c.Params)
+	case "DestroyGeometry":
+		return fmt.Sprintf("DestroyGeometry geometry=%p", c.Geometry)
+	case "DrawInstanced":
+		return fmt.Sprintf("DrawInstanced instanced=%p instances=%v position=%v shader=%p material=%p cubeMap=%p params=%+v",
+			c.Instanced, c.Instances, c.Transform.Col(3).Vec3(), c.Shader, c.Material, c.CubeMap, c.Params)
+	case "DestroyInstanced":
+		return fmt.Sprintf("DestroyInstanced instanced=%p", c.Instanced)
+	case "DrawSkinned":
+		return fmt.Sprintf("DrawSkinned skinned=%p bones=%v position=%v shader=%p material=%p cubeMap=%p params=%+v",
+			c.Skinned, len(c.Skinned.BoneMatrices), c.Transform.Col(3).Vec3(), c.Shader, c.Material, c.CubeMap, c.Params)
+	case "DestroySkinned":
+		return fmt.Sprintf("DestroySkinned skinned=%p", c.Skinned)
+	case "UseShader":
+		return fmt.Sprintf("UseShader shader=%p", c.Shader)
+	case "UseMaterial", "DestroyMaterial":
+		return fmt.Sprintf("%v material=%p", c.Call, c.Material)
+	case "UseCubeMap", "DestroyCubeMap":
+		return fmt.Sprintf("%v cubeMap=%p", c.Call, c.CubeMap)
+	case "CreatePostEffect", "DestroyPostEffects":
+		return fmt.Sprintf("%v shader=%p", c.Call, c.Shader)
+	case "UseRendererParams":
+		return fmt.Sprintf("UseRendererParams params=%+v", c.Params)
+	case "AddLight", "RemoveLight":
+		return fmt.Sprintf("%v light=%p", c.Call, c.Light)
+	}
+	return c.Call
+}
+
+// Frame - the commands recorded while rendering a frame
+type Frame []RenderCommand
+
+// Draws - the DrawGeometry, DrawInstanced and DrawSkinned commands in the frame
+func (f Frame) Draws() Frame {
+	var draws Frame
+	for _, command := range f {
+		if command.Call == "DrawGeometry" || command.Call == "DrawInstanced" || command.Call == "DrawSkinned" {
+			draws = append(draws, command)
+		}
+	}
+	return draws
+}
+
+// Geometries - the geometry drawn in the frame, in the order it was drawn
+func (f Frame) Geometries() []*Geometry {
+	var geometries []*Geometry
+	for _, command := range f.Draws() {
+		geometries = append(geometries, command.Geometry)
+	}
+	return geometries
+}
+
+func (f Frame) String() string {
+	lines := make([]string, len(f))
+	for i, command := range f {
+		lines[i] = command.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffFrames - describe the differences between two frames, one line per command that differs.
+// An empty result means the frames are the same.
+func DiffFrames(expected, actual Frame) []string {
+	var diff []string
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(actual):
+			diff = append(diff, fmt.Sprintf("%v: missing %v", i, expected[i]))
+		case i >= len(expected):
+			diff = append(diff, fmt.Sprintf("%v: extra %v", i, actual[i]))
+		case expected[i].String() != actual[i].String() || expected[i].Transform != actual[i].Transform:
+			diff = append(diff, fmt.Sprintf("%v: expected %v, got %v", i, expected[i], actual[i]))
+		}
+	}
+	return diff
+}
+
+// RecordingRenderer - a Renderer that records every call instead of drawing, for testing what is rendered.
+// The commands of the current frame are available from Frame.
+//
+//	r := renderer.NewRecordingRenderer(800, 600)
+//	r.SetRender(func() { node.Draw(r, mgl32.Ident4()) })
+//	frame := r.RenderFrame()
+type RecordingRenderer struct {
+	onInit, onUpdate, onRender func()
+	Width, Height              int
+	camera                     *Camera
+
+	initialized bool
+	stopped     bool
+	frame       Frame
+	frames      []Frame
+	lights      []*Light
+
+	shader   *Shader
+	material *Material
+	cubeMap  *CubeMap
+	params   RendererParams
+}
+
+// NewRecordingRenderer - create a recording renderer with the given window dimensions
+func NewRecordingRenderer(width, height int) *RecordingRenderer {
+	return &RecordingRenderer{
+		Width:  width,
+		Height: height,
+		camera: CreateCamera(),
+		params: DefaultRendererParams(),
+	}
+}
+
+func (r *RecordingRenderer) SetInit(callback func()) {
+	r.onInit = callback
+}
+
+func (r *RecordingRenderer) SetUpdate(callback func()) {
+	r.onUpdate = callback
+}
+
+func (r *RecordingRenderer) SetRender(callback func()) {
+	r.onRender = callback
+}
+
+func (r *RecordingRenderer) SetCamera(camera *Camera) {
+	r.camera = camera
+}
+
+func (r *RecordingRenderer) Camera() *Camera {
+	return r.camera
+}
+
+// Start - render frames until Stop is called
+func (r *RecordingRenderer) Start() {
+	r.stopped = false
+	for !r.stopped {
+		r.RenderFrame()
+	}
+}
+
+// Stop - stop the loop started by Start after the current frame
+func (r *RecordingRenderer) Stop() {
+	r.stopped = true
+}
+
+// RenderFrame - run the update and render callbacks once and return the commands recorded during the render.
+// The init callback is run before the first frame.
+func (r *RecordingRenderer) RenderFrame() Frame {
+	if !r.initialized {
+		r.initialized = true
+		if r.onInit != nil {
+			r.onInit()
+		}
+	}
+	if r.onUpdate != nil {
+		r.onUpdate()
+	}
+	r.frame = nil
+	if r.onRender != nil {
+		r.onRender()
+	}
+	r.frames = append(r.frames, r.frame)
+	return r.frame
+}
+
+// Frame - the commands recorded since the start of the current frame
+func (r *RecordingRenderer) Frame() Frame {
+	return r.frame
+}
+
+// Frames - every frame rendered by RenderFrame
+func (r *RecordingRenderer) Frames() []Frame {
+	return r.frames
+}
+
+// Reset - discard all recorded commands
+func (r *RecordingRenderer) Reset() {
+	r.frame, r.frames = nil, nil
+}
+
+// Lights - the lights that have been added and not removed
+func (r *RecordingRenderer) Lights() []*Light {
+	return r.lights
+}
+
+func (r *RecordingRenderer) record(command RenderCommand) {
+	r.frame = append(r.frame, command)
+}
+
+func (r *RecordingRenderer) BackGroundColor(red, g, b, a float32) {
+	r.record(RenderCommand{Call: "BackGroundColor"})
+}
+
+func (r *RecordingRenderer) WindowDimensions() mgl32.Vec2 {
+	return mgl32.Vec2{float32(r.Width), float32(r.Height)}
+}
+
+func (r *RecordingRenderer) LockCursor(lock bool) {}
+
+func (r *RecordingRenderer) UseRendererParams(params RendererParams) {
+	r.params = params
+	r.record(RenderCommand{Call: "UseRendererParams", Params: params})
+}
+
+func (r *RecordingRenderer) DrawGeometry(geometry *Geometry, transform mgl32.Mat4) {
+	geometry.Loaded = true
+	geometry.VboDirty = false
+	r.record(RenderCommand{
+		Call:      "DrawGeometry",
+		Geometry:  geometry,
+		Transform: transform,
+		Shader:    r.shader,
+		Material:  r.material,
+		CubeMap:   r.cubeMap,
+		Params:    r.params,
+	})
+}
+
+func (r *RecordingRenderer) DestroyGeometry(geometry *Geometry) {
+	r.record(RenderCommand{Call: "DestroyGeometry", Geometry: geometry})
+}
+
+func (r *RecordingRenderer) DrawInstanced(instanced *InstancedGeometry, transform mgl32.Mat4) {
+	instanced.Geometry.Loaded = true
+	instanced.Geometry.VboDirty = false
+	instanced.InstanceLoaded = true
+	r.record(RenderCommand{
+		Call:      "DrawInstanced",
+		Geometry:  instanced.Geometry,
+		Transform: transform,
+		Shader:    r.shader,
+		Material:  r.material,
+		CubeMap:   r.cubeMap,
+		Params:    r.params,
+		Instanced: instanced,
+		Instances: len(instanced.VisibleInstances()),
+	})
+}
+
+func (r *RecordingRenderer) DestroyInstanced(instanced *InstancedGeometry) {
+	r.record(RenderCommand{Call: "DestroyInstanced", Instanced: instanced})
+}
+
+func (r *RecordingRenderer) DrawSkinned(skinned *SkinnedGeometry, transform mgl32.Mat4) {
+	skinned.Geometry.Loaded = true
+	skinned.Geometry.VboDirty = false
+	skinned.SkinLoaded = true
+	skinned.SkinDirty = false
+	r.record(RenderCommand{
+		Call:      "DrawSkinned",
+		Geometry:  skinned.Geometry,
+		Transform: transform,
+		Shader:    r.shader,
+		Material:  r.material,
+		CubeMap:   r.cubeMap,
+		Params:    r.params,
+		Skinned:   skinned,
+	})
+}
+
+func (r *RecordingRenderer) DestroySkinned(skinned *SkinnedGeometry) {
+	r.record(RenderCommand{Call: "DestroySkinned", Skinned: skinned})
+}
+
+func (r *RecordingRenderer) UseMaterial(material *Material) {
+	r.material = material
+	r.record(RenderCommand{Call: "UseMaterial", Material: material})
+}
+
+func (r *RecordingRenderer) DestroyMaterial(material *Material) {
+	r.record(RenderCommand{Call: "DestroyMaterial", Material: material})
+}
+
+func (r *RecordingRenderer) UseCubeMap(cubeMap *CubeMap) {
+	r.cubeMap = cubeMap
+	r.record(RenderCommand{Call: "UseCubeMap", CubeMap: cubeMap})
+}
+
+func (r *RecordingRenderer) DestroyCubeMap(cubeMap *CubeMap) {
+	r.record(RenderCommand{Call: "DestroyCubeMap", CubeMap: cubeMap})
+}
+
+func (r *RecordingRenderer) UseShader(shader *Shader) {
+	r.shader = shader
+	r.record(RenderCommand{Call: "UseShader", Shader: shader})
+}
+
+func (r *RecordingRenderer) CreatePostEffect(shader *Shader) {
+	r.record(RenderCommand{Call: "CreatePostEffect", Shader: shader})
+}
+
+func (r *RecordingRenderer) DestroyPostEffects(shader *Shader) {
+	r.record(RenderCommand{Call: "DestroyPostEffects", Shader: shader})
+}
+
+func (r *RecordingRenderer) AddLight(light *Light) {
+	r.lights = append(r.lights, light)
+	r.record(RenderCommand{Call: "AddLight", Light: light})
+}
+
+func (r *RecordingRenderer) RemoveLight(light *Light) {
+	for i, l := range r.lights {
+		if l == light {
+			r.lights = append(r.lights[:i], r.lights[i+1:]...)
+			break
+		}
+	}
+	r.record(RenderCommand{Call: "RemoveLight", Light: light})
+}
@@ -0,0 +1,140 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Renderer = (*RecordingRenderer)(nil)
+
+func nodeAt(position mgl32.Vec3, spatial Spatial) *Node {
+	node := NewNode()
+	node.SetTranslation(position)
+	node.Add(spatial)
+	return node
+}
+
+func TestRecordingRenderer(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	var inits, updates int
+	r.SetInit(func() { inits++ })
+	r.SetUpdate(func() { updates++ })
+
+	geometry := CreateBox(1, 1)
+	material := NewMaterial()
+	r.SetRender(func() {
+		r.UseMaterial(material)
+		r.DrawGeometry(geometry, mgl32.Translate3D(1, 2, 3))
+	})
+
+	frame := r.RenderFrame()
+	r.RenderFrame()
+	assert.Equal(t, 1, inits)
+	assert.Equal(t, 2, updates)
+	assert.Len(t, r.Frames(), 2)
+	assert.Empty(t, DiffFrames(r.Frames()[0], r.Frames()[1]))
+
+	assert.Len(t, frame, 2)
+	draw := frame.Draws()[0]
+	assert.Equal(t, geometry, draw.Geometry)
+	assert.Equal(t, material, draw.Material)
+	assert.Equal(t, mgl32.Vec3{1, 2, 3}, draw.Transform.Col(3).Vec3())
+	assert.Equal(t, DefaultRendererParams(), draw.Params)
+	assert.True(t, geometry.Loaded)
+
+	light := NewLight(POINT)
+	r.AddLight(light)
+	assert.Equal(t, []*Light{light}, r.Lights())
+	r.RemoveLight(light)
+	assert.Empty(t, r.Lights())
+
+	r.Reset()
+	assert.Empty(t, r.Frames())
+}
+
+func TestDiffFrames(t *testing.T) {
+	geometry := CreateBox(1, 1)
+	a := Frame{{Call: "DrawGeometry", Geometry: geometry, Transform: mgl32.Ident4()}}
+	b := Frame{{Call: "DrawGeometry", Geometry: geometry, Transform: mgl32.Translate3D(1, 0, 0)}}
+
+	assert.Empty(t, DiffFrames(a, a))
+	assert.Len(t, DiffFrames(a, b), 1)
+	assert.Equal(t, []string{"1: extra UseShader shader=0x0"}, DiffFrames(a, append(a, RenderCommand{Call: "UseShader"})))
+	assert.Len(t, DiffFrames(append(a, b...), a), 1)
+}
+
+func TestSceneGraphTransparency(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	near, middle, far := CreateBox(1, 1), CreateBox(1, 1), CreateBox(1, 1)
+	opaque := CreateBox(1, 1)
+
+	sceneGraph := CreateSceneGraph()
+	sceneGraph.Add(opaque)
+	sceneGraph.AddTransparent(nodeAt(mgl32.Vec3{5, 0, 0}, middle))
+	sceneGraph.AddTransparent(nodeAt(mgl32.Vec3{1, 0, 0}, near))
+	sceneGraph.AddTransparent(nodeAt(mgl32.Vec3{10, 0, 0}, far))
+
+	r.SetRender(func() { sceneGraph.RenderScene(r, r.Camera().Translation) })
+	frame := r.RenderFrame()
+	assert.Equal(t, []*Geometry{opaque, far, middle, near}, frame.Geometries(), "opaque first, then transparent back to front")
+
+	r.Camera().Translation = mgl32.Vec3{20, 0, 0}
+	frame = r.RenderFrame()
+	assert.Equal(t, []*Geometry{opaque, near, middle, far}, frame.Geometries())
+}
+
+func TestNodeRenderStates(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	shader := NewShader()
+	material := NewMaterial()
+	params := DefaultRendererParams()
+	params.Unlit = true
+
+	root := NewNode()
+	root.Shader = shader
+	root.Material = material
+	child := NewNode()
+	child.RendererParams = &params
+	inherited, overridden := CreateBox(1, 1), CreateBox(1, 1)
+	child.Add(overridden)
+	root.Add(inherited)
+	root.Add(child)
+
+	r.SetRender(func() { root.Draw(r, mgl32.Ident4()) })
+	draws := r.RenderFrame().Draws()
+	assert.Len(t, draws, 2)
+	for _, draw := range draws {
+		assert.Equal(t, shader, draw.Shader)
+		assert.Equal(t, material, draw.Material)
+		assert.Equal(t, draw.Geometry == overridden, draw.Params.Unlit)
+	}
+}
+
+func TestNodeOrthoOrder(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	back, front := CreateBox(1, 1), CreateBox(1, 1)
+	frontNode, backNode := nodeAt(mgl32.Vec3{}, front), nodeAt(mgl32.Vec3{}, back)
+	frontNode.OrthoOrderValue = 2
+	backNode.OrthoOrderValue = 1
+
+	root := NewNode()
+	root.Add(frontNode)
+	root.Add(backNode)
+	r.SetRender(func() { root.Draw(r, mgl32.Ident4()) })
+	assert.Equal(t, []*Geometry{back, front}, r.RenderFrame().Geometries())
+}
+
+func TestNodeFrustrumCulling(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	visible, behind := CreateCube(), CreateCube()
+
+	root := NewNode()
+	root.Add(nodeAt(mgl32.Vec3{10, 0, 0}, visible))
+	root.Add(nodeAt(mgl32.Vec3{-10, 0, 0}, behind))
+	root.SetFrustrumCullingRecursive(true)
+
+	r.SetRender(func() { root.Draw(r, mgl32.Ident4()) })
+	assert.Equal(t, []*Geometry{visible}, r.RenderFrame().Geometries())
+}
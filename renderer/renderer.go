@@ -18,6 +18,12 @@ type Renderer interface {
 	DrawGeometry(geometry *Geometry, transform mgl32.Mat4)
 	DestroyGeometry(geometry *Geometry)
 
+	DrawInstanced(instanced *InstancedGeometry, transform mgl32.Mat4)
+	DestroyInstanced(instanced *InstancedGeometry)
+
+	DrawSkinned(skinned *SkinnedGeometry, transform mgl32.Mat4)
+	DestroySkinned(skinned *SkinnedGeometry)
+
 	UseMaterial(material *Material)
 	DestroyMaterial(material *Material)
 
@@ -44,6 +50,11 @@ type Spatial interface {
 	SetParent(parent *Node)
 }
 
+//A Bounded spatial has an axis aligned bounding box in its parent's space, used for culling and ray queries
+type Bounded interface {
+	Bounds() AABB
+}
+
 //An Entity is something that can be scaled, positioned and rotated (orientation)
 type Entity interface {
 	SetScale(scale mgl32.Vec3)
@@ -14,6 +14,8 @@ type SceneGraph struct {
 	txStack         *matstack.TransformStack
 	bucketCount     int
 	bucket          bucketEntries
+
+	opaqueBVH, transparentBVH *BVH
 }
 
 //factory
@@ -48,7 +50,8 @@ func (sceneGraph *SceneGraph) RenderScene(renderer Renderer, cameraLocation mgl3
 	sceneGraph.buildBuckets(sceneGraph.transparentNode, cameraLocation)
 	sort.Sort(sceneGraph.bucket)
 	//render buckets
-	sceneGraph.opaqueNode.Draw(renderer, mgl32.Ident4())
+	sceneGraph.opaqueBVH = updateBVH(sceneGraph.opaqueBVH, sceneGraph.opaqueNode)
+	sceneGraph.opaqueBVH.Draw(renderer)
 	for i := 0; i < sceneGraph.bucketCount; i++ {
 		entry := sceneGraph.bucket[i]
 		entry.parent.DrawChild(renderer, entry.transform, entry.spatial)
@@ -100,3 +103,27 @@ func (sceneGraph *SceneGraph) buildBuckets(node *Node, cameraLocation mgl32.Vec3
 	}
 	sceneGraph.txStack.Pop()
 }
+
+// RayIntersect - the closest point where the ray intersects the scene
+func (sceneGraph *SceneGraph) RayIntersect(start, direction mgl32.Vec3) (point mgl32.Vec3, spatial Spatial, ok bool) {
+	sceneGraph.opaqueBVH = updateBVH(sceneGraph.opaqueBVH, sceneGraph.opaqueNode)
+	sceneGraph.transparentBVH = updateBVH(sceneGraph.transparentBVH, sceneGraph.transparentNode)
+	point, spatial, ok = sceneGraph.opaqueBVH.RayIntersect(start, direction)
+	if tPoint, tSpatial, tOk := sceneGraph.transparentBVH.RayIntersect(start, direction); tOk {
+		if !ok || util.Vec3LenSq(tPoint.Sub(start)) < util.Vec3LenSq(point.Sub(start)) {
+			point, spatial, ok = tPoint, tSpatial, true
+		}
+	}
+	return
+}
+
+// updateBVH - rebuild the bvh if spatials have been added or removed since it was built, or refit it if they have moved
+func updateBVH(bvh *BVH, node *Node) *BVH {
+	if bvh == nil || bvh.Stale() {
+		return NewBVH(node)
+	}
+	if bvh.revision != node.revision {
+		bvh.Refit()
+	}
+	return bvh
+}
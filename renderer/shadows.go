@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// ShadowCascadeSplits - the far distance of each of count directional shadow cascades covering the view from near to far.
+// The splits blend logarithmic and uniform spacing, so the nearer cascades cover less of the view at a higher resolution.
+func ShadowCascadeSplits(near, far float32, count int) []float32 {
+	const lambda = 0.75
+	splits := make([]float32, count)
+	for i := range splits {
+		f := float64(i+1) / float64(count)
+		logarithmic := float64(near) * math.Pow(float64(far/near), f)
+		uniform := float64(near) + float64(far-near)*f
+		splits[i] = float32(lambda*logarithmic + (1-lambda)*uniform)
+	}
+	return splits
+}
+
+// ShadowCascade - the light view projection of a directional shadow map covering the camera frustrum between the near and far distances.
+// The projection is fitted to the bounding sphere of the frustrum slice, extended by casterDistance towards the light to include
+// shadow casters outside of the view, and snapped to shadow map texels so shadow edges don't shimmer as the camera moves.
+func (c *Camera) ShadowCascade(windowSize mgl32.Vec2, direction mgl32.Vec3, near, far, casterDistance float32, mapSize int) mgl32.Mat4 {
+	forward := c.GetDirection()
+	right := forward.Cross(c.Up).Normalize()
+	up := right.Cross(forward)
+	tanY := float32(math.Tan(float64(mgl32.DegToRad(c.Angle)) / 2))
+	tanX := tanY * windowSize.X() / windowSize.Y()
+
+	var corners []mgl32.Vec3
+	var center mgl32.Vec3
+	for _, distance := range []float32{near, far} {
+		for _, x := range []float32{-1, 1} {
+			for _, y := range []float32{-1, 1} {
+				corner := c.Translation.Add(forward.Mul(distance)).Add(right.Mul(x * tanX * distance)).Add(up.Mul(y * tanY * distance))
+				corners = append(corners, corner)
+				center = center.Add(corner)
+			}
+		}
+	}
+	center = center.Mul(1.0 / float32(len(corners)))
+	var radius float32
+	for _, corner := range corners {
+		radius = maxF32(radius, corner.Sub(center).Len())
+	}
+	// round the radius up so float error doesn't change the texel size
+	radius = float32(math.Ceil(float64(radius)*16)) / 16
+
+	lightDirection := direction.Normalize()
+	lightUp := mgl32.Vec3{0, 1, 0}
+	if math.Abs(float64(lightDirection.Y())) > 0.99 {
+		lightUp = mgl32.Vec3{1, 0, 0}
+	}
+	view := mgl32.LookAtV(mgl32.Vec3{}, lightDirection, lightUp)
+
+	texel := 2 * radius / float32(mapSize)
+	lightCenter := view.Mul4x1(center.Vec4(1))
+	x := float32(math.Floor(float64(lightCenter.X()/texel))) * texel
+	y := float32(math.Floor(float64(lightCenter.Y()/texel))) * texel
+	depth := -lightCenter.Z()
+	projection := mgl32.Ortho(x-radius, x+radius, y-radius, y+radius, depth-radius-casterDistance, depth+radius)
+	return projection.Mul4(view)
+}
+
+// PointShadowMatrices - the view projections of the 6 faces of a point light's cube shadow map, in the order +x, -x, +y, -y, +z, -z
+func PointShadowMatrices(position mgl32.Vec3, near, far float32) [6]mgl32.Mat4 {
+	projection := mgl32.Perspective(math.Pi/2, 1, near, far)
+	faces := [6][2]mgl32.Vec3{
+		{{1, 0, 0}, {0, -1, 0}},
+		{{-1, 0, 0}, {0, -1, 0}},
+		{{0, 1, 0}, {0, 0, 1}},
+		{{0, -1, 0}, {0, 0, -1}},
+		{{0, 0, 1}, {0, -1, 0}},
+		{{0, 0, -1}, {0, -1, 0}},
+	}
+	var matrices [6]mgl32.Mat4
+	for i, face := range faces {
+		matrices[i] = projection.Mul4(mgl32.LookAtV(position, position.Add(face[0]), face[1]))
+	}
+	return matrices
+}
@@ -0,0 +1,67 @@
+package renderer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func project(m mgl32.Mat4, point mgl32.Vec3) mgl32.Vec3 {
+	v := m.Mul4x1(point.Vec4(1))
+	return v.Vec3().Mul(1 / v.W())
+}
+
+func inClipSpace(v mgl32.Vec3) bool {
+	return v.X() >= -1 && v.X() <= 1 && v.Y() >= -1 && v.Y() <= 1 && v.Z() >= -1 && v.Z() <= 1
+}
+
+func TestShadowCascadeSplits(t *testing.T) {
+	splits := ShadowCascadeSplits(0.1, 100, 3)
+	assert.Len(t, splits, 3)
+	assert.InDelta(t, 100, splits[2], 0.001)
+	assert.True(t, splits[0] > 0.1 && splits[0] < splits[1] && splits[1] < splits[2], "%v", splits)
+	assert.True(t, splits[0] < 100.0/3, "the first cascade is smaller than an even split: %v", splits)
+}
+
+func TestShadowCascade(t *testing.T) {
+	camera := CreateCamera()
+	windowSize := mgl32.Vec2{800, 600}
+	direction := mgl32.Vec3{0.3, -1, 0.2}
+	cascade := camera.ShadowCascade(windowSize, direction, 1, 10, 20, 1024)
+
+	// the corners of the view between the near and far distances
+	for _, distance := range []float32{1, 10} {
+		for _, corner := range []mgl32.Vec2{{0, 0}, {800, 0}, {0, 600}, {800, 600}} {
+			point := camera.GetMouseVector(windowSize, corner).Mul(distance / camera.GetMouseVector(windowSize, corner).Dot(camera.GetDirection()))
+			assert.True(t, inClipSpace(project(cascade, point)), "%v %v", distance, corner)
+		}
+	}
+	center := mgl32.Vec3{5, 0, 0}
+	assert.True(t, inClipSpace(project(cascade, center.Sub(direction.Normalize().Mul(15)))), "casters towards the light are included")
+	assert.False(t, inClipSpace(project(cascade, center.Add(direction.Normalize().Mul(15)))), "points beyond the view are excluded")
+
+	// moving the camera moves the shadow map by whole texels
+	texelOffset := func(cascade mgl32.Mat4) mgl32.Vec2 {
+		p := project(cascade, mgl32.Vec3{})
+		x, y := float64(p.X()*512), float64(p.Y()*512)
+		return mgl32.Vec2{float32(x - math.Floor(x)), float32(y - math.Floor(y))}
+	}
+	camera.Translation = mgl32.Vec3{0.013, 0.027, -0.004}
+	camera.Lookat = camera.Translation.Add(mgl32.Vec3{1, 0, 0})
+	moved := camera.ShadowCascade(windowSize, direction, 1, 10, 20, 1024)
+	assert.False(t, moved.ApproxEqual(cascade))
+	assert.True(t, texelOffset(moved).ApproxEqualThreshold(texelOffset(cascade), 1e-2), "%v %v", texelOffset(moved), texelOffset(cascade))
+}
+
+func TestPointShadowMatrices(t *testing.T) {
+	position := mgl32.Vec3{1, 2, 3}
+	matrices := PointShadowMatrices(position, 0.1, 50)
+	directions := []mgl32.Vec3{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+	for i, direction := range directions {
+		p := project(matrices[i], position.Add(direction.Mul(5)))
+		assert.True(t, p.Vec2().ApproxEqual(mgl32.Vec2{}), "face %v: %v", i, p)
+		assert.True(t, inClipSpace(p))
+	}
+}
@@ -0,0 +1,174 @@
+package renderer
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SkinStride - the number of floats per vertex in the skin buffer: 4 bone indices followed by 4 bone weights
+const SkinStride = 8
+
+// MaxSkinBones - the most bones the opengl renderer skins on the gpu, geometry with more bones is skinned on the cpu
+const MaxSkinBones = 64
+
+// SkinnedGeometry - a Spatial that deforms its geometry with the bones of a skeleton.
+// Each vertex is influenced by up to 4 bones, read from the skin buffer.
+// BoneMatrices move the bind pose verticies to the posed position of each bone (the bone's world transform multiplied by its inverse bind matrix),
+// they are usually updated every frame by an animation.Animator.
+type SkinnedGeometry struct {
+	Geometry     *Geometry
+	Skin         []float32
+	BoneMatrices []mgl32.Mat4
+
+	SkinVboId  uint32
+	SkinLoaded bool
+	SkinDirty  bool
+
+	skinned        *Geometry
+	bounds         AABB
+	geometryBounds AABB
+	boundsDirty    bool
+	parent         *Node
+}
+
+// skin format : b1,b2,b3,b4,   w1,w2,w3,w4 (one per vertex of the geometry)
+// The weights of each vertex are normalized in place to sum to 1.
+func NewSkinnedGeometry(geometry *Geometry, skin []float32) *SkinnedGeometry {
+	return &SkinnedGeometry{
+		Geometry:    geometry,
+		Skin:        normalizeSkin(skin),
+		boundsDirty: true,
+	}
+}
+
+// SetBoneMatrices - copy the skinning matrices of the current pose
+func (skinned *SkinnedGeometry) SetBoneMatrices(matrices []mgl32.Mat4) {
+	skinned.BoneMatrices = append(skinned.BoneMatrices[:0], matrices...)
+	skinned.boundsDirty = true
+	if skinned.parent != nil {
+		skinned.parent.MarkBoundsDirty()
+	}
+}
+
+// SetSkin - replace the skin buffer, the weights of each vertex are normalized in place to sum to 1
+func (skinned *SkinnedGeometry) SetSkin(skin []float32) {
+	skinned.Skin = normalizeSkin(skin)
+	skinned.SkinDirty = true
+	skinned.boundsDirty = true
+}
+
+// normalizeSkin - scale the weights of each vertex to sum to 1, verticies without weights are left unskinned
+func normalizeSkin(skin []float32) []float32 {
+	for i := 0; i+SkinStride <= len(skin); i += SkinStride {
+		weights := skin[i+4 : i+SkinStride]
+		total := weights[0] + weights[1] + weights[2] + weights[3]
+		if total == 0 {
+			continue
+		}
+		for j := range weights {
+			weights[j] /= total
+		}
+	}
+	return skin
+}
+
+// skinMatrix - the weighted sum of the bone matrices influencing the vertex.
+// This matches skinTransform in shaders/lib/skinning.glsl: bone indices are clamped to the bone matrices,
+// and verticies without weights are not moved.
+func (skinned *SkinnedGeometry) skinMatrix(vertex int) mgl32.Mat4 {
+	if (vertex+1)*SkinStride > len(skinned.Skin) || len(skinned.BoneMatrices) == 0 {
+		return mgl32.Ident4()
+	}
+	skin := skinned.Skin[vertex*SkinStride : (vertex+1)*SkinStride]
+	if skin[4] == 0 && skin[5] == 0 && skin[6] == 0 && skin[7] == 0 {
+		return mgl32.Ident4()
+	}
+	var matrix mgl32.Mat4
+	for i := 0; i < 4; i++ {
+		bone := int(skin[i])
+		if bone < 0 {
+			bone = 0
+		} else if bone >= len(skinned.BoneMatrices) {
+			bone = len(skinned.BoneMatrices) - 1
+		}
+		matrix = matrix.Add(skinned.BoneMatrices[bone].Mul(skin[i+4]))
+	}
+	return matrix
+}
+
+// CPUSkin - the geometry deformed by the current bone matrices, the returned geometry is reused by later calls
+func (skinned *SkinnedGeometry) CPUSkin() *Geometry {
+	geometry := skinned.Geometry
+	if skinned.skinned == nil {
+		skinned.skinned = CreateGeometry(nil, nil)
+	}
+	result := skinned.skinned
+	result.Indicies = append(result.Indicies[:0], geometry.Indicies...)
+	result.Verticies = append(result.Verticies[:0], geometry.Verticies...)
+	verts := result.Verticies
+	for i := 0; i+VertexStride <= len(verts); i += VertexStride {
+		matrix := skinned.skinMatrix(i / VertexStride)
+		v := mgl32.TransformCoordinate(mgl32.Vec3{verts[i], verts[i+1], verts[i+2]}, matrix)
+		n := mgl32.TransformNormal(mgl32.Vec3{verts[i+3], verts[i+4], verts[i+5]}, matrix)
+		if n.Len() > 0 {
+			n = n.Normalize()
+		}
+		verts[i], verts[i+1], verts[i+2] = v[0], v[1], v[2]
+		verts[i+3], verts[i+4], verts[i+5] = n[0], n[1], n[2]
+	}
+	result.updateGeometry()
+	return result
+}
+
+func (skinned *SkinnedGeometry) Draw(renderer Renderer, transform mgl32.Mat4) {
+	if len(skinned.Geometry.Verticies) == 0 || len(skinned.Geometry.Indicies) == 0 {
+		return
+	}
+	renderer.DrawSkinned(skinned, transform)
+}
+
+// Optimize - load the geometry in its current pose
+func (skinned *SkinnedGeometry) Optimize(geometry *Geometry, transform mgl32.Mat4) {
+	skinned.CPUSkin().Optimize(geometry, transform)
+}
+
+func (skinned *SkinnedGeometry) Destroy(renderer Renderer) {
+	renderer.DestroySkinned(skinned)
+	skinned.Geometry.Destroy(renderer)
+	if skinned.skinned != nil {
+		skinned.skinned.Destroy(renderer)
+	}
+}
+
+// Bounds - the bounds of the geometry moved by each of the bone matrices, which contain every skinned vertex
+func (skinned *SkinnedGeometry) Bounds() AABB {
+	geometryBounds := skinned.Geometry.Bounds()
+	if skinned.boundsDirty || geometryBounds != skinned.geometryBounds {
+		skinned.geometryBounds = geometryBounds
+		if len(skinned.BoneMatrices) == 0 {
+			skinned.bounds = geometryBounds
+		} else {
+			skinned.bounds = EmptyAABB()
+			for _, matrix := range skinned.BoneMatrices {
+				skinned.bounds = skinned.bounds.Union(geometryBounds.Transform(matrix))
+			}
+		}
+		skinned.boundsDirty = false
+	}
+	return skinned.bounds
+}
+
+func (skinned *SkinnedGeometry) Center() mgl32.Vec3 {
+	return skinned.Bounds().Center()
+}
+
+func (skinned *SkinnedGeometry) BoundingRadius() float32 {
+	return skinned.Bounds().Radius()
+}
+
+func (skinned *SkinnedGeometry) OrthoOrder() int {
+	return 0
+}
+
+func (skinned *SkinnedGeometry) SetParent(parent *Node) {
+	skinned.parent = parent
+}
@@ -0,0 +1,65 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkinnedGeometryCPUSkin(t *testing.T) {
+	geometry := CreateBoxWithOffset(1, 1, 0, 0)
+	skinned := NewSkinnedGeometry(geometry, []float32{
+		0, 0, 0, 0, 1, 0, 0, 0,
+		1, 0, 0, 0, 1, 0, 0, 0,
+		0, 1, 0, 0, 0.5, 0.5, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	})
+	skinned.SetBoneMatrices([]mgl32.Mat4{mgl32.Ident4(), mgl32.Translate3D(0, 0, 2)})
+
+	result := skinned.CPUSkin()
+	vertex := func(i int) mgl32.Vec3 {
+		return mgl32.Vec3{result.Verticies[i*VertexStride], result.Verticies[i*VertexStride+1], result.Verticies[i*VertexStride+2]}
+	}
+	assert.Equal(t, mgl32.Vec3{0, 1, 0}, vertex(0))
+	assert.Equal(t, mgl32.Vec3{1, 1, 2}, vertex(1))
+	assert.Equal(t, mgl32.Vec3{1, 0, 1}, vertex(2), "weights blend the bone matrices")
+	assert.Equal(t, mgl32.Vec3{0, 0, 0}, vertex(3), "verticies without weights aren't moved")
+	assert.Equal(t, geometry.Indicies, result.Indicies)
+	assert.Equal(t, mgl32.Vec3{0, 1, 0}, mgl32.Vec3{geometry.Verticies[0], geometry.Verticies[1], geometry.Verticies[2]}, "the bind pose is unchanged")
+
+	bounds := skinned.Bounds()
+	assert.Equal(t, mgl32.Vec3{0, 0, 0}, bounds.Min)
+	assert.Equal(t, mgl32.Vec3{1, 1, 2}, bounds.Max)
+}
+
+func TestSkinnedGeometryNormalizeSkin(t *testing.T) {
+	skinned := NewSkinnedGeometry(CreateBoxWithOffset(1, 1, 0, 0), nil)
+	skinned.SetSkin([]float32{
+		0, 1, 0, 0, 2, 2, 0, 0,
+		5, 0, 0, 0, 1, 0, 0, 0,
+		-1, 0, 0, 0, 1, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	})
+	assert.Equal(t, []float32{0.5, 0.5, 0, 0}, skinned.Skin[4:8], "weights should be normalized")
+	assert.Equal(t, []float32{0, 0, 0, 0}, skinned.Skin[28:32])
+
+	skinned.SetBoneMatrices([]mgl32.Mat4{mgl32.Ident4(), mgl32.Translate3D(0, 0, 2)})
+	assert.Equal(t, mgl32.Translate3D(0, 0, 1), skinned.skinMatrix(0))
+	assert.Equal(t, mgl32.Translate3D(0, 0, 2), skinned.skinMatrix(1), "bone indices should be clamped to the last bone")
+	assert.Equal(t, mgl32.Ident4(), skinned.skinMatrix(2), "bone indices should be clamped to the first bone")
+	assert.Equal(t, mgl32.Ident4(), skinned.skinMatrix(3))
+}
+
+func TestSkinnedGeometryDraw(t *testing.T) {
+	r := NewRecordingRenderer(800, 600)
+	skinned := NewSkinnedGeometry(CreateBox(1, 1), make([]float32, 4*SkinStride))
+	node := NewNode()
+	node.Add(skinned)
+	node.Draw(r, mgl32.Ident4())
+	draws := r.Frame().Draws()
+	if assert.Len(t, draws, 1) {
+		assert.Equal(t, "DrawSkinned", draws[0].Call)
+		assert.Equal(t, skinned, draws[0].Skinned)
+	}
+}
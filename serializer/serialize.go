@@ -106,6 +106,14 @@ func Vector3bytes64(w io.Writer, vector mgl64.Vec3) {
 	GlobalSerializer.Vector3bytes64(w, vector)
 }
 
+func Quatfrombytes(r io.Reader) mgl32.Quat {
+	return GlobalSerializer.Quatfrombytes(r)
+}
+
+func Quatbytes(w io.Writer, quat mgl32.Quat) {
+	GlobalSerializer.Quatbytes(w, quat)
+}
+
 func BoolFromBytes(r io.Reader) bool {
 	return GlobalSerializer.BoolFromBytes(r)
 }
@@ -175,6 +175,20 @@ func (s Serializer) Vector3bytes64(w io.Writer, vector mgl64.Vec3) {
 	}
 }
 
+func (s Serializer) Quatfrombytes(r io.Reader) mgl32.Quat {
+	result, err := util.Quatfrombytes(r)
+	if err != nil {
+		s.OnError(err)
+	}
+	return result
+}
+
+func (s Serializer) Quatbytes(w io.Writer, quat mgl32.Quat) {
+	if err := util.Quatbytes(w, quat); err != nil {
+		s.OnError(err)
+	}
+}
+
 func (s Serializer) BoolFromBytes(r io.Reader) bool {
 	result, err := util.BoolFromBytes(r)
 	if err != nil {
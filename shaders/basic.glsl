@@ -5,6 +5,7 @@
 #include "./lib/textures.glsl"
 #include "./lib/ambientLight.glsl"
 #include "./lib/pointLights.glsl"
+#include "./lib/spotLights.glsl"
 #include "./lib/directionalLights.glsl"
 
 void main() {
@@ -12,14 +13,14 @@ void main() {
 
 	#vert
 	worldTransform();
-	gl_Position = projection * camera * model * vec4(vert, 1);
+	gl_Position = projection * camera * instanceTransform() * vec4(vert, 1);
 	#endvert
 
 	#frag
 	if (unlit) {
 		outputColor = diffuse;
 	} else {
-		vec3 finalColor = ambientLight(diffuse) + pointLights(diffuse, specular, normalValue) + directionalLights(diffuse, specular, normalValue);
+		vec3 finalColor = ambientLight(diffuse) + pointLights(diffuse, specular, normalValue) + spotLights(diffuse, specular, normalValue) + directionalLights(diffuse, specular, normalValue);
 		outputColor = vec4(finalColor, diffuse.a);
 	}
 	#endfrag
@@ -8,9 +8,12 @@ uniform vec3 cameraTranslation;
 
 uniform bool unlit;
 uniform bool useTextures;
+uniform bool instanced;
 
 layout(location = 0) out vec4 outputColor;
 
+#define MAX_BONES 64
+
 in vec3 worldVertex;
 in vec3 worldNormal;
 in vec3 eyeDirection;
@@ -87,11 +90,27 @@ vec3 directLight( vec3 light, vec3 direction, vec4 diffuse, vec4 specular, vec4
 
 
 
-#define MAX_POINT_LIGHTS 4
+// the fraction of a light reaching lightDistance, 1 / (x + y*d + z*d*d), faded out smoothly at the range in w unless it is 0
+float attenuation(vec4 factors, float lightDistance) {
+	float value = 1.0 / max(factors.x + factors.y * lightDistance + factors.z * lightDistance * lightDistance, 0.0001);
+	if (factors.w > 0.0) {
+		float window = clamp(1.0 - pow(lightDistance / factors.w, 4.0), 0.0, 1.0);
+		value *= window * window;
+	}
+	return value;
+}
+
+
+
+#define MAX_POINT_LIGHTS 8
 
 uniform int nbPointLights;
 uniform vec4 pointLightPositions[ MAX_POINT_LIGHTS ];
 uniform vec4 pointLightValues[ MAX_POINT_LIGHTS ];
+uniform vec4 pointLightAttenuations[ MAX_POINT_LIGHTS ];
+
+// the fraction of each light reaching the fragment, set by shadows()
+float pointShadows[ MAX_POINT_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0 );
 
 vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
@@ -100,30 +119,58 @@ vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 		vec3 LightValue = pointLightValues[i].rgb;
 
 		vec3 v = worldVertex - LightPos;
-		float lightDistance = dot(v, v);
-		float brightness = 1.0 / lightDistance;
+		float brightness = attenuation(pointLightAttenuations[i], length(v));
 
 		vec3 worldLightDir = normalize(v);
-		vec3 light = brightness*LightValue;
+		vec3 light = brightness*pointShadows[i]*LightValue;
 
 		totalLight += directLight(light, worldLightDir, diffuse, specular, normalValue);
 	}
 	return totalLight;
 }
 
+#define MAX_SPOT_LIGHTS 4
+
+uniform int nbSpotLights;
+uniform vec4 spotLightPositions[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightDirections[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightValues[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightAttenuations[ MAX_SPOT_LIGHTS ];
+// cosines of the inner and outer cone angles
+uniform vec4 spotLightCones[ MAX_SPOT_LIGHTS ];
+
+vec3 spotLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
+	vec3 totalLight = vec3(0.0, 0.0, 0.0);
+	for (int i=0; i < nbSpotLights; i++) {
+		vec3 LightPos = spotLightPositions[i].rgb;
+		vec3 LightValue = spotLightValues[i].rgb;
+
+		vec3 v = worldVertex - LightPos;
+		vec3 worldLightDir = normalize(v);
+		float cone = smoothstep(spotLightCones[i].y, spotLightCones[i].x, dot(worldLightDir, normalize(spotLightDirections[i].rgb)));
+		float brightness = cone * attenuation(spotLightAttenuations[i], length(v));
+
+		totalLight += directLight(brightness*LightValue, worldLightDir, diffuse, specular, normalValue);
+	}
+	return totalLight;
+}
+
 #define MAX_DIRECTIONAL_LIGHTS 4
 
 uniform int nbDirectionalLights;
 uniform vec4 directionalLightVectors[ MAX_DIRECTIONAL_LIGHTS ];
 uniform vec4 directionalLightValues[ MAX_DIRECTIONAL_LIGHTS ];
 
+// the fraction of each light reaching the fragment, set by shadows()
+float directionalShadows[ MAX_DIRECTIONAL_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0 );
+
 vec3 directionalLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
 	for (int i=0; i < nbDirectionalLights; i++) {
 		vec3 LightDirection = directionalLightVectors[i].rgb;
 		vec3 LightValue = directionalLightValues[i].rgb;
 
-		totalLight += directLight(LightValue, LightDirection, diffuse, specular, normalValue);
+		totalLight += directionalShadows[i] * directLight(LightValue, LightDirection, diffuse, specular, normalValue);
 	}
 	return totalLight;
 }
@@ -135,7 +182,7 @@ void main() {
 	if (unlit) {
 		outputColor = diffuse;
 	} else {
-		vec3 finalColor = ambientLight(diffuse) + pointLights(diffuse, specular, normalValue) + directionalLights(diffuse, specular, normalValue);
+		vec3 finalColor = ambientLight(diffuse) + pointLights(diffuse, specular, normalValue) + spotLights(diffuse, specular, normalValue) + directionalLights(diffuse, specular, normalValue);
 		outputColor = vec4(finalColor, diffuse.a);
 	}
 	
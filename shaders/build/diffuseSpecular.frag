@@ -8,9 +8,12 @@ uniform vec3 cameraTranslation;
 
 uniform bool unlit;
 uniform bool useTextures;
+uniform bool instanced;
 
 layout(location = 0) out vec4 outputColor;
 
+#define MAX_BONES 64
+
 in vec3 worldVertex;
 in vec3 worldNormal;
 in vec3 eyeDirection;
@@ -107,11 +110,27 @@ vec3 directLight( vec3 light, vec3 direction, vec4 diffuse, vec4 specular, vec4
 
 
 
-#define MAX_POINT_LIGHTS 4
+// the fraction of a light reaching lightDistance, 1 / (x + y*d + z*d*d), faded out smoothly at the range in w unless it is 0
+float attenuation(vec4 factors, float lightDistance) {
+	float value = 1.0 / max(factors.x + factors.y * lightDistance + factors.z * lightDistance * lightDistance, 0.0001);
+	if (factors.w > 0.0) {
+		float window = clamp(1.0 - pow(lightDistance / factors.w, 4.0), 0.0, 1.0);
+		value *= window * window;
+	}
+	return value;
+}
+
+
+
+#define MAX_POINT_LIGHTS 8
 
 uniform int nbPointLights;
 uniform vec4 pointLightPositions[ MAX_POINT_LIGHTS ];
 uniform vec4 pointLightValues[ MAX_POINT_LIGHTS ];
+uniform vec4 pointLightAttenuations[ MAX_POINT_LIGHTS ];
+
+// the fraction of each light reaching the fragment, set by shadows()
+float pointShadows[ MAX_POINT_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0 );
 
 vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
@@ -120,30 +139,58 @@ vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 		vec3 LightValue = pointLightValues[i].rgb;
 
 		vec3 v = worldVertex - LightPos;
-		float lightDistance = dot(v, v);
-		float brightness = 1.0 / lightDistance;
+		float brightness = attenuation(pointLightAttenuations[i], length(v));
 
 		vec3 worldLightDir = normalize(v);
-		vec3 light = brightness*LightValue;
+		vec3 light = brightness*pointShadows[i]*LightValue;
 
 		totalLight += directLight(light, worldLightDir, diffuse, specular, normalValue);
 	}
 	return totalLight;
 }
 
+#define MAX_SPOT_LIGHTS 4
+
+uniform int nbSpotLights;
+uniform vec4 spotLightPositions[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightDirections[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightValues[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightAttenuations[ MAX_SPOT_LIGHTS ];
+// cosines of the inner and outer cone angles
+uniform vec4 spotLightCones[ MAX_SPOT_LIGHTS ];
+
+vec3 spotLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
+	vec3 totalLight = vec3(0.0, 0.0, 0.0);
+	for (int i=0; i < nbSpotLights; i++) {
+		vec3 LightPos = spotLightPositions[i].rgb;
+		vec3 LightValue = spotLightValues[i].rgb;
+
+		vec3 v = worldVertex - LightPos;
+		vec3 worldLightDir = normalize(v);
+		float cone = smoothstep(spotLightCones[i].y, spotLightCones[i].x, dot(worldLightDir, normalize(spotLightDirections[i].rgb)));
+		float brightness = cone * attenuation(spotLightAttenuations[i], length(v));
+
+		totalLight += directLight(brightness*LightValue, worldLightDir, diffuse, specular, normalValue);
+	}
+	return totalLight;
+}
+
 #define MAX_DIRECTIONAL_LIGHTS 4
 
 uniform int nbDirectionalLights;
 uniform vec4 directionalLightVectors[ MAX_DIRECTIONAL_LIGHTS ];
 uniform vec4 directionalLightValues[ MAX_DIRECTIONAL_LIGHTS ];
 
+// the fraction of each light reaching the fragment, set by shadows()
+float directionalShadows[ MAX_DIRECTIONAL_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0 );
+
 vec3 directionalLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
 	for (int i=0; i < nbDirectionalLights; i++) {
 		vec3 LightDirection = directionalLightVectors[i].rgb;
 		vec3 LightValue = directionalLightValues[i].rgb;
 
-		totalLight += directLight(LightValue, LightDirection, diffuse, specular, normalValue);
+		totalLight += directionalShadows[i] * directLight(LightValue, LightDirection, diffuse, specular, normalValue);
 	}
 	return totalLight;
 }
@@ -161,6 +208,104 @@ vec3 indirectLight(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	return (diffuse.rgb * diffuseValue) + (specular.rgb * specularValue);
 }
 
+// offsets from the sampled direction for percentage closer filtering of cube maps
+const vec3 pcfCubeOffsets[20] = vec3[](
+	vec3( 1,  1,  1), vec3( 1, -1,  1), vec3(-1, -1,  1), vec3(-1,  1,  1),
+	vec3( 1,  1, -1), vec3( 1, -1, -1), vec3(-1, -1, -1), vec3(-1,  1, -1),
+	vec3( 1,  1,  0), vec3( 1, -1,  0), vec3(-1, -1,  0), vec3(-1,  1,  0),
+	vec3( 1,  0,  1), vec3(-1,  0,  1), vec3( 1,  0, -1), vec3(-1,  0, -1),
+	vec3( 0,  1,  1), vec3( 0, -1,  1), vec3( 0, -1, -1), vec3( 0,  1, -1)
+);
+
+// the fraction of the 3x3 texels around coord.xy in the shadow map layer that are further from the light than coord.z
+float pcf(sampler2DArrayShadow shadowMap, vec3 coord, int layer) {
+	vec2 texelSize = 1.0 / vec2(textureSize(shadowMap, 0).xy);
+	float lit = 0.0;
+	for (int x = -1; x <= 1; x++) {
+		for (int y = -1; y <= 1; y++) {
+			lit += texture(shadowMap, vec4(coord.xy + vec2(x, y) * texelSize, layer, coord.z));
+		}
+	}
+	return lit / 9.0;
+}
+
+// the fraction of samples around the direction in the cube shadow map layer that are further from the light than depth
+float pcfCube(samplerCubeArrayShadow shadowMap, vec3 direction, int layer, float depth, float radius) {
+	float lit = texture(shadowMap, vec4(direction, layer), depth);
+	for (int i = 0; i < 20; i++) {
+		lit += texture(shadowMap, vec4(direction + pcfCubeOffsets[i] * radius, layer), depth);
+	}
+	return lit / 21.0;
+}
+
+#define MAX_SHADOW_CASCADES 3
+#define MAX_DIRECTIONAL_SHADOWS 4
+
+uniform bool receiveShadows;
+uniform float shadowBias;
+
+uniform sampler2DArrayShadow directionalShadowMap;
+uniform ivec4 directionalLightShadows[ MAX_DIRECTIONAL_LIGHTS ];
+uniform mat4 directionalShadowMatrices[ MAX_DIRECTIONAL_SHADOWS * MAX_SHADOW_CASCADES ];
+uniform vec4 shadowCascadeSplits;
+
+uniform samplerCubeArrayShadow pointShadowMap;
+uniform ivec4 pointLightShadows[ MAX_POINT_LIGHTS ];
+uniform float pointShadowFar;
+
+float directionalShadow(int shadow, vec3 direction) {
+	float viewDepth = -(camera * vec4(worldVertex, 1)).z;
+	if (viewDepth > shadowCascadeSplits[MAX_SHADOW_CASCADES - 1]) {
+		return 1.0;
+	}
+	int cascade = 0;
+	while (cascade < MAX_SHADOW_CASCADES - 1 && viewDepth > shadowCascadeSplits[cascade]) {
+		cascade++;
+	}
+
+	// move the fragment towards the light to avoid shadow acne, further on surfaces facing away from the light
+	vec3 lightDirection = normalize(direction);
+	float cosTheta = clamp(dot(worldNormal, -lightDirection), 0.0, 1.0);
+	float bias = shadowBias * float(cascade + 1) * (1.0 + 4.0 * (1.0 - cosTheta));
+
+	int layer = shadow * MAX_SHADOW_CASCADES + cascade;
+	vec4 lightSpace = directionalShadowMatrices[layer] * vec4(worldVertex - lightDirection * bias, 1);
+	vec3 coord = (lightSpace.xyz / lightSpace.w) * 0.5 + 0.5;
+	if (any(lessThan(coord, vec3(0.0))) || any(greaterThan(coord, vec3(1.0)))) {
+		return 1.0;
+	}
+	return pcf(directionalShadowMap, coord, layer);
+}
+
+float pointShadow(int shadow, vec3 position) {
+	vec3 v = worldVertex - position;
+	float lightDistance = length(v);
+	if (lightDistance >= pointShadowFar) {
+		return 1.0;
+	}
+	float depth = (lightDistance - shadowBias) / pointShadowFar;
+	return pcfCube(pointShadowMap, v, shadow, depth, lightDistance * 0.01);
+}
+
+// shadows - the fraction of each shadow casting light that reaches the fragment
+void shadows() {
+	if (!receiveShadows) {
+		return;
+	}
+	for (int i=0; i < nbDirectionalLights; i++) {
+		int shadow = directionalLightShadows[i].x;
+		if (shadow >= 0) {
+			directionalShadows[i] = directionalShadow(shadow, directionalLightVectors[i].xyz);
+		}
+	}
+	for (int i=0; i < nbPointLights; i++) {
+		int shadow = pointLightShadows[i].x;
+		if (shadow >= 0) {
+			pointShadows[i] = pointShadow(shadow, pointLightPositions[i].xyz);
+		}
+	}
+}
+
 uniform sampler2D glowMap;
 
 layout(location = 1) out vec4 brightColor;
@@ -180,9 +325,10 @@ void main() {
 	if (unlit) {
 		outputColor = diffuse;
 	} else {
+		shadows();
 		vec4 aoDiffuse = ao * metalDiffuse;
 		vec4 feSpecular = fresnelEffect(metalSpecular, normalValue);
-		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
+		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + spotLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
 		vec3 iLight = indirectLight(aoDiffuse, feSpecular, normalValue);
 		outputColor = vec4(dLight + iLight, diffuse.a);
 	}
@@ -8,12 +8,37 @@ uniform vec3 cameraTranslation;
 
 uniform bool unlit;
 uniform bool useTextures;
+uniform bool instanced;
 
 
 in vec3 vert;
 in vec3 normal;
 in vec2 texCoord;
 in vec4 color;
+in mat4 instanceModel;
+in vec4 instanceColor;
+
+#define MAX_BONES 64
+
+
+uniform bool skinned;
+uniform mat4 boneMatrices[MAX_BONES];
+uniform int boneCount;
+
+in vec4 boneIndices;
+in vec4 boneWeights;
+
+// the weights are normalized by the SkinnedGeometry, verticies without weights are not moved
+mat4 skinTransform() {
+	if (!skinned || boneWeights == vec4(0.0)) {
+		return mat4(1.0);
+	}
+	ivec4 bones = clamp(ivec4(boneIndices), 0, boneCount - 1);
+	return boneMatrices[bones.x] * boneWeights.x +
+		boneMatrices[bones.y] * boneWeights.y +
+		boneMatrices[bones.z] * boneWeights.z +
+		boneMatrices[bones.w] * boneWeights.w;
+}
 
 out vec3 worldVertex;
 out vec3 worldNormal;
@@ -21,17 +46,25 @@ out vec3 eyeDirection;
 out mat3 TBNMatrix;
 out mat3 inverseTBNMatrix;
 
+mat4 instanceTransform() {
+	if (instanced) {
+		return model * instanceModel * skinTransform();
+	}
+	return model * skinTransform();
+}
+
 void worldTransform() {
-	worldVertex = (model * vec4(vert,1)).xyz;
-	worldNormal = (modelNormal * vec4(normal,1)).xyz;
+	mat4 normalTransform = (instanced || skinned) ? transpose(inverse(instanceTransform())) : modelNormal;
+	worldVertex = (instanceTransform() * vec4(vert,1)).xyz;
+	worldNormal = (normalTransform * vec4(normal,1)).xyz;
 	worldNormal = normalize(worldNormal);
 	eyeDirection = normalize(worldVertex - cameraTranslation);
 
 	// generate arbitrary tangent and bitangent to the normal
 	vec3 tangent = cross(normal, normal + vec3(-1));
 	vec3 bitangent = cross(normal, tangent);
-	vec3 worldTangent = normalize((modelNormal * vec4(tangent,1)).xyz);
-	vec3 worldBitangent = normalize((modelNormal * vec4(bitangent,1)).xyz);
+	vec3 worldTangent = normalize((normalTransform * vec4(tangent,1)).xyz);
+	vec3 worldBitangent = normalize((normalTransform * vec4(bitangent,1)).xyz);
 
 	//tangent space conversion - worldToTangent
 	TBNMatrix = mat3(worldTangent, worldBitangent, worldNormal);
@@ -43,7 +76,7 @@ out vec4 fragColor;
 
 void textures() {
 	fragTexCoord = texCoord;
-	fragColor = color;
+	fragColor = instanced ? color * instanceColor : color;
 }
 
 void pbrCompositeTextures() {}
@@ -65,6 +98,6 @@ void main() {
 
 	
 	worldTransform();
-	gl_Position = projection * camera * model * vec4(vert, 1);
+	gl_Position = projection * camera * instanceTransform() * vec4(vert, 1);
 
 }
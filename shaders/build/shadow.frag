@@ -0,0 +1,21 @@
+#version 400
+
+#define MAX_BONES 64
+
+uniform bool pointShadow;
+uniform vec3 lightPosition;
+uniform float shadowFar;
+
+in vec3 worldVertex;
+
+
+void main() {
+
+	if (pointShadow) {
+		gl_FragDepth = length(worldVertex - lightPosition) / shadowFar;
+	} else {
+		gl_FragDepth = gl_FragCoord.z;
+	}
+	
+}
+
@@ -0,0 +1,42 @@
+#version 400
+
+#define MAX_BONES 64
+
+
+uniform bool skinned;
+uniform mat4 boneMatrices[MAX_BONES];
+uniform int boneCount;
+
+in vec4 boneIndices;
+in vec4 boneWeights;
+
+// the weights are normalized by the SkinnedGeometry, verticies without weights are not moved
+mat4 skinTransform() {
+	if (!skinned || boneWeights == vec4(0.0)) {
+		return mat4(1.0);
+	}
+	ivec4 bones = clamp(ivec4(boneIndices), 0, boneCount - 1);
+	return boneMatrices[bones.x] * boneWeights.x +
+		boneMatrices[bones.y] * boneWeights.y +
+		boneMatrices[bones.z] * boneWeights.z +
+		boneMatrices[bones.w] * boneWeights.w;
+}
+
+uniform mat4 lightViewProjection;
+uniform mat4 model;
+uniform bool instanced;
+
+in vec3 vert;
+in mat4 instanceModel;
+
+out vec3 worldVertex;
+
+void main() {
+	
+	mat4 transform = instanced ? model * instanceModel : model;
+	vec4 world = transform * skinTransform() * vec4(vert, 1);
+	worldVertex = world.xyz;
+	gl_Position = lightViewProjection * world;
+
+}
+
@@ -7,8 +7,10 @@
 #include "./lib/roughnessTexture.glsl"
 #include "./lib/ambientLight.glsl"
 #include "./lib/pointLights.glsl"
+#include "./lib/spotLights.glsl"
 #include "./lib/directionalLights.glsl"
 #include "./lib/indirectLight.glsl"
+#include "./lib/shadows.glsl"
 #include "./lib/glowOutput.glsl"
 
 void main() {
@@ -18,16 +20,17 @@ void main() {
 
 	#vert
 	worldTransform();
-	gl_Position = projection * camera * model * vec4(vert, 1);
+	gl_Position = projection * camera * instanceTransform() * vec4(vert, 1);
 	#endvert
 
 	#frag
 	if (unlit) {
 		outputColor = diffuse;
 	} else {
+		shadows();
 		vec4 aoDiffuse = ao * metalDiffuse;
 		vec4 feSpecular = fresnelEffect(metalSpecular, normalValue);
-		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
+		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + spotLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
 		vec3 iLight = indirectLight(aoDiffuse, feSpecular, normalValue);
 		outputColor = vec4(dLight + iLight, diffuse.a);
 	}
@@ -0,0 +1,11 @@
+#frag
+// the fraction of a light reaching lightDistance, 1 / (x + y*d + z*d*d), faded out smoothly at the range in w unless it is 0
+float attenuation(vec4 factors, float lightDistance) {
+	float value = 1.0 / max(factors.x + factors.y * lightDistance + factors.z * lightDistance * lightDistance, 0.0001);
+	if (factors.w > 0.0) {
+		float window = clamp(1.0 - pow(lightDistance / factors.w, 4.0), 0.0, 1.0);
+		value *= window * window;
+	}
+	return value;
+}
+#endfrag
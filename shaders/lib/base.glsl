@@ -6,12 +6,15 @@ uniform vec3 cameraTranslation;
 
 uniform bool unlit;
 uniform bool useTextures;
+uniform bool instanced;
 
 #vert
 in vec3 vert;
 in vec3 normal;
 in vec2 texCoord;
 in vec4 color;
+in mat4 instanceModel;
+in vec4 instanceColor;
 #endvert
 
 #frag
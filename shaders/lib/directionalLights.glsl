@@ -9,13 +9,16 @@ uniform int nbDirectionalLights;
 uniform vec4 directionalLightVectors[ MAX_DIRECTIONAL_LIGHTS ];
 uniform vec4 directionalLightValues[ MAX_DIRECTIONAL_LIGHTS ];
 
+// the fraction of each light reaching the fragment, set by shadows()
+float directionalShadows[ MAX_DIRECTIONAL_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0 );
+
 vec3 directionalLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
 	for (int i=0; i < nbDirectionalLights; i++) {
 		vec3 LightDirection = directionalLightVectors[i].rgb;
 		vec3 LightValue = directionalLightValues[i].rgb;
 
-		totalLight += directLight(LightValue, LightDirection, diffuse, specular, normalValue);
+		totalLight += directionalShadows[i] * directLight(LightValue, LightDirection, diffuse, specular, normalValue);
 	}
 	return totalLight;
 }
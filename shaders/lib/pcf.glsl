@@ -0,0 +1,31 @@
+#frag
+// offsets from the sampled direction for percentage closer filtering of cube maps
+const vec3 pcfCubeOffsets[20] = vec3[](
+	vec3( 1,  1,  1), vec3( 1, -1,  1), vec3(-1, -1,  1), vec3(-1,  1,  1),
+	vec3( 1,  1, -1), vec3( 1, -1, -1), vec3(-1, -1, -1), vec3(-1,  1, -1),
+	vec3( 1,  1,  0), vec3( 1, -1,  0), vec3(-1, -1,  0), vec3(-1,  1,  0),
+	vec3( 1,  0,  1), vec3(-1,  0,  1), vec3( 1,  0, -1), vec3(-1,  0, -1),
+	vec3( 0,  1,  1), vec3( 0, -1,  1), vec3( 0, -1, -1), vec3( 0,  1, -1)
+);
+
+// the fraction of the 3x3 texels around coord.xy in the shadow map layer that are further from the light than coord.z
+float pcf(sampler2DArrayShadow shadowMap, vec3 coord, int layer) {
+	vec2 texelSize = 1.0 / vec2(textureSize(shadowMap, 0).xy);
+	float lit = 0.0;
+	for (int x = -1; x <= 1; x++) {
+		for (int y = -1; y <= 1; y++) {
+			lit += texture(shadowMap, vec4(coord.xy + vec2(x, y) * texelSize, layer, coord.z));
+		}
+	}
+	return lit / 9.0;
+}
+
+// the fraction of samples around the direction in the cube shadow map layer that are further from the light than depth
+float pcfCube(samplerCubeArrayShadow shadowMap, vec3 direction, int layer, float depth, float radius) {
+	float lit = texture(shadowMap, vec4(direction, layer), depth);
+	for (int i = 0; i < 20; i++) {
+		lit += texture(shadowMap, vec4(direction + pcfCubeOffsets[i] * radius, layer), depth);
+	}
+	return lit / 21.0;
+}
+#endfrag
@@ -2,12 +2,17 @@
 #include "./base.glsl"
 #include "./worldTransform.glsl"
 #include "./directLight.glsl"
+#include "./attenuation.glsl"
 
-#define MAX_POINT_LIGHTS 4
+#define MAX_POINT_LIGHTS 8
 
 uniform int nbPointLights;
 uniform vec4 pointLightPositions[ MAX_POINT_LIGHTS ];
 uniform vec4 pointLightValues[ MAX_POINT_LIGHTS ];
+uniform vec4 pointLightAttenuations[ MAX_POINT_LIGHTS ];
+
+// the fraction of each light reaching the fragment, set by shadows()
+float pointShadows[ MAX_POINT_LIGHTS ] = float[]( 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0, 1.0 );
 
 vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 	vec3 totalLight = vec3(0.0, 0.0, 0.0);
@@ -16,11 +21,10 @@ vec3 pointLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
 		vec3 LightValue = pointLightValues[i].rgb;
 
 		vec3 v = worldVertex - LightPos;
-		float lightDistance = dot(v, v);
-		float brightness = 1.0 / lightDistance;
+		float brightness = attenuation(pointLightAttenuations[i], length(v));
 
 		vec3 worldLightDir = normalize(v);
-		vec3 light = brightness*LightValue;
+		vec3 light = brightness*pointShadows[i]*LightValue;
 
 		totalLight += directLight(light, worldLightDir, diffuse, specular, normalValue);
 	}
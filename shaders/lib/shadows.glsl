@@ -0,0 +1,75 @@
+#frag
+#include "./base.glsl"
+#include "./worldTransform.glsl"
+#include "./pcf.glsl"
+#include "./pointLights.glsl"
+#include "./directionalLights.glsl"
+
+#define MAX_SHADOW_CASCADES 3
+#define MAX_DIRECTIONAL_SHADOWS 4
+
+uniform bool receiveShadows;
+uniform float shadowBias;
+
+uniform sampler2DArrayShadow directionalShadowMap;
+uniform ivec4 directionalLightShadows[ MAX_DIRECTIONAL_LIGHTS ];
+uniform mat4 directionalShadowMatrices[ MAX_DIRECTIONAL_SHADOWS * MAX_SHADOW_CASCADES ];
+uniform vec4 shadowCascadeSplits;
+
+uniform samplerCubeArrayShadow pointShadowMap;
+uniform ivec4 pointLightShadows[ MAX_POINT_LIGHTS ];
+uniform float pointShadowFar;
+
+float directionalShadow(int shadow, vec3 direction) {
+	float viewDepth = -(camera * vec4(worldVertex, 1)).z;
+	if (viewDepth > shadowCascadeSplits[MAX_SHADOW_CASCADES - 1]) {
+		return 1.0;
+	}
+	int cascade = 0;
+	while (cascade < MAX_SHADOW_CASCADES - 1 && viewDepth > shadowCascadeSplits[cascade]) {
+		cascade++;
+	}
+
+	// move the fragment towards the light to avoid shadow acne, further on surfaces facing away from the light
+	vec3 lightDirection = normalize(direction);
+	float cosTheta = clamp(dot(worldNormal, -lightDirection), 0.0, 1.0);
+	float bias = shadowBias * float(cascade + 1) * (1.0 + 4.0 * (1.0 - cosTheta));
+
+	int layer = shadow * MAX_SHADOW_CASCADES + cascade;
+	vec4 lightSpace = directionalShadowMatrices[layer] * vec4(worldVertex - lightDirection * bias, 1);
+	vec3 coord = (lightSpace.xyz / lightSpace.w) * 0.5 + 0.5;
+	if (any(lessThan(coord, vec3(0.0))) || any(greaterThan(coord, vec3(1.0)))) {
+		return 1.0;
+	}
+	return pcf(directionalShadowMap, coord, layer);
+}
+
+float pointShadow(int shadow, vec3 position) {
+	vec3 v = worldVertex - position;
+	float lightDistance = length(v);
+	if (lightDistance >= pointShadowFar) {
+		return 1.0;
+	}
+	float depth = (lightDistance - shadowBias) / pointShadowFar;
+	return pcfCube(pointShadowMap, v, shadow, depth, lightDistance * 0.01);
+}
+
+// shadows - the fraction of each shadow casting light that reaches the fragment
+void shadows() {
+	if (!receiveShadows) {
+		return;
+	}
+	for (int i=0; i < nbDirectionalLights; i++) {
+		int shadow = directionalLightShadows[i].x;
+		if (shadow >= 0) {
+			directionalShadows[i] = directionalShadow(shadow, directionalLightVectors[i].xyz);
+		}
+	}
+	for (int i=0; i < nbPointLights; i++) {
+		int shadow = pointLightShadows[i].x;
+		if (shadow >= 0) {
+			pointShadows[i] = pointShadow(shadow, pointLightPositions[i].xyz);
+		}
+	}
+}
+#endfrag
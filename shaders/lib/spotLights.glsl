@@ -0,0 +1,32 @@
+#frag
+#include "./base.glsl"
+#include "./worldTransform.glsl"
+#include "./directLight.glsl"
+#include "./attenuation.glsl"
+
+#define MAX_SPOT_LIGHTS 4
+
+uniform int nbSpotLights;
+uniform vec4 spotLightPositions[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightDirections[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightValues[ MAX_SPOT_LIGHTS ];
+uniform vec4 spotLightAttenuations[ MAX_SPOT_LIGHTS ];
+// cosines of the inner and outer cone angles
+uniform vec4 spotLightCones[ MAX_SPOT_LIGHTS ];
+
+vec3 spotLights(vec4 diffuse, vec4 specular, vec4 normalValue) {
+	vec3 totalLight = vec3(0.0, 0.0, 0.0);
+	for (int i=0; i < nbSpotLights; i++) {
+		vec3 LightPos = spotLightPositions[i].rgb;
+		vec3 LightValue = spotLightValues[i].rgb;
+
+		vec3 v = worldVertex - LightPos;
+		vec3 worldLightDir = normalize(v);
+		float cone = smoothstep(spotLightCones[i].y, spotLightCones[i].x, dot(worldLightDir, normalize(spotLightDirections[i].rgb)));
+		float brightness = cone * attenuation(spotLightAttenuations[i], length(v));
+
+		totalLight += directLight(brightness*LightValue, worldLightDir, diffuse, specular, normalValue);
+	}
+	return totalLight;
+}
+#endfrag
@@ -6,7 +6,7 @@ out vec4 fragColor;
 
 void textures() {
 	fragTexCoord = texCoord;
-	fragColor = color;
+	fragColor = instanced ? color * instanceColor : color;
 }
 #endvert
 
@@ -1,4 +1,5 @@
 #include "./base.glsl"
+#include "./skinning.glsl"
 
 #frag
 in vec3 worldVertex;
@@ -15,17 +16,25 @@ out vec3 eyeDirection;
 out mat3 TBNMatrix;
 out mat3 inverseTBNMatrix;
 
+mat4 instanceTransform() {
+	if (instanced) {
+		return model * instanceModel * skinTransform();
+	}
+	return model * skinTransform();
+}
+
 void worldTransform() {
-	worldVertex = (model * vec4(vert,1)).xyz;
-	worldNormal = (modelNormal * vec4(normal,1)).xyz;
+	mat4 normalTransform = (instanced || skinned) ? transpose(inverse(instanceTransform())) : modelNormal;
+	worldVertex = (instanceTransform() * vec4(vert,1)).xyz;
+	worldNormal = (normalTransform * vec4(normal,1)).xyz;
 	worldNormal = normalize(worldNormal);
 	eyeDirection = normalize(worldVertex - cameraTranslation);
 
 	// generate arbitrary tangent and bitangent to the normal
 	vec3 tangent = cross(normal, normal + vec3(-1));
 	vec3 bitangent = cross(normal, tangent);
-	vec3 worldTangent = normalize((modelNormal * vec4(tangent,1)).xyz);
-	vec3 worldBitangent = normalize((modelNormal * vec4(bitangent,1)).xyz);
+	vec3 worldTangent = normalize((normalTransform * vec4(tangent,1)).xyz);
+	vec3 worldBitangent = normalize((normalTransform * vec4(bitangent,1)).xyz);
 
 	//tangent space conversion - worldToTangent
 	TBNMatrix = mat3(worldTangent, worldBitangent, worldNormal);
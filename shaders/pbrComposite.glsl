@@ -7,6 +7,7 @@
 #include "./lib/fresnelEffect.glsl"
 #include "./lib/ambientLight.glsl"
 #include "./lib/pointLights.glsl"
+#include "./lib/spotLights.glsl"
 #include "./lib/directionalLights.glsl"
 #include "./lib/indirectLight.glsl"
 #include "./lib/glowOutput.glsl"
@@ -18,7 +19,7 @@ void main() {
 
 	#vert
 	worldTransform();
-	gl_Position = projection * camera * model * vec4(vert, 1);
+	gl_Position = projection * camera * instanceTransform() * vec4(vert, 1);
 	#endvert
 
 	#frag
@@ -27,7 +28,7 @@ void main() {
 	} else {
 		vec4 aoDiffuse = ao * metalDiffuse;
 		vec4 feSpecular = fresnelEffect(metalSpecular, normalValue);
-		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
+		vec3 dLight = ambientLight(aoDiffuse) + pointLights(aoDiffuse, feSpecular, normalValue) + spotLights(aoDiffuse, feSpecular, normalValue) + directionalLights(aoDiffuse, feSpecular, normalValue);
 		vec3 iLight = indirectLight(aoDiffuse, feSpecular, normalValue);
 		outputColor = vec4(dLight + iLight, diffuse.a);
 	}
@@ -0,0 +1,15 @@
+// Package shaders - the shaders built into the engine, rebuild them with the shaderBuilder after changing the glsl, eg.
+// shaderBuilder shadow.glsl vert > build/shadow.vert
+package shaders
+
+import _ "embed"
+
+// ShadowVert - the depth only vertex shader used to render shadow maps, built from shadow.glsl
+//
+//go:embed build/shadow.vert
+var ShadowVert string
+
+// ShadowFrag - the depth only fragment shader used to render shadow maps, built from shadow.glsl
+//
+//go:embed build/shadow.frag
+var ShadowFrag string
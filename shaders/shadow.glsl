@@ -0,0 +1,39 @@
+#version 400
+
+#include "./lib/skinning.glsl"
+
+#vert
+uniform mat4 lightViewProjection;
+uniform mat4 model;
+uniform bool instanced;
+
+in vec3 vert;
+in mat4 instanceModel;
+
+out vec3 worldVertex;
+#endvert
+
+#frag
+uniform bool pointShadow;
+uniform vec3 lightPosition;
+uniform float shadowFar;
+
+in vec3 worldVertex;
+#endfrag
+
+void main() {
+	#vert
+	mat4 transform = instanced ? model * instanceModel : model;
+	vec4 world = transform * skinTransform() * vec4(vert, 1);
+	worldVertex = world.xyz;
+	gl_Position = lightViewProjection * world;
+	#endvert
+
+	#frag
+	if (pointShadow) {
+		gl_FragDepth = length(worldVertex - lightPosition) / shadowFar;
+	} else {
+		gl_FragDepth = gl_FragCoord.z;
+	}
+	#endfrag
+}
@@ -0,0 +1,226 @@
+package software
+
+import (
+	"image"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// vertex - a vertex after the vertex transform, with the attributes interpolated across triangles
+type vertex struct {
+	clip   mgl32.Vec4
+	world  mgl32.Vec3
+	normal mgl32.Vec3
+	uv     mgl32.Vec2
+	color  mgl32.Vec4
+}
+
+func lerpVertex(a, b vertex, t float32) vertex {
+	return vertex{
+		clip:   a.clip.Add(b.clip.Sub(a.clip).Mul(t)),
+		world:  a.world.Add(b.world.Sub(a.world).Mul(t)),
+		normal: a.normal.Add(b.normal.Sub(a.normal).Mul(t)),
+		uv:     a.uv.Add(b.uv.Sub(a.uv).Mul(t)),
+		color:  a.color.Add(b.color.Sub(a.color).Mul(t)),
+	}
+}
+
+// screenVertex - a vertex in window coordinates, y down
+type screenVertex struct {
+	x, y, z float32
+	invW    float32
+	vertex
+}
+
+// projection - the same projection and camera matrices as the opengl renderer
+func (sr *SoftwareRenderer) projection() mgl32.Mat4 {
+	cam := sr.camera
+	win := sr.WindowDimensions()
+	if cam.Ortho {
+		return mgl32.Ortho2D(0, win.X(), win.Y(), 0)
+	}
+	return mgl32.Perspective(mgl32.DegToRad(cam.Angle), win.X()/win.Y(), cam.Near, cam.Far).
+		Mul4(mgl32.LookAtV(cam.Translation, cam.Lookat, cam.Up))
+}
+
+// DrawGeometry - rasterize the triangles of the geometry, using the vertex format x,y,z, nx,ny,nz, u,v, r,g,b,a
+func (sr *SoftwareRenderer) DrawGeometry(geometry *renderer.Geometry, transform mgl32.Mat4) {
+	geometry.Loaded = true
+	geometry.VboDirty = false
+	sr.drawGeometry(geometry, transform, mgl32.Vec4{1, 1, 1, 1})
+}
+
+// DrawInstanced - draw the geometry once for each visible instance, with the vertex colors multiplied by the instance color
+func (sr *SoftwareRenderer) DrawInstanced(instanced *renderer.InstancedGeometry, transform mgl32.Mat4) {
+	instanced.Geometry.Loaded = true
+	instanced.Geometry.VboDirty = false
+	instanced.InstanceLoaded = true
+	for _, instance := range instanced.VisibleInstances() {
+		sr.drawGeometry(instanced.Geometry, transform.Mul4(instance.Transform), instance.Color)
+	}
+}
+
+// DrawSkinned - draw the geometry skinned on the cpu
+func (sr *SoftwareRenderer) DrawSkinned(skinned *renderer.SkinnedGeometry, transform mgl32.Mat4) {
+	skinned.Geometry.Loaded = true
+	skinned.Geometry.VboDirty = false
+	skinned.SkinLoaded = true
+	skinned.SkinDirty = false
+	sr.drawGeometry(skinned.CPUSkin(), transform, mgl32.Vec4{1, 1, 1, 1})
+}
+
+func (sr *SoftwareRenderer) drawGeometry(geometry *renderer.Geometry, transform mgl32.Mat4, tint mgl32.Vec4) {
+	verts := geometry.Verticies
+	if len(verts) == 0 || len(geometry.Indicies) == 0 {
+		return
+	}
+
+	mvp := sr.projection().Mul4(transform)
+	modelNormal := transform.Inv().Transpose()
+	vertexCount := uint32(len(verts) / renderer.VertexStride)
+	transformed := make([]vertex, vertexCount)
+	for i := range transformed {
+		v := verts[i*renderer.VertexStride : (i+1)*renderer.VertexStride]
+		position := mgl32.Vec4{v[0], v[1], v[2], 1}
+		transformed[i] = vertex{
+			clip:   mvp.Mul4x1(position),
+			world:  transform.Mul4x1(position).Vec3(),
+			normal: modelNormal.Mul4x1(mgl32.Vec4{v[3], v[4], v[5], 0}).Vec3(),
+			uv:     mgl32.Vec2{v[6], v[7]},
+			color:  mgl32.Vec4{v[8] * tint[0], v[9] * tint[1], v[10] * tint[2], v[11] * tint[3]},
+		}
+	}
+
+	shader := sr.newFragmentShader()
+	indicies := geometry.Indicies
+	for i := 0; i+2 < len(indicies); i += 3 {
+		a, b, c := indicies[i], indicies[i+1], indicies[i+2]
+		if a >= vertexCount || b >= vertexCount || c >= vertexCount {
+			continue
+		}
+		clipped := clipNear([]vertex{transformed[a], transformed[b], transformed[c]})
+		for j := 1; j+1 < len(clipped); j++ {
+			sr.rasterize(shader, clipped[0], clipped[j], clipped[j+1])
+		}
+	}
+}
+
+// clipNear - clip a polygon against the near plane (z >= -w)
+func clipNear(polygon []vertex) []vertex {
+	const epsilon = 1e-5
+	distance := func(v vertex) float32 { return v.clip[2] + v.clip[3] - epsilon }
+	var result []vertex
+	for i, current := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		dc, dn := distance(current), distance(next)
+		if dc >= 0 {
+			result = append(result, current)
+		}
+		if (dc >= 0) != (dn >= 0) {
+			result = append(result, lerpVertex(current, next, dc/(dc-dn)))
+		}
+	}
+	return result
+}
+
+func (sr *SoftwareRenderer) toScreen(v vertex) screenVertex {
+	invW := 1 / v.clip[3]
+	return screenVertex{
+		x:      (v.clip[0]*invW + 1) * 0.5 * float32(sr.Width),
+		y:      (1 - v.clip[1]*invW) * 0.5 * float32(sr.Height),
+		z:      (v.clip[2]*invW + 1) * 0.5,
+		invW:   invW,
+		vertex: v,
+	}
+}
+
+func edge(a, b screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// isTopLeft - the fill rule for pixels exactly on an edge, so pixels on edges shared by two triangles are only drawn once
+func isTopLeft(a, b screenVertex) bool {
+	return (a.y == b.y && b.x < a.x) || b.y > a.y
+}
+
+func (sr *SoftwareRenderer) rasterize(shader *fragmentShader, v0, v1, v2 vertex) {
+	a, b, c := sr.toScreen(v0), sr.toScreen(v1), sr.toScreen(v2)
+	area := edge(a, b, c.x, c.y)
+	if area == 0 {
+		return
+	}
+	// counter clockwise triangles face the camera (the y axis is flipped in window coordinates)
+	if area > 0 {
+		if sr.rendererParams.CullBackface {
+			return
+		}
+		b, c = c, b
+		area = -area
+	}
+
+	bounds := image.Rect(
+		int(math.Floor(float64(min3(a.x, b.x, c.x)))), int(math.Floor(float64(min3(a.y, b.y, c.y)))),
+		int(math.Ceil(float64(max3(a.x, b.x, c.x))))+1, int(math.Ceil(float64(max3(a.y, b.y, c.y))))+1,
+	).Intersect(image.Rect(0, 0, sr.Width, sr.Height))
+
+	params := sr.rendererParams
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px, py := float32(x)+0.5, float32(y)+0.5
+			w0, w1, w2 := edge(b, c, px, py), edge(c, a, px, py), edge(a, b, px, py)
+			if !inside(w0, c, b) || !inside(w1, a, c) || !inside(w2, b, a) {
+				continue
+			}
+			w0, w1, w2 = w0/area, w1/area, w2/area
+
+			z := w0*a.z + w1*b.z + w2*c.z
+			if z < 0 || z > 1 {
+				continue
+			}
+			index := y*sr.Width + x
+			if params.DepthTest && z > sr.depthBuffer[index] {
+				continue
+			}
+
+			// perspective correct interpolation
+			p0, p1, p2 := w0*a.invW, w1*b.invW, w2*c.invW
+			sum := p0 + p1 + p2
+			p0, p1, p2 = p0/sum, p1/sum, p2/sum
+			fragment := vertex{
+				world:  a.world.Mul(p0).Add(b.world.Mul(p1)).Add(c.world.Mul(p2)),
+				normal: a.normal.Mul(p0).Add(b.normal.Mul(p1)).Add(c.normal.Mul(p2)),
+				uv:     a.uv.Mul(p0).Add(b.uv.Mul(p1)).Add(c.uv.Mul(p2)),
+				color:  a.color.Mul(p0).Add(b.color.Mul(p1)).Add(c.color.Mul(p2)),
+			}
+
+			sr.colorBuffer[index] = blend(shader.shade(fragment), sr.colorBuffer[index], params.Transparency)
+			if params.DepthMask {
+				sr.depthBuffer[index] = z
+			}
+		}
+	}
+}
+
+// inside - true if the edge function w places the pixel inside the edge from a to b, using the top left fill rule
+func inside(w float32, a, b screenVertex) bool {
+	return w < 0 || (w == 0 && isTopLeft(a, b))
+}
+
+// blend - the opengl blend functions used for each transparency mode, applied to all four channels
+func blend(src, dst mgl32.Vec4, transparency renderer.Transparency) mgl32.Vec4 {
+	alpha := clamp(src[3])
+	if transparency == renderer.EMISSIVE {
+		return src.Mul(alpha).Add(dst)
+	}
+	return src.Mul(alpha).Add(dst.Mul(1 - alpha))
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
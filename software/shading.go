@@ -0,0 +1,106 @@
+package software
+
+import (
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+type directLight struct {
+	color     mgl32.Vec3
+	direction mgl32.Vec3
+	source    *renderer.Light // point and spot lights, which fall off with distance
+}
+
+// fragmentShader - the state used to shade the fragments of a single draw call
+type fragmentShader struct {
+	unlit               bool
+	diffuseMap, specMap *image.NRGBA
+	cameraTranslation   mgl32.Vec3
+	ambient             mgl32.Vec3
+	lights              []directLight
+}
+
+func (sr *SoftwareRenderer) newFragmentShader() *fragmentShader {
+	shader := &fragmentShader{
+		unlit:             sr.rendererParams.Unlit,
+		diffuseMap:        sr.texture("diffuseMap"),
+		specMap:           sr.texture("specularMap"),
+		cameraTranslation: sr.camera.Translation,
+	}
+	for _, light := range sr.lights {
+		c := mgl32.Vec3{light.Color[0], light.Color[1], light.Color[2]}
+		switch light.LightType {
+		case renderer.AMBIENT:
+			shader.ambient = shader.ambient.Add(c)
+		case renderer.POINT, renderer.SPOT:
+			shader.lights = append(shader.lights, directLight{color: c, source: light})
+		case renderer.DIRECTIONAL:
+			shader.lights = append(shader.lights, directLight{color: c, direction: light.Direction.Normalize()})
+		}
+	}
+	return shader
+}
+
+// shade - the color of a fragment, following shaders/basic.glsl
+func (shader *fragmentShader) shade(fragment vertex) mgl32.Vec4 {
+	diffuse := fragment.color
+	var specular mgl32.Vec3
+	if shader.diffuseMap != nil {
+		texel := sample(shader.diffuseMap, fragment.uv)
+		diffuse = mgl32.Vec4{diffuse[0] * texel[0], diffuse[1] * texel[1], diffuse[2] * texel[2], diffuse[3] * texel[3]}
+	}
+	if shader.specMap != nil {
+		specular = sample(shader.specMap, fragment.uv).Vec3()
+	}
+	if shader.unlit {
+		return diffuse
+	}
+
+	diffuseColor := diffuse.Vec3()
+	normal := fragment.normal
+	if normal.Len() > 0 {
+		normal = normal.Normalize()
+	}
+	eyeDirection := fragment.world.Sub(shader.cameraTranslation)
+	if eyeDirection.Len() > 0 {
+		eyeDirection = eyeDirection.Normalize()
+	}
+	reflectedEye := eyeDirection.Sub(normal.Mul(2 * normal.Dot(eyeDirection)))
+
+	color := mulVec3(shader.ambient, diffuseColor)
+	for _, light := range shader.lights {
+		value, direction := light.color, light.direction
+		if source := light.source; source != nil {
+			v := fragment.world.Sub(source.Position)
+			distance := v.Len()
+			if distance == 0 {
+				continue
+			}
+			direction = v.Mul(1 / distance)
+			brightness := source.Falloff(distance)
+			if source.LightType == renderer.SPOT {
+				brightness *= source.SpotFactor(direction)
+			}
+			value = value.Mul(brightness)
+		}
+		diffuseMultiplier := maxF(0, normal.Dot(direction.Mul(-1)))
+		specularMultiplier := maxF(0, reflectedEye.Dot(direction.Mul(-1)))
+		specularMultiplier *= specularMultiplier
+		lit := diffuseColor.Mul(diffuseMultiplier).Add(specular.Mul(specularMultiplier))
+		color = color.Add(mulVec3(lit, value))
+	}
+	return mgl32.Vec4{color[0], color[1], color[2], diffuse[3]}
+}
+
+func mulVec3(a, b mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
+
+func maxF(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
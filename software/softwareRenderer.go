@@ -0,0 +1,267 @@
+package software
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/walesey/go-engine/renderer"
+)
+
+// SoftwareRenderer - a pure go rasterizer implementing renderer.Renderer.
+// Frames are rendered into an image.RGBA, so scenes can be rendered without a window or a gpu.
+//
+// Fragments are shaded like shaders/basic.glsl: vertex color multiplied by the diffuseMap texture,
+// lit by ambient, point and directional lights with the specularMap texture.
+// Shaders, cube maps, normal maps and post effects are ignored.
+type SoftwareRenderer struct {
+	onInit, onUpdate, onRender func()
+	Width, Height              int
+	camera                     *renderer.Camera
+
+	initialized bool
+	stopMux     sync.Mutex
+	stopped     bool
+
+	backgroundColor mgl32.Vec4
+	colorBuffer     []mgl32.Vec4
+	depthBuffer     []float32
+	frame           *image.RGBA
+
+	material       *renderer.Material
+	rendererParams renderer.RendererParams
+	textures       map[*renderer.Texture]*image.NRGBA
+
+	lights []*renderer.Light
+}
+
+// NewSoftwareRenderer - create a renderer that draws frames of the given size
+func NewSoftwareRenderer(width, height int) *SoftwareRenderer {
+	sr := &SoftwareRenderer{
+		Width:          width,
+		Height:         height,
+		camera:         renderer.CreateCamera(),
+		colorBuffer:    make([]mgl32.Vec4, width*height),
+		depthBuffer:    make([]float32, width*height),
+		frame:          image.NewRGBA(image.Rect(0, 0, width, height)),
+		rendererParams: renderer.DefaultRendererParams(),
+		textures:       make(map[*renderer.Texture]*image.NRGBA),
+	}
+	sr.Clear()
+	return sr
+}
+
+func (sr *SoftwareRenderer) SetInit(callback func()) {
+	sr.onInit = callback
+}
+
+func (sr *SoftwareRenderer) SetUpdate(callback func()) {
+	sr.onUpdate = callback
+}
+
+func (sr *SoftwareRenderer) SetRender(callback func()) {
+	sr.onRender = callback
+}
+
+func (sr *SoftwareRenderer) SetCamera(camera *renderer.Camera) {
+	sr.camera = camera
+}
+
+func (sr *SoftwareRenderer) Camera() *renderer.Camera {
+	return sr.camera
+}
+
+// Start - render frames until Stop is called
+func (sr *SoftwareRenderer) Start() {
+	sr.stopMux.Lock()
+	sr.stopped = false
+	sr.stopMux.Unlock()
+	for !sr.isStopped() {
+		sr.RenderFrame()
+	}
+}
+
+// Stop - stop the loop started by Start after the current frame
+func (sr *SoftwareRenderer) Stop() {
+	sr.stopMux.Lock()
+	defer sr.stopMux.Unlock()
+	sr.stopped = true
+}
+
+func (sr *SoftwareRenderer) isStopped() bool {
+	sr.stopMux.Lock()
+	defer sr.stopMux.Unlock()
+	return sr.stopped
+}
+
+// RenderFrame - run the update and render callbacks once and return the rendered frame.
+// The init callback is run before the first frame.
+func (sr *SoftwareRenderer) RenderFrame() *image.RGBA {
+	if !sr.initialized {
+		sr.initialized = true
+		if sr.onInit != nil {
+			sr.onInit()
+		}
+	}
+	if sr.onUpdate != nil {
+		sr.onUpdate()
+	}
+
+	sr.UseRendererParams(renderer.DefaultRendererParams())
+	sr.UseMaterial(nil)
+	sr.Clear()
+	if sr.onRender != nil {
+		sr.onRender()
+	}
+	return sr.Image()
+}
+
+// Clear - fill the frame with the background color and reset the depth buffer
+func (sr *SoftwareRenderer) Clear() {
+	for i := range sr.colorBuffer {
+		sr.colorBuffer[i] = sr.backgroundColor
+		sr.depthBuffer[i] = math.MaxFloat32
+	}
+}
+
+// Image - the frame rendered so far
+func (sr *SoftwareRenderer) Image() *image.RGBA {
+	for i, c := range sr.colorBuffer {
+		a := clamp(c[3])
+		sr.frame.Pix[i*4] = toByte(clamp(c[0]) * a)
+		sr.frame.Pix[i*4+1] = toByte(clamp(c[1]) * a)
+		sr.frame.Pix[i*4+2] = toByte(clamp(c[2]) * a)
+		sr.frame.Pix[i*4+3] = toByte(a)
+	}
+	return sr.frame
+}
+
+// BackGroundColor - set background color for the scene
+func (sr *SoftwareRenderer) BackGroundColor(r, g, b, a float32) {
+	sr.backgroundColor = mgl32.Vec4{r, g, b, a}
+}
+
+func (sr *SoftwareRenderer) WindowDimensions() mgl32.Vec2 {
+	return mgl32.Vec2{float32(sr.Width), float32(sr.Height)}
+}
+
+func (sr *SoftwareRenderer) LockCursor(lock bool) {}
+
+func (sr *SoftwareRenderer) UseRendererParams(params renderer.RendererParams) {
+	sr.rendererParams = params
+}
+
+func (sr *SoftwareRenderer) DestroyGeometry(geometry *renderer.Geometry) {}
+
+func (sr *SoftwareRenderer) DestroyInstanced(instanced *renderer.InstancedGeometry) {}
+
+func (sr *SoftwareRenderer) DestroySkinned(skinned *renderer.SkinnedGeometry) {}
+
+func (sr *SoftwareRenderer) UseMaterial(material *renderer.Material) {
+	sr.material = material
+}
+
+func (sr *SoftwareRenderer) DestroyMaterial(material *renderer.Material) {
+	for _, tex := range material.Textures {
+		delete(sr.textures, tex)
+		tex.Loaded = false
+	}
+}
+
+func (sr *SoftwareRenderer) UseCubeMap(cubeMap *renderer.CubeMap) {}
+
+func (sr *SoftwareRenderer) DestroyCubeMap(cubeMap *renderer.CubeMap) {}
+
+func (sr *SoftwareRenderer) UseShader(shader *renderer.Shader) {}
+
+func (sr *SoftwareRenderer) CreatePostEffect(shader *renderer.Shader) {}
+
+func (sr *SoftwareRenderer) DestroyPostEffects(shader *renderer.Shader) {}
+
+func (sr *SoftwareRenderer) AddLight(light *renderer.Light) {
+	sr.lights = append(sr.lights, light)
+}
+
+func (sr *SoftwareRenderer) RemoveLight(light *renderer.Light) {
+	for i, l := range sr.lights {
+		if l == light {
+			sr.lights = append(sr.lights[:i], sr.lights[i+1:]...)
+			break
+		}
+	}
+}
+
+// texture - the texture with the given name in the current material, converted for sampling
+func (sr *SoftwareRenderer) texture(name string) *image.NRGBA {
+	if sr.material == nil {
+		return nil
+	}
+	for _, tex := range sr.material.Textures {
+		if tex.TextureName != name || tex.Img == nil {
+			continue
+		}
+		img, ok := sr.textures[tex]
+		if !ok {
+			img = image.NewNRGBA(tex.Img.Bounds())
+			draw.Draw(img, img.Bounds(), tex.Img, tex.Img.Bounds().Min, draw.Src)
+			sr.textures[tex] = img
+			tex.Loaded = true
+		}
+		return img
+	}
+	return nil
+}
+
+// sample - bilinear sample with repeating texture coordinates
+func sample(img *image.NRGBA, uv mgl32.Vec2) mgl32.Vec4 {
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return mgl32.Vec4{0, 0, 0, 1}
+	}
+	u, v := uv[0]-float32(math.Floor(float64(uv[0]))), uv[1]-float32(math.Floor(float64(uv[1])))
+	x, y := u*float32(size.X)-0.5, v*float32(size.Y)-0.5
+	x0, y0 := int(math.Floor(float64(x))), int(math.Floor(float64(y)))
+	fx, fy := x-float32(x0), y-float32(y0)
+
+	texel := func(x, y int) mgl32.Vec4 {
+		x, y = clampInt(x, 0, size.X-1), clampInt(y, 0, size.Y-1)
+		c := img.NRGBAAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y)
+		return mgl32.Vec4{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+	}
+	top := texel(x0, y0).Mul(1 - fx).Add(texel(x0+1, y0).Mul(fx))
+	bottom := texel(x0, y0+1).Mul(1 - fx).Add(texel(x0+1, y0+1).Mul(fx))
+	return top.Mul(1 - fy).Add(bottom.Mul(fy))
+}
+
+func clamp(f float32) float32 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+func clampInt(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+func toByte(f float32) uint8 {
+	return uint8(f*255 + 0.5)
+}
+
+// ColorAt - the color of a pixel in the last rendered frame
+func (sr *SoftwareRenderer) ColorAt(x, y int) color.NRGBA {
+	c := sr.colorBuffer[y*sr.Width+x]
+	return color.NRGBA{toByte(clamp(c[0])), toByte(clamp(c[1])), toByte(clamp(c[2])), toByte(clamp(c[3]))}
+}
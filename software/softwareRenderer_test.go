@@ -0,0 +1,202 @@
+package software
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"github.com/walesey/go-engine/engine"
+	"github.com/walesey/go-engine/renderer"
+)
+
+var _ renderer.Renderer = (*SoftwareRenderer)(nil)
+
+// quad - a 2x2 quad at depth z, facing -z
+func quad(z float32, c color.Color) *renderer.Geometry {
+	geometry := renderer.CreateBox(2, 2)
+	geometry.Transform(mgl32.Translate3D(0, 0, z))
+	for i := 0; i < len(geometry.Verticies); i += renderer.VertexStride {
+		geometry.Verticies[i+3], geometry.Verticies[i+4], geometry.Verticies[i+5] = 0, 0, -1
+	}
+	geometry.SetColor(c)
+	return geometry
+}
+
+func perspectiveRenderer(cameraZ float32) *SoftwareRenderer {
+	sr := NewSoftwareRenderer(32, 32)
+	sr.Camera().Translation = mgl32.Vec3{0, 0, cameraZ}
+	sr.Camera().Lookat = mgl32.Vec3{0, 0, 0}
+	sr.BackGroundColor(0, 0, 0, 1)
+	sr.Clear()
+	return sr
+}
+
+func unlitParams() renderer.RendererParams {
+	params := renderer.DefaultRendererParams()
+	params.Unlit = true
+	return params
+}
+
+func TestOrthoDraw(t *testing.T) {
+	sr := NewSoftwareRenderer(20, 20)
+	sr.Camera().Ortho = true
+	sr.BackGroundColor(0, 0, 1, 1)
+	sr.SetRender(func() {
+		sr.UseRendererParams(unlitParams())
+		box := renderer.CreateBoxWithOffset(10, 10, 5, 5)
+		box.SetColor(color.NRGBA{255, 0, 0, 255})
+		box.Draw(sr, mgl32.Ident4())
+	})
+	frame := sr.RenderFrame()
+	assert.EqualValues(t, image.Rect(0, 0, 20, 20), frame.Bounds())
+	assert.EqualValues(t, color.RGBA{255, 0, 0, 255}, frame.At(10, 10))
+	assert.EqualValues(t, color.RGBA{255, 0, 0, 255}, frame.At(5, 5))
+	assert.EqualValues(t, color.RGBA{0, 0, 255, 255}, frame.At(15, 15), "pixels on the far edge belong to the neighbouring triangle")
+	assert.EqualValues(t, color.RGBA{0, 0, 255, 255}, frame.At(2, 2))
+}
+
+func TestDepthAndCulling(t *testing.T) {
+	sr := perspectiveRenderer(-5)
+	sr.UseRendererParams(unlitParams())
+	quad(-1, color.NRGBA{0, 255, 0, 255}).Draw(sr, mgl32.Ident4())
+	quad(1, color.NRGBA{255, 0, 0, 255}).Draw(sr, mgl32.Ident4())
+	assert.EqualValues(t, color.NRGBA{0, 255, 0, 255}, sr.ColorAt(16, 16), "the nearer quad should pass the depth test")
+
+	params := unlitParams()
+	params.DepthTest = false
+	sr.UseRendererParams(params)
+	quad(1, color.NRGBA{255, 0, 0, 255}).Draw(sr, mgl32.Ident4())
+	assert.EqualValues(t, color.NRGBA{255, 0, 0, 255}, sr.ColorAt(16, 16))
+
+	sr = perspectiveRenderer(5)
+	sr.UseRendererParams(unlitParams())
+	quad(0, color.NRGBA{255, 0, 0, 255}).Draw(sr, mgl32.Ident4())
+	assert.EqualValues(t, color.NRGBA{0, 0, 0, 255}, sr.ColorAt(16, 16), "back faces should be culled")
+	params = unlitParams()
+	params.CullBackface = false
+	sr.UseRendererParams(params)
+	quad(0, color.NRGBA{255, 0, 0, 255}).Draw(sr, mgl32.Ident4())
+	assert.EqualValues(t, color.NRGBA{255, 0, 0, 255}, sr.ColorAt(16, 16))
+
+	sr = perspectiveRenderer(-5)
+	sr.UseRendererParams(unlitParams())
+	quad(0, color.NRGBA{255, 0, 0, 255}).Draw(sr, mgl32.Translate3D(0, 0, -10))
+	assert.EqualValues(t, color.NRGBA{0, 0, 0, 255}, sr.ColorAt(16, 16), "geometry behind the camera should be clipped")
+}
+
+func TestTransparency(t *testing.T) {
+	sr := perspectiveRenderer(-5)
+	sr.BackGroundColor(0.5, 0, 0, 1)
+	sr.Clear()
+	params := unlitParams()
+	sr.UseRendererParams(params)
+	quad(0, color.RGBA{0, 0, 255, 128}).Draw(sr, mgl32.Ident4())
+	c := sr.ColorAt(16, 16)
+	assert.InDelta(t, 64, c.R, 1)
+	assert.InDelta(t, 128, c.B, 1)
+
+	params.Transparency = renderer.EMISSIVE
+	sr.UseRendererParams(params)
+	sr.Clear()
+	quad(0, color.RGBA{0, 0, 255, 128}).Draw(sr, mgl32.Ident4())
+	c = sr.ColorAt(16, 16)
+	assert.InDelta(t, 128, c.R, 1, "emissive blending adds to the background")
+	assert.InDelta(t, 128, c.B, 1)
+}
+
+func TestTextures(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{0, 0, 255, 255})
+	material := renderer.NewMaterial(renderer.NewTexture("diffuseMap", img, false))
+
+	sr := NewSoftwareRenderer(20, 20)
+	sr.Camera().Ortho = true
+	sr.UseRendererParams(unlitParams())
+	sr.UseMaterial(material)
+	renderer.CreateBoxWithOffset(20, 20, 0, 0).Draw(sr, mgl32.Ident4())
+	assert.EqualValues(t, color.NRGBA{255, 0, 0, 255}, sr.ColorAt(2, 10))
+	assert.EqualValues(t, color.NRGBA{0, 0, 255, 255}, sr.ColorAt(17, 10))
+	assert.True(t, material.Textures[0].Loaded)
+
+	sr.DestroyMaterial(material)
+	assert.False(t, material.Textures[0].Loaded)
+	assert.Empty(t, sr.textures)
+}
+
+func TestLighting(t *testing.T) {
+	sr := perspectiveRenderer(-5)
+	white := color.NRGBA{255, 255, 255, 255}
+	ambient := renderer.NewLight(renderer.AMBIENT)
+	ambient.Color = [3]float32{0.2, 0.2, 0.2}
+	sr.AddLight(ambient)
+	quad(0, white).Draw(sr, mgl32.Ident4())
+	assert.InDelta(t, 51, sr.ColorAt(16, 16).R, 1, "ambient light only")
+
+	directional := renderer.NewLight(renderer.DIRECTIONAL)
+	directional.Color = [3]float32{0.5, 0.5, 0.5}
+	directional.Direction = mgl32.Vec3{0, 0, 1}
+	sr.AddLight(directional)
+	quad(0, white).Draw(sr, mgl32.Ident4())
+	assert.InDelta(t, 178, sr.ColorAt(16, 16).R, 1, "ambient and directional light")
+
+	sr.RemoveLight(directional)
+	sr.RemoveLight(ambient)
+	point := renderer.NewLight(renderer.POINT)
+	point.Position = mgl32.Vec3{0, 0, -1}
+	sr.AddLight(point)
+	quad(0, white).Draw(sr, mgl32.Ident4())
+	center, edge := sr.ColorAt(16, 16).R, sr.ColorAt(12, 16).R
+	assert.InDelta(t, 255, center, 5)
+	assert.True(t, edge < center, "point lights should fall off with distance")
+
+	sr.RemoveLight(point)
+	spot := renderer.NewLight(renderer.SPOT)
+	spot.Position = mgl32.Vec3{0, 0, -1}
+	spot.Direction = mgl32.Vec3{0, 0, 1}
+	spot.InnerAngle, spot.OuterAngle = 5, 10
+	sr.AddLight(spot)
+	quad(0, white).Draw(sr, mgl32.Ident4())
+	assert.InDelta(t, 255, sr.ColorAt(16, 16).R, 5, "inside the spot light cone")
+	assert.EqualValues(t, 0, sr.ColorAt(8, 16).R, "outside the spot light cone")
+}
+
+func TestEngineRender(t *testing.T) {
+	sr := NewSoftwareRenderer(32, 32)
+	sr.BackGroundColor(0, 0, 0, 1)
+	eng := engine.NewEngine(sr)
+	frames := 0
+	eng.Start(func() {
+		eng.Camera().SetTranslation(mgl32.Vec3{0, 0, -5})
+		eng.Camera().Lookat = mgl32.Vec3{0, 0, 0}
+		node := renderer.NewNode()
+		node.RendererParams = renderer.NewRendererParams()
+		node.RendererParams.Unlit = true
+		node.Add(quad(0, color.NRGBA{0, 255, 0, 255}))
+		eng.AddSpatial(node)
+		eng.AddUpdatable(engine.UpdatableFunc(func(dt float64) {
+			if frames++; frames == 2 {
+				sr.Stop()
+			}
+		}))
+	})
+	assert.EqualValues(t, 2, frames)
+	assert.EqualValues(t, color.RGBA{0, 255, 0, 255}, sr.Image().At(16, 16))
+}
+
+func TestInstancedDraw(t *testing.T) {
+	sr := perspectiveRenderer(-5)
+	sr.UseRendererParams(unlitParams())
+	instanced := renderer.NewInstancedGeometry(quad(0, color.NRGBA{255, 255, 255, 255}))
+	instanced.AddInstance(mgl32.Translate3D(1.5, 0, 0).Mul4(mgl32.Scale3D(0.5, 0.5, 0.5)), mgl32.Vec4{0, 1, 0, 1})
+	instanced.AddInstance(mgl32.Translate3D(-1.5, 0, 0).Mul4(mgl32.Scale3D(0.5, 0.5, 0.5)), mgl32.Vec4{1, 0, 0, 1})
+	instanced.AddInstance(mgl32.Translate3D(100, 0, 0), mgl32.Vec4{0, 0, 1, 1})
+	instanced.Draw(sr, mgl32.Ident4())
+
+	assert.Len(t, instanced.VisibleInstances(), 2)
+	assert.EqualValues(t, color.NRGBA{0, 255, 0, 255}, sr.ColorAt(5, 16), "+x is on the left looking down +z")
+	assert.EqualValues(t, color.NRGBA{255, 0, 0, 255}, sr.ColorAt(27, 16))
+	assert.EqualValues(t, color.NRGBA{0, 0, 0, 255}, sr.ColorAt(16, 16))
+}
@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"strings"
@@ -33,6 +34,15 @@ func LoadFontFromFile(fontfile string) (*truetype.Font, error) {
 	return LoadFont(fontBytes)
 }
 
+func LoadFontFromFS(fsys fs.FS, fontfile string) (*truetype.Font, error) {
+	fontBytes, err := fs.ReadFile(fsys, fontfile)
+	if err != nil {
+		log.Printf("Error Reading from font file: %v\n", err)
+		return nil, err
+	}
+	return LoadFont(fontBytes)
+}
+
 func LoadFont(fontData []byte) (*truetype.Font, error) {
 	f, err := truetype.Parse(fontData)
 	if err != nil {
@@ -6,11 +6,20 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/go-gl/mathgl/mgl64"
 )
 
+// maxSliceLength - the largest slice that DeserializeArgs will allocate
+const maxSliceLength = 1 << 24
+
+// MaxStringLength - the longest string, in bytes, that Stringbytes can encode
+const MaxStringLength = 255
+
+// SerializeArgs - serialize the args in order.
+// Structs (exported fields in order), slices (uint32 length then elements) and arrays are serialized by their contents.
 func SerializeArgs(args ...interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	var err error
@@ -48,6 +57,8 @@ func SerializeArgs(args ...interface{}) ([]byte, error) {
 			err = Vector2bytes64(buf, v)
 		case mgl64.Vec3:
 			err = Vector3bytes64(buf, v)
+		case mgl32.Quat:
+			err = Quatbytes(buf, v)
 		case bool:
 			err = BoolBytes(buf, v)
 		case []byte:
@@ -56,24 +67,196 @@ func SerializeArgs(args ...interface{}) ([]byte, error) {
 				_, err = buf.Write(v)
 			}
 		default:
-			fmt.Printf("Unknown typed used in SerializeArgs: %T \n", v)
+			err = serializeValue(buf, reflect.ValueOf(v))
+		}
+		if err != nil {
+			return buf.Bytes(), err
 		}
 	}
 	return buf.Bytes(), err
 }
 
+// DeserializeArgs - read values serialized with SerializeArgs into the given pointers
+func DeserializeArgs(r io.Reader, args ...interface{}) error {
+	for _, arg := range args {
+		v := reflect.ValueOf(arg)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return fmt.Errorf("DeserializeArgs requires a non nil pointer: %T", arg)
+		}
+		if err := deserializeValue(r, v.Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serializeValue(w io.Writer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		return Stringbytes(w, v.String())
+	case reflect.Bool:
+		return BoolBytes(w, v.Bool())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return uintBytes(w, intSize(v.Kind()), uint64(v.Int()))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return uintBytes(w, intSize(v.Kind()), v.Uint())
+	case reflect.Float32:
+		return Float32bytes(w, float32(v.Float()))
+	case reflect.Float64:
+		return Float64bytes(w, v.Float())
+	case reflect.Slice:
+		if err := UInt32Bytes(w, uint32(v.Len())); err != nil {
+			return err
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			_, err := w.Write(v.Bytes())
+			return err
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := serializeValue(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := serializeValue(w, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown type used in SerializeArgs: %v", v.Type())
+}
+
+func deserializeValue(r io.Reader, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		str, err := Stringfrombytes(r)
+		v.SetString(str)
+		return err
+	case reflect.Bool:
+		b, err := BoolFromBytes(r)
+		v.SetBool(b)
+		return err
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		i, err := uintFromBytes(r, intSize(v.Kind()))
+		v.SetInt(signExtend(i, intSize(v.Kind())))
+		return err
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		i, err := uintFromBytes(r, intSize(v.Kind()))
+		v.SetUint(i)
+		return err
+	case reflect.Float32:
+		f, err := Float32frombytes(r)
+		v.SetFloat(float64(f))
+		return err
+	case reflect.Float64:
+		f, err := Float64frombytes(r)
+		v.SetFloat(f)
+		return err
+	case reflect.Slice:
+		length, err := UInt32frombytes(r)
+		if err != nil {
+			return err
+		}
+		if length > maxSliceLength {
+			return fmt.Errorf("Slice length %v exceeds the maximum", length)
+		}
+		v.Set(reflect.MakeSlice(v.Type(), int(length), int(length)))
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			_, err := io.ReadFull(r, v.Bytes())
+			return err
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := deserializeValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := deserializeValue(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown type used in DeserializeArgs: %v", v.Type())
+}
+
+// intSize - the number of bytes used to serialize an integer kind, int and uint are serialized as 32 bits
+func intSize(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int64, reflect.Uint64:
+		return 8
+	}
+	return 4
+}
+
+func uintBytes(w io.Writer, size int, i uint64) error {
+	switch size {
+	case 1:
+		return UInt8Bytes(w, uint8(i))
+	case 2:
+		return UInt16Bytes(w, uint16(i))
+	case 8:
+		return UInt64Bytes(w, i)
+	}
+	return UInt32Bytes(w, uint32(i))
+}
+
+func uintFromBytes(r io.Reader, size int) (uint64, error) {
+	switch size {
+	case 1:
+		i, err := UInt8frombytes(r)
+		return uint64(i), err
+	case 2:
+		i, err := UInt16frombytes(r)
+		return uint64(i), err
+	case 8:
+		return UInt64frombytes(r)
+	}
+	i, err := UInt32frombytes(r)
+	return uint64(i), err
+}
+
+// signExtend - convert an unsigned integer of the given size in bytes back to a signed integer
+func signExtend(i uint64, size int) int64 {
+	shift := uint(64 - 8*size)
+	return int64(i<<shift) >> shift
+}
+
 func Stringfrombytes(r io.Reader) (string, error) {
 	var strLenData [1]byte
-	if _, err := r.Read(strLenData[:]); err != nil {
+	if _, err := io.ReadFull(r, strLenData[:]); err != nil {
 		return "", err
 	}
 	strLen := int(strLenData[0])
 	strData := make([]byte, strLen)
-	_, err := r.Read(strData)
+	_, err := io.ReadFull(r, strData)
 	return string(strData), err
 }
 
+// Stringbytes - write a string with a single byte length, strings longer than MaxStringLength are an error
 func Stringbytes(w io.Writer, str string) error {
+	if len(str) > MaxStringLength {
+		return fmt.Errorf("String length %v exceeds the maximum of %v", len(str), MaxStringLength)
+	}
 	w.Write([]byte{byte(len(str))})
 	_, err := io.WriteString(w, str)
 	return err
@@ -81,7 +264,7 @@ func Stringbytes(w io.Writer, str string) error {
 
 func Float64frombytes(r io.Reader) (float64, error) {
 	var bytes [8]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	bits := binary.LittleEndian.Uint64(bytes[:])
 	float := math.Float64frombits(bits)
 	return float, err
@@ -97,7 +280,7 @@ func Float64bytes(w io.Writer, float float64) error {
 
 func Float32frombytes(r io.Reader) (float32, error) {
 	var bytes [4]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	bits := binary.LittleEndian.Uint32(bytes[:])
 	float := math.Float32frombits(bits)
 	return float, err
@@ -113,7 +296,7 @@ func Float32bytes(w io.Writer, float float32) error {
 
 func UInt8frombytes(r io.Reader) (uint8, error) {
 	var bytes [1]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	return uint8(bytes[0]), err
 }
 
@@ -124,7 +307,7 @@ func UInt8Bytes(w io.Writer, i uint8) error {
 
 func UInt16frombytes(r io.Reader) (uint16, error) {
 	var bytes [2]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	return binary.LittleEndian.Uint16(bytes[:]), err
 }
 
@@ -137,7 +320,7 @@ func UInt16Bytes(w io.Writer, i uint16) error {
 
 func UInt32frombytes(r io.Reader) (uint32, error) {
 	var bytes [4]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	return binary.LittleEndian.Uint32(bytes[:]), err
 }
 
@@ -150,7 +333,7 @@ func UInt32Bytes(w io.Writer, i uint32) error {
 
 func UInt64frombytes(r io.Reader) (uint64, error) {
 	var bytes [8]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	return binary.LittleEndian.Uint64(bytes[:]), err
 }
 
@@ -239,9 +422,25 @@ func Vector3bytes64(w io.Writer, vector mgl64.Vec3) error {
 	return Float64bytes(w, vector.Z())
 }
 
+func Quatfrombytes(r io.Reader) (mgl32.Quat, error) {
+	w, err := Float32frombytes(r)
+	if err != nil {
+		return mgl32.Quat{}, err
+	}
+	v, err := Vector3frombytes(r)
+	return mgl32.Quat{W: w, V: v}, err
+}
+
+func Quatbytes(w io.Writer, quat mgl32.Quat) error {
+	if err := Float32bytes(w, quat.W); err != nil {
+		return err
+	}
+	return Vector3bytes(w, quat.V)
+}
+
 func BoolFromBytes(r io.Reader) (bool, error) {
 	var bytes [1]byte
-	_, err := r.Read(bytes[:])
+	_, err := io.ReadFull(r, bytes[:])
 	return bytes[0] == 1, err
 }
 
@@ -1,10 +1,12 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"bytes"
 
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,3 +27,61 @@ func TestAbc(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 2, result)
 }
+
+func TestLongString(t *testing.T) {
+	data, err := SerializeArgs(strings.Repeat("a", MaxStringLength))
+	assert.NoError(t, err)
+	result, err := Stringfrombytes(bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	assert.EqualValues(t, MaxStringLength, len(result))
+
+	buf := new(bytes.Buffer)
+	assert.Error(t, Stringbytes(buf, strings.Repeat("a", MaxStringLength+1)))
+	assert.EqualValues(t, 0, buf.Len(), "nothing should be written for a string that is too long")
+	_, err = SerializeArgs(1, strings.Repeat("a", 300))
+	assert.Error(t, err)
+}
+
+func TestQuat(t *testing.T) {
+	q := mgl32.QuatRotate(1.2, mgl32.Vec3{0, 1, 0})
+	data, err := SerializeArgs(q)
+	assert.NoError(t, err)
+	result, err := Quatfrombytes(bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	assert.EqualValues(t, q, result)
+}
+
+type serializeTestStruct struct {
+	Name     string
+	Position mgl32.Vec3
+	Tags     []string
+	Scores   []int16
+	Data     []byte
+	hidden   int
+}
+
+func TestSerializeStructsAndSlices(t *testing.T) {
+	in := serializeTestStruct{
+		Name:     "player",
+		Position: mgl32.Vec3{1, 2, 3},
+		Tags:     []string{"a", "b"},
+		Scores:   []int16{-5, 300},
+		Data:     []byte{1, 2, 3},
+		hidden:   7,
+	}
+	data, err := SerializeArgs(in, -42, []serializeTestStruct{{Name: "x"}})
+	assert.NoError(t, err)
+
+	var out serializeTestStruct
+	var i int
+	var list []serializeTestStruct
+	assert.NoError(t, DeserializeArgs(bytes.NewBuffer(data), &out, &i, &list))
+	in.hidden = 0
+	assert.EqualValues(t, in, out)
+	assert.EqualValues(t, -42, i)
+	assert.EqualValues(t, "x", list[0].Name)
+
+	assert.Error(t, DeserializeArgs(bytes.NewBuffer(data[:5]), &out), "truncated data")
+	_, err = SerializeArgs(map[string]int{})
+	assert.Error(t, err, "maps are not supported")
+}